@@ -1,11 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"market_system/config"
 	"market_system/controller"
+	"market_system/storage"
+	"market_system/storage/audit"
+	"market_system/storage/cache"
+	"market_system/storage/jsonfile"
+	"market_system/storage/memory"
 	"market_system/storage/postgres"
 )
 
@@ -13,22 +20,94 @@ func main() {
 
 	var cfg = config.Load()
 
-	pgStorage, err := postgres.NewConnectionPostgres(&cfg)
-	if err != nil {
-		panic(err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(&cfg, os.Args[2:]); err != nil {
+			log.Fatal(config.Error, err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		if err := runPurge(&cfg, os.Args[2:]); err != nil {
+			log.Fatal(config.Error, err)
+		}
+		return
+	}
+
+	if err := runServer(&cfg); err != nil {
+		log.Fatal(config.Error, err)
 	}
+}
+
+func routes(handler *controller.Handler) *http.ServeMux {
+
+	mux := http.NewServeMux()
 
-	handler := controller.NewController(&cfg, pgStorage)
+	mux.HandleFunc("/client", handler.Client)
+	mux.HandleFunc("/order_products", handler.OrderProduct)
+	mux.HandleFunc("/order", handler.Order)
+	mux.HandleFunc("/checkout", handler.Checkout)
+	mux.HandleFunc("/category", handler.Category)
+	mux.HandleFunc("/product", handler.Product)
+	mux.HandleFunc("/branch", handler.Branch)
+	mux.HandleFunc("/stock", handler.Stock)
+	mux.HandleFunc("/promotion", handler.Promotion)
+	mux.HandleFunc("/tax_rate", handler.TaxRate)
+	mux.HandleFunc("/audit", handler.Audit)
+	mux.HandleFunc("/cache/stats", handler.CacheStats)
 
-	http.HandleFunc("/client", handler.Client)
-	http.HandleFunc("/order_products", handler.OrderProduct)
-	http.HandleFunc("/order", handler.Order)
-	http.HandleFunc("/category", handler.Category)
-	http.HandleFunc("/product", handler.Product)
-	http.HandleFunc("/branch", handler.Branch)
+	mux.HandleFunc("/client/restore", handler.RestoreClient)
+	mux.HandleFunc("/order_products/restore", handler.RestoreOrderProduct)
+	mux.HandleFunc("/order/restore", handler.RestoreOrder)
+	mux.HandleFunc("/order/history", handler.OrderHistory)
+	mux.HandleFunc("/category/restore", handler.RestoreCategory)
+	mux.HandleFunc("/product/restore", handler.RestoreProduct)
+	mux.HandleFunc("/branch/restore", handler.RestoreBranch)
+	mux.HandleFunc("/branch/delivery_tariff", handler.DeliveryTariff)
+	mux.HandleFunc("/branch/nearest", handler.NearestBranch)
+	mux.HandleFunc("/stock/adjust", handler.AdjustStock)
+	mux.HandleFunc("/stock/movements", handler.StockMovements)
+	mux.HandleFunc("/stock/reconciliation", handler.StockReconciliation)
+	mux.HandleFunc("/stock/transfer", handler.StockTransfer)
+	mux.HandleFunc("/tax_rate/report", handler.TaxReport)
 
-	log.Println("Listening:", cfg.ServiceHost+cfg.ServiceHTTPPort, "...")
-	if err := http.ListenAndServe(cfg.ServiceHost+cfg.ServiceHTTPPort, nil); err != nil {
-		panic("Listent and service panic:" + err.Error())
+	mux.HandleFunc("/healthz", handler.Healthz)
+	mux.HandleFunc("/readyz", handler.Readyz)
+
+	return mux
+}
+
+// newStorage opens the backend named by cfg.StorageDriver. Every write
+// through it is recorded in the audit log, and catalog reads are cached
+// unless cfg.CacheSize is 0.
+func newStorage(cfg *config.Config) (storage.StorageI, error) {
+
+	var (
+		strg storage.StorageI
+		err  error
+	)
+
+	switch cfg.StorageDriver {
+	case "memory":
+		log.Println(config.Info, "using in-memory storage, data is lost on restart")
+		strg = memory.NewConnectionMemory()
+	case "jsonfile":
+		log.Println(config.Info, "using json files in", cfg.DataDir)
+		strg, err = jsonfile.Open(cfg.DataDir)
+	case "postgres":
+		strg, err = postgres.NewConnectionPostgres(cfg)
+	default:
+		err = fmt.Errorf("unknown STORAGE_DRIVER %q", cfg.StorageDriver)
 	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	strg = audit.New(strg)
+	if cfg.CacheSize > 0 {
+		strg = cache.New(strg, cfg.CacheSize, cfg.CacheTTL)
+	}
+
+	return strg, nil
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"market_system/config"
+	"market_system/storage/postgres"
+	"market_system/storage/postgres/migrations"
+)
+
+const migrateUsage = "usage: migrate up | down [n] | status"
+
+// runMigrate implements "migrate up|down [n]|status" for the postgres schema.
+func runMigrate(cfg *config.Config, args []string) error {
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := postgres.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Println(config.Info, "applied", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		log.Println(config.Info, "schema is at version", migrator.Latest())
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, n)
+		for _, migration := range reverted {
+			log.Println(config.Info, "reverted", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt
+			}
+
+			fmt.Printf("%06d %-30s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"market_system/config"
+	"market_system/storage"
+)
+
+const purgeUsage = "usage: purge [retention], e.g. purge 720h"
+
+// runPurge removes rows that were soft deleted longer than the retention
+// period ago, cfg.PurgeRetention unless given as an argument. Children are
+// purged before their parents, so an order and its lines go in one run.
+func runPurge(cfg *config.Config, args []string) error {
+
+	retention := cfg.PurgeRetention
+	if len(args) > 1 {
+		return errors.New(purgeUsage)
+	}
+
+	if len(args) == 1 {
+		var err error
+		retention, err = time.ParseDuration(args[0])
+		if err != nil || retention < 0 {
+			return fmt.Errorf("invalid retention %q, %s", args[0], purgeUsage)
+		}
+	}
+
+	strg, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer strg.Close()
+
+	var (
+		ctx    = context.Background()
+		before = time.Now().Add(-retention)
+	)
+
+	return strg.Tx(ctx, func(tx storage.StorageI) error {
+		purges := []struct {
+			table string
+			purge func(ctx context.Context, deletedBefore time.Time) (int64, error)
+		}{
+			{"order_products", tx.OrderProduct().Purge},
+			{"order", tx.Order().Purge},
+			{"product", tx.Product().Purge},
+			{"category", tx.Category().Purge},
+			{"client", tx.Client().Purge},
+			{"branches", tx.Branch().Purge},
+		}
+
+		for _, p := range purges {
+			n, err := p.purge(ctx, before)
+			if err != nil {
+				return fmt.Errorf("purge %s: %w", p.table, err)
+			}
+
+			log.Println(config.Info, "purged", n, "rows from", p.table, "deleted before", before.Format(time.RFC3339))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"market_system/config"
+	"market_system/controller"
+)
+
+// runServer serves HTTP until SIGINT or SIGTERM, then stops accepting
+// connections, waits up to cfg.ShutdownTimeout for in-flight requests and
+// closes the storage last.
+func runServer(cfg *config.Config) error {
+
+	if err := cfg.OrderNumber.Validate(); err != nil {
+		return fmt.Errorf("order number: %w", err)
+	}
+
+	if err := cfg.ProductNumber.Validate(); err != nil {
+		return fmt.Errorf("product number: %w", err)
+	}
+
+	strg, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := strg.Close(); err != nil {
+			log.Println(config.Error, "close storage:", err)
+		}
+	}()
+
+	server := &http.Server{
+		Addr:         cfg.ServiceHost + cfg.ServiceHTTPPort,
+		Handler:      routes(controller.NewController(cfg, strg)),
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Println("Listening:", server.Addr, "...")
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the process without waiting for the drain.
+	stop()
+	log.Println(config.Info, "shutting down, draining in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	log.Println(config.Info, "server stopped")
+	return nil
+}
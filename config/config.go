@@ -3,6 +3,10 @@ package config
 import (
 	"log"
 	"os"
+	"time"
+
+	"market_system/pkg/delivery"
+	"market_system/pkg/sequence"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cast"
@@ -21,8 +25,49 @@ type Config struct {
 	PostgresPassword string
 	PostgresPort     string
 
+	// Connection pool limits of the postgres backend.
+	PostgresMaxOpenConns    int
+	PostgresMaxIdleConns    int
+	PostgresConnMaxLifetime time.Duration
+	PostgresConnMaxIdleTime time.Duration
+
+	// DBTimeout bounds the storage work of a single HTTP request.
+	DBTimeout time.Duration
+
 	ServiceHost     string
 	ServiceHTTPPort string
+
+	// HTTPReadTimeout, HTTPWriteTimeout and HTTPIdleTimeout bound reading a
+	// request, writing its response and keeping an idle connection open.
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// after SIGTERM.
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	ShutdownTimeout  time.Duration
+
+	StorageDriver string
+
+	// DataDir is the directory of the jsonfile storage driver.
+	DataDir string
+
+	// PurgeRetention is how long soft deleted rows are kept before the
+	// purge command removes them.
+	PurgeRetention time.Duration
+
+	// CacheSize is how many category and product reads are cached, 0
+	// turns the cache off. CacheTTL is how long one stays cached.
+	CacheSize int
+	CacheTTL  time.Duration
+
+	// OrderNumber and ProductNumber format the human readable order_id and
+	// product_id, such as O-000123.
+	OrderNumber   sequence.Format
+	ProductNumber sequence.Format
+
+	// DeliverySpeed estimates delivery times: how long an order takes to
+	// get ready and how fast couriers go.
+	DeliverySpeed delivery.Speed
 }
 
 func Load() Config {
@@ -36,12 +81,49 @@ func Load() Config {
 	cfg.ServiceHost = cast.ToString(getValueOrDefault("SERVICE_HOST", "localhost"))
 	cfg.ServiceHTTPPort = cast.ToString(getValueOrDefault("SERVICE_HTTP_PORT", ":8080"))
 
+	cfg.HTTPReadTimeout = cast.ToDuration(getValueOrDefault("HTTP_READ_TIMEOUT", "10s"))
+	cfg.HTTPWriteTimeout = cast.ToDuration(getValueOrDefault("HTTP_WRITE_TIMEOUT", "15s"))
+	cfg.HTTPIdleTimeout = cast.ToDuration(getValueOrDefault("HTTP_IDLE_TIMEOUT", "60s"))
+	cfg.ShutdownTimeout = cast.ToDuration(getValueOrDefault("SHUTDOWN_TIMEOUT", "30s"))
+
+	cfg.StorageDriver = cast.ToString(getValueOrDefault("STORAGE_DRIVER", "postgres"))
+	cfg.DataDir = cast.ToString(getValueOrDefault("DATA_DIR", "data"))
+
 	cfg.PostgresHost = cast.ToString(getValueOrDefault("POSTGRES_HOST", "localhost"))
 	cfg.PostgresUser = cast.ToString(getValueOrDefault("POSTGRES_USER", "javohir"))
 	cfg.PostgresDatabase = cast.ToString(getValueOrDefault("POSTGRES_DATABASE", "test"))
 	cfg.PostgresPassword = cast.ToString(getValueOrDefault("POSTGRES_PASSWORD", "12345"))
 	cfg.PostgresPort = cast.ToString(getValueOrDefault("POSTGRES_PORT", "5432"))
 
+	cfg.PostgresMaxOpenConns = cast.ToInt(getValueOrDefault("POSTGRES_MAX_OPEN_CONNS", 25))
+	cfg.PostgresMaxIdleConns = cast.ToInt(getValueOrDefault("POSTGRES_MAX_IDLE_CONNS", 25))
+	cfg.PostgresConnMaxLifetime = cast.ToDuration(getValueOrDefault("POSTGRES_CONN_MAX_LIFETIME", "30m"))
+	cfg.PostgresConnMaxIdleTime = cast.ToDuration(getValueOrDefault("POSTGRES_CONN_MAX_IDLE_TIME", "5m"))
+
+	cfg.DBTimeout = cast.ToDuration(getValueOrDefault("DB_TIMEOUT", "5s"))
+	cfg.PurgeRetention = cast.ToDuration(getValueOrDefault("PURGE_RETENTION", "720h"))
+
+	cfg.CacheSize = cast.ToInt(getValueOrDefault("CACHE_SIZE", 1000))
+	cfg.CacheTTL = cast.ToDuration(getValueOrDefault("CACHE_TTL", "1m"))
+
+	cfg.OrderNumber = sequence.Format{
+		Prefix:    cast.ToString(getValueOrDefault("ORDER_NUMBER_PREFIX", "O-")),
+		Padding:   cast.ToInt(getValueOrDefault("ORDER_NUMBER_PADDING", 6)),
+		Date:      cast.ToString(getValueOrDefault("ORDER_NUMBER_DATE", "")),
+		PerBranch: cast.ToBool(getValueOrDefault("ORDER_NUMBER_PER_BRANCH", false)),
+	}
+
+	cfg.ProductNumber = sequence.Format{
+		Prefix:  cast.ToString(getValueOrDefault("PRODUCT_NUMBER_PREFIX", "P-")),
+		Padding: cast.ToInt(getValueOrDefault("PRODUCT_NUMBER_PADDING", 6)),
+		Date:    cast.ToString(getValueOrDefault("PRODUCT_NUMBER_DATE", "")),
+	}
+
+	cfg.DeliverySpeed = delivery.Speed{
+		Prep:      cast.ToDuration(getValueOrDefault("DELIVERY_PREP_TIME", "15m")),
+		KmPerHour: cast.ToFloat64(getValueOrDefault("DELIVERY_SPEED_KMH", 30)),
+	}
+
 	return cfg
 }
 
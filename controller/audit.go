@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"market_system/models"
+	"market_system/pkg/cursor"
+)
+
+// Audit lists the audit log, optionally of one entity type ("entity") and
+// one row ("id"), newest first.
+func (c *Handler) Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	offset, err := getIntegerOrDefaultValue(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query offset")
+		return
+	}
+
+	resp, err := c.storage.Audit().GetList(ctx, &models.GetListAuditRequest{
+		Limit:    limit,
+		Offset:   offset,
+		Cursor:   r.URL.Query().Get("cursor"),
+		Entity:   r.URL.Query().Get("entity"),
+		EntityId: r.URL.Query().Get("id"),
+	})
+	if errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
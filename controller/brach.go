@@ -3,8 +3,14 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
 	"market_system/pkg/helpers"
+	"market_system/pkg/schedule"
+	"market_system/storage"
 	"net/http"
 )
 
@@ -27,6 +33,9 @@ func (c *Handler) Branch(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateBranch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var createBranch models.CreateBranch
 	err := json.NewDecoder(r.Body).Decode(&createBranch)
 	if err != nil {
@@ -34,23 +43,32 @@ func (c *Handler) CreateBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Branch().Create(&createBranch)
+	resp, err := c.storage.Branch().Create(ctx, &createBranch)
+	if errors.Is(err, geo.ErrInvalid) || errors.Is(err, schedule.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetByIDBranch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
 
-	resp, err := c.storage.Branch().GetByID(&models.BranchPrimaryKey{ID: id})
+	resp, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusBadRequest, "no rows in result set")
 		return
@@ -61,10 +79,14 @@ func (c *Handler) GetByIDBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) UpdateBranch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var updateBranch models.UpdateBranch
 	err := json.NewDecoder(r.Body).Decode(&updateBranch)
 	if err != nil {
@@ -76,8 +98,28 @@ func (c *Handler) UpdateBranch(w http.ResponseWriter, r *http.Request) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
-	rowsAffected, err := c.storage.Branch().Update(&updateBranch)
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateBranch.Version = version
+	}
+
+	rowsAffected, err := c.storage.Branch().Update(ctx, &updateBranch)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
+		if errors.Is(err, geo.ErrInvalid) || errors.Is(err, schedule.ErrInvalid) {
+			handleResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -87,23 +129,27 @@ func (c *Handler) UpdateBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Branch().GetByID(&models.BranchPrimaryKey{ID: updateBranch.ID})
+	resp, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: updateBranch.ID})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteBranch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
 
-	err := c.storage.Branch().Delete(&models.BranchPrimaryKey{ID: id})
+	err := c.storage.Branch().Delete(ctx, &models.BranchPrimaryKey{ID: id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -112,7 +158,46 @@ func (c *Handler) DeleteBranch(w http.ResponseWriter, r *http.Request) {
 	handleResponse(w, http.StatusNoContent, nil)
 }
 
+// RestoreBranch brings back a soft deleted branch and returns it.
+func (c *Handler) RestoreBranch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.Branch().Restore(ctx, &models.BranchPrimaryKey{ID: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
+
 func (c *Handler) GetListBranch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
@@ -126,17 +211,37 @@ func (c *Handler) GetListBranch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query search")
 		return
 	}
 
-	resp, err := c.storage.Branch().GetList(&models.GetListBranchRequest{
-		Limit:  limit,
-		Offset: offset,
-		Search: search,
+	resp, err := c.storage.Branch().GetList(ctx, &models.GetListBranchRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		Search:         search,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
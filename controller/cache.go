@@ -0,0 +1,23 @@
+package controller
+
+import (
+	"net/http"
+
+	"market_system/storage/cache"
+)
+
+// CacheStats reports the hit and miss counters of the catalog cache.
+func (c *Handler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	strg, ok := c.storage.(*cache.Store)
+	if !ok {
+		handleResponse(w, http.StatusNotFound, "cache is disabled")
+		return
+	}
+
+	handleResponse(w, http.StatusOK, strg.Stats())
+}
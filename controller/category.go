@@ -3,10 +3,14 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
+	"market_system/storage"
 )
 
 func (c *Handler) Category(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +33,8 @@ func (c *Handler) Category(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var createCategory models.CreateCategory
 	err := json.NewDecoder(r.Body).Decode(&createCategory)
@@ -44,16 +50,26 @@ func (c *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp, err := c.storage.Category().Create(&createCategory)
+	if createCategory.TaxRateId != "" {
+		if !helpers.IsValidUUID(createCategory.TaxRateId) {
+			handleResponse(w, http.StatusBadRequest, "tax rate id is not uuid")
+			return
+		}
+	}
+
+	resp, err := c.storage.Category().Create(ctx, &createCategory)
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetByIDCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
@@ -61,7 +77,7 @@ func (c *Handler) GetByIDCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Category().GetByID(&models.CategoryPrimaryKey{Id: id})
+	resp, err := c.storage.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusBadRequest, "no rows in result set")
 		return
@@ -72,10 +88,13 @@ func (c *Handler) GetByIDCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) GetListCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
@@ -89,17 +108,37 @@ func (c *Handler) GetListCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query search")
 		return
 	}
 
-	resp, err := c.storage.Category().GetList(&models.GetListCategoryRequest{
-		Limit:  limit,
-		Offset: offset,
-		Search: search,
+	resp, err := c.storage.Category().GetList(ctx, &models.GetListCategoryRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		Search:         search,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -109,6 +148,8 @@ func (c *Handler) GetListCategory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var updateCategory models.UpdateCategory
 	err := json.NewDecoder(r.Body).Decode(&updateCategory)
@@ -129,8 +170,30 @@ func (c *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rowsAffected, err := c.storage.Category().Update(&updateCategory)
+	if updateCategory.TaxRateId != "" {
+		if !helpers.IsValidUUID(updateCategory.TaxRateId) {
+			handleResponse(w, http.StatusBadRequest, "tax rate id is not uuid")
+			return
+		}
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateCategory.Version = version
+	}
+
+	rowsAffected, err := c.storage.Category().Update(ctx, &updateCategory)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -140,16 +203,20 @@ func (c *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Category().GetByID(&models.CategoryPrimaryKey{Id: updateCategory.Id})
+	resp, err := c.storage.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: updateCategory.Id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 
 	if !helpers.IsValidUUID(id) {
@@ -157,7 +224,7 @@ func (c *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := c.storage.Category().Delete(&models.CategoryPrimaryKey{Id: id})
+	err := c.storage.Category().Delete(ctx, &models.CategoryPrimaryKey{Id: id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -165,3 +232,40 @@ func (c *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 
 	handleResponse(w, http.StatusNoContent, nil)
 }
+
+// RestoreCategory brings back a soft deleted category and returns it.
+func (c *Handler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.Category().Restore(ctx, &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
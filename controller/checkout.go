@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/geo"
+	"market_system/pkg/helpers"
+	"market_system/pkg/pricing"
+	"market_system/pkg/promo"
+	"market_system/storage"
+)
+
+// errInvalidCheckout wraps every checkout error caused by the request.
+var errInvalidCheckout = errors.New("invalid checkout")
+
+// Checkout creates an order and all of its lines in one transaction, so a
+// failing line or promo code leaves no half built order behind, and
+// returns the priced order with its lines.
+func (c *Handler) Checkout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var checkout models.Checkout
+	if err := json.NewDecoder(r.Body).Decode(&checkout); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateCheckout(&checkout); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	branch, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: checkout.BranchId})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusBadRequest, "branch not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var branchPrefix string
+	if c.cfg.OrderNumber.PerBranch {
+		branchPrefix = branch.OrderPrefix
+	}
+
+	orderNumber, err := c.nextNumber(ctx, c.cfg.OrderNumber, branchPrefix)
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var resp *models.OrderWithItems
+	err = c.storage.Tx(ctx, func(tx storage.StorageI) error {
+		resp, err = placeOrder(ctx, tx, orderNumber, &checkout)
+		return err
+	})
+	if errors.Is(err, errInvalidCheckout) || errors.Is(err, pricing.ErrInvalid) || errors.Is(err, promo.ErrNotApplicable) ||
+		errors.Is(err, geo.ErrInvalid) || errors.Is(err, delivery.ErrOutOfRange) || errors.Is(err, delivery.ErrNoLocation) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusCreated, resp)
+}
+
+func validateCheckout(checkout *models.Checkout) error {
+
+	if !helpers.IsValidUUID(checkout.ClientId) {
+		return fmt.Errorf("%w: client id is not uuid", errInvalidCheckout)
+	}
+
+	if !helpers.IsValidUUID(checkout.BranchId) {
+		return fmt.Errorf("%w: branch id is not uuid", errInvalidCheckout)
+	}
+
+	if len(checkout.Items) == 0 {
+		return fmt.Errorf("%w: no items", errInvalidCheckout)
+	}
+
+	for i, item := range checkout.Items {
+		if !helpers.IsValidUUID(item.ProductID) {
+			return fmt.Errorf("%w: item %d: product id is not uuid", errInvalidCheckout, i)
+		}
+	}
+
+	return nil
+}
+
+// placeOrder writes the order and its lines through tx. The lines are priced
+// by the order product repository, which also keeps the order totals.
+func placeOrder(ctx context.Context, tx storage.StorageI, orderNumber string, checkout *models.Checkout) (*models.OrderWithItems, error) {
+
+	_, err := tx.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: checkout.ClientId})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: client %s not found", errInvalidCheckout, checkout.ClientId)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range checkout.Items {
+		_, err := tx.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: item.ProductID})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: item %d: product %s not found", errInvalidCheckout, i, item.ProductID)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := tx.Order().Create(ctx, &models.CreateOrder{
+		OrderId:   orderNumber,
+		ClientId:  checkout.ClientId,
+		BranchId:  checkout.BranchId,
+		Address:   checkout.Address,
+		Latitude:  checkout.Latitude,
+		Longitude: checkout.Longitude,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*models.OrderProduct
+	for i, item := range checkout.Items {
+		orderProduct, err := tx.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:        order.Id,
+			ProductID:      item.ProductID,
+			DiscountType:   item.DiscountType,
+			DiscountAmount: item.DiscountAmount,
+			Quantity:       item.Quantity,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+
+		items = append(items, orderProduct)
+	}
+
+	// The lines changed the totals and the version of the order.
+	order, err = tx.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	// A promo code applies to the order as a whole, so it goes on once the
+	// lines are there.
+	if checkout.PromoCode != "" {
+		_, err = tx.Order().Update(ctx, &models.UpdateOrder{
+			Id:            order.Id,
+			ClientId:      order.ClientId,
+			OrderId:       order.OrderId,
+			BranchId:      order.BranchId,
+			Address:       order.Address,
+			Latitude:      order.Latitude,
+			Longitude:     order.Longitude,
+			DeliveryPrice: order.DeliveryPrice,
+			PromoCode:     &checkout.PromoCode,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		order, err = tx.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.OrderWithItems{Order: *order, Items: items}, nil
+}
@@ -3,10 +3,14 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
+	"market_system/storage"
 )
 
 func (c *Handler) Client(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +33,8 @@ func (c *Handler) Client(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var createClient models.CreateClient
 	err := json.NewDecoder(r.Body).Decode(&createClient)
@@ -37,16 +43,19 @@ func (c *Handler) CreateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Client().Create(&createClient)
+	resp, err := c.storage.Client().Create(ctx, &createClient)
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetByIDClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
@@ -54,7 +63,7 @@ func (c *Handler) GetByIDClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Client().GetByID(&models.ClientPrimaryKey{ID: id})
+	resp, err := c.storage.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusBadRequest, "no rows in result set")
 		return
@@ -65,10 +74,13 @@ func (c *Handler) GetByIDClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) GetListClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
@@ -82,17 +94,37 @@ func (c *Handler) GetListClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query search")
 		return
 	}
 
-	resp, err := c.storage.Client().GetList(&models.GetListClientRequest{
-		Limit:  limit,
-		Offset: offset,
-		Search: search,
+	resp, err := c.storage.Client().GetList(ctx, &models.GetListClientRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		Search:         search,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -102,6 +134,8 @@ func (c *Handler) GetListClient(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var updateClient models.UpdateClient
 	err := json.NewDecoder(r.Body).Decode(&updateClient)
@@ -115,8 +149,23 @@ func (c *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rowsAffected, err := c.storage.Client().Update(&updateClient)
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateClient.Version = version
+	}
+
+	rowsAffected, err := c.storage.Client().Update(ctx, &updateClient)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -126,16 +175,20 @@ func (c *Handler) UpdateClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Client().GetByID(&models.ClientPrimaryKey{ID: updateClient.ID})
+	resp, err := c.storage.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: updateClient.ID})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 
 	if !helpers.IsValidUUID(id) {
@@ -143,7 +196,7 @@ func (c *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := c.storage.Client().Delete(&models.ClientPrimaryKey{ID: id})
+	err := c.storage.Client().Delete(ctx, &models.ClientPrimaryKey{ID: id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -151,3 +204,40 @@ func (c *Handler) DeleteClient(w http.ResponseWriter, r *http.Request) {
 
 	handleResponse(w, http.StatusNoContent, nil)
 }
+
+// RestoreClient brings back a soft deleted client and returns it.
+func (c *Handler) RestoreClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.Client().Restore(ctx, &models.ClientPrimaryKey{ID: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
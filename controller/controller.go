@@ -1,13 +1,19 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"market_system/config"
+	"market_system/pkg/filter"
+	"market_system/pkg/sequence"
 	"market_system/storage"
+	"market_system/storage/audit"
 )
 
 type Handler struct {
@@ -26,6 +32,44 @@ func NewController(cfg *config.Config, strg storage.StorageI) *Handler {
 	return &Handler{cfg: cfg, storage: strg}
 }
 
+// actorHeader names who makes a request. Writes are audited under it.
+const actorHeader = "X-Actor"
+
+// anonymousActor is audited for requests without an actorHeader.
+const anonymousActor = "anonymous"
+
+// requestContext bounds the storage calls of one request by cfg.DBTimeout
+// and cancels them when the client disconnects. It carries the actor of
+// the request for the audit log.
+func (c *Handler) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+
+	actor := strings.TrimSpace(r.Header.Get(actorHeader))
+	if actor == "" {
+		actor = anonymousActor
+	}
+
+	ctx := audit.WithActor(r.Context(), actor)
+	if c.cfg.DBTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, c.cfg.DBTimeout)
+}
+
+// nextNumber takes the next order or product number of format from the
+// storage sequence.
+func (c *Handler) nextNumber(ctx context.Context, format sequence.Format, branchPrefix string) (string, error) {
+
+	key := format.Key(branchPrefix, time.Now())
+
+	n, err := c.storage.Sequence().Next(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	return format.Number(key, n), nil
+}
+
 func getIntegerOrDefaultValue(value string, defaultValue int64) (int64, error) {
 
 	if len(value) <= 0 {
@@ -36,6 +80,66 @@ func getIntegerOrDefaultValue(value string, defaultValue int64) (int64, error) {
 	return int64(number), err
 }
 
+func getBoolOrDefaultValue(value string, defaultValue bool) (bool, error) {
+
+	if len(value) <= 0 {
+		return defaultValue, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// getTimeOrZero parses an RFC 3339 timestamp or a date, which is taken as
+// its midnight in UTC. An empty value gives the zero time.
+func getTimeOrZero(value string) (time.Time, error) {
+
+	if len(value) <= 0 {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// getFilter decodes the optional "filter" query parameter, a JSON encoded
+// filter.Expr such as {"field":"price","op":"between","value":[1000,5000]},
+// and rejects values that are objects or nested lists.
+func getFilter(value string) (filter.Expr, error) {
+
+	var expr filter.Expr
+	if len(value) <= 0 {
+		return expr, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &expr); err != nil {
+		return expr, err
+	}
+
+	return expr, expr.Validate()
+}
+
+// setETag sends the row version as a strong ETag. Clients echo it back in
+// If-Match to make sure they update what they have read.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatch reads the version from the If-Match header. A missing header or
+// "*" gives 0, which updates without a version check.
+func ifMatch(r *http.Request) (int64, error) {
+
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	return strconv.ParseInt(value, 10, 64)
+}
+
 func handleResponse(w http.ResponseWriter, status int, data interface{}) {
 	var description string
 	switch code := status; {
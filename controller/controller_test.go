@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"market_system/config"
+	"market_system/models"
+	"market_system/pkg/sequence"
+	"market_system/storage"
+	"market_system/storage/memory"
+)
+
+// missingID names no row in any table.
+const missingID = "00000000-0000-4000-8000-000000000000"
+
+// testResponse is a Response with its data left undecoded.
+type testResponse struct {
+	Status      int             `json:"status"`
+	Description string          `json:"description"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// newTestHandler returns a handler over an empty memory store.
+func newTestHandler(t *testing.T) (*Handler, storage.StorageI) {
+	t.Helper()
+
+	var (
+		strg = memory.NewConnectionMemory()
+		cfg  = &config.Config{
+			DBTimeout:     time.Second,
+			OrderNumber:   sequence.Format{Prefix: "O-", Padding: 6},
+			ProductNumber: sequence.Format{Prefix: "P-", Padding: 6},
+		}
+	)
+
+	return NewController(cfg, strg), strg
+}
+
+// newRequest builds a request to target. A string body is sent as it is,
+// any other one but nil encoded as JSON.
+func newRequest(t *testing.T, method, target string, body interface{}) *http.Request {
+	t.Helper()
+
+	var reader io.Reader = http.NoBody
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	return httptest.NewRequest(method, target, reader)
+}
+
+// withHeader sets the header key of r to value.
+func withHeader(r *http.Request, key, value string) *http.Request {
+	r.Header.Set(key, value)
+	return r
+}
+
+// serve sends r to handler and checks the status of the response.
+func serve(t *testing.T, handler http.HandlerFunc, r *http.Request, status int) testResponse {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code != status {
+		t.Fatalf("%s %s: want status %d, got %d: %s", r.Method, r.URL, status, w.Code, w.Body.String())
+	}
+
+	var resp testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: decode response: %v", r.Method, r.URL, err)
+	}
+
+	if resp.Status != status {
+		t.Fatalf("%s %s: want status %d in the body, got %d", r.Method, r.URL, status, resp.Status)
+	}
+
+	return resp
+}
+
+// decodeData decodes the data of resp into v.
+func decodeData(t *testing.T, resp testResponse, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		t.Fatalf("decode data %s: %v", resp.Data, err)
+	}
+}
+
+func createTestClient(t *testing.T, strg storage.StorageI) *models.Client {
+	t.Helper()
+
+	client, err := strg.Client().Create(context.Background(), &models.CreateClient{
+		FirstName:   "Test",
+		LastName:    "Client",
+		Phone:       "+998901234567",
+		DateOfBirth: "2000-01-02",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func createTestBranch(t *testing.T, strg storage.StorageI) *models.Branch {
+	t.Helper()
+
+	branch, err := strg.Branch().Create(context.Background(), &models.CreateBranch{
+		Name:          "Chilonzor",
+		Phone:         "+998711234567",
+		WorkStartHour: "00:00",
+		WorkEndHour:   "00:00",
+		Address:       "Tashkent",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return branch
+}
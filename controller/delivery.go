@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/geo"
+	"market_system/pkg/helpers"
+	"market_system/storage"
+)
+
+// DeliveryTariff reads, sets and removes the delivery tariff of the branch
+// named by branch_id. Setting one replaces it as a whole.
+func (c *Handler) DeliveryTariff(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		c.GetDeliveryTariff(w, r)
+	case http.MethodPut:
+		c.SetDeliveryTariff(w, r)
+	case http.MethodDelete:
+		c.DeleteDeliveryTariff(w, r)
+	default:
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (c *Handler) GetDeliveryTariff(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var branchID = r.URL.Query().Get("branch_id")
+	if !helpers.IsValidUUID(branchID) {
+		handleResponse(w, http.StatusBadRequest, "branch id is not uuid")
+		return
+	}
+
+	resp, err := c.storage.Branch().GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branchID})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusNotFound, "delivery tariff not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (c *Handler) SetDeliveryTariff(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var tariff models.DeliveryTariff
+	if err := json.NewDecoder(r.Body).Decode(&tariff); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !helpers.IsValidUUID(tariff.BranchId) {
+		handleResponse(w, http.StatusBadRequest, "branch id is not uuid")
+		return
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		tariff.Version = version
+	}
+
+	resp, err := c.storage.Branch().SetDeliveryTariff(ctx, &tariff)
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusBadRequest, "branch not found")
+		return
+	}
+
+	if errors.Is(err, storage.ErrVersionConflict) {
+		handleResponse(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+
+	if errors.Is(err, delivery.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusAccepted, resp)
+}
+
+func (c *Handler) DeleteDeliveryTariff(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var branchID = r.URL.Query().Get("branch_id")
+	if !helpers.IsValidUUID(branchID) {
+		handleResponse(w, http.StatusBadRequest, "branch id is not uuid")
+		return
+	}
+
+	err := c.storage.Branch().DeleteDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branchID})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusNoContent, nil)
+}
+
+// NearestBranch lists the active branches closest to the client location
+// given by lat and lng, marked open or closed at the optional time at and
+// with the delivery fee and time of each, so a client app can pick the
+// branch that fulfills an order.
+func (c *Handler) NearestBranch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var values = r.URL.Query()
+
+	lat, err := strconv.ParseFloat(values.Get("lat"), 64)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query lat")
+		return
+	}
+
+	lng, err := strconv.ParseFloat(values.Get("lng"), 64)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query lng")
+		return
+	}
+
+	at, err := getTimeOrZero(values.Get("at"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query at")
+		return
+	}
+
+	limit, err := getIntegerOrDefaultValue(values.Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	resp, err := c.storage.Branch().Nearest(ctx, &models.NearestBranchRequest{
+		Latitude:  lat,
+		Longitude: lng,
+		At:        at,
+		Limit:     limit,
+		Speed:     c.cfg.DeliverySpeed,
+	})
+	if errors.Is(err, geo.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"net/http"
+)
+
+// Healthz reports that the process is up. It never touches the storage, so
+// a database outage does not get the process restarted.
+func (c *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	handleResponse(w, http.StatusOK, "ok")
+}
+
+// Readyz reports whether requests can be served: the storage answers a ping
+// and, for postgres, has no pending migration.
+func (c *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	if err := c.storage.Ping(ctx); err != nil {
+		handleResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, "ready")
+}
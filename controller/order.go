@@ -3,10 +3,19 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/delivery"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
 	"market_system/pkg/helpers"
+	"market_system/pkg/pricing"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
 )
 
 func (c *Handler) Order(w http.ResponseWriter, r *http.Request) {
@@ -30,6 +39,9 @@ func (c *Handler) Order(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var createOrder models.CreateOrder
 	err := json.NewDecoder(r.Body).Decode(&createOrder)
 	if err != nil {
@@ -37,25 +49,59 @@ func (c *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createOrder.OrderId = helpers.GetNextOrderID()
+	var branchPrefix string
+	if c.cfg.OrderNumber.PerBranch {
+		if !helpers.IsValidUUID(createOrder.BranchId) {
+			handleResponse(w, http.StatusBadRequest, "branch id is not uuid")
+			return
+		}
+
+		branch, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: createOrder.BranchId})
+		if errors.Is(err, sql.ErrNoRows) {
+			handleResponse(w, http.StatusBadRequest, "branch not found")
+			return
+		}
+
+		if err != nil {
+			handleResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		branchPrefix = branch.OrderPrefix
+	}
 
-	resp, err := c.storage.Order().Create(&createOrder)
+	createOrder.OrderId, err = c.nextNumber(ctx, c.cfg.OrderNumber, branchPrefix)
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	resp, err := c.storage.Order().Create(ctx, &createOrder)
+	if errors.Is(err, geo.ErrInvalid) || errors.Is(err, delivery.ErrOutOfRange) || errors.Is(err, delivery.ErrNoLocation) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetByIDOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
 
-	resp, err := c.storage.Order().GetByID(&models.OrderPrimaryKey{Id: id})
+	resp, err := c.storage.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusBadRequest, "no rows in result set")
 		return
@@ -66,10 +112,14 @@ func (c *Handler) GetByIDOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) GetListOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query limit")
@@ -82,13 +132,33 @@ func (c *Handler) GetListOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 
-	resp, err := c.storage.Order().GetList(&models.GetListOrderRequest{
-		Limit:  limit,
-		Offset: offset,
-		Search: search,
+	resp, err := c.storage.Order().GetList(ctx, &models.GetListOrderRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		Search:         search,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -97,7 +167,13 @@ func (c *Handler) GetListOrder(w http.ResponseWriter, r *http.Request) {
 	handleResponse(w, http.StatusOK, resp)
 }
 
+// UpdateOrder replaces the fields of an order. An update without
+// "promo_code" keeps the promotion of the order; an empty code takes it
+// off. Closed orders no longer change.
 func (c *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var updateOrder models.UpdateOrder
 	err := json.NewDecoder(r.Body).Decode(&updateOrder)
 	if err != nil {
@@ -114,8 +190,35 @@ func (c *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
-	rowsAffected, err := c.storage.Order().Update(&updateOrder)
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateOrder.Version = version
+	}
+
+	rowsAffected, err := c.storage.Order().Update(ctx, &updateOrder)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
+		if errors.Is(err, pricing.ErrInvalid) || errors.Is(err, workflow.ErrTransition) || errors.Is(err, promo.ErrNotApplicable) ||
+			errors.Is(err, geo.ErrInvalid) || errors.Is(err, delivery.ErrOutOfRange) || errors.Is(err, delivery.ErrNoLocation) {
+			handleResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errors.Is(err, storage.ErrInsufficientStock) || errors.Is(err, storage.ErrOrderClosed) {
+			handleResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -125,23 +228,27 @@ func (c *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Order().GetByID(&models.OrderPrimaryKey{Id: updateOrder.Id})
+	resp, err := c.storage.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: updateOrder.Id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "id is not uuid")
 		return
 	}
 
-	err := c.storage.Order().Delete(&models.OrderPrimaryKey{Id: id})
+	err := c.storage.Order().Delete(ctx, &models.OrderPrimaryKey{Id: id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -150,7 +257,83 @@ func (c *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	handleResponse(w, http.StatusNoContent, nil)
 }
 
+// RestoreOrder brings back a soft deleted order and returns it. An open
+// order reserves its lines again, which fails when the stock is gone.
+func (c *Handler) RestoreOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.Order().Restore(ctx, &models.OrderPrimaryKey{Id: id})
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// OrderHistory lists the status changes of an order, oldest first.
+func (c *Handler) OrderHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	resp, err := c.storage.Order().History(ctx, &models.OrderPrimaryKey{Id: id})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusBadRequest, "no rows in result set")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
 func (c *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var checkStatus models.CheckStatus
 	err := json.NewDecoder(r.Body).Decode(&checkStatus)
 	if err != nil {
@@ -163,11 +346,32 @@ func (c *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Order().StatusUpdate(checkStatus)
+	version, err := ifMatch(r)
 	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		checkStatus.Version = version
+	}
+
+	resp, err := c.storage.Order().StatusUpdate(ctx, checkStatus)
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
+		if errors.Is(err, workflow.ErrTransition) {
+			handleResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
@@ -3,10 +3,16 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
+	"market_system/pkg/pricing"
+	"market_system/pkg/workflow"
+	"market_system/storage"
 )
 
 func (c *Handler) OrderProduct(w http.ResponseWriter, r *http.Request) {
@@ -30,6 +36,9 @@ func (c *Handler) OrderProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateOrderProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var createOrderProduct models.CreateOrderProduct
 	err := json.NewDecoder(r.Body).Decode(&createOrderProduct)
 	if err != nil {
@@ -37,23 +46,37 @@ func (c *Handler) CreateOrderProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.OrderProduct().Create(&createOrderProduct)
+	resp, err := c.storage.OrderProduct().Create(ctx, &createOrderProduct)
+	if errors.Is(err, pricing.ErrInvalid) || errors.Is(err, workflow.ErrTransition) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetOrderProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	id := r.URL.Query().Get("order_product_id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "Invalid or missing order_product_id")
 		return
 	}
 
-	resp, err := c.storage.OrderProduct().GetByID(&models.OrderProductPrimaryKey{OrderProductID: id})
+	resp, err := c.storage.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusNotFound, "Order product not found")
 		return
@@ -64,10 +87,14 @@ func (c *Handler) GetOrderProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) GetListOrderProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query limit")
@@ -80,10 +107,30 @@ func (c *Handler) GetListOrderProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.OrderProduct().GetList(&models.GetListOrderProductRequest{
-		Limit:  limit,
-		Offset: offset,
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
+	resp, err := c.storage.OrderProduct().GetList(ctx, &models.GetListOrderProductRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -93,6 +140,9 @@ func (c *Handler) GetListOrderProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) UpdateOrderProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var updateOrderProduct models.UpdateOrderProduct
 	err := json.NewDecoder(r.Body).Decode(&updateOrderProduct)
 	if err != nil {
@@ -105,8 +155,33 @@ func (c *Handler) UpdateOrderProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rowsAffected, err := c.storage.OrderProduct().Update(&updateOrderProduct)
+	version, err := ifMatch(r)
 	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateOrderProduct.Version = version
+	}
+
+	rowsAffected, err := c.storage.OrderProduct().Update(ctx, &updateOrderProduct)
+	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
+		if errors.Is(err, pricing.ErrInvalid) || errors.Is(err, workflow.ErrTransition) {
+			handleResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errors.Is(err, storage.ErrInsufficientStock) {
+			handleResponse(w, http.StatusConflict, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -116,23 +191,32 @@ func (c *Handler) UpdateOrderProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.OrderProduct().GetByID(&models.OrderProductPrimaryKey{OrderProductID: updateOrderProduct.OrderProductID})
+	resp, err := c.storage.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: updateOrderProduct.OrderProductID})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteOrderProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	id := r.URL.Query().Get("order_product_id")
 	if !helpers.IsValidUUID(id) {
 		handleResponse(w, http.StatusBadRequest, "Invalid or missing order_product_id")
 		return
 	}
 
-	err := c.storage.OrderProduct().Delete(&models.OrderProductPrimaryKey{OrderProductID: id})
+	err := c.storage.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: id})
+	if errors.Is(err, workflow.ErrTransition) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -140,3 +224,50 @@ func (c *Handler) DeleteOrderProduct(w http.ResponseWriter, r *http.Request) {
 
 	handleResponse(w, http.StatusNoContent, nil)
 }
+
+// RestoreOrderProduct brings back a soft deleted order product and returns it.
+func (c *Handler) RestoreOrderProduct(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("order_product_id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "order_product_id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.OrderProduct().Restore(ctx, &models.OrderProductPrimaryKey{OrderProductID: id})
+	if errors.Is(err, workflow.ErrTransition) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
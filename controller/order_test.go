@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/workflow"
+)
+
+func TestOrder(t *testing.T) {
+	var (
+		handler, strg = newTestHandler(t)
+		client        = createTestClient(t, strg)
+		branch        = createTestBranch(t, strg)
+	)
+
+	resp := serve(t, handler.Order, newRequest(t, http.MethodPost, "/order", models.CreateOrder{
+		ClientId: client.ID,
+		BranchId: branch.ID,
+		Address:  "Chilonzor 1",
+	}), http.StatusCreated)
+
+	var order models.Order
+	decodeData(t, resp, &order)
+
+	if order.OrderId != "O-000001" {
+		t.Fatalf("order number: want O-000001, got %s", order.OrderId)
+	}
+
+	if order.Status != workflow.StatusNew {
+		t.Fatalf("status: want %s, got %s", workflow.StatusNew, order.Status)
+	}
+
+	resp = serve(t, handler.Order, newRequest(t, http.MethodGet, "/order?id="+order.Id, nil), http.StatusOK)
+
+	var got models.Order
+	decodeData(t, resp, &got)
+
+	if got.Id != order.Id || got.Address != "Chilonzor 1" {
+		t.Fatalf("got %+v, want %+v", got, order)
+	}
+
+	update := newRequest(t, http.MethodPut, "/order", models.UpdateOrder{
+		Id:       order.Id,
+		OrderId:  order.OrderId,
+		ClientId: client.ID,
+		BranchId: branch.ID,
+		Address:  "Yunusobod 2",
+	})
+	update.Header.Set("If-Match", `"1"`)
+	resp = serve(t, handler.Order, update, http.StatusAccepted)
+
+	decodeData(t, resp, &got)
+	if got.Address != "Yunusobod 2" || got.Version != 2 {
+		t.Fatalf("after update: got address %q version %d, want Yunusobod 2 and 2", got.Address, got.Version)
+	}
+
+	stale := newRequest(t, http.MethodPut, "/order", models.UpdateOrder{Id: order.Id, ClientId: client.ID, BranchId: branch.ID})
+	stale.Header.Set("If-Match", `"1"`)
+	serve(t, handler.Order, stale, http.StatusPreconditionFailed)
+
+	// An order without items cannot be confirmed.
+	serve(t, handler.Order, newRequest(t, http.MethodPatch, "/order", models.CheckStatus{
+		Id:     order.Id,
+		Status: workflow.StatusConfirmed,
+	}), http.StatusBadRequest)
+
+	resp = serve(t, handler.Order, newRequest(t, http.MethodPatch, "/order", models.CheckStatus{
+		Id:     order.Id,
+		Status: workflow.StatusInProcess,
+	}), http.StatusOK)
+
+	decodeData(t, resp, &got)
+	if got.Status != workflow.StatusInProcess {
+		t.Fatalf("status after the transition: want %s, got %s", workflow.StatusInProcess, got.Status)
+	}
+
+	resp = serve(t, handler.OrderHistory, newRequest(t, http.MethodGet, "/order/history?id="+order.Id, nil), http.StatusOK)
+
+	var history models.GetOrderHistoryResponse
+	decodeData(t, resp, &history)
+
+	if history.Count != 1 || history.History[0].ToStatus != workflow.StatusInProcess {
+		t.Fatalf("history: want the one transition, got %+v", history)
+	}
+
+	serve(t, handler.Order, newRequest(t, http.MethodDelete, "/order?id="+order.Id, nil), http.StatusNoContent)
+	serve(t, handler.Order, newRequest(t, http.MethodGet, "/order?id="+order.Id, nil), http.StatusBadRequest)
+	serve(t, handler.RestoreOrder, newRequest(t, http.MethodPost, "/order/restore?id="+order.Id, nil), http.StatusOK)
+}
+
+func TestOrderValidation(t *testing.T) {
+	var (
+		handler, strg = newTestHandler(t)
+		client        = createTestClient(t, strg)
+		branch        = createTestBranch(t, strg)
+	)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		r       *http.Request
+		status  int
+	}{
+		{"malformed body", handler.Order, newRequest(t, http.MethodPost, "/order", `{"client_id":`), http.StatusBadRequest},
+		{"latitude", handler.Order, newRequest(t, http.MethodPost, "/order", models.CreateOrder{ClientId: client.ID, BranchId: branch.ID, Latitude: 91}), http.StatusBadRequest},
+		{"id", handler.Order, newRequest(t, http.MethodGet, "/order?id=1", nil), http.StatusBadRequest},
+		{"missing order", handler.Order, newRequest(t, http.MethodGet, "/order?id="+missingID, nil), http.StatusBadRequest},
+		{"branch id", handler.Order, newRequest(t, http.MethodPut, "/order", models.UpdateOrder{Id: missingID, ClientId: client.ID, BranchId: "main"}), http.StatusBadRequest},
+		{"client id", handler.Order, newRequest(t, http.MethodPut, "/order", models.UpdateOrder{Id: missingID, ClientId: "me", BranchId: branch.ID}), http.StatusBadRequest},
+		{"update of a missing order", handler.Order, newRequest(t, http.MethodPut, "/order", models.UpdateOrder{Id: missingID, ClientId: client.ID, BranchId: branch.ID}), http.StatusBadRequest},
+		{"status id", handler.Order, newRequest(t, http.MethodPatch, "/order", models.CheckStatus{Id: "1", Status: workflow.StatusConfirmed}), http.StatusBadRequest},
+		{"offset", handler.Order, newRequest(t, http.MethodGet, "/order?offset=first", nil), http.StatusBadRequest},
+		{"filter object", handler.Order, newRequest(t, http.MethodGet, "/order?filter="+url.QueryEscape(`{"field":"status","op":"eq","value":{"$ne":""}}`), nil), http.StatusBadRequest},
+		{"include_deleted", handler.Order, newRequest(t, http.MethodGet, "/order?include_deleted=maybe", nil), http.StatusBadRequest},
+		{"history id", handler.OrderHistory, newRequest(t, http.MethodGet, "/order/history?id=1", nil), http.StatusBadRequest},
+		{"history method", handler.OrderHistory, newRequest(t, http.MethodPost, "/order/history?id="+missingID, nil), http.StatusMethodNotAllowed},
+		{"restore of a missing order", handler.RestoreOrder, newRequest(t, http.MethodPost, "/order/restore?id="+missingID, nil), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serve(t, tt.handler, tt.r, tt.status)
+		})
+	}
+}
@@ -3,11 +3,15 @@ package controller
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
+	"market_system/storage"
 )
 
 func (c *Handler) Product(w http.ResponseWriter, r *http.Request) {
@@ -30,6 +34,9 @@ func (c *Handler) Product(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var createProduct models.CreateProduct
 	err := json.NewDecoder(r.Body).Decode(&createProduct)
 	if err != nil {
@@ -42,18 +49,30 @@ func (c *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createProduct.ProductId = helpers.GetNextProductID()
+	if createProduct.TaxRateId != "" && !helpers.IsValidUUID(createProduct.TaxRateId) {
+		handleResponse(w, http.StatusBadRequest, "tax rate id is not a valid UUID")
+		return
+	}
+
+	createProduct.ProductId, err = c.nextNumber(ctx, c.cfg.ProductNumber, "")
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
 
-	resp, err := c.storage.Product().Create(&createProduct)
+	resp, err := c.storage.Product().Create(ctx, &createProduct)
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusCreated, resp)
 }
 
 func (c *Handler) GetByIDProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var id = r.URL.Query().Get("id")
 	if !helpers.IsValidUUID(id) {
@@ -61,7 +80,7 @@ func (c *Handler) GetByIDProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Product().GetByID(&models.ProductPrimaryKey{Id: id})
+	resp, err := c.storage.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: id})
 	if err == sql.ErrNoRows {
 		handleResponse(w, http.StatusBadRequest, "no rows in result set")
 		return
@@ -72,10 +91,13 @@ func (c *Handler) GetByIDProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusOK, resp)
 }
 
 func (c *Handler) GetListProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
 	if err != nil {
@@ -89,41 +111,54 @@ func (c *Handler) GetListProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	listFilter, err := getFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query filter")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
 	search := r.URL.Query().Get("search")
 	if err != nil {
 		handleResponse(w, http.StatusBadRequest, "invalid query search")
 		return
 	}
 
-	resp, err := c.storage.Product().GetList(&models.GetListProductRequest{
-		Limit:  limit,
-		Offset: offset,
-		Search: search,
+	resp, err := c.storage.Product().GetList(ctx, &models.GetListProductRequest{
+		Limit:          limit,
+		Offset:         offset,
+		Cursor:         r.URL.Query().Get("cursor"),
+		Filter:         listFilter,
+		Search:         search,
+		IncludeDeleted: includeDeleted,
 	})
+	if errors.Is(err, filter.ErrInvalid) || errors.Is(err, cursor.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	var (
-		categoryIds     []string
-		categoryIdQuery string = " AND id IN ("
-	)
+	var categoryIds []string
 	for _, product := range resp.Products {
 		categoryIds = append(categoryIds, product.CategoryId)
 	}
 	categoryIds = helpers.RemoveDuplicatesStrings(categoryIds)
 
-	for _, categoryId := range categoryIds {
-		categoryIdQuery += fmt.Sprintf("'%s',", categoryId)
-	}
-	categoryIdQuery = categoryIdQuery[:len(categoryIdQuery)-1]
-	categoryIdQuery += ")"
-
-	categories, err := c.storage.Category().GetList(&models.GetListCategoryRequest{
-		Limit:  1000,
+	categories, err := c.storage.Category().GetList(ctx, &models.GetListCategoryRequest{
+		Limit:  int64(len(categoryIds)),
 		Offset: 0,
-		Query:  categoryIdQuery,
+		Filter: filter.In("id", categoryIds),
+		// Products keep showing a category that was deleted after them.
+		IncludeDeleted: true,
 	})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
@@ -146,6 +181,8 @@ func (c *Handler) GetListProduct(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
 
 	var updateProduct models.UpdateProduct
 	err := json.NewDecoder(r.Body).Decode(&updateProduct)
@@ -166,8 +203,30 @@ func (c *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rowsAffected, err := c.storage.Product().Update(&updateProduct)
+	if updateProduct.TaxRateId != "" {
+		if !helpers.IsValidUUID(updateProduct.TaxRateId) {
+			handleResponse(w, http.StatusBadRequest, "tax rate id is not uuid")
+			return
+		}
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateProduct.Version = version
+	}
+
+	rowsAffected, err := c.storage.Product().Update(ctx, &updateProduct)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			handleResponse(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -177,16 +236,20 @@ func (c *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := c.storage.Product().GetByID(&models.ProductPrimaryKey{Id: updateProduct.Id})
+	resp, err := c.storage.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: updateProduct.Id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	setETag(w, resp.Version)
 	handleResponse(w, http.StatusAccepted, resp)
 }
 
 func (c *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
 	var id = r.URL.Query().Get("id")
 
 	if !helpers.IsValidUUID(id) {
@@ -194,7 +257,7 @@ func (c *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := c.storage.Product().Delete(&models.ProductPrimaryKey{Id: id})
+	err := c.storage.Product().Delete(ctx, &models.ProductPrimaryKey{Id: id})
 	if err != nil {
 		handleResponse(w, http.StatusInternalServerError, err)
 		return
@@ -202,3 +265,40 @@ func (c *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 
 	handleResponse(w, http.StatusNoContent, nil)
 }
+
+// RestoreProduct brings back a soft deleted product and returns it.
+func (c *Handler) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	rowsAffected, err := c.storage.Product().Restore(ctx, &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no deleted rows to restore")
+		return
+	}
+
+	resp, err := c.storage.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
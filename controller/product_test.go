@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+)
+
+func TestProduct(t *testing.T) {
+	handler, strg := newTestHandler(t)
+
+	category, err := strg.Category().Create(context.Background(), &models.CreateCategory{Title: "drinks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(t, handler.Product, newRequest(t, http.MethodPost, "/product", models.CreateProduct{
+		Title:      "Cola",
+		CategoryId: category.Id,
+		Price:      money.Units(12000),
+	}), http.StatusCreated)
+
+	var product models.Product
+	decodeData(t, resp, &product)
+
+	if product.ProductId != "P-000001" {
+		t.Fatalf("product number: want P-000001, got %s", product.ProductId)
+	}
+
+	if product.Price != money.Units(12000) {
+		t.Fatalf("price: want 12000, got %s", product.Price)
+	}
+
+	resp = serve(t, handler.Product, newRequest(t, http.MethodGet, "/product?id="+product.Id, nil), http.StatusOK)
+
+	var got models.Product
+	decodeData(t, resp, &got)
+
+	if got.Title != "Cola" || got.Version != product.Version {
+		t.Fatalf("got %+v, want %+v", got, product)
+	}
+
+	update := newRequest(t, http.MethodPut, "/product", models.UpdateProduct{
+		Id:         product.Id,
+		Title:      "Pepsi",
+		CategoryId: category.Id,
+		Price:      money.Units(11000),
+	})
+	update.Header.Set("If-Match", `"1"`)
+	resp = serve(t, handler.Product, update, http.StatusAccepted)
+
+	decodeData(t, resp, &got)
+	if got.Title != "Pepsi" || got.Version != 2 {
+		t.Fatalf("after update: got title %q version %d, want Pepsi and 2", got.Title, got.Version)
+	}
+
+	stale := newRequest(t, http.MethodPut, "/product", models.UpdateProduct{Id: product.Id, Title: "Fanta", CategoryId: category.Id})
+	stale.Header.Set("If-Match", `"1"`)
+	serve(t, handler.Product, stale, http.StatusPreconditionFailed)
+
+	serve(t, handler.Product, newRequest(t, http.MethodDelete, "/product?id="+product.Id, nil), http.StatusNoContent)
+	serve(t, handler.Product, newRequest(t, http.MethodGet, "/product?id="+product.Id, nil), http.StatusBadRequest)
+	serve(t, handler.RestoreProduct, newRequest(t, http.MethodPost, "/product/restore?id="+product.Id, nil), http.StatusOK)
+}
+
+func TestProductValidation(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		r       *http.Request
+		status  int
+	}{
+		{"malformed body", handler.Product, newRequest(t, http.MethodPost, "/product", `{"title":`), http.StatusBadRequest},
+		{"malformed price", handler.Product, newRequest(t, http.MethodPost, "/product", `{"title":"Cola","price":"12.345"}`), http.StatusBadRequest},
+		{"category id", handler.Product, newRequest(t, http.MethodPost, "/product", models.CreateProduct{Title: "Cola", CategoryId: "drinks"}), http.StatusBadRequest},
+		{"tax rate id", handler.Product, newRequest(t, http.MethodPost, "/product", models.CreateProduct{Title: "Cola", TaxRateId: "vat"}), http.StatusBadRequest},
+		{"id", handler.Product, newRequest(t, http.MethodGet, "/product?id=1", nil), http.StatusBadRequest},
+		{"missing product", handler.Product, newRequest(t, http.MethodGet, "/product?id="+missingID, nil), http.StatusBadRequest},
+		{"update of a missing product", handler.Product, newRequest(t, http.MethodPut, "/product", models.UpdateProduct{Id: missingID, Title: "Cola"}), http.StatusBadRequest},
+		{"If-Match", handler.Product, withHeader(newRequest(t, http.MethodPut, "/product", models.UpdateProduct{Id: missingID}), "If-Match", "one"), http.StatusBadRequest},
+		{"limit", handler.Product, newRequest(t, http.MethodGet, "/product?limit=ten", nil), http.StatusBadRequest},
+		{"filter", handler.Product, newRequest(t, http.MethodGet, "/product?filter="+url.QueryEscape("price"), nil), http.StatusBadRequest},
+		{"filter object", handler.Product, newRequest(t, http.MethodGet, "/product?filter="+url.QueryEscape(`{"field":"title","op":"eq","value":{"a":1}}`), nil), http.StatusBadRequest},
+		{"filter nested list", handler.Product, newRequest(t, http.MethodGet, "/product?filter="+url.QueryEscape(`{"field":"price","op":"in","value":[1,[2,3]]}`), nil), http.StatusBadRequest},
+		{"filter nested in a group", handler.Product, newRequest(t, http.MethodGet, "/product?filter="+url.QueryEscape(`{"or":[{"field":"price","op":"gt","value":[1]}]}`), nil), http.StatusBadRequest},
+		{"cursor", handler.Product, newRequest(t, http.MethodGet, "/product?cursor=next", nil), http.StatusBadRequest},
+		{"restore of a missing product", handler.RestoreProduct, newRequest(t, http.MethodPost, "/product/restore?id="+missingID, nil), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serve(t, tt.handler, tt.r, tt.status)
+		})
+	}
+}
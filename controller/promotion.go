@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"market_system/models"
+	"market_system/pkg/helpers"
+	"market_system/pkg/promo"
+	"market_system/storage"
+)
+
+func (c *Handler) Promotion(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodPost:
+		c.CreatePromotion(w, r)
+	case http.MethodGet:
+		var values = r.URL.Query()
+		if _, ok := values["id"]; ok {
+			c.GetByIDPromotion(w, r)
+		} else {
+			c.GetListPromotion(w, r)
+		}
+	case http.MethodPut:
+		c.UpdatePromotion(w, r)
+	case http.MethodDelete:
+		c.DeletePromotion(w, r)
+	default:
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (c *Handler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var createPromotion models.CreatePromotion
+	if err := json.NewDecoder(r.Body).Decode(&createPromotion); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validatePromotionIds(createPromotion.BranchIds, createPromotion.CategoryIds); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := c.storage.Promotion().Create(ctx, &createPromotion)
+	if errors.Is(err, promo.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusCreated, resp)
+}
+
+func (c *Handler) GetByIDPromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	resp, err := c.storage.Promotion().GetByID(ctx, &models.PromotionPrimaryKey{Id: id})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusNotFound, "promotion not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// GetListPromotion lists promotions newest first; "search" matches the
+// start of the code.
+func (c *Handler) GetListPromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	offset, err := getIntegerOrDefaultValue(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query offset")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
+	resp, err := c.storage.Promotion().GetList(ctx, &models.GetListPromotionRequest{
+		Offset:         offset,
+		Limit:          limit,
+		Search:         r.URL.Query().Get("search"),
+		IncludeDeleted: includeDeleted,
+	})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (c *Handler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var updatePromotion models.UpdatePromotion
+	if err := json.NewDecoder(r.Body).Decode(&updatePromotion); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !helpers.IsValidUUID(updatePromotion.Id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	if err := validatePromotionIds(updatePromotion.BranchIds, updatePromotion.CategoryIds); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updatePromotion.Version = version
+	}
+
+	rowsAffected, err := c.storage.Promotion().Update(ctx, &updatePromotion)
+	if errors.Is(err, storage.ErrVersionConflict) {
+		handleResponse(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+
+	if errors.Is(err, promo.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no rows affected")
+		return
+	}
+
+	resp, err := c.storage.Promotion().GetByID(ctx, &models.PromotionPrimaryKey{Id: updatePromotion.Id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusAccepted, resp)
+}
+
+// DeletePromotion retires a code. Orders that use it keep their discount.
+func (c *Handler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	if err := c.storage.Promotion().Delete(ctx, &models.PromotionPrimaryKey{Id: id}); err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusNoContent, nil)
+}
+
+func validatePromotionIds(branchIds, categoryIds []string) error {
+
+	for i, id := range branchIds {
+		if !helpers.IsValidUUID(id) {
+			return fmt.Errorf("branch_ids %d is not uuid", i)
+		}
+	}
+
+	for i, id := range categoryIds {
+		if !helpers.IsValidUUID(id) {
+			return fmt.Errorf("category_ids %d is not uuid", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/helpers"
+	"market_system/pkg/ledger"
+	"market_system/storage"
+)
+
+// Stock returns what one branch holds of a product ("branch_id" and
+// "product_id"), or lists the stock of all branches or of one.
+func (c *Handler) Stock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var (
+		branchID  = r.URL.Query().Get("branch_id")
+		productID = r.URL.Query().Get("product_id")
+	)
+
+	if branchID != "" && !helpers.IsValidUUID(branchID) {
+		handleResponse(w, http.StatusBadRequest, "branch_id is not uuid")
+		return
+	}
+
+	if productID != "" {
+		if !helpers.IsValidUUID(productID) || branchID == "" {
+			handleResponse(w, http.StatusBadRequest, "product_id needs a branch_id and must be uuid")
+			return
+		}
+
+		resp, err := c.storage.Stock().GetByID(ctx, &models.StockPrimaryKey{BranchId: branchID, ProductId: productID})
+		if errors.Is(err, sql.ErrNoRows) {
+			handleResponse(w, http.StatusNotFound, "stock not found")
+			return
+		}
+
+		if err != nil {
+			handleResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		handleResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	offset, err := getIntegerOrDefaultValue(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query offset")
+		return
+	}
+
+	resp, err := c.storage.Stock().GetList(ctx, &models.GetListStockRequest{
+		BranchId: branchID,
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// AdjustStock adds to or takes away from what a branch holds of a product
+// with a receipt, a write-off or a count. The quantity cannot go below what
+// open orders have reserved.
+func (c *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var adjustStock models.AdjustStock
+	if err := json.NewDecoder(r.Body).Decode(&adjustStock); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !helpers.IsValidUUID(adjustStock.BranchId) || !helpers.IsValidUUID(adjustStock.ProductId) {
+		handleResponse(w, http.StatusBadRequest, "branch_id and product_id must be uuid")
+		return
+	}
+
+	_, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: adjustStock.BranchId})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusBadRequest, "branch not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	_, err = c.storage.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: adjustStock.ProductId})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusBadRequest, "product not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp, err := c.storage.Stock().Adjust(ctx, &adjustStock)
+	if errors.Is(err, ledger.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// StockMovements lists the stock ledger oldest first, optionally of one
+// branch, product, movement type or reference, and between "from",
+// inclusive, and "to", exclusive.
+func (c *Handler) StockMovements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var query = r.URL.Query()
+
+	limit, err := getIntegerOrDefaultValue(query.Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	offset, err := getIntegerOrDefaultValue(query.Get("offset"), 0)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query offset")
+		return
+	}
+
+	from, err := getTimeOrZero(query.Get("from"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query from")
+		return
+	}
+
+	to, err := getTimeOrZero(query.Get("to"))
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query to")
+		return
+	}
+
+	for _, param := range []string{"branch_id", "product_id"} {
+		if id := query.Get(param); id != "" && !helpers.IsValidUUID(id) {
+			handleResponse(w, http.StatusBadRequest, param+" is not uuid")
+			return
+		}
+	}
+
+	if movementType := query.Get("type"); movementType != "" && !ledger.Known(movementType) {
+		handleResponse(w, http.StatusBadRequest, "unknown movement type")
+		return
+	}
+
+	resp, err := c.storage.Stock().Movements(ctx, &models.GetListStockMovementRequest{
+		BranchId:  query.Get("branch_id"),
+		ProductId: query.Get("product_id"),
+		Type:      query.Get("type"),
+		Reference: query.Get("reference"),
+		From:      from,
+		To:        to,
+		Offset:    offset,
+		Limit:     limit,
+	})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// StockReconciliation sums up the ledger of a branch over a calendar month
+// ("month", as 2006-01, in UTC) or between "from" and "to": the stock of
+// every product at the start, its movements by type and its stock at the
+// end. Without a period it gives the stock now as the ledger has it.
+func (c *Handler) StockReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var query = r.URL.Query()
+
+	req := models.StockReconciliationRequest{BranchId: query.Get("branch_id")}
+	if !helpers.IsValidUUID(req.BranchId) {
+		handleResponse(w, http.StatusBadRequest, "branch_id is not uuid")
+		return
+	}
+
+	if month := query.Get("month"); month != "" {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query month")
+			return
+		}
+
+		req.From, req.To = start, start.AddDate(0, 1, 0)
+	} else {
+		var err error
+
+		if req.From, err = getTimeOrZero(query.Get("from")); err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query from")
+			return
+		}
+
+		if req.To, err = getTimeOrZero(query.Get("to")); err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query to")
+			return
+		}
+	}
+
+	resp, err := c.storage.Stock().Reconcile(ctx, &req)
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// StockTransfer creates a transfer between branches (POST) or returns one
+// ("id", GET).
+func (c *Handler) StockTransfer(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		c.CreateStockTransfer(w, r)
+	case http.MethodGet:
+		c.GetStockTransfer(w, r)
+	default:
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// CreateStockTransfer takes the items out of the stock of one branch and
+// puts them into the stock of the other in one transaction. Only what is
+// available, not reserved by open orders, can be transferred.
+func (c *Handler) CreateStockTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var createTransfer models.CreateStockTransfer
+	if err := json.NewDecoder(r.Body).Decode(&createTransfer); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateStockTransfer(&createTransfer); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, branchID := range []string{createTransfer.FromBranchId, createTransfer.ToBranchId} {
+		_, err := c.storage.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: branchID})
+		if errors.Is(err, sql.ErrNoRows) {
+			handleResponse(w, http.StatusBadRequest, "branch "+branchID+" not found")
+			return
+		}
+
+		if err != nil {
+			handleResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	for i, item := range createTransfer.Items {
+		_, err := c.storage.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: item.ProductId})
+		if errors.Is(err, sql.ErrNoRows) {
+			handleResponse(w, http.StatusBadRequest, fmt.Sprintf("item %d: product %s not found", i, item.ProductId))
+			return
+		}
+
+		if err != nil {
+			handleResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	resp, err := c.storage.Stock().Transfer(ctx, &createTransfer)
+	if errors.Is(err, ledger.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrInsufficientStock) {
+		handleResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusCreated, resp)
+}
+
+func (c *Handler) GetStockTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	resp, err := c.storage.Stock().GetTransfer(ctx, &models.StockTransferPrimaryKey{Id: id})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusNotFound, "stock transfer not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func validateStockTransfer(transfer *models.CreateStockTransfer) error {
+
+	if !helpers.IsValidUUID(transfer.FromBranchId) || !helpers.IsValidUUID(transfer.ToBranchId) {
+		return errors.New("from_branch_id and to_branch_id must be uuid")
+	}
+
+	if len(transfer.Items) == 0 {
+		return errors.New("no items")
+	}
+
+	for i, item := range transfer.Items {
+		if !helpers.IsValidUUID(item.ProductId) {
+			return fmt.Errorf("item %d: product id is not uuid", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/helpers"
+	"market_system/pkg/tax"
+	"market_system/storage"
+)
+
+func (c *Handler) TaxRate(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodPost:
+		c.CreateTaxRate(w, r)
+	case http.MethodGet:
+		var values = r.URL.Query()
+		if _, ok := values["id"]; ok {
+			c.GetByIDTaxRate(w, r)
+		} else {
+			c.GetListTaxRate(w, r)
+		}
+	case http.MethodPut:
+		c.UpdateTaxRate(w, r)
+	case http.MethodDelete:
+		c.DeleteTaxRate(w, r)
+	default:
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (c *Handler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var createTaxRate models.CreateTaxRate
+	if err := json.NewDecoder(r.Body).Decode(&createTaxRate); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := c.storage.TaxRate().Create(ctx, &createTaxRate)
+	if errors.Is(err, tax.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusCreated, resp)
+}
+
+func (c *Handler) GetByIDTaxRate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	resp, err := c.storage.TaxRate().GetByID(ctx, &models.TaxRatePrimaryKey{Id: id})
+	if errors.Is(err, sql.ErrNoRows) {
+		handleResponse(w, http.StatusNotFound, "tax rate not found")
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusOK, resp)
+}
+
+// GetListTaxRate lists tax rates newest first; "search" matches any part
+// of the name.
+func (c *Handler) GetListTaxRate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	limit, err := getIntegerOrDefaultValue(r.URL.Query().Get("limit"), 10)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query limit")
+		return
+	}
+
+	offset, err := getIntegerOrDefaultValue(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query offset")
+		return
+	}
+
+	includeDeleted, err := getBoolOrDefaultValue(r.URL.Query().Get("include_deleted"), false)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid query include_deleted")
+		return
+	}
+
+	resp, err := c.storage.TaxRate().GetList(ctx, &models.GetListTaxRateRequest{
+		Offset:         offset,
+		Limit:          limit,
+		Search:         r.URL.Query().Get("search"),
+		IncludeDeleted: includeDeleted,
+	})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
+
+func (c *Handler) UpdateTaxRate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var updateTaxRate models.UpdateTaxRate
+	if err := json.NewDecoder(r.Body).Decode(&updateTaxRate); err != nil {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !helpers.IsValidUUID(updateTaxRate.Id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		handleResponse(w, http.StatusBadRequest, "invalid If-Match header")
+		return
+	}
+
+	if version != 0 {
+		updateTaxRate.Version = version
+	}
+
+	rowsAffected, err := c.storage.TaxRate().Update(ctx, &updateTaxRate)
+	if errors.Is(err, storage.ErrVersionConflict) {
+		handleResponse(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+
+	if errors.Is(err, tax.ErrInvalid) {
+		handleResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if rowsAffected == 0 {
+		handleResponse(w, http.StatusBadRequest, "no rows affected")
+		return
+	}
+
+	resp, err := c.storage.TaxRate().GetByID(ctx, &models.TaxRatePrimaryKey{Id: updateTaxRate.Id})
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	setETag(w, resp.Version)
+	handleResponse(w, http.StatusAccepted, resp)
+}
+
+// DeleteTaxRate retires a rate. Products and categories that name it are
+// no longer taxed at it; order lines keep the rate they were added at.
+func (c *Handler) DeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var id = r.URL.Query().Get("id")
+	if !helpers.IsValidUUID(id) {
+		handleResponse(w, http.StatusBadRequest, "id is not uuid")
+		return
+	}
+
+	if err := c.storage.TaxRate().Delete(ctx, &models.TaxRatePrimaryKey{Id: id}); err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusNoContent, nil)
+}
+
+// TaxReport sums the tax breakdowns of the orders per branch and rate:
+// of one branch ("branch_id") or all, for a calendar month ("month",
+// YYYY-MM) or a period ("from", "to"). Canceled orders are left out.
+func (c *Handler) TaxReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ctx, cancel := c.requestContext(r)
+	defer cancel()
+
+	var query = r.URL.Query()
+
+	req := models.TaxReportRequest{BranchId: query.Get("branch_id")}
+	if req.BranchId != "" && !helpers.IsValidUUID(req.BranchId) {
+		handleResponse(w, http.StatusBadRequest, "branch_id is not uuid")
+		return
+	}
+
+	if month := query.Get("month"); month != "" {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query month")
+			return
+		}
+
+		req.From, req.To = start, start.AddDate(0, 1, 0)
+	} else {
+		var err error
+
+		if req.From, err = getTimeOrZero(query.Get("from")); err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query from")
+			return
+		}
+
+		if req.To, err = getTimeOrZero(query.Get("to")); err != nil {
+			handleResponse(w, http.StatusBadRequest, "invalid query to")
+			return
+		}
+	}
+
+	resp, err := c.storage.TaxRate().Report(ctx, &req)
+	if err != nil {
+		handleResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	handleResponse(w, http.StatusOK, resp)
+}
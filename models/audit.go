@@ -0,0 +1,42 @@
+package models
+
+import "encoding/json"
+
+// AuditEntry records one write. Before and After are JSON snapshots of the
+// row, missing when there was no row on that side, such as Before of a
+// create. Diff maps every changed field to its before and after value.
+type AuditEntry struct {
+	Id        string          `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityId  string          `json:"entity_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Diff      json.RawMessage `json:"diff,omitempty"`
+	CreatedAt string          `json:"created_at"`
+}
+
+type CreateAuditEntry struct {
+	Entity   string          `json:"entity"`
+	EntityId string          `json:"entity_id"`
+	Action   string          `json:"action"`
+	Actor    string          `json:"actor"`
+	Before   json.RawMessage `json:"before"`
+	After    json.RawMessage `json:"after"`
+	Diff     json.RawMessage `json:"diff"`
+}
+
+type GetListAuditRequest struct {
+	Offset   int64  `json:"offset"`
+	Limit    int64  `json:"limit"`
+	Cursor   string `json:"cursor"`
+	Entity   string `json:"entity"`
+	EntityId string `json:"entity_id"`
+}
+
+type GetListAuditResponse struct {
+	Count      int           `json:"count"`
+	Entries    []*AuditEntry `json:"entries"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -1,57 +1,218 @@
 package models
 
+import (
+	"time"
+
+	"market_system/pkg/delivery"
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+	"market_system/pkg/schedule"
+)
+
 type BranchPrimaryKey struct {
 	ID string `json:"id"`
 }
 
 type CreateBranch struct {
-	Name          string `json:"name"`
-	Phone         string `json:"phone"`
-	Photo         string `json:"photo"`
-	WorkStartHour string `json:"work_start_hour"`
-	WorkEndHour   string `json:"work_end_hour"`
-	Address       string `json:"address"`
-	DeliveryPrice float64  `json:"delivery_price"`
-	Active        bool   `json:"active"`
-	CreatedAt     string `json:"created_at"`
+	Name          string          `json:"name"`
+	Phone         string          `json:"phone"`
+	Photo         string          `json:"photo"`
+	WorkStartHour string          `json:"work_start_hour"`
+	WorkEndHour   string          `json:"work_end_hour"`
+	Timezone      string          `json:"timezone"`
+	Schedule      []BranchHours   `json:"schedule"`
+	Holidays      []BranchHoliday `json:"holidays"`
+	Address       string          `json:"address"`
+	Latitude      float64         `json:"latitude"`
+	Longitude     float64         `json:"longitude"`
+	OrderPrefix   string          `json:"order_prefix"`
+	DeliveryPrice money.Amount    `json:"delivery_price"`
+	Active        bool            `json:"active"`
+	CreatedAt     string          `json:"created_at"`
 }
 
 type Branch struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	Phone         string `json:"phone"`
-	Photo         string `json:"photo"`
-	WorkStartHour string `json:"work_start_hour"`
-	WorkEndHour   string `json:"work_end_hour"`
-	Address       string `json:"address"`
-	DeliveryPrice float64  `json:"delivery_price"`
-	Active        bool   `json:"active"`
-	CreatedAt     string `json:"created_at"`
-	UpdatedAt     string `json:"updated_at"`
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Phone         string          `json:"phone"`
+	Photo         string          `json:"photo"`
+	WorkStartHour string          `json:"work_start_hour"`
+	WorkEndHour   string          `json:"work_end_hour"`
+	Timezone      string          `json:"timezone"`
+	Schedule      []BranchHours   `json:"schedule"`
+	Holidays      []BranchHoliday `json:"holidays"`
+	Address       string          `json:"address"`
+	Latitude      float64         `json:"latitude"`
+	Longitude     float64         `json:"longitude"`
+	OrderPrefix   string          `json:"order_prefix"`
+	DeliveryPrice money.Amount    `json:"delivery_price"`
+	Active        bool            `json:"active"`
+	CreatedAt     string          `json:"created_at"`
+	UpdatedAt     string          `json:"updated_at"`
+	DeletedAt     string          `json:"deleted_at,omitempty"`
+	Version       int64           `json:"version"`
+	// IsOpenNow is whether the branch is open at the time it was read, and
+	// NextOpenAt, for a closed branch, when it opens next.
+	IsOpenNow  bool   `json:"is_open_now"`
+	NextOpenAt string `json:"next_open_at,omitempty"`
+}
+
+// BranchHours is a shift of a branch on a weekday, from 0 for Sunday to 6
+// for Saturday, as "HH:MM" clock times in the timezone of the branch. A
+// Close before Open ends the next day. A branch without shifts is open
+// from work_start_hour to work_end_hour every day.
+type BranchHours struct {
+	Weekday int    `json:"weekday"`
+	Open    string `json:"open"`
+	Close   string `json:"close"`
+}
+
+// BranchHoliday replaces the shifts of the weekday of Date, a 2006-01-02
+// date. A holiday without Open and Close is closed all day; several
+// holidays of one date are the shifts of that date.
+type BranchHoliday struct {
+	Date  string `json:"date"`
+	Open  string `json:"open,omitempty"`
+	Close string `json:"close,omitempty"`
+}
+
+// OpenHours returns the opening hours of the branch, see schedule.Parse.
+func (b *Branch) OpenHours() (schedule.Schedule, error) {
+
+	shifts := make([]schedule.Shift, len(b.Schedule))
+	for i, shift := range b.Schedule {
+		shifts[i] = schedule.Shift(shift)
+	}
+
+	holidays := make([]schedule.Holiday, len(b.Holidays))
+	for i, holiday := range b.Holidays {
+		holidays[i] = schedule.Holiday(holiday)
+	}
+
+	return schedule.Parse(b.Timezone, b.WorkStartHour, b.WorkEndHour, shifts, holidays)
+}
+
+// SetOpenState sets whether the branch is open at t and, when it is
+// closed, when it opens next. A branch stored before its hours were
+// checked is closed.
+func (b *Branch) SetOpenState(t time.Time) {
+
+	b.IsOpenNow, b.NextOpenAt = false, ""
+
+	s, err := b.OpenHours()
+	if err != nil {
+		return
+	}
+
+	if b.IsOpenNow = s.Open(t); b.IsOpenNow {
+		return
+	}
+
+	if next, ok := s.NextOpen(t); ok {
+		b.NextOpenAt = next.Format(time.RFC3339)
+	}
 }
 
 type UpdateBranch struct {
-	ID            string `json:"id"`
-	Name          string `json:"name"`
-	Phone         string `json:"phone"`
-	Photo         string `json:"photo"`
-	WorkStartHour string `json:"work_start_hour"`
-	WorkEndHour   string `json:"work_end_hour"`
-	Address       string `json:"address"`
-	DeliveryPrice float64  `json:"delivery_price"`
-	Active        bool   `json:"active"`
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Phone         string          `json:"phone"`
+	Photo         string          `json:"photo"`
+	WorkStartHour string          `json:"work_start_hour"`
+	WorkEndHour   string          `json:"work_end_hour"`
+	Timezone      string          `json:"timezone"`
+	Schedule      []BranchHours   `json:"schedule"`
+	Holidays      []BranchHoliday `json:"holidays"`
+	Address       string          `json:"address"`
+	Latitude      float64         `json:"latitude"`
+	Longitude     float64         `json:"longitude"`
+	OrderPrefix   string          `json:"order_prefix"`
+	DeliveryPrice money.Amount    `json:"delivery_price"`
+	Active        bool            `json:"active"`
+	Version       int64           `json:"version"`
 }
 
 type GetListBranchRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
-	StartDate string `json:"start_date"`
-	EndDate string `json:"end_date"`
-	Query  string `json:"query"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	StartDate      string      `json:"start_date"`
+	EndDate        string      `json:"end_date"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListBranchResponse struct {
-	Count    int       `json:"count"`
-	Branches []*Branch `json:"branches"`
+	Count      int       `json:"count"`
+	Branches   []*Branch `json:"branches"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// NearestBranchRequest asks for the active branches with a location
+// closest to Latitude and Longitude, open or closed at At, the current
+// time when zero. Speed estimates their delivery times.
+type NearestBranchRequest struct {
+	Latitude  float64
+	Longitude float64
+	At        time.Time
+	Limit     int64
+	Speed     delivery.Speed
+}
+
+// NearestBranch is a branch as seen from a client location. A branch that
+// is not Deliverable has the location beyond its delivery radius, and no
+// delivery fee or time. IsOpen is whether it is open at the At of the
+// request, while IsOpenNow and NextOpenAt of the branch are, as on every
+// read of a branch, as of the time it was read; without an At both are
+// worked out from the same reading of the clock.
+type NearestBranch struct {
+	Branch
+	DistanceKm      float64      `json:"distance_km"`
+	IsOpen          bool         `json:"is_open"`
+	Deliverable     bool         `json:"deliverable"`
+	DeliveryFee     money.Amount `json:"delivery_fee"`
+	DeliveryMinutes int64        `json:"delivery_minutes"`
+}
+
+type NearestBranchResponse struct {
+	Branches []*NearestBranch `json:"branches"`
+}
+
+// DeliveryTariff is how a branch prices deliveries by distance, see
+// delivery.Tariff. Orders with a location placed with a branch that has a
+// location and a tariff pay by the tariff; all others pay the flat
+// delivery_price of the branch.
+type DeliveryTariff struct {
+	BranchId    string         `json:"branch_id"`
+	BaseFee     money.Amount   `json:"base_fee"`
+	PerKm       money.Amount   `json:"per_km"`
+	Bands       []DeliveryBand `json:"bands"`
+	FreeFrom    money.Amount   `json:"free_from"`
+	MaxRadiusKm float64        `json:"max_radius_km"`
+	CreatedAt   string         `json:"created_at"`
+	UpdatedAt   string         `json:"updated_at"`
+	Version     int64          `json:"version"`
+}
+
+type DeliveryBand struct {
+	UpToKm float64      `json:"up_to_km"`
+	Fee    money.Amount `json:"fee"`
+}
+
+// Tariff returns the tariff as the delivery package prices it.
+func (t *DeliveryTariff) Tariff() delivery.Tariff {
+
+	tariff := delivery.Tariff{
+		BaseFee:     t.BaseFee,
+		PerKm:       t.PerKm,
+		FreeFrom:    t.FreeFrom,
+		MaxRadiusKm: t.MaxRadiusKm,
+	}
+
+	for _, band := range t.Bands {
+		tariff.Bands = append(tariff.Bands, delivery.Band{UpToKm: band.UpToKm, Fee: band.Fee})
+	}
+
+	return tariff
 }
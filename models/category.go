@@ -1,5 +1,7 @@
 package models
 
+import "market_system/pkg/filter"
+
 type CategoryPrimaryKey struct {
 	Id string `json:"id"`
 }
@@ -8,6 +10,7 @@ type CreateCategory struct {
 	Title     string `json:"title"`
 	ParentID  string `json:"parent_id"`
 	Image     string `json:"image"`
+	TaxRateId string `json:"tax_rate_id"`
 	CreatedAt string `json:"created_at"`
 }
 
@@ -16,25 +19,33 @@ type Category struct {
 	Title     string `json:"title"`
 	ParentID  string `json:"parent_id"`
 	Image     string `json:"image"`
+	TaxRateId string `json:"tax_rate_id"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+	Version   int64  `json:"version"`
 }
 
 type UpdateCategory struct {
-	Id       string `json:"id"`
-	Title    string `json:"title"`
-	ParentID string `json:"parent_id"`
-	Image    string `json:"image"`
+	Id        string `json:"id"`
+	Title     string `json:"title"`
+	ParentID  string `json:"parent_id"`
+	Image     string `json:"image"`
+	TaxRateId string `json:"tax_rate_id"`
+	Version   int64  `json:"version"`
 }
 
 type GetListCategoryRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
-	Query  string `json:"query"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListCategoryResponse struct {
 	Count      int         `json:"count"`
 	Categories []*Category `json:"categories"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "market_system/pkg/money"
+
+// Checkout places an order together with its lines in one call.
+type Checkout struct {
+	ClientId  string         `json:"client_id"`
+	BranchId  string         `json:"branch_id"`
+	Address   string         `json:"address"`
+	Latitude  float64        `json:"latitude"`
+	Longitude float64        `json:"longitude"`
+	PromoCode string         `json:"promo_code"`
+	Items     []CheckoutItem `json:"items"`
+}
+
+type CheckoutItem struct {
+	ProductID      string       `json:"product_id"`
+	Quantity       float64      `json:"quantity"`
+	DiscountType   string       `json:"discount_type"`
+	DiscountAmount money.Amount `json:"discount_amount"`
+}
+
+// OrderWithItems is an order with its lines, in the order they were added.
+type OrderWithItems struct {
+	Order
+	Items []*OrderProduct `json:"items"`
+}
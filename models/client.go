@@ -1,5 +1,7 @@
 package models
 
+import "market_system/pkg/filter"
+
 type ClientPrimaryKey struct {
 	ID string `json:"id"`
 }
@@ -14,33 +16,39 @@ type CreateClient struct {
 }
 
 type Client struct {
-	ID         string `json:"id"`
-	FirstName  string `json:"first_name"`
-	LastName   string `json:"last_name"`
-	Phone      string `json:"phone"`
-	Photo      string `json:"photo"`
+	ID          string `json:"id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Phone       string `json:"phone"`
+	Photo       string `json:"photo"`
 	DateOfBirth string `json:"date_of_birth"`
-	CreatedAt  string `json:"created_at"`
-	UpdatedAt  string `json:"updated_at"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	DeletedAt   string `json:"deleted_at,omitempty"`
+	Version     int64  `json:"version"`
 }
 
 type UpdateClient struct {
-	ID         string `json:"id"`
-	FirstName  string `json:"first_name"`
-	LastName   string `json:"last_name"`
-	Phone      string `json:"phone"`
-	Photo      string `json:"photo"`
+	ID          string `json:"id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Phone       string `json:"phone"`
+	Photo       string `json:"photo"`
 	DateOfBirth string `json:"date_of_birth"`
+	Version     int64  `json:"version"`
 }
 
 type GetListClientRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
-	Query  string `json:"query"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListClientResponse struct {
-	Count   int      `json:"count"`
-	Clients []*Client `json:"clients"`
+	Count      int       `json:"count"`
+	Clients    []*Client `json:"clients"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
@@ -1,57 +1,107 @@
 package models
 
+import (
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+)
+
 type Order struct {
-	Id            string  `json:"id"`
-	OrderId       string  `json:"order_id"`
-	ClientId      string  `json:"client_id"`
-	BranchId      string  `json:"branch_id"`
-	Address       string  `json:"address"`
-	DeliveryPrice float64 `json:"delivery_price"`
-	TotalCount    float64 `json:"total_count"`
-	TotalPrice    float64 `json:"total_price"`
-	Status        string  `json:"status"`
-	CreatedAt     string  `json:"created_at"`
-	UpdatedAt     string  `json:"updated_at"`
+	Id            string       `json:"id"`
+	OrderId       string       `json:"order_id"`
+	ClientId      string       `json:"client_id"`
+	BranchId      string       `json:"branch_id"`
+	Address       string       `json:"address"`
+	Latitude      float64      `json:"latitude"`
+	Longitude     float64      `json:"longitude"`
+	DeliveryPrice money.Amount `json:"delivery_price"`
+	// DeliveryDistance is the great-circle distance in kilometers the
+	// delivery is priced by, 0 for an order that pays a flat price.
+	DeliveryDistance float64      `json:"delivery_distance"`
+	TotalCount       float64      `json:"total_count"`
+	TotalPrice       money.Amount `json:"total_price"`
+	PromotionId      string       `json:"promotion_id,omitempty"`
+	PromoCode        string       `json:"promo_code,omitempty"`
+	Discount         money.Amount `json:"discount"`
+	Tax              money.Amount `json:"tax"`
+	Taxes            []OrderTax   `json:"taxes,omitempty"`
+	Status           string       `json:"status"`
+	CreatedAt        string       `json:"created_at"`
+	UpdatedAt        string       `json:"updated_at"`
+	DeletedAt        string       `json:"deleted_at,omitempty"`
+	Version          int64        `json:"version"`
 }
 
 type OrderPrimaryKey struct {
 	Id string `json:"id"`
 }
 
+// CreateOrder places an order. Latitude and Longitude locate the address;
+// the delivery of an order without them is priced flat.
 type CreateOrder struct {
-	OrderId    string  `json:"order_id"`
-	ClientId   string  `json:"client_id"`
-	BranchId   string  `json:"branch_id"`
-	Address    string  `json:"address"`
-	TotalCount float64 `json:"total_count"`
-	TotalPrice float64 `json:"total_price"`
-	Status     string  `json:"status"`
+	OrderId   string  `json:"order_id"`
+	ClientId  string  `json:"client_id"`
+	BranchId  string  `json:"branch_id"`
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Status    string  `json:"status"`
 }
 
+// UpdateOrder replaces the fields of an order. PromoCode is the code the
+// order uses from now on: an empty one takes the promotion off and none at
+// all keeps the promotion the order has. DeliveryPrice is only kept for an
+// order whose delivery is not priced by distance.
 type UpdateOrder struct {
-	Id            string  `json:"id"`
-	ClientId      string  `json:"client_id"`
-	OrderId       string  `json:"order_id"`
-	BranchId      string  `json:"branch_id"`
-	Address       string  `json:"address"`
-	DeliveryPrice float64 `json:"delivery_price"`
-	TotalCount    float64 `json:"total_count"`
-	TotalPrice    float64 `json:"total_price"`
-	Status        string  `json:"status"`
+	Id            string       `json:"id"`
+	ClientId      string       `json:"client_id"`
+	OrderId       string       `json:"order_id"`
+	BranchId      string       `json:"branch_id"`
+	Address       string       `json:"address"`
+	Latitude      float64      `json:"latitude"`
+	Longitude     float64      `json:"longitude"`
+	DeliveryPrice money.Amount `json:"delivery_price"`
+	PromoCode     *string      `json:"promo_code"`
+	Status        string       `json:"status"`
+	Version       int64        `json:"version"`
 }
 
 type GetListOrderRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListOrderResponse struct {
-	Count  int      `json:"count"`
-	Orders []*Order `json:"orders"`
+	Count      int      `json:"count"`
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
+// CheckStatus moves an order to Status. Actor is who does it; the audit
+// storage fills it in from the request context.
 type CheckStatus struct {
-	Id     string `json:"id"`
-	Status string `json:"status"`
+	Id      string `json:"id"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Actor   string `json:"-"`
+	Version int64  `json:"version"`
+}
+
+// OrderStatusChange is one row of the order status history.
+type OrderStatusChange struct {
+	Id         string `json:"id"`
+	OrderId    string `json:"order_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	Actor      string `json:"actor"`
+	Reason     string `json:"reason,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type GetOrderHistoryResponse struct {
+	Count   int                  `json:"count"`
+	History []*OrderStatusChange `json:"history"`
 }
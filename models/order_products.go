@@ -1,49 +1,63 @@
 package models
 
+import (
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+)
+
 type OrderProductPrimaryKey struct {
 	OrderProductID string `json:"order_product_id"`
 }
 
 type CreateOrderProduct struct {
-	OrderID        string  `json:"order_id"`
-	ProductID      string  `json:"product_id"`
-	DiscountType   string  `json:"discount_type"`
-	DiscountAmount float64 `json:"discount_amount"`
-	Quantity       float64 `json:"quantity"`
-	Price          float64 `json:"price"`
-	Sum            float64 `json:"sum"`
+	OrderID        string       `json:"order_id"`
+	ProductID      string       `json:"product_id"`
+	DiscountType   string       `json:"discount_type"`
+	DiscountAmount money.Amount `json:"discount_amount"`
+	Quantity       float64      `json:"quantity"`
 }
 
 type OrderProduct struct {
-	OrderProductID string  `json:"order_product_id"`
-	OrderID        string  `json:"order_id"`
-	ProductID      string  `json:"product_id"`
-	DiscountType   string  `json:"discount_type"`
-	DiscountAmount float64 `json:"discount_amount"`
-	Quantity       float64 `json:"quantity"`
-	Price          float64 `json:"price"`
-	Sum            float64 `json:"sum"`
+	OrderProductID string       `json:"order_product_id"`
+	OrderID        string       `json:"order_id"`
+	ProductID      string       `json:"product_id"`
+	DiscountType   string       `json:"discount_type"`
+	DiscountAmount money.Amount `json:"discount_amount"`
+	Quantity       float64      `json:"quantity"`
+	Price          money.Amount `json:"price"`
+	Sum            money.Amount `json:"sum"`
+	TaxRateId      string       `json:"tax_rate_id,omitempty"`
+	TaxPercent     money.Amount `json:"tax_percent"`
+	TaxInclusive   bool         `json:"tax_inclusive"`
+	Tax            money.Amount `json:"tax"`
+	Reserved       float64      `json:"reserved"`
+	CreatedAt      string       `json:"created_at"`
+	UpdatedAt      string       `json:"updated_at"`
+	DeletedAt      string       `json:"deleted_at,omitempty"`
+	Version        int64        `json:"version"`
 }
 
 type UpdateOrderProduct struct {
-	OrderProductID string  `json:"order_product_id"`
-	OrderID        string  `json:"order_id"`
-	ProductID      string  `json:"product_id"`
-	DiscountType   string  `json:"discount_type"`
-	DiscountAmount float64 `json:"discount_amount"`
-	Quantity       float64 `json:"quantity"`
-	Price          float64 `json:"price"`
-	Sum            float64 `json:"sum"`
+	OrderProductID string       `json:"order_product_id"`
+	OrderID        string       `json:"order_id"`
+	ProductID      string       `json:"product_id"`
+	DiscountType   string       `json:"discount_type"`
+	DiscountAmount money.Amount `json:"discount_amount"`
+	Quantity       float64      `json:"quantity"`
+	Version        int64        `json:"version"`
 }
 
 type GetListOrderProductRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
-	Query  string `json:"query"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListOrderProductResponse struct {
 	Count         int             `json:"count"`
 	OrderProducts []*OrderProduct `json:"order_products"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
 }
@@ -1,48 +1,63 @@
 package models
 
+import (
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+)
+
 type ProductPrimaryKey struct {
 	Id string `json:"id"`
 }
 
 type CreateProduct struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ProductId   string `json:"product_id"`
-	CategoryId  string `json:"category_id"`
-	Photo       string `json:"photo"`
-	Price       float64 `json:"price"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	ProductId   string       `json:"product_id"`
+	CategoryId  string       `json:"category_id"`
+	Photo       string       `json:"photo"`
+	Price       money.Amount `json:"price"`
+	TaxRateId   string       `json:"tax_rate_id"`
 }
 
 type Product struct {
-	Id          string      `json:"id"`
-	ProductId   string      `json:"product_id"`
-	Title       string      `json:"title"`
-	Photo       string      `json:"photo"`
-	Price       float64      `json:"price"`
-	Description string      `json:"description"`
-	CategoryId  string      `json:"category_id"`
-	Category    interface{} `json:"category"`
-	CreatedAt   string      `json:"created_at"`
-	UpdatedAt   string      `json:"updated_at"`
+	Id          string       `json:"id"`
+	ProductId   string       `json:"product_id"`
+	Title       string       `json:"title"`
+	Photo       string       `json:"photo"`
+	Price       money.Amount `json:"price"`
+	Description string       `json:"description"`
+	CategoryId  string       `json:"category_id"`
+	TaxRateId   string       `json:"tax_rate_id"`
+	Category    interface{}  `json:"category"`
+	CreatedAt   string       `json:"created_at"`
+	UpdatedAt   string       `json:"updated_at"`
+	DeletedAt   string       `json:"deleted_at,omitempty"`
+	Version     int64        `json:"version"`
 }
 
 type UpdateProduct struct {
-	Id          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ProductId   string `json:"product_id"`
-	Photo       string `json:"photo"`
-	Price       float64 `json:"price"`
-	CategoryId  string `json:"category_id"`
+	Id          string       `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	ProductId   string       `json:"product_id"`
+	Photo       string       `json:"photo"`
+	Price       money.Amount `json:"price"`
+	CategoryId  string       `json:"category_id"`
+	TaxRateId   string       `json:"tax_rate_id"`
+	Version     int64        `json:"version"`
 }
 
 type GetListProductRequest struct {
-	Offset int64  `json:"offset"`
-	Limit  int64  `json:"limit"`
-	Search string `json:"search"`
+	Offset         int64       `json:"offset"`
+	Limit          int64       `json:"limit"`
+	Cursor         string      `json:"cursor"`
+	Search         string      `json:"search"`
+	Filter         filter.Expr `json:"filter"`
+	IncludeDeleted bool        `json:"include_deleted"`
 }
 
 type GetListProductResponse struct {
-	Count    int        `json:"count"`
-	Products []*Product `json:"products"`
+	Count      int        `json:"count"`
+	Products   []*Product `json:"products"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"market_system/pkg/money"
+	"time"
+)
+
+// Promotion is a promo code. Zero limits and empty BranchIds or CategoryIds
+// mean no restriction, and StartsAt and EndsAt are empty for an open
+// window.
+type Promotion struct {
+	Id             string       `json:"id"`
+	Code           string       `json:"code"`
+	Type           string       `json:"type"`
+	Value          money.Amount `json:"value"`
+	StartsAt       string       `json:"starts_at,omitempty"`
+	EndsAt         string       `json:"ends_at,omitempty"`
+	UsageLimit     int          `json:"usage_limit"`
+	PerClientLimit int          `json:"per_client_limit"`
+	MinOrderTotal  money.Amount `json:"min_order_total"`
+	BranchIds      []string     `json:"branch_ids"`
+	CategoryIds    []string     `json:"category_ids"`
+	CreatedAt      string       `json:"created_at"`
+	UpdatedAt      string       `json:"updated_at"`
+	DeletedAt      string       `json:"deleted_at,omitempty"`
+	Version        int64        `json:"version"`
+}
+
+type PromotionPrimaryKey struct {
+	Id string `json:"id"`
+}
+
+type CreatePromotion struct {
+	Code           string       `json:"code"`
+	Type           string       `json:"type"`
+	Value          money.Amount `json:"value"`
+	StartsAt       time.Time    `json:"starts_at"`
+	EndsAt         time.Time    `json:"ends_at"`
+	UsageLimit     int          `json:"usage_limit"`
+	PerClientLimit int          `json:"per_client_limit"`
+	MinOrderTotal  money.Amount `json:"min_order_total"`
+	BranchIds      []string     `json:"branch_ids"`
+	CategoryIds    []string     `json:"category_ids"`
+}
+
+type UpdatePromotion struct {
+	Id             string       `json:"id"`
+	Code           string       `json:"code"`
+	Type           string       `json:"type"`
+	Value          money.Amount `json:"value"`
+	StartsAt       time.Time    `json:"starts_at"`
+	EndsAt         time.Time    `json:"ends_at"`
+	UsageLimit     int          `json:"usage_limit"`
+	PerClientLimit int          `json:"per_client_limit"`
+	MinOrderTotal  money.Amount `json:"min_order_total"`
+	BranchIds      []string     `json:"branch_ids"`
+	CategoryIds    []string     `json:"category_ids"`
+	Version        int64        `json:"version"`
+}
+
+// GetListPromotionRequest lists promotions newest first. Search matches the
+// start of the code.
+type GetListPromotionRequest struct {
+	Offset         int64  `json:"offset"`
+	Limit          int64  `json:"limit"`
+	Search         string `json:"search"`
+	IncludeDeleted bool   `json:"include_deleted"`
+}
+
+type GetListPromotionResponse struct {
+	Count      int          `json:"count"`
+	Promotions []*Promotion `json:"promotions"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// Sequence is a named counter; Value is the last number handed out.
+type Sequence struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import "time"
+
+// Stock is what a branch holds of a product. Reserved is held for the lines
+// of open orders, so only Available, Quantity minus Reserved, can be sold.
+type Stock struct {
+	BranchId  string  `json:"branch_id"`
+	ProductId string  `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+	Reserved  float64 `json:"reserved"`
+	Available float64 `json:"available"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type StockPrimaryKey struct {
+	BranchId  string `json:"branch_id"`
+	ProductId string `json:"product_id"`
+}
+
+// AdjustStock adds Delta, negative to take away, to the quantity a branch
+// holds of a product, and records it in the ledger as a movement of Type
+// with the document in Reference, such as an invoice number.
+type AdjustStock struct {
+	BranchId  string  `json:"branch_id"`
+	ProductId string  `json:"product_id"`
+	Type      string  `json:"type"`
+	Delta     float64 `json:"delta"`
+	Reference string  `json:"reference"`
+	Actor     string  `json:"-"`
+}
+
+type GetListStockRequest struct {
+	BranchId string `json:"branch_id"`
+	Offset   int64  `json:"offset"`
+	Limit    int64  `json:"limit"`
+}
+
+type GetListStockResponse struct {
+	Count  int      `json:"count"`
+	Stocks []*Stock `json:"stocks"`
+}
+
+// StockMovement is one entry of the stock ledger. Quantity is what the
+// movement added to the stock of the branch, negative when it took some
+// away. Reference is the document behind it: the order of a sale or a
+// return, the transfer of a transfer, or what was entered by hand.
+type StockMovement struct {
+	Id        string  `json:"id"`
+	BranchId  string  `json:"branch_id"`
+	ProductId string  `json:"product_id"`
+	Type      string  `json:"type"`
+	Quantity  float64 `json:"quantity"`
+	Reference string  `json:"reference"`
+	Actor     string  `json:"actor"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// GetListStockMovementRequest lists the ledger oldest first. Movements are
+// filtered by the fields that are set; From is inclusive and To exclusive.
+type GetListStockMovementRequest struct {
+	BranchId  string
+	ProductId string
+	Type      string
+	Reference string
+	From      time.Time
+	To        time.Time
+	Offset    int64
+	Limit     int64
+}
+
+type GetListStockMovementResponse struct {
+	Count     int              `json:"count"`
+	Movements []*StockMovement `json:"movements"`
+}
+
+// StockReconciliationRequest asks for the ledger of a branch between From,
+// inclusive, and To, exclusive. A zero From starts at the first movement
+// and a zero To ends now.
+type StockReconciliationRequest struct {
+	BranchId string
+	From     time.Time
+	To       time.Time
+}
+
+// StockReconciliation sums up the ledger of a branch over a period, one
+// line per product that had stock at its start or moved during it.
+type StockReconciliation struct {
+	BranchId string                     `json:"branch_id"`
+	Products []*StockReconciliationLine `json:"products"`
+}
+
+// StockReconciliationLine is the stock of a product at the start of the
+// period, its movements by type and its stock at the end.
+type StockReconciliationLine struct {
+	ProductId string             `json:"product_id"`
+	Opening   float64            `json:"opening"`
+	Movements map[string]float64 `json:"movements"`
+	Closing   float64            `json:"closing"`
+}
+
+// StockTransfer moves goods from one branch to another. Both sides are
+// written at once, as transfer-out and transfer-in movements that refer to
+// the transfer.
+type StockTransfer struct {
+	Id           string              `json:"id"`
+	FromBranchId string              `json:"from_branch_id"`
+	ToBranchId   string              `json:"to_branch_id"`
+	Reference    string              `json:"reference"`
+	Actor        string              `json:"actor"`
+	Items        []StockTransferItem `json:"items"`
+	CreatedAt    string              `json:"created_at"`
+}
+
+type StockTransferItem struct {
+	ProductId string  `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+type StockTransferPrimaryKey struct {
+	Id string `json:"id"`
+}
+
+type CreateStockTransfer struct {
+	FromBranchId string              `json:"from_branch_id"`
+	ToBranchId   string              `json:"to_branch_id"`
+	Reference    string              `json:"reference"`
+	Items        []StockTransferItem `json:"items"`
+	Actor        string              `json:"-"`
+}
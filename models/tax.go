@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"market_system/pkg/money"
+)
+
+// TaxRate is a VAT rate that categories and products point at. Percent is
+// 12.5 for 12.5 percent. An inclusive rate is already in the prices it
+// applies to; an exclusive one is charged on top of them.
+type TaxRate struct {
+	Id        string       `json:"id"`
+	Name      string       `json:"name"`
+	Percent   money.Amount `json:"percent"`
+	Inclusive bool         `json:"inclusive"`
+	CreatedAt string       `json:"created_at"`
+	UpdatedAt string       `json:"updated_at"`
+	DeletedAt string       `json:"deleted_at,omitempty"`
+	Version   int64        `json:"version"`
+}
+
+type TaxRatePrimaryKey struct {
+	Id string `json:"id"`
+}
+
+type CreateTaxRate struct {
+	Name      string       `json:"name"`
+	Percent   money.Amount `json:"percent"`
+	Inclusive bool         `json:"inclusive"`
+}
+
+type UpdateTaxRate struct {
+	Id        string       `json:"id"`
+	Name      string       `json:"name"`
+	Percent   money.Amount `json:"percent"`
+	Inclusive bool         `json:"inclusive"`
+	Version   int64        `json:"version"`
+}
+
+type GetListTaxRateRequest struct {
+	Offset         int64  `json:"offset"`
+	Limit          int64  `json:"limit"`
+	Search         string `json:"search"`
+	IncludeDeleted bool   `json:"include_deleted"`
+}
+
+type GetListTaxRateResponse struct {
+	Count    int        `json:"count"`
+	TaxRates []*TaxRate `json:"tax_rates"`
+}
+
+// OrderTax is the tax of one rate in an order. Base is the amount without
+// the tax, so Base plus Tax is what the lines of the rate cost.
+type OrderTax struct {
+	TaxRateId string       `json:"tax_rate_id"`
+	Percent   money.Amount `json:"percent"`
+	Inclusive bool         `json:"inclusive"`
+	Base      money.Amount `json:"base"`
+	Tax       money.Amount `json:"tax"`
+}
+
+// TaxReportRequest asks for the tax of the orders created between From,
+// inclusive, and To, exclusive. A zero bound leaves that side open and an
+// empty BranchId means every branch.
+type TaxReportRequest struct {
+	BranchId string
+	From     time.Time
+	To       time.Time
+}
+
+// TaxReport sums up the tax of completed sales, delivered or finished
+// orders, by branch and rate. Every other order is left out, returned
+// ones included since their sale and refund net to nothing, as are
+// deleted ones.
+type TaxReport struct {
+	Lines []*TaxReportLine `json:"lines"`
+}
+
+type TaxReportLine struct {
+	BranchId  string       `json:"branch_id"`
+	TaxRateId string       `json:"tax_rate_id"`
+	Percent   money.Amount `json:"percent"`
+	Inclusive bool         `json:"inclusive"`
+	Orders    int          `json:"orders"`
+	Base      money.Amount `json:"base"`
+	Tax       money.Amount `json:"tax"`
+}
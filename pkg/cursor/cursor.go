@@ -0,0 +1,63 @@
+// Package cursor encodes the opaque page tokens of keyset pagination.
+//
+// Lists are ordered by created_at DESC, id DESC. A token names the last row
+// of a page by that sort key, so the next page starts right after it no
+// matter how many rows were inserted or deleted in between.
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+type Cursor struct {
+	CreatedAt string `json:"created_at"`
+	ID        string `json:"id"`
+}
+
+func Encode(createdAt, id string) string {
+
+	data, _ := json.Marshal(Cursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func Decode(token string) (Cursor, error) {
+
+	var c Cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalid
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalid
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, c.CreatedAt); err != nil {
+		return c, ErrInvalid
+	}
+
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return c, ErrInvalid
+	}
+
+	return c, nil
+}
+
+// Before reports whether the row (createdAt, id) sorts before c, that is
+// on an earlier page. Timestamps must share one sortable layout.
+func (c Cursor) Before(createdAt, id string) bool {
+
+	if createdAt != c.CreatedAt {
+		return createdAt > c.CreatedAt
+	}
+
+	return id >= c.ID
+}
@@ -0,0 +1,173 @@
+// Package delivery prices the delivery of an order by distance: a tariff of
+// a branch applied to the great-circle distance from the branch to the
+// address. Storage backends store what it computes, so the rules are the
+// same everywhere.
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"market_system/pkg/geo"
+	"market_system/pkg/money"
+)
+
+var (
+	// ErrInvalid wraps every error about a tariff that cannot be stored.
+	ErrInvalid = errors.New("invalid delivery tariff")
+	// ErrOutOfRange is returned for an address the branch does not deliver
+	// to.
+	ErrOutOfRange = errors.New("address is out of delivery range")
+	// ErrNoLocation is returned for an address without coordinates when
+	// the branch prices deliveries by distance.
+	ErrNoLocation = errors.New("address has no location")
+)
+
+// Band is a step of distance pricing: deliveries up to UpToKm cost Fee on
+// top of the base fee.
+type Band struct {
+	UpToKm float64
+	Fee    money.Amount
+}
+
+// Tariff is how a branch prices deliveries. Every delivery costs BaseFee
+// plus a distance part: the fee of the first band that reaches the
+// distance, or else PerKm for every kilometer. Deliveries of orders whose
+// goods cost FreeFrom or more are free. A MaxRadiusKm of 0 delivers
+// anywhere, and a FreeFrom of 0 never delivers for free.
+type Tariff struct {
+	BaseFee     money.Amount
+	PerKm       money.Amount
+	Bands       []Band
+	FreeFrom    money.Amount
+	MaxRadiusKm float64
+}
+
+// Validate checks a tariff before it is stored. Bands must be ordered by
+// distance.
+func (t Tariff) Validate() error {
+
+	if t.BaseFee < 0 || t.PerKm < 0 || t.FreeFrom < 0 {
+		return fmt.Errorf("%w: fees must not be negative", ErrInvalid)
+	}
+
+	if t.MaxRadiusKm < 0 || math.IsNaN(t.MaxRadiusKm) {
+		return fmt.Errorf("%w: max radius %v is negative", ErrInvalid, t.MaxRadiusKm)
+	}
+
+	for i, band := range t.Bands {
+		if band.Fee < 0 {
+			return fmt.Errorf("%w: band %d fee %s is negative", ErrInvalid, i, band.Fee)
+		}
+
+		if !(band.UpToKm > 0) {
+			return fmt.Errorf("%w: band %d distance %v is not positive", ErrInvalid, i, band.UpToKm)
+		}
+
+		if i > 0 && band.UpToKm <= t.Bands[i-1].UpToKm {
+			return fmt.Errorf("%w: band %d does not reach further than band %d", ErrInvalid, i, i-1)
+		}
+	}
+
+	return nil
+}
+
+// Route is a delivery of Km kilometers under a tariff.
+type Route struct {
+	Tariff Tariff
+	Km     float64
+}
+
+// NewRoute returns the route from a branch to an address. The distance is
+// rounded to meters, the precision it is stored at, so a fee can always be
+// priced again from a stored route.
+func NewRoute(tariff Tariff, from, to geo.Point) Route {
+	return Route{Tariff: tariff, Km: math.Round(geo.Distance(from, to)*1000) / 1000}
+}
+
+// Check rejects a route beyond the radius of the tariff.
+func (r Route) Check() error {
+
+	if r.Tariff.MaxRadiusKm > 0 && r.Km > r.Tariff.MaxRadiusKm {
+		return fmt.Errorf("%w: %v km is further than %v km", ErrOutOfRange, r.Km, r.Tariff.MaxRadiusKm)
+	}
+
+	return nil
+}
+
+// Fee returns the delivery fee for goods worth goods, rounded half up to
+// cents.
+func (r Route) Fee(goods money.Amount) money.Amount {
+
+	if r.Tariff.FreeFrom > 0 && goods >= r.Tariff.FreeFrom {
+		return 0
+	}
+
+	for _, band := range r.Tariff.Bands {
+		if r.Km <= band.UpToKm {
+			return r.Tariff.BaseFee + band.Fee
+		}
+	}
+
+	return r.Tariff.BaseFee + r.Tariff.PerKm.Mul(r.Km, money.HalfUp)
+}
+
+// Speed estimates how long deliveries take: Prep to get an order ready,
+// then KmPerHour on the road. A KmPerHour of 0 leaves the road out.
+type Speed struct {
+	Prep      time.Duration
+	KmPerHour float64
+}
+
+// Estimate returns how long a delivery of km kilometers takes, rounded up
+// to whole minutes.
+func (s Speed) Estimate(km float64) time.Duration {
+
+	estimate := s.Prep
+	if s.KmPerHour > 0 {
+		estimate += time.Duration(km / s.KmPerHour * float64(time.Hour))
+	}
+
+	if rounded := estimate.Truncate(time.Minute); rounded < estimate {
+		return rounded + time.Minute
+	}
+
+	return estimate
+}
+
+// Quote is what a delivery from a branch offers an address: the distance,
+// whether the branch delivers there at all, and if so the fee for no goods
+// yet and how long the delivery takes.
+type Quote struct {
+	Km          float64
+	Deliverable bool
+	Fee         money.Amount
+	Estimate    time.Duration
+}
+
+// NewQuote returns the quote of a branch at from for an address at to. A
+// branch with a tariff prices the delivery by it and delivers within its
+// radius only; one without charges its flat fee anywhere.
+func NewQuote(tariff *Tariff, flat money.Amount, from, to geo.Point, speed Speed) Quote {
+
+	var (
+		route = NewRoute(Tariff{}, from, to)
+		quote = Quote{Km: route.Km, Deliverable: true, Fee: flat}
+	)
+
+	if tariff != nil {
+		route.Tariff = *tariff
+		quote.Deliverable = route.Check() == nil
+		quote.Fee = route.Fee(0)
+	}
+
+	if !quote.Deliverable {
+		quote.Fee = 0
+		return quote
+	}
+
+	quote.Estimate = speed.Estimate(route.Km)
+	return quote
+}
@@ -0,0 +1,138 @@
+package delivery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"market_system/pkg/geo"
+	"market_system/pkg/money"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Tariff{
+		BaseFee:     money.Units(5),
+		PerKm:       money.Units(2),
+		Bands:       []Band{{UpToKm: 3, Fee: 0}, {UpToKm: 7, Fee: money.Units(4)}},
+		FreeFrom:    money.Units(100),
+		MaxRadiusKm: 15,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tariff := range map[string]Tariff{
+		"negative fee":       {BaseFee: -1},
+		"negative radius":    {MaxRadiusKm: -1},
+		"negative band fee":  {Bands: []Band{{UpToKm: 1, Fee: -1}}},
+		"empty band":         {Bands: []Band{{UpToKm: 0}}},
+		"bands out of order": {Bands: []Band{{UpToKm: 5}, {UpToKm: 3}}},
+	} {
+		if err := tariff.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%s: want ErrInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestFee(t *testing.T) {
+	tariff := Tariff{
+		BaseFee:  money.Units(5),
+		PerKm:    money.Units(2),
+		Bands:    []Band{{UpToKm: 3, Fee: 0}, {UpToKm: 7, Fee: money.Units(4)}},
+		FreeFrom: money.Units(100),
+	}
+
+	tests := []struct {
+		name  string
+		km    float64
+		goods money.Amount
+		fee   money.Amount
+	}{
+		{"first band", 2.5, money.Units(50), money.Units(5)},
+		{"edge of the first band", 3, money.Units(50), money.Units(5)},
+		{"second band", 3.001, money.Units(50), money.Units(9)},
+		{"per km past the bands", 10.125, money.Units(50), 2525},
+		{"free from the threshold", 10.125, money.Units(100), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Route{Tariff: tariff, Km: tt.km}).Fee(tt.goods); got != tt.fee {
+				t.Fatalf("want %s, got %s", tt.fee, got)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	var (
+		tariff = Tariff{MaxRadiusKm: 100}
+		branch = geo.Point{Lat: 0, Lng: 0}
+	)
+
+	route := NewRoute(tariff, branch, geo.Point{Lat: 0.5, Lng: 0})
+	if route.Km != 55.598 {
+		t.Fatalf("want the distance rounded to meters, got %v", route.Km)
+	}
+
+	if err := route.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewRoute(tariff, branch, geo.Point{Lat: 1, Lng: 0}).Check(); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("want ErrOutOfRange, got %v", err)
+	}
+
+	if err := NewRoute(Tariff{}, branch, geo.Point{Lat: 10, Lng: 0}).Check(); err != nil {
+		t.Fatalf("no radius: %v", err)
+	}
+}
+
+func TestNewQuote(t *testing.T) {
+	var (
+		tariff = Tariff{BaseFee: money.Units(5), PerKm: money.Units(2), MaxRadiusKm: 100}
+		speed  = Speed{Prep: 10 * time.Minute, KmPerHour: 60}
+		branch = geo.Point{Lat: 0, Lng: 0}
+		near   = geo.Point{Lat: 0.5, Lng: 0}
+		far    = geo.Point{Lat: 1, Lng: 0}
+	)
+
+	got := NewQuote(&tariff, money.Units(3), branch, near, speed)
+	want := Quote{Km: 55.598, Deliverable: true, Fee: money.Units(5) + money.Units(2).Mul(55.598, money.HalfUp), Estimate: 66 * time.Minute}
+	if got != want {
+		t.Fatalf("by tariff: got %+v, want %+v", got, want)
+	}
+
+	if got := NewQuote(&tariff, money.Units(3), branch, far, speed); got.Deliverable || got.Fee != 0 || got.Estimate != 0 {
+		t.Fatalf("beyond the radius: got %+v, want no delivery", got)
+	}
+
+	got = NewQuote(nil, money.Units(3), branch, far, speed)
+	want = Quote{Km: 111.195, Deliverable: true, Fee: money.Units(3), Estimate: 122 * time.Minute}
+	if got != want {
+		t.Fatalf("flat: got %+v, want %+v", got, want)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	speed := Speed{Prep: 15 * time.Minute, KmPerHour: 30}
+
+	tests := []struct {
+		km   float64
+		want time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{5, 25 * time.Minute},
+		{5.01, 26 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := speed.Estimate(tt.km); got != tt.want {
+			t.Fatalf("%v km: got %v, want %v", tt.km, got, tt.want)
+		}
+	}
+
+	if got := (Speed{Prep: time.Minute}).Estimate(100); got != time.Minute {
+		t.Fatalf("without a speed: got %v, want %v", got, time.Minute)
+	}
+}
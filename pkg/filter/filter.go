@@ -0,0 +1,207 @@
+// Package filter describes list filters as data: conditions on named fields
+// combined into AND/OR groups. Storage backends translate an Expr into a
+// parameterized WHERE clause or evaluate it in memory, so callers never
+// write SQL.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalid wraps every error about a malformed expression, an unknown
+// field or an unknown operator.
+var ErrInvalid = errors.New("invalid filter")
+
+type Operator string
+
+const (
+	OpEq      Operator = "eq"
+	OpNe      Operator = "ne"
+	OpLt      Operator = "lt"
+	OpLte     Operator = "lte"
+	OpGt      Operator = "gt"
+	OpGte     Operator = "gte"
+	OpIn      Operator = "in"
+	OpBetween Operator = "between"
+	OpILike   Operator = "ilike"
+	OpIsNull  Operator = "is_null"
+	OpNotNull Operator = "not_null"
+)
+
+// Expr is either a single condition (Field, Op, Value) or a group of
+// expressions joined by AND or OR. The zero Expr matches everything.
+type Expr struct {
+	Field string      `json:"field,omitempty"`
+	Op    Operator    `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+	And   []Expr      `json:"and,omitempty"`
+	Or    []Expr      `json:"or,omitempty"`
+}
+
+func Eq(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpEq, Value: value}
+}
+
+func Ne(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpNe, Value: value}
+}
+
+func Lt(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpLt, Value: value}
+}
+
+func Lte(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpLte, Value: value}
+}
+
+func Gt(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpGt, Value: value}
+}
+
+func Gte(field string, value interface{}) Expr {
+	return Expr{Field: field, Op: OpGte, Value: value}
+}
+
+// In matches any element of values, which must be a slice.
+func In(field string, values interface{}) Expr {
+	return Expr{Field: field, Op: OpIn, Value: values}
+}
+
+// Between matches from <= field <= to.
+func Between(field string, from, to interface{}) Expr {
+	return Expr{Field: field, Op: OpBetween, Value: []interface{}{from, to}}
+}
+
+// ILike matches fields that contain value, ignoring case. Unlike a raw
+// ILIKE pattern, % and _ in value are matched literally.
+func ILike(field string, value string) Expr {
+	return Expr{Field: field, Op: OpILike, Value: value}
+}
+
+func IsNull(field string) Expr {
+	return Expr{Field: field, Op: OpIsNull}
+}
+
+func NotNull(field string) Expr {
+	return Expr{Field: field, Op: OpNotNull}
+}
+
+func And(exprs ...Expr) Expr {
+	return Expr{And: exprs}
+}
+
+func Or(exprs ...Expr) Expr {
+	return Expr{Or: exprs}
+}
+
+// IsZero reports whether e has no condition and no group.
+func (e Expr) IsZero() bool {
+	return e.Field == "" && e.Op == "" && e.And == nil && e.Or == nil
+}
+
+func (e Expr) validate() error {
+
+	var kinds int
+	if e.Field != "" || e.Op != "" {
+		kinds++
+	}
+
+	if e.And != nil {
+		kinds++
+	}
+
+	if e.Or != nil {
+		kinds++
+	}
+
+	if kinds > 1 {
+		return fmt.Errorf("%w: an expression is either a condition, an and group or an or group", ErrInvalid)
+	}
+
+	if e.Field == "" && e.Op != "" {
+		return fmt.Errorf("%w: operator %s has no field", ErrInvalid, e.Op)
+	}
+
+	if e.Field != "" && e.Op == "" {
+		return fmt.Errorf("%w: field %s has no operator", ErrInvalid, e.Field)
+	}
+
+	switch e.Op {
+	case "", OpIsNull, OpNotNull:
+	case OpIn, OpBetween:
+		values, err := e.values()
+		if err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			if !scalar(value) {
+				return fmt.Errorf("%w: %s on %s needs a list of strings, numbers or booleans", ErrInvalid, e.Op, e.Field)
+			}
+		}
+	default:
+		if !scalar(e.Value) {
+			return fmt.Errorf("%w: %s on %s needs a string, number or boolean value", ErrInvalid, e.Op, e.Field)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks e and every expression nested in it, so a malformed
+// filter is rejected before it reaches a backend. Fields are only known
+// to the backends, which reject the ones they do not list.
+func (e Expr) Validate() error {
+
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	for _, group := range [][]Expr{e.And, e.Or} {
+		for _, expr := range group {
+			if err := expr.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// scalar reports whether value can be bound as a single SQL parameter:
+// nil, a string, a number or a boolean. Objects and nested lists decoded
+// from JSON cannot.
+func scalar(value interface{}) bool {
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Invalid, reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// values returns the elements of an In or Between value.
+func (e Expr) values() ([]interface{}, error) {
+
+	v := reflect.ValueOf(e.Value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%w: %s on %s needs a list value", ErrInvalid, e.Op, e.Field)
+	}
+
+	values := make([]interface{}, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+
+	if e.Op == OpBetween && len(values) != 2 {
+		return nil, fmt.Errorf("%w: between on %s needs exactly two values", ErrInvalid, e.Field)
+	}
+
+	return values, nil
+}
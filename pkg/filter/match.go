@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// Getter returns the value of a field of one record, and false when the
+// record has no such field.
+type Getter func(field string) (interface{}, bool)
+
+// Match evaluates e against one record the way ToSQL's output would in
+// postgres. Empty strings count as NULL.
+func Match(e Expr, get Getter) (bool, error) {
+
+	if err := e.validate(); err != nil {
+		return false, err
+	}
+
+	switch {
+	case e.And != nil:
+		for _, expr := range e.And {
+			ok, err := Match(expr, get)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case e.Or != nil:
+		for _, expr := range e.Or {
+			ok, err := Match(expr, get)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case e.IsZero():
+		return true, nil
+	}
+
+	value, ok := get(e.Field)
+	if !ok {
+		return false, fmt.Errorf("%w: unknown field %q", ErrInvalid, e.Field)
+	}
+
+	isNull := value == nil || value == ""
+
+	switch e.Op {
+	case OpIsNull:
+		return isNull, nil
+	case OpNotNull:
+		return !isNull, nil
+	case OpILike:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(e.Value))), nil
+	case OpEq:
+		return compare(value, e.Value) == 0, nil
+	case OpNe:
+		return compare(value, e.Value) != 0, nil
+	case OpLt:
+		return compare(value, e.Value) < 0, nil
+	case OpLte:
+		return compare(value, e.Value) <= 0, nil
+	case OpGt:
+		return compare(value, e.Value) > 0, nil
+	case OpGte:
+		return compare(value, e.Value) >= 0, nil
+	case OpIn, OpBetween:
+		values, err := e.values()
+		if err != nil {
+			return false, err
+		}
+
+		if e.Op == OpBetween {
+			return compare(value, values[0]) >= 0 && compare(value, values[1]) <= 0, nil
+		}
+
+		for _, v := range values {
+			if compare(value, v) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%w: unknown operator %q", ErrInvalid, e.Op)
+}
+
+// compare orders a stored value against a filter value. Numbers compare
+// numerically, everything else as strings; timestamps are stored in a
+// sortable layout, so a date prefix such as "2024-01-31" orders correctly.
+func compare(value, other interface{}) int {
+
+	switch value.(type) {
+	case int, int32, int64, float32, float64:
+		a := cast.ToFloat64(value)
+		if b, err := cast.ToFloat64E(other); err == nil {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(value), fmt.Sprint(other))
+}
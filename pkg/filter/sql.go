@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Columns maps the field names a repo accepts to their SQL expressions.
+// Fields that are not listed are rejected, so field names never reach SQL.
+type Columns map[string]string
+
+// Where renders e as " WHERE ...". Placeholders continue after the args
+// already bound by the caller, and the extended args are returned.
+func Where(e Expr, columns Columns, args []interface{}) (string, []interface{}, error) {
+
+	cond, args, err := ToSQL(e, columns, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return " WHERE " + cond, args, nil
+}
+
+// ToSQL renders e as a boolean SQL expression with $n placeholders.
+func ToSQL(e Expr, columns Columns, args []interface{}) (string, []interface{}, error) {
+
+	if err := e.validate(); err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case e.And != nil:
+		return group(e.And, " AND ", "TRUE", columns, args)
+	case e.Or != nil:
+		return group(e.Or, " OR ", "FALSE", columns, args)
+	case e.IsZero():
+		return "TRUE", args, nil
+	}
+
+	column, ok := columns[e.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalid, e.Field)
+	}
+
+	bind := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch e.Op {
+	case OpEq:
+		return column + " = " + bind(e.Value), args, nil
+	case OpNe:
+		return column + " <> " + bind(e.Value), args, nil
+	case OpLt:
+		return column + " < " + bind(e.Value), args, nil
+	case OpLte:
+		return column + " <= " + bind(e.Value), args, nil
+	case OpGt:
+		return column + " > " + bind(e.Value), args, nil
+	case OpGte:
+		return column + " >= " + bind(e.Value), args, nil
+	case OpIsNull:
+		return column + " IS NULL", args, nil
+	case OpNotNull:
+		return column + " IS NOT NULL", args, nil
+	case OpILike:
+		return column + "::text ILIKE '%' || " + bind(escapeLike(fmt.Sprint(e.Value))) + " || '%'", args, nil
+	case OpIn, OpBetween:
+		values, err := e.values()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if e.Op == OpBetween {
+			return column + " BETWEEN " + bind(values[0]) + " AND " + bind(values[1]), args, nil
+		}
+
+		if len(values) == 0 {
+			return "FALSE", args, nil
+		}
+
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			placeholders[i] = bind(value)
+		}
+
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")", args, nil
+	}
+
+	return "", nil, fmt.Errorf("%w: unknown operator %q", ErrInvalid, e.Op)
+}
+
+func group(exprs []Expr, sep, empty string, columns Columns, args []interface{}) (string, []interface{}, error) {
+
+	if len(exprs) == 0 {
+		return empty, args, nil
+	}
+
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		var (
+			part string
+			err  error
+		)
+
+		part, args, err = ToSQL(expr, columns, args)
+		if err != nil {
+			return "", nil, err
+		}
+
+		parts[i] = part
+	}
+
+	return "(" + strings.Join(parts, sep) + ")", args, nil
+}
+
+// escapeLike makes %, _ and \ match literally inside an ILIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testColumns = Columns{
+	"title": `p."title"`,
+	"price": `p."price"`,
+}
+
+func TestToSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+		sql  string
+		args []interface{}
+	}{
+		{"zero", Expr{}, "TRUE", nil},
+		{"eq", Eq("title", "cola"), `p."title" = $1`, []interface{}{"cola"}},
+		{"ne", Ne("title", "cola"), `p."title" <> $1`, []interface{}{"cola"}},
+		{"lt", Lt("price", 10), `p."price" < $1`, []interface{}{10}},
+		{"lte", Lte("price", 10), `p."price" <= $1`, []interface{}{10}},
+		{"gt", Gt("price", 10), `p."price" > $1`, []interface{}{10}},
+		{"gte", Gte("price", 10), `p."price" >= $1`, []interface{}{10}},
+		{"is null", IsNull("title"), `p."title" IS NULL`, nil},
+		{"not null", NotNull("title"), `p."title" IS NOT NULL`, nil},
+		{"ilike", ILike("title", "cola"), `p."title"::text ILIKE '%' || $1 || '%'`, []interface{}{"cola"}},
+		{"ilike wildcards", ILike("title", `50%_off\`), `p."title"::text ILIKE '%' || $1 || '%'`, []interface{}{`50\%\_off\\`}},
+		{"in", In("price", []int{1, 2, 3}), `p."price" IN ($1, $2, $3)`, []interface{}{1, 2, 3}},
+		{"empty in", In("price", []int{}), "FALSE", nil},
+		{"between", Between("price", 1, 5), `p."price" BETWEEN $1 AND $2`, []interface{}{1, 5}},
+		{"empty and", Expr{And: []Expr{}}, "TRUE", nil},
+		{"empty or", Expr{Or: []Expr{}}, "FALSE", nil},
+		{
+			"nested groups",
+			And(Eq("title", "cola"), Or(Gt("price", 1), In("price", []interface{}{7, 8}))),
+			`(p."title" = $1 AND (p."price" > $2 OR p."price" IN ($3, $4)))`,
+			[]interface{}{"cola", 1, 7, 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := ToSQL(tt.expr, testColumns, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sql != tt.sql {
+				t.Fatalf("sql: want %s, got %s", tt.sql, sql)
+			}
+
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Fatalf("args: want %#v, got %#v", tt.args, args)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	sql, args, err := Where(And(Eq("title", "cola"), Between("price", 1, 5)), testColumns, []interface{}{"bound", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := ` WHERE (p."title" = $3 AND p."price" BETWEEN $4 AND $5)`; sql != want {
+		t.Fatalf("sql: want %s, got %s", want, sql)
+	}
+
+	if want := []interface{}{"bound", 2, "cola", 1, 5}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("args: want %#v, got %#v", want, args)
+	}
+}
+
+func TestToSQLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+	}{
+		{"unknown field", Eq("password", "x")},
+		{"unknown field in a group", Or(Eq("title", "cola"), Eq("password", "x"))},
+		{"unknown operator", Expr{Field: "title", Op: "like", Value: "x"}},
+		{"operator without a field", Expr{Op: OpEq, Value: "x"}},
+		{"field without an operator", Expr{Field: "title"}},
+		{"condition and group", Expr{Field: "title", Op: OpEq, Value: "x", And: []Expr{Eq("price", 1)}}},
+		{"in without a list", In("price", 1)},
+		{"between with one value", Expr{Field: "price", Op: OpBetween, Value: []interface{}{1}}},
+		{"object value", Eq("title", map[string]interface{}{"$ne": ""})},
+		{"nested list", In("price", []interface{}{1, []interface{}{2}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ToSQL(tt.expr, testColumns, nil); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("want ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Package geo holds the geometry of deliveries: points on the Earth and the
+// great-circle distance between them.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalid wraps every error about a point that cannot be stored.
+var ErrInvalid = errors.New("invalid location")
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0088
+
+// Point is a location in decimal degrees. The zero point stands for no
+// location, the way an empty id stands for no row.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// IsZero reports whether p is no location.
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lng == 0
+}
+
+// Validate checks a point before it is stored.
+func (p Point) Validate() error {
+
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("%w: latitude %v is not between -90 and 90", ErrInvalid, p.Lat)
+	}
+
+	if math.IsNaN(p.Lng) || p.Lng < -180 || p.Lng > 180 {
+		return fmt.Errorf("%w: longitude %v is not between -180 and 180", ErrInvalid, p.Lng)
+	}
+
+	return nil
+}
+
+// Distance returns the great-circle distance between a and b in
+// kilometers, by the haversine formula on a spherical Earth.
+func Distance(a, b Point) float64 {
+
+	var (
+		lat1 = radians(a.Lat)
+		lat2 = radians(b.Lat)
+		dLat = lat2 - lat1
+		dLng = radians(b.Lng - a.Lng)
+		h    = math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,47 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		km   float64
+	}{
+		{"same point", Point{41.3111, 69.2797}, Point{41.3111, 69.2797}, 0},
+		{"one degree of latitude", Point{0, 0}, Point{1, 0}, 111.195},
+		{"tashkent to samarkand", Point{41.2995, 69.2401}, Point{39.6270, 66.9750}, 267.0},
+		{"antipodes", Point{0, 0}, Point{0, 180}, 20015.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Distance(tt.a, tt.b)
+			if math.Abs(got-tt.km) > 0.1 {
+				t.Fatalf("want %v km, got %v", tt.km, got)
+			}
+
+			if back := Distance(tt.b, tt.a); math.Abs(back-got) > 1e-9 {
+				t.Fatalf("not symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range []Point{{}, {90, 180}, {-90, -180}, {41.3, 69.2}} {
+		if err := p.Validate(); err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		}
+	}
+
+	for _, p := range []Point{{91, 0}, {0, -181}, {math.NaN(), 0}} {
+		if err := p.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%+v: want ErrInvalid, got %v", p, err)
+		}
+	}
+}
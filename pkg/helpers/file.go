@@ -3,6 +3,7 @@ package helpers
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 )
 
 func Read(path string) ([]interface{}, error) {
@@ -22,6 +23,9 @@ func Read(path string) ([]interface{}, error) {
 	return response, nil
 }
 
+// Write replaces the file at path atomically: the data goes to a temporary
+// file in the same directory, which is synced and renamed over path, so
+// readers and crashes see either the old or the new content.
 func Write(path string, data []interface{}) error {
 
 	body, err := json.MarshalIndent(data, "", "  ")
@@ -29,10 +33,30 @@ func Write(path string, data []interface{}) error {
 		return err
 	}
 
-	err = os.WriteFile(path, body, os.ModePerm)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
 
-	return err
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,87 @@
+// Package ledger is the stock movement ledger: the types of movement and
+// the rules for the ones entered by hand. Every change to the quantity a
+// branch holds of a product is one movement, so the stock at any point in
+// time is the sum of the movements up to it.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid wraps every rejected movement.
+var ErrInvalid = errors.New("invalid stock movement")
+
+// Movement types.
+const (
+	// Receipt brings goods in from a supplier.
+	Receipt = "receipt"
+	// Sale takes out the goods of a finished order.
+	Sale = "sale"
+	// Return brings back the goods of a returned order.
+	Return = "return"
+	// TransferOut and TransferIn are the two sides of a transfer between
+	// branches.
+	TransferOut = "transfer-out"
+	TransferIn  = "transfer-in"
+	// WriteOff takes out damaged, expired or lost goods.
+	WriteOff = "write-off"
+	// Count corrects the quantity to what an inventory count found.
+	Count = "count"
+)
+
+// Types lists every movement type.
+var Types = []string{Receipt, Sale, Return, TransferOut, TransferIn, WriteOff, Count}
+
+// Known reports whether movementType is a movement type.
+func Known(movementType string) bool {
+
+	for _, known := range Types {
+		if known == movementType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckAdjust checks a movement entered by hand. Receipts add stock,
+// write-offs take it away and counts go either way. Sales, returns and
+// transfers are written by orders and transfers only.
+func CheckAdjust(movementType string, quantity float64) error {
+
+	switch movementType {
+	case Receipt:
+		if quantity <= 0 {
+			return fmt.Errorf("%w: a receipt must add stock, got %v", ErrInvalid, quantity)
+		}
+	case WriteOff:
+		if quantity >= 0 {
+			return fmt.Errorf("%w: a write-off must take stock away, got %v", ErrInvalid, quantity)
+		}
+	case Count:
+		if quantity == 0 {
+			return fmt.Errorf("%w: a count must change the stock", ErrInvalid)
+		}
+	case Sale, Return, TransferOut, TransferIn:
+		return fmt.Errorf("%w: %s movements are not entered by hand", ErrInvalid, movementType)
+	default:
+		return fmt.Errorf("%w: unknown type '%s'", ErrInvalid, movementType)
+	}
+
+	return nil
+}
+
+// CheckTransfer checks one item of a transfer between branches.
+func CheckTransfer(from, to string, quantity float64) error {
+
+	if from == to {
+		return fmt.Errorf("%w: a transfer must go to another branch", ErrInvalid)
+	}
+
+	if quantity <= 0 {
+		return fmt.Errorf("%w: a transfer must move a positive quantity, got %v", ErrInvalid, quantity)
+	}
+
+	return nil
+}
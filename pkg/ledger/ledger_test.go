@@ -0,0 +1,50 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckAdjust(t *testing.T) {
+	tests := []struct {
+		movementType string
+		quantity     float64
+		ok           bool
+	}{
+		{Receipt, 5, true},
+		{Receipt, -5, false},
+		{WriteOff, -2, true},
+		{WriteOff, 2, false},
+		{Count, 3, true},
+		{Count, -3, true},
+		{Count, 0, false},
+		{Sale, -1, false},
+		{TransferIn, 1, false},
+		{"gift", 1, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckAdjust(tt.movementType, tt.quantity)
+		if tt.ok && err != nil {
+			t.Fatalf("%s %v: %v", tt.movementType, tt.quantity, err)
+		}
+
+		if !tt.ok && !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%s %v: want ErrInvalid, got %v", tt.movementType, tt.quantity, err)
+		}
+	}
+}
+
+func TestCheckTransfer(t *testing.T) {
+	if err := CheckTransfer("a", "b", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckTransfer("a", "a", 1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("same branch: want ErrInvalid, got %v", err)
+	}
+
+	if err := CheckTransfer("a", "b", 0); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("zero quantity: want ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+// Package money is an exact amount of money. An Amount is a whole number
+// of minor units, cents, so sums and totals never drift the way float64
+// prices do. Amounts read and write JSON as plain decimal numbers such as
+// 12.34, and scan from and store into NUMERIC columns.
+//
+// Anything that can produce fractions of a cent, such as a quantity or a
+// percent, takes a Rounding mode, so every rounding in the system is
+// spelled out where it happens.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is money in cents: Amount(1234) is 12.34.
+type Amount int64
+
+// Digits is the number of decimal places of an Amount.
+const Digits = 2
+
+const scale = 100
+
+// ErrInvalid wraps every error about text that is not an amount.
+var ErrInvalid = errors.New("invalid amount")
+
+// Rounding says which way a fraction of a cent goes.
+type Rounding int
+
+const (
+	// HalfUp rounds to the nearest cent and halves away from zero, the
+	// way postgres ROUND does.
+	HalfUp Rounding = iota
+	// HalfEven rounds to the nearest cent and halves to the even cent.
+	HalfEven
+	// Down drops the fraction, rounding toward zero.
+	Down
+	// Up rounds away from zero.
+	Up
+)
+
+// Units returns n whole units of the currency.
+func Units(n int64) Amount {
+	return Amount(n * scale)
+}
+
+// Parse reads a decimal such as "12.34", "-5" or "0.5". More than Digits
+// decimal places are an error unless they are zeros, so no input is
+// rounded silently.
+func Parse(s string) (Amount, error) {
+
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+
+	r.Mul(r, big.NewRat(scale, 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w: %s has more than %d decimal places", ErrInvalid, s, Digits)
+	}
+
+	return fromInt(r.Num())
+}
+
+// ParseRound reads a decimal like Parse and rounds it to cents with mode.
+func ParseRound(s string, mode Rounding) (Amount, error) {
+
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return round(r.Mul(r, big.NewRat(scale, 1)), mode)
+}
+
+// FromFloat converts f, read as the shortest decimal that prints as f, and
+// rounds it to cents with mode. It is meant for values that arrive as
+// float64, such as a query filter; money itself never is one.
+func FromFloat(f float64, mode Rounding) (Amount, error) {
+	return ParseRound(strconv.FormatFloat(f, 'f', -1, 64), mode)
+}
+
+// String returns the amount with Digits decimal places, such as "12.30".
+func (a Amount) String() string {
+
+	var (
+		sign  string
+		cents = int64(a)
+	)
+
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/scale, cents%scale)
+}
+
+// Float64 returns the amount as a float64, for comparisons that need a
+// number and for display only.
+func (a Amount) Float64() float64 {
+	return float64(a) / scale
+}
+
+// Mul returns the amount times quantity, rounded to cents with mode. The
+// quantity is read as the shortest decimal that prints as it, so 0.3 is
+// exactly three tenths.
+func (a Amount) Mul(quantity float64, mode Rounding) Amount {
+
+	q, err := parseRat(strconv.FormatFloat(quantity, 'f', -1, 64))
+	if err != nil {
+		// Only NaN and the infinities get here; they price as nothing.
+		return 0
+	}
+
+	product, err := round(q.Mul(q, big.NewRat(int64(a), 1)), mode)
+	if err != nil {
+		return 0
+	}
+
+	return product
+}
+
+// Percent returns percent of the amount, rounded to cents with mode.
+// percent is an Amount as well, so 12.5 percent is Amount(1250).
+func (a Amount) Percent(percent Amount, mode Rounding) Amount {
+
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(percent)))
+
+	share, err := round(new(big.Rat).SetFrac(product, big.NewInt(100*scale)), mode)
+	if err != nil {
+		return 0
+	}
+
+	return share
+}
+
+// Share returns part/whole of the amount, rounded to cents with mode. It
+// returns zero when whole is zero.
+func (a Amount) Share(part, whole Amount, mode Rounding) Amount {
+
+	if whole == 0 {
+		return 0
+	}
+
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(part)))
+
+	share, err := round(new(big.Rat).SetFrac(product, big.NewInt(int64(whole))), mode)
+	if err != nil {
+		return 0
+	}
+
+	return share
+}
+
+// Min returns the smaller of a and b.
+func Min(a, b Amount) Amount {
+
+	if b < a {
+		return b
+	}
+
+	return a
+}
+
+// MarshalJSON writes the amount as a JSON number with Digits decimal
+// places.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a string holding one, exactly as
+// Parse does. null leaves the amount as it is.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+
+	amount, err := Parse(strings.Trim(text, `"`))
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Scan reads a NUMERIC column, rounding it to cents half up. NULL is zero.
+func (a *Amount) Scan(src interface{}) error {
+
+	var (
+		amount Amount
+		err    error
+	)
+
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		amount, err = ParseRound(string(v), HalfUp)
+	case string:
+		amount, err = ParseRound(v, HalfUp)
+	case int64:
+		amount = Units(v)
+	case float64:
+		amount, err = FromFloat(v, HalfUp)
+	default:
+		err = fmt.Errorf("%w: cannot scan %T", ErrInvalid, src)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Value stores the amount as a decimal string, which NUMERIC takes
+// exactly.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// parseRat reads an optionally signed decimal without an exponent.
+func parseRat(s string) (*big.Rat, error) {
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, fmt.Errorf("%w: %q is not a decimal number", ErrInvalid, s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a decimal number", ErrInvalid, s)
+	}
+
+	return r, nil
+}
+
+// round rounds r, in cents, to a whole number of cents with mode.
+func round(r *big.Rat, mode Rounding) (Amount, error) {
+
+	var (
+		num       = r.Num()
+		den       = r.Denom()
+		quotient  = new(big.Int)
+		remainder = new(big.Int)
+	)
+
+	quotient.QuoRem(num, den, remainder)
+
+	if remainder.Sign() != 0 {
+		twice := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1)
+
+		var away bool
+		switch mode {
+		case HalfUp:
+			away = twice.Cmp(den) >= 0
+		case HalfEven:
+			half := twice.Cmp(den)
+			away = half > 0 || half == 0 && quotient.Bit(0) == 1
+		case Up:
+			away = true
+		case Down:
+		default:
+			return 0, fmt.Errorf("%w: unknown rounding %d", ErrInvalid, mode)
+		}
+
+		if away {
+			quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+		}
+	}
+
+	return fromInt(quotient)
+}
+
+func fromInt(cents *big.Int) (Amount, error) {
+
+	if !cents.IsInt64() {
+		return 0, fmt.Errorf("%w: %s cents is out of range", ErrInvalid, cents)
+	}
+
+	return Amount(cents.Int64()), nil
+}
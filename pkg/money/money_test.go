@@ -0,0 +1,179 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Amount
+	}{
+		{"12.34", 1234},
+		{"12.3", 1230},
+		{"12", 1200},
+		{"-0.05", -5},
+		{"+7.10", 710},
+		{"0.500", 50},
+		{".5", 50},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.text)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.text, err)
+		}
+
+		if got != tt.want {
+			t.Fatalf("%s: want %d, got %d", tt.text, tt.want, got)
+		}
+	}
+
+	for _, text := range []string{"", "-", ".", "1.234", "1e3", "1/3", "12,5", "abc"} {
+		if _, err := Parse(text); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%q: want ErrInvalid, got %v", text, err)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		text string
+		mode Rounding
+		want Amount
+	}{
+		{"1.005", HalfUp, 101},
+		{"1.015", HalfEven, 102},
+		{"1.025", HalfEven, 102},
+		{"-1.005", HalfUp, -101},
+		{"1.009", Down, 100},
+		{"1.001", Up, 101},
+		{"-1.001", Up, -101},
+		{"1.0049", HalfUp, 100},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRound(tt.text, tt.mode)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.text, err)
+		}
+
+		if got != tt.want {
+			t.Fatalf("%s with %d: want %d, got %d", tt.text, tt.mode, tt.want, got)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for amount, want := range map[Amount]string{1234: "12.34", 5: "0.05", -120: "-1.20", 0: "0.00"} {
+		if got := amount.String(); got != want {
+			t.Fatalf("%d: want %s, got %s", amount, want, got)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		quantity float64
+		mode     Rounding
+		want     Amount
+	}{
+		{1000, 3, HalfUp, 3000},
+		{5, 0.3, HalfUp, 2},
+		{5, 0.3, Down, 1},
+		{10, 0.6, HalfUp, 6},
+		{999, 0.5, HalfEven, 500},
+		{333, 0.5, HalfEven, 166},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.Mul(tt.quantity, tt.mode); got != tt.want {
+			t.Fatalf("%s x %v: want %s, got %s", tt.amount, tt.quantity, tt.want, got)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	if got := Amount(999).Percent(Units(15), HalfUp); got != 150 {
+		t.Fatalf("15%% of 9.99: want 1.50, got %s", got)
+	}
+
+	if got := Units(200).Percent(1250, HalfUp); got != Units(25) {
+		t.Fatalf("12.5%% of 200: want 25.00, got %s", got)
+	}
+}
+
+func TestShare(t *testing.T) {
+	// The VAT in 112.00 at 12 percent is 112 * 12 / 112.
+	if got := Units(112).Share(Units(12), Units(112), HalfUp); got != Units(12) {
+		t.Fatalf("want 12.00, got %s", got)
+	}
+
+	if got := Units(10).Share(1, 3, HalfUp); got != 333 {
+		t.Fatalf("a third of 10.00: want 3.33, got %s", got)
+	}
+
+	if got := Units(10).Share(1, 0, HalfUp); got != 0 {
+		t.Fatalf("a share of nothing: want 0, got %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Price Amount `json:"price"`
+		Other Amount `json:"other"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"price": 0.1, "other": "2.50"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Price != 10 || v.Other != 250 {
+		t.Fatalf("want 10 and 250, got %d and %d", v.Price, v.Other)
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(body), `{"price":0.10,"other":2.50}`; got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"price": 0.105}`), &v); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("want ErrInvalid for a fraction of a cent, got %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Amount
+	}{
+		{[]byte("10000.0000"), Units(10000)},
+		{"8.495", 850},
+		{int64(7), 700},
+		{0.1, 10},
+		{nil, 0},
+	}
+
+	for _, tt := range tests {
+		var got Amount
+		if err := got.Scan(tt.src); err != nil {
+			t.Fatalf("%v: %v", tt.src, err)
+		}
+
+		if got != tt.want {
+			t.Fatalf("%v: want %s, got %s", tt.src, tt.want, got)
+		}
+	}
+
+	value, err := Amount(-1205).Value()
+	if err != nil || value != "-12.05" {
+		t.Fatalf("want -12.05, got %v (%v)", value, err)
+	}
+}
@@ -0,0 +1,256 @@
+// Package pricing holds the order math: line sums with their discounts and
+// order totals with an order discount and delivery. Storage backends persist what it computes, so
+// the rules are the same everywhere and testable without a database. Money
+// is a money.Amount, so every rounding to cents is spelled out here.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"market_system/pkg/money"
+	"market_system/pkg/tax"
+)
+
+// ErrInvalid wraps every error about a line that cannot be priced.
+var ErrInvalid = errors.New("invalid order line")
+
+// Discount types of an order line. An empty type means no discount.
+const (
+	DiscountNone    = ""
+	DiscountFix     = "fix"
+	DiscountPercent = "percent"
+)
+
+// Line is what an order line is priced from. Price is the unit price, and
+// DiscountAmount is money off the unit price for DiscountFix or a share of
+// it in percent for DiscountPercent, so 12.5 percent is money.Amount(1250).
+// Tax is the rate of the line; the zero rate charges no tax. Share is the
+// part of the order discount the line bears, as Spread sets it: the tax of
+// the line is charged on its sum less the share.
+type Line struct {
+	Price          money.Amount
+	Quantity       float64
+	DiscountType   string
+	DiscountAmount money.Amount
+	Tax            tax.Rate
+	Share          money.Amount
+}
+
+// Totals of an order. Count is the summed quantity rounded to whole items,
+// the way the INT total_count column stores it. Discount is money off the
+// whole order, such as from a promo code: the shares of the lines. Tax is
+// the tax in all lines and TaxAdded the part of it charged on top of the
+// line sums, by exclusive rates; Total includes TaxAdded. Tax is charged on
+// what the customer pays, the line sums less the order discount.
+type Totals struct {
+	Count    float64
+	Subtotal money.Amount
+	Discount money.Amount
+	Tax      money.Amount
+	TaxAdded money.Amount
+	Delivery money.Amount
+	Total    money.Amount
+}
+
+// Sum returns the discounted unit price times the quantity, rounded half
+// up to cents. A percent discount is rounded on the unit price first, so the
+// unit price on a receipt is always a whole number of cents.
+func (l Line) Sum() (money.Amount, error) {
+
+	if err := l.validate(); err != nil {
+		return 0, err
+	}
+
+	price := l.Price
+	switch l.DiscountType {
+	case DiscountFix:
+		price -= l.DiscountAmount
+	case DiscountPercent:
+		price -= price.Percent(l.DiscountAmount, money.HalfUp)
+	}
+
+	return price.Mul(l.Quantity, money.HalfUp), nil
+}
+
+// Taxable returns the line sum less the share of the order discount, the
+// amount the tax of the line is charged on.
+func (l Line) Taxable() (money.Amount, error) {
+
+	sum, err := l.Sum()
+	if err != nil {
+		return 0, err
+	}
+
+	if l.Share < 0 || l.Share > sum {
+		return 0, fmt.Errorf("%w: discount share %v is not between 0 and the sum %v", ErrInvalid, l.Share, sum)
+	}
+
+	return sum - l.Share, nil
+}
+
+// Taxed returns the line sum together with the tax in its taxable amount,
+// or on top of it for an exclusive rate.
+func (l Line) Taxed() (sum, tax money.Amount, err error) {
+
+	if sum, err = l.Sum(); err != nil {
+		return 0, 0, err
+	}
+
+	taxable, err := l.Taxable()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := l.Tax.Validate(); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	_, tax = l.Tax.Split(taxable)
+	return sum, tax, nil
+}
+
+func (l Line) validate() error {
+
+	if l.Price < 0 {
+		return fmt.Errorf("%w: price %v is negative", ErrInvalid, l.Price)
+	}
+
+	if l.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity %v is not positive", ErrInvalid, l.Quantity)
+	}
+
+	switch l.DiscountType {
+	case DiscountNone:
+		return nil
+	case DiscountFix:
+		if l.DiscountAmount < 0 || l.DiscountAmount > l.Price {
+			return fmt.Errorf("%w: fix discount %v is not between 0 and the price %v", ErrInvalid, l.DiscountAmount, l.Price)
+		}
+	case DiscountPercent:
+		if l.DiscountAmount < 0 || l.DiscountAmount > money.Units(100) {
+			return fmt.Errorf("%w: percent discount %v is not between 0 and 100", ErrInvalid, l.DiscountAmount)
+		}
+	default:
+		return fmt.Errorf("%w: unknown discount type %q", ErrInvalid, l.DiscountType)
+	}
+
+	return nil
+}
+
+// Order totals the lines of an order. Delivery is only charged when there
+// is something to deliver, so an order without lines costs nothing.
+func Order(lines []Line, delivery money.Amount) (Totals, error) {
+
+	if delivery < 0 {
+		return Totals{}, fmt.Errorf("%w: delivery price %v is negative", ErrInvalid, delivery)
+	}
+
+	var totals Totals
+	for _, line := range lines {
+		sum, tax, err := line.Taxed()
+		if err != nil {
+			return Totals{}, err
+		}
+
+		totals.Count += line.Quantity
+		totals.Subtotal += sum
+		totals.Discount += line.Share
+		totals.Tax += tax
+		totals.TaxAdded += line.Tax.Added(sum - line.Share)
+	}
+
+	if len(lines) > 0 {
+		totals.Delivery = delivery
+	}
+
+	totals.Count = math.Round(totals.Count)
+	totals.Total = totals.Subtotal - totals.Discount + totals.TaxAdded + totals.Delivery
+
+	return totals, nil
+}
+
+// Spread returns a copy of lines with discount shared out among the lines
+// eligible says it applies to, in proportion to their sums. The shares are
+// whole cents that add up to the discount, capped at the sum of those
+// lines, so no line costs less than nothing. Order then charges the tax on
+// what is left of every line.
+func Spread(lines []Line, discount money.Amount, eligible func(i int) bool) ([]Line, error) {
+
+	if discount < 0 {
+		return nil, fmt.Errorf("%w: order discount %v is negative", ErrInvalid, discount)
+	}
+
+	var (
+		spread = make([]Line, len(lines))
+		sums   = make([]money.Amount, len(lines))
+		total  money.Amount
+	)
+
+	for i, line := range lines {
+		sum, err := line.Sum()
+		if err != nil {
+			return nil, err
+		}
+
+		spread[i] = line
+		spread[i].Share = 0
+
+		if eligible(i) {
+			sums[i] = sum
+			total += sum
+		}
+	}
+
+	if total == 0 {
+		return spread, nil
+	}
+
+	// Every share is the rounded discount of the lines so far less that of
+	// the lines before, so the rounding never adds up past the discount.
+	var (
+		discounted = money.Min(discount, total)
+		running    money.Amount
+		given      money.Amount
+	)
+
+	for i, sum := range sums {
+		if sum == 0 {
+			continue
+		}
+
+		running += sum
+		share := discounted.Share(running, total, money.HalfUp) - given
+		spread[i].Share = share
+		given += share
+	}
+
+	return spread, nil
+}
+
+// Goods returns what the goods of the order cost: the total without the
+// delivery.
+func (t Totals) Goods() money.Amount {
+	return t.Total - t.Delivery
+}
+
+// Delivered returns the totals with delivery charged at fee instead, for a
+// fee that depends on the goods, such as one that is free above an order
+// total. Like Order, it charges nothing for an order without lines.
+func (t Totals) Delivered(fee money.Amount, lines int) (Totals, error) {
+
+	if fee < 0 {
+		return Totals{}, fmt.Errorf("%w: delivery price %v is negative", ErrInvalid, fee)
+	}
+
+	goods := t.Goods()
+
+	t.Delivery = 0
+	if lines > 0 {
+		t.Delivery = fee
+	}
+
+	t.Total = goods + t.Delivery
+	return t, nil
+}
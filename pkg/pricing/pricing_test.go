@@ -0,0 +1,218 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+
+	"market_system/pkg/money"
+	"market_system/pkg/tax"
+)
+
+func TestLineSum(t *testing.T) {
+	tests := []struct {
+		name string
+		line Line
+		want money.Amount
+	}{
+		{"no discount", Line{Price: money.Units(10000), Quantity: 2}, money.Units(20000)},
+		{"fix", Line{Price: money.Units(2500), Quantity: 4, DiscountType: DiscountFix, DiscountAmount: money.Units(500)}, money.Units(8000)},
+		{"fix down to zero", Line{Price: money.Units(2500), Quantity: 1, DiscountType: DiscountFix, DiscountAmount: money.Units(2500)}, 0},
+		{"percent charges the discounted price", Line{Price: money.Units(10000), Quantity: 3, DiscountType: DiscountPercent, DiscountAmount: money.Units(10)}, money.Units(27000)},
+		{"percent rounds to cents", Line{Price: 999, Quantity: 1, DiscountType: DiscountPercent, DiscountAmount: money.Units(15)}, 849},
+		{"percent rounds the unit price", Line{Price: 999, Quantity: 3, DiscountType: DiscountPercent, DiscountAmount: money.Units(15)}, 2547},
+		{"fractional quantity", Line{Price: money.Units(1200), Quantity: 0.5}, money.Units(600)},
+		{"a third of a kilo", Line{Price: 10, Quantity: 0.3}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.line.Sum()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLineSumInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		line Line
+	}{
+		{"negative price", Line{Price: -1, Quantity: 1}},
+		{"zero quantity", Line{Price: 100, Quantity: 0}},
+		{"fix above the price", Line{Price: 100, Quantity: 1, DiscountType: DiscountFix, DiscountAmount: 101}},
+		{"negative fix", Line{Price: 100, Quantity: 1, DiscountType: DiscountFix, DiscountAmount: -1}},
+		{"percent above 100", Line{Price: 100, Quantity: 1, DiscountType: DiscountPercent, DiscountAmount: money.Units(100) + 1}},
+		{"unknown type", Line{Price: 100, Quantity: 1, DiscountType: "coupon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.line.Sum(); !errors.Is(err, ErrInvalid) {
+				t.Fatalf("want ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	lines := []Line{
+		{Price: money.Units(10000), Quantity: 2},
+		{Price: money.Units(2500), Quantity: 4, DiscountType: DiscountFix, DiscountAmount: money.Units(500)},
+		{Price: 10, Quantity: 0.6},
+	}
+
+	got, err := Order(lines, money.Units(10000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Totals{Count: 7, Subtotal: 2800006, Delivery: money.Units(10000), Total: 3800006}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestOrderTax(t *testing.T) {
+	lines := []Line{
+		{Price: money.Units(112), Quantity: 1, Tax: tax.Rate{Percent: money.Units(12), Inclusive: true}},
+		{Price: money.Units(20), Quantity: 2, Tax: tax.Rate{Percent: money.Units(5)}},
+		{Price: money.Units(10), Quantity: 1},
+	}
+
+	got, err := Order(lines, money.Units(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Totals{Count: 4, Subtotal: money.Units(162), Tax: money.Units(14), TaxAdded: money.Units(2), Delivery: money.Units(50), Total: money.Units(214)}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+
+	// 16.20 off the whole order takes a tenth off every line, and off the
+	// tax in it.
+	discounted, err := Spread(lines, 1620, func(int) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = Order(discounted, money.Units(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want = Totals{Count: 4, Subtotal: money.Units(162), Discount: 1620, Tax: 1260, TaxAdded: 180, Delivery: money.Units(50), Total: 19760}
+	if got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+
+	if _, err := Order([]Line{{Price: 100, Quantity: 1, Tax: tax.Rate{Percent: -1}}}, 0); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("negative tax: want ErrInvalid, got %v", err)
+	}
+}
+
+func TestOrderWithoutLines(t *testing.T) {
+	got, err := Order(nil, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != (Totals{}) {
+		t.Fatalf("an order without lines is free, got %+v", got)
+	}
+}
+
+func TestOrderInvalid(t *testing.T) {
+	if _, err := Order([]Line{{Price: 100, Quantity: -1}}, 0); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("invalid line: want ErrInvalid, got %v", err)
+	}
+
+	if _, err := Order(nil, -1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("negative delivery: want ErrInvalid, got %v", err)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	var (
+		lines = []Line{
+			{Price: money.Units(100), Quantity: 1},
+			{Price: money.Units(200), Quantity: 1},
+			{Price: money.Units(50), Quantity: 1},
+		}
+		eligible = func(i int) bool { return i < 2 }
+	)
+
+	got, err := Spread(lines, 1000, eligible)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got[0].Share != 333 || got[1].Share != 667 || got[2].Share != 0 {
+		t.Fatalf("want shares 3.33, 6.67 and 0, got %v, %v and %v", got[0].Share, got[1].Share, got[2].Share)
+	}
+
+	if lines[0].Share != 0 {
+		t.Fatalf("Spread changed the lines it was given: %+v", lines[0])
+	}
+
+	totals, err := Order(got, money.Units(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Totals{Count: 3, Subtotal: money.Units(350), Discount: 1000, Delivery: money.Units(50), Total: 39000}
+	if totals != want {
+		t.Fatalf("want %+v, got %+v", want, totals)
+	}
+
+	got, err = Spread(lines, money.Units(500), eligible)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got[0].Share != money.Units(100) || got[1].Share != money.Units(200) || got[2].Share != 0 {
+		t.Fatalf("a discount above the eligible lines is capped at their sums, got %+v", got)
+	}
+
+	if _, err := Spread(lines, -1, eligible); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("negative discount: want ErrInvalid, got %v", err)
+	}
+
+	if _, err := Order([]Line{{Price: 100, Quantity: 1, Share: 101}}, 0); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("share above the sum: want ErrInvalid, got %v", err)
+	}
+}
+
+func TestDelivered(t *testing.T) {
+	totals, err := Order([]Line{{Price: money.Units(40), Quantity: 1, Share: money.Units(5)}}, money.Units(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if totals.Goods() != money.Units(35) {
+		t.Fatalf("goods: want 35.00, got %s", totals.Goods())
+	}
+
+	got, err := totals.Delivered(money.Units(3), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Delivery != money.Units(3) || got.Total != money.Units(38) {
+		t.Fatalf("want delivery 3.00 and total 38.00, got %+v", got)
+	}
+
+	if got, _ := (Totals{}).Delivered(money.Units(3), 0); got != (Totals{}) {
+		t.Fatalf("an order without lines is free, got %+v", got)
+	}
+
+	if _, err := totals.Delivered(-1, 1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("negative delivery: want ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package promo holds the rules of promotions: codes that take a percent or
+// a fixed amount off an order. A promotion can be limited to a validity
+// window, to a number of uses in total and per client, to orders from a
+// minimum total and to some branches or categories. Storage backends apply
+// what it decides, so the rules are the same everywhere.
+package promo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"market_system/pkg/money"
+)
+
+// ErrInvalid wraps every error about a promotion that cannot be stored.
+var ErrInvalid = errors.New("invalid promotion")
+
+// ErrNotApplicable wraps every reason a code cannot be applied to an order.
+var ErrNotApplicable = errors.New("promo code does not apply")
+
+// Promotion types.
+const (
+	TypePercent = "percent"
+	TypeFix     = "fix"
+)
+
+// Rule is a promotion as far as its rules go. Zero StartsAt, EndsAt and
+// limits mean no bound, and empty Branches and Categories mean all. The
+// Value of a percent promotion is the percent, so 12.5 is money.Amount(1250).
+type Rule struct {
+	Code           string
+	Type           string
+	Value          money.Amount
+	StartsAt       time.Time
+	EndsAt         time.Time
+	UsageLimit     int
+	PerClientLimit int
+	MinOrderTotal  money.Amount
+	Branches       []string
+	Categories     []string
+}
+
+// Line is an order line as a promotion sees it: the category of its
+// product and its sum after the line discount.
+type Line struct {
+	Category string
+	Sum      money.Amount
+}
+
+// Normalize returns code the way it is stored and looked up, so codes are
+// matched regardless of case and surrounding spaces.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Validate checks a promotion before it is stored.
+func (r Rule) Validate() error {
+
+	if r.Code == "" || strings.ContainsAny(r.Code, " \t\n") {
+		return fmt.Errorf("%w: code '%s' must be one word", ErrInvalid, r.Code)
+	}
+
+	switch r.Type {
+	case TypePercent:
+		if r.Value <= 0 || r.Value > money.Units(100) {
+			return fmt.Errorf("%w: percent %v is not above 0 and up to 100", ErrInvalid, r.Value)
+		}
+	case TypeFix:
+		if r.Value <= 0 {
+			return fmt.Errorf("%w: fix value %v is not positive", ErrInvalid, r.Value)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type '%s'", ErrInvalid, r.Type)
+	}
+
+	if !r.StartsAt.IsZero() && !r.EndsAt.IsZero() && !r.EndsAt.After(r.StartsAt) {
+		return fmt.Errorf("%w: ends_at is not after starts_at", ErrInvalid)
+	}
+
+	if r.UsageLimit < 0 || r.PerClientLimit < 0 {
+		return fmt.Errorf("%w: usage limits cannot be negative", ErrInvalid)
+	}
+
+	if r.MinOrderTotal < 0 {
+		return fmt.Errorf("%w: min_order_total %v is negative", ErrInvalid, r.MinOrderTotal)
+	}
+
+	return nil
+}
+
+// Check reports whether the code can be applied at now, given how many
+// other orders use it already, in total and of the same client.
+func (r Rule) Check(now time.Time, uses, clientUses int) error {
+
+	if !r.StartsAt.IsZero() && now.Before(r.StartsAt) {
+		return fmt.Errorf("%w: %s is not valid before %s", ErrNotApplicable, r.Code, r.StartsAt.Format(time.RFC3339))
+	}
+
+	if !r.EndsAt.IsZero() && !now.Before(r.EndsAt) {
+		return fmt.Errorf("%w: %s expired at %s", ErrNotApplicable, r.Code, r.EndsAt.Format(time.RFC3339))
+	}
+
+	if r.UsageLimit > 0 && uses >= r.UsageLimit {
+		return fmt.Errorf("%w: %s is used up", ErrNotApplicable, r.Code)
+	}
+
+	if r.PerClientLimit > 0 && clientUses >= r.PerClientLimit {
+		return fmt.Errorf("%w: the client used %s %d times already", ErrNotApplicable, r.Code, clientUses)
+	}
+
+	return nil
+}
+
+// Discount returns the money taken off an order of branch with lines. It
+// applies to the lines of the promotion categories only: a percent of
+// their sum rounded half up to cents, or the fixed value up to their sum. An order
+// that does not qualify gets an ErrNotApplicable error.
+func (r Rule) Discount(branch string, lines []Line) (money.Amount, error) {
+
+	if len(r.Branches) > 0 && !contains(r.Branches, branch) {
+		return 0, fmt.Errorf("%w: %s is not valid in this branch", ErrNotApplicable, r.Code)
+	}
+
+	var total, eligible money.Amount
+	for _, line := range lines {
+		total += line.Sum
+		if r.Applies(line.Category) {
+			eligible += line.Sum
+		}
+	}
+
+	if total < r.MinOrderTotal {
+		return 0, fmt.Errorf("%w: %s needs an order total of %v", ErrNotApplicable, r.Code, r.MinOrderTotal)
+	}
+
+	if eligible <= 0 {
+		return 0, fmt.Errorf("%w: the order has nothing %s applies to", ErrNotApplicable, r.Code)
+	}
+
+	if r.Type == TypePercent {
+		return eligible.Percent(r.Value, money.HalfUp), nil
+	}
+
+	return money.Min(r.Value, eligible), nil
+}
+
+// Applies reports whether the discount applies to a line of category: a
+// promotion without categories applies to every line.
+func (r Rule) Applies(category string) bool {
+	return len(r.Categories) == 0 || contains(r.Categories, category)
+}
+
+func contains(ids []string, id string) bool {
+
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+
+	return false
+}
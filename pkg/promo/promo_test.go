@@ -0,0 +1,136 @@
+package promo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"market_system/pkg/money"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+	}{
+		{"percent", Rule{Code: "SPRING10", Type: TypePercent, Value: money.Units(10)}, true},
+		{"fix with a window", Rule{Code: "MINUS5", Type: TypeFix, Value: money.Units(5), StartsAt: start, EndsAt: start.AddDate(0, 1, 0)}, true},
+		{"no code", Rule{Type: TypeFix, Value: money.Units(5)}, false},
+		{"code with a space", Rule{Code: "TWO WORDS", Type: TypeFix, Value: money.Units(5)}, false},
+		{"percent above 100", Rule{Code: "ALL", Type: TypePercent, Value: money.Units(120)}, false},
+		{"zero value", Rule{Code: "NONE", Type: TypeFix}, false},
+		{"unknown type", Rule{Code: "GIFT", Type: "gift", Value: money.Units(1)}, false},
+		{"ends before it starts", Rule{Code: "BACK", Type: TypeFix, Value: money.Units(5), StartsAt: start, EndsAt: start}, false},
+		{"negative limit", Rule{Code: "LIMIT", Type: TypeFix, Value: money.Units(5), UsageLimit: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.ok && !errors.Is(err, ErrInvalid) {
+				t.Fatalf("want ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var (
+		start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		rule  = Rule{Code: "SPRING", Type: TypePercent, Value: money.Units(10), StartsAt: start, EndsAt: start.AddDate(0, 1, 0), UsageLimit: 100, PerClientLimit: 1}
+	)
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		uses       int
+		clientUses int
+		ok         bool
+	}{
+		{"in the window", start.AddDate(0, 0, 10), 99, 0, true},
+		{"before the window", start.Add(-time.Second), 0, 0, false},
+		{"at the end of the window", start.AddDate(0, 1, 0), 0, 0, false},
+		{"used up", start, 100, 0, false},
+		{"used up by the client", start, 1, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rule.Check(tt.now, tt.uses, tt.clientUses)
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.ok && !errors.Is(err, ErrNotApplicable) {
+				t.Fatalf("want ErrNotApplicable, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	lines := []Line{{Category: "drinks", Sum: money.Units(30)}, {Category: "food", Sum: 7050}}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want money.Amount
+	}{
+		{"percent of every line", Rule{Type: TypePercent, Value: money.Units(10)}, 1005},
+		{"percent rounds half up", Rule{Type: TypePercent, Value: 1250, Categories: []string{"food"}}, 881},
+		{"percent of one category", Rule{Type: TypePercent, Value: money.Units(15), Categories: []string{"drinks"}}, 450},
+		{"fix", Rule{Type: TypeFix, Value: money.Units(20)}, money.Units(20)},
+		{"fix up to the eligible sum", Rule{Type: TypeFix, Value: money.Units(50), Categories: []string{"drinks"}}, money.Units(30)},
+		{"in an allowed branch", Rule{Type: TypeFix, Value: money.Units(5), Branches: []string{"north", "south"}}, money.Units(5)},
+		{"at the minimum total", Rule{Type: TypeFix, Value: money.Units(5), MinOrderTotal: 10050}, money.Units(5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Discount("north", lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiscountNotApplicable(t *testing.T) {
+	lines := []Line{{Category: "drinks", Sum: money.Units(30)}}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		lines []Line
+	}{
+		{"other branch", Rule{Type: TypeFix, Value: money.Units(5), Branches: []string{"south"}}, lines},
+		{"below the minimum total", Rule{Type: TypeFix, Value: money.Units(5), MinOrderTotal: money.Units(50)}, lines},
+		{"no line of the categories", Rule{Type: TypePercent, Value: money.Units(10), Categories: []string{"food"}}, lines},
+		{"no lines", Rule{Type: TypeFix, Value: money.Units(5)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.rule.Discount("north", tt.lines); !errors.Is(err, ErrNotApplicable) {
+				t.Fatalf("want ErrNotApplicable, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("  spring10 "); got != "SPRING10" {
+		t.Fatalf("want SPRING10, got %s", got)
+	}
+}
@@ -0,0 +1,263 @@
+// Package schedule tells whether a branch is open from its opening hours:
+// shifts per weekday, holidays that replace the shifts of a date and the
+// timezone the clock times are in. Storage backends and handlers share it,
+// so a branch is open or closed the same way everywhere.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Branches name their timezone, which must load on hosts without a
+	// zoneinfo database too.
+	_ "time/tzdata"
+)
+
+// ErrInvalid wraps every error about opening hours that cannot be read.
+var ErrInvalid = errors.New("invalid opening hours")
+
+// dateLayout is how holidays name their date.
+const dateLayout = "2006-01-02"
+
+// searchDays bounds how far ahead NextOpen looks: a year of holidays and
+// the week after it.
+const searchDays = 366 + 7
+
+// Hours are the opening hours of one shift in minutes after midnight. The
+// shift is open from Start up to End, so a shift to 18:00 is closed at
+// 18:00. An End before Start is a shift past midnight, such as 22:00 to
+// 02:00, which ends the next day, and an End equal to Start is open round
+// the clock.
+type Hours struct {
+	Start int
+	End   int
+}
+
+// ParseHours reads opening hours written as "HH:MM" clock times. Hours
+// and minutes may be unpadded, as in "9:5".
+func ParseHours(start, end string) (Hours, error) {
+
+	from, err := parseClock(start)
+	if err != nil {
+		return Hours{}, err
+	}
+
+	to, err := parseClock(end)
+	if err != nil {
+		return Hours{}, err
+	}
+
+	return Hours{Start: from, End: to}, nil
+}
+
+func parseClock(value string) (int, error) {
+
+	hour, minute, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is not a HH:MM time", ErrInvalid, value)
+	}
+
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("%w: %q has no hour between 0 and 23", ErrInvalid, value)
+	}
+
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%w: %q has no minute between 0 and 59", ErrInvalid, value)
+	}
+
+	return h*60 + m, nil
+}
+
+// Schedule is when a branch is open. Week holds the shifts that start on
+// each weekday. A date in Holidays has its shifts instead of those of its
+// weekday, none for a date the branch is closed. Shifts past midnight of
+// the day before still end on a holiday.
+type Schedule struct {
+	Location *time.Location
+	Week     [7][]Hours
+	Holidays map[string][]Hours
+}
+
+// New returns an empty schedule in timezone, an IANA name such as
+// "Asia/Tashkent". An empty timezone is the local time of the server.
+func New(timezone string) (Schedule, error) {
+
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return Schedule{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalid, timezone)
+		}
+	}
+
+	return Schedule{Location: location, Holidays: make(map[string][]Hours)}, nil
+}
+
+// AddShift adds hours to the shifts that start on weekday.
+func (s *Schedule) AddShift(weekday time.Weekday, hours Hours) error {
+
+	if weekday < time.Sunday || weekday > time.Saturday {
+		return fmt.Errorf("%w: weekday %d is not between 0 (Sunday) and 6 (Saturday)", ErrInvalid, weekday)
+	}
+
+	s.Week[weekday] = append(s.Week[weekday], hours)
+	return nil
+}
+
+// AddHoliday makes date, written as 2006-01-02, a holiday with hours as
+// its shifts, or closed all day without hours.
+func (s *Schedule) AddHoliday(date string, hours ...Hours) error {
+
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return fmt.Errorf("%w: holiday date %q is not a 2006-01-02 date", ErrInvalid, date)
+	}
+
+	s.Holidays[date] = append(s.Holidays[date], hours...)
+	return nil
+}
+
+// Shift is a shift as it is written down: Open and Close are "HH:MM" clock
+// times on Weekday, from 0 for Sunday to 6 for Saturday.
+type Shift struct {
+	Weekday int
+	Open    string
+	Close   string
+}
+
+// Holiday is a holiday as it is written down: a 2006-01-02 Date, closed
+// all day without Open and Close.
+type Holiday struct {
+	Date  string
+	Open  string
+	Close string
+}
+
+// Parse returns the schedule in timezone with shifts, or without shifts
+// open from start to end every day, and holidays. Without shifts or daily
+// hours it never opens.
+func Parse(timezone, start, end string, shifts []Shift, holidays []Holiday) (Schedule, error) {
+
+	s, err := New(timezone)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	if len(shifts) == 0 && (start != "" || end != "") {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			shifts = append(shifts, Shift{Weekday: int(weekday), Open: start, Close: end})
+		}
+	}
+
+	for _, shift := range shifts {
+		hours, err := ParseHours(shift.Open, shift.Close)
+		if err != nil {
+			return Schedule{}, err
+		}
+
+		if err := s.AddShift(time.Weekday(shift.Weekday), hours); err != nil {
+			return Schedule{}, err
+		}
+	}
+
+	for _, holiday := range holidays {
+		if holiday.Open == "" && holiday.Close == "" {
+			if err := s.AddHoliday(holiday.Date); err != nil {
+				return Schedule{}, err
+			}
+
+			continue
+		}
+
+		hours, err := ParseHours(holiday.Open, holiday.Close)
+		if err != nil {
+			return Schedule{}, err
+		}
+
+		if err := s.AddHoliday(holiday.Date, hours); err != nil {
+			return Schedule{}, err
+		}
+	}
+
+	return s, nil
+}
+
+// shifts returns the open periods of the shifts that start on the date
+// of day, a midnight in the location of the schedule.
+func (s Schedule) shifts(day time.Time) [][2]time.Time {
+
+	hours, ok := s.Holidays[day.Format(dateLayout)]
+	if !ok {
+		hours = s.Week[day.Weekday()]
+	}
+
+	var (
+		periods [][2]time.Time
+		y, m, d = day.Date()
+	)
+
+	for _, h := range hours {
+		end := d
+		if h.End <= h.Start {
+			end++
+		}
+
+		periods = append(periods, [2]time.Time{
+			time.Date(y, m, d, h.Start/60, h.Start%60, 0, 0, s.Location),
+			time.Date(y, m, end, h.End/60, h.End%60, 0, 0, s.Location),
+		})
+	}
+
+	return periods
+}
+
+// midnight returns the start of the date of t in the location of the
+// schedule, days later.
+func (s Schedule) midnight(t time.Time, days int) time.Time {
+	y, m, d := t.In(s.Location).Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, s.Location)
+}
+
+// Open reports whether a shift covers t.
+func (s Schedule) Open(t time.Time) bool {
+
+	// A shift of the day before may run past midnight.
+	for days := -1; days <= 0; days++ {
+		for _, period := range s.shifts(s.midnight(t, days)) {
+			if !t.Before(period[0]) && t.Before(period[1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// NextOpen returns when the first shift that starts after t opens, in the
+// location of the schedule. It reports false for a schedule without such
+// a shift in the year ahead.
+func (s Schedule) NextOpen(t time.Time) (time.Time, bool) {
+
+	for days := 0; days <= searchDays; days++ {
+		var (
+			next  time.Time
+			found bool
+		)
+
+		for _, period := range s.shifts(s.midnight(t, days)) {
+			if period[0].After(t) && (!found || period[0].Before(next)) {
+				next, found = period[0], true
+			}
+		}
+
+		if found {
+			return next, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,219 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseHours(t *testing.T) {
+	hours, err := ParseHours("9:5", "18:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Hours{Start: 545, End: 1080}); hours != want {
+		t.Fatalf("got %+v, want %+v", hours, want)
+	}
+
+	for _, value := range [][2]string{{"", "18:00"}, {"9", "18:00"}, {"24:00", "18:00"}, {"09:60", "18:00"}, {"09:00", "x:00"}} {
+		if _, err := ParseHours(value[0], value[1]); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%q: want ErrInvalid, got %v", value, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("Mars/Olympus_Mons"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("want ErrInvalid for an unknown timezone, got %v", err)
+	}
+
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddShift(7, Hours{}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("want ErrInvalid for weekday 7, got %v", err)
+	}
+
+	if err := s.AddHoliday("2024-13-01"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("want ErrInvalid for month 13, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	daily, err := Parse("Asia/Tashkent", "09:00", "18:00", nil, []Holiday{{Date: "2024-03-08"}, {Date: "2024-03-21", Open: "12:00", Close: "15:00"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for weekday, shifts := range daily.Week {
+		if len(shifts) != 1 || shifts[0] != (Hours{Start: 9 * 60, End: 18 * 60}) {
+			t.Fatalf("weekday %d: want the daily hours, got %+v", weekday, shifts)
+		}
+	}
+
+	if got := daily.Holidays["2024-03-08"]; len(got) != 0 {
+		t.Fatalf("closed holiday: want no shifts, got %+v", got)
+	}
+
+	if got := daily.Holidays["2024-03-21"]; len(got) != 1 || got[0] != (Hours{Start: 12 * 60, End: 15 * 60}) {
+		t.Fatalf("short holiday: got %+v", got)
+	}
+
+	shifts, err := Parse("Asia/Tashkent", "09:00", "18:00", []Shift{{Weekday: 6, Open: "10:00", Close: "14:00"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shifts.Week[time.Monday]) != 0 || len(shifts.Week[time.Saturday]) != 1 {
+		t.Fatalf("shifts replace the daily hours, got %+v", shifts.Week)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		shifts   []Shift
+		holidays []Holiday
+	}{
+		{"weekday", []Shift{{Weekday: 7, Open: "10:00", Close: "14:00"}}, nil},
+		{"shift hours", []Shift{{Weekday: 1, Open: "10", Close: "14:00"}}, nil},
+		{"holiday date", nil, []Holiday{{Date: "2024-13-01"}}},
+		{"holiday hours", nil, []Holiday{{Date: "2024-03-08", Open: "12:00"}}},
+	} {
+		if _, err := Parse("", "", "", tt.shifts, tt.holidays); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%s: want ErrInvalid, got %v", tt.name, err)
+		}
+	}
+}
+
+// tashkentWeek is open 09:00 to 18:00 on weekdays, 22:00 to 02:00 on
+// Friday night and closed on Sunday and on 2024-03-08.
+func tashkentWeek(t *testing.T) Schedule {
+	t.Helper()
+
+	s, err := New("Asia/Tashkent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		if err := s.AddShift(weekday, Hours{Start: 9 * 60, End: 18 * 60}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.AddShift(time.Friday, Hours{Start: 22 * 60, End: 2 * 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddShift(time.Saturday, Hours{Start: 10 * 60, End: 14 * 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddHoliday("2024-03-08"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddHoliday("2024-03-21", Hours{Start: 12 * 60, End: 15 * 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestOpen(t *testing.T) {
+	s := tashkentWeek(t)
+
+	tests := []struct {
+		name string
+		at   string
+		open bool
+	}{
+		{"before opening", "2024-03-04T08:59:00+05:00", false},
+		{"at opening", "2024-03-04T09:00:00+05:00", true},
+		{"unpadded morning", "2024-03-04T09:05:00+05:00", true},
+		{"last minute", "2024-03-04T17:59:00+05:00", true},
+		{"at closing", "2024-03-04T18:00:00+05:00", false},
+		{"in another timezone", "2024-03-04T04:30:00Z", true},
+		{"friday night", "2024-03-01T23:30:00+05:00", true},
+		{"past midnight", "2024-03-02T01:59:00+05:00", true},
+		{"end of the night shift", "2024-03-02T02:00:00+05:00", false},
+		{"sunday", "2024-03-03T12:00:00+05:00", false},
+		{"closed holiday", "2024-03-08T12:00:00+05:00", false},
+		{"night shift of a closed holiday", "2024-03-08T23:00:00+05:00", false},
+		{"short holiday", "2024-03-21T14:00:00+05:00", true},
+		{"short holiday morning", "2024-03-21T10:00:00+05:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := s.Open(at); got != tt.open {
+				t.Fatalf("at %s: got %v, want %v", tt.at, got, tt.open)
+			}
+		})
+	}
+}
+
+func TestOpenRoundTheClock(t *testing.T) {
+	s, err := Parse("UTC", "00:00", "00:00", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, at := range []string{"2024-03-04T00:00:00Z", "2024-03-04T12:00:00Z", "2024-03-04T23:59:59Z"} {
+		at, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !s.Open(at) {
+			t.Fatalf("closed at %s", at)
+		}
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	s := tashkentWeek(t)
+
+	tests := []struct {
+		name string
+		at   string
+		next string
+	}{
+		{"later today", "2024-03-04T07:00:00+05:00", "2024-03-04T09:00:00+05:00"},
+		{"tomorrow", "2024-03-04T19:00:00+05:00", "2024-03-05T09:00:00+05:00"},
+		{"night shift", "2024-03-01T19:00:00+05:00", "2024-03-01T22:00:00+05:00"},
+		{"over sunday", "2024-03-02T15:00:00+05:00", "2024-03-04T09:00:00+05:00"},
+		{"over a holiday", "2024-03-07T19:00:00+05:00", "2024-03-09T10:00:00+05:00"},
+		{"holiday hours", "2024-03-20T19:00:00+05:00", "2024-03-21T12:00:00+05:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, ok := s.NextOpen(at)
+			if !ok || next.Format(time.RFC3339) != tt.next {
+				t.Fatalf("at %s: got %s, %v, want %s", tt.at, next.Format(time.RFC3339), ok, tt.next)
+			}
+		})
+	}
+
+	closed, err := New("UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next, ok := closed.NextOpen(time.Now()); ok {
+		t.Fatalf("a schedule without shifts opens at %s", next)
+	}
+}
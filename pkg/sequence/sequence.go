@@ -0,0 +1,55 @@
+// Package sequence formats the human readable numbers of orders and
+// products, such as O-000123 or O-TAS-20240102-0042. The counting itself is
+// done by storage.SequenceRepoI.
+package sequence
+
+import (
+	"fmt"
+	"time"
+)
+
+// Format describes a number: Prefix, then the branch prefix when PerBranch
+// is set and the branch has one, then the date in the Date layout when it
+// is set, each followed by "-", then the counter padded with zeros to
+// Padding digits.
+type Format struct {
+	Prefix    string
+	Padding   int
+	Date      string
+	PerBranch bool
+}
+
+// Validate rejects formats that cannot give unique numbers.
+func (f Format) Validate() error {
+
+	if f.Padding < 0 || f.Padding > 18 {
+		return fmt.Errorf("sequence padding %d is not between 0 and 18", f.Padding)
+	}
+
+	if f.Date != "" && time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC).Format(f.Date) == f.Date {
+		return fmt.Errorf("sequence date layout %q has no date in it", f.Date)
+	}
+
+	return nil
+}
+
+// Key returns everything before the counter. Numbers with the same key
+// share one counter, so a new day or another branch starts again at 1.
+func (f Format) Key(branchPrefix string, at time.Time) string {
+
+	key := f.Prefix
+	if f.PerBranch && branchPrefix != "" {
+		key += branchPrefix + "-"
+	}
+
+	if f.Date != "" {
+		key += at.Format(f.Date) + "-"
+	}
+
+	return key
+}
+
+// Number appends the padded counter n to key.
+func (f Format) Number(key string, n int64) string {
+	return fmt.Sprintf("%s%0*d", key, f.Padding, n)
+}
@@ -0,0 +1,124 @@
+// Package tax holds the VAT math: how much of a line sum is tax under a
+// rate, and the breakdown of an order by rate. A rate is either included
+// in the prices it applies to or charged on top of them. Storage backends
+// persist what it computes, so the rules are the same everywhere.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"market_system/pkg/money"
+)
+
+// ErrInvalid wraps every error about a tax rate that cannot be stored.
+var ErrInvalid = errors.New("invalid tax rate")
+
+// Rate is a tax rate as far as the math goes. Percent is a money.Amount,
+// so 12.5 percent is money.Amount(1250). Inclusive means the prices it
+// applies to already hold the tax; otherwise the tax is added to them.
+type Rate struct {
+	Percent   money.Amount
+	Inclusive bool
+}
+
+// Line is an order line as the breakdown sees it: the id of its rate, the
+// rate itself and the line sum it was charged on.
+type Line struct {
+	RateId string
+	Rate   Rate
+	Sum    money.Amount
+}
+
+// Bucket is the tax of one rate over an order or a report. Base is the
+// amount without the tax and Tax the tax on it, so Base plus Tax is what
+// the customer paid for those lines.
+type Bucket struct {
+	RateId    string
+	Percent   money.Amount
+	Inclusive bool
+	Base      money.Amount
+	Tax       money.Amount
+}
+
+// Validate checks a rate before it is stored.
+func (r Rate) Validate() error {
+
+	if r.Percent < 0 || r.Percent > money.Units(100) {
+		return fmt.Errorf("%w: percent %s is not between 0 and 100", ErrInvalid, r.Percent)
+	}
+
+	return nil
+}
+
+// Split returns the base and the tax of a line sum, each rounded half up
+// to cents. An inclusive rate takes the tax out of sum; an exclusive one
+// puts it on top, so the base is sum itself.
+func (r Rate) Split(sum money.Amount) (base, tax money.Amount) {
+
+	if r.Inclusive {
+		tax = sum.Share(r.Percent, money.Units(100)+r.Percent, money.HalfUp)
+		return sum - tax, tax
+	}
+
+	return sum, sum.Percent(r.Percent, money.HalfUp)
+}
+
+// Added returns the tax the customer pays on top of sum: the tax of an
+// exclusive rate, and nothing for an inclusive one.
+func (r Rate) Added(sum money.Amount) money.Amount {
+
+	if r.Inclusive {
+		return 0
+	}
+
+	_, tax := r.Split(sum)
+	return tax
+}
+
+// Summarize returns the breakdown of lines by rate, ordered by percent
+// and then rate id. Lines without a rate are left out. The tax of every
+// line is rounded on its own, so the buckets add up to the line taxes.
+func Summarize(lines []Line) []Bucket {
+
+	var (
+		buckets []Bucket
+		index   = make(map[Rate]map[string]int)
+	)
+
+	for _, line := range lines {
+		if line.RateId == "" {
+			continue
+		}
+
+		if index[line.Rate] == nil {
+			index[line.Rate] = make(map[string]int)
+		}
+
+		i, ok := index[line.Rate][line.RateId]
+		if !ok {
+			i = len(buckets)
+			index[line.Rate][line.RateId] = i
+			buckets = append(buckets, Bucket{RateId: line.RateId, Percent: line.Rate.Percent, Inclusive: line.Rate.Inclusive})
+		}
+
+		base, tax := line.Rate.Split(line.Sum)
+		buckets[i].Base += base
+		buckets[i].Tax += tax
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Percent != buckets[j].Percent {
+			return buckets[i].Percent < buckets[j].Percent
+		}
+
+		if buckets[i].RateId != buckets[j].RateId {
+			return buckets[i].RateId < buckets[j].RateId
+		}
+
+		return !buckets[i].Inclusive && buckets[j].Inclusive
+	})
+
+	return buckets
+}
@@ -0,0 +1,90 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"market_system/pkg/money"
+)
+
+func TestValidate(t *testing.T) {
+	for _, rate := range []Rate{{Percent: 0}, {Percent: money.Units(12)}, {Percent: money.Units(100), Inclusive: true}} {
+		if err := rate.Validate(); err != nil {
+			t.Fatalf("%+v: %v", rate, err)
+		}
+	}
+
+	for _, rate := range []Rate{{Percent: -1}, {Percent: money.Units(100) + 1}} {
+		if err := rate.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Fatalf("%+v: want ErrInvalid, got %v", rate, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		rate Rate
+		sum  money.Amount
+		base money.Amount
+		tax  money.Amount
+	}{
+		{"exclusive", Rate{Percent: money.Units(12)}, money.Units(100), money.Units(100), money.Units(12)},
+		{"inclusive", Rate{Percent: money.Units(12), Inclusive: true}, money.Units(112), money.Units(100), money.Units(12)},
+		{"inclusive rounds half up", Rate{Percent: money.Units(20), Inclusive: true}, 999, 832, 167},
+		{"exclusive rounds half up", Rate{Percent: 750}, 1010, 1010, 76},
+		{"zero rate", Rate{}, money.Units(50), money.Units(50), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, tax := tt.rate.Split(tt.sum)
+			if base != tt.base || tax != tt.tax {
+				t.Fatalf("want %s + %s, got %s + %s", tt.base, tt.tax, base, tax)
+			}
+		})
+	}
+}
+
+func TestAdded(t *testing.T) {
+	if got := (Rate{Percent: money.Units(12)}).Added(money.Units(100)); got != money.Units(12) {
+		t.Fatalf("exclusive: want 12.00, got %s", got)
+	}
+
+	if got := (Rate{Percent: money.Units(12), Inclusive: true}).Added(money.Units(112)); got != 0 {
+		t.Fatalf("inclusive: want nothing on top, got %s", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var (
+		standard = Rate{Percent: money.Units(12), Inclusive: true}
+		reduced  = Rate{Percent: money.Units(5)}
+	)
+
+	got := Summarize([]Line{
+		{RateId: "standard", Rate: standard, Sum: money.Units(112)},
+		{RateId: "reduced", Rate: reduced, Sum: money.Units(40)},
+		{Sum: money.Units(1000)},
+		{RateId: "standard", Rate: standard, Sum: money.Units(56)},
+	})
+
+	want := []Bucket{
+		{RateId: "reduced", Percent: money.Units(5), Base: money.Units(40), Tax: money.Units(2)},
+		{RateId: "standard", Percent: money.Units(12), Inclusive: true, Base: money.Units(150), Tax: money.Units(18)},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("want %d buckets, got %+v", len(want), got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("bucket %d: want %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if got := Summarize(nil); len(got) != 0 {
+		t.Fatalf("no lines: want no buckets, got %+v", got)
+	}
+}
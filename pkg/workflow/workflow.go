@@ -0,0 +1,202 @@
+// Package workflow is the order status state machine. The transitions are
+// data: where an order may go from each status, and the guard rules that
+// must hold on the way. Storage backends check every status change against
+// it and record the change in the order status history.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTransition wraps every rejected status change.
+var ErrTransition = errors.New("invalid status transition")
+
+// Order statuses.
+const (
+	StatusNew        = "new"
+	StatusConfirmed  = "confirmed"
+	StatusInProcess  = "in-process"
+	StatusPicking    = "picking"
+	StatusDelivering = "delivering"
+	StatusDelivered  = "delivered"
+	StatusFinished   = "finished"
+	StatusCanceled   = "canceled"
+	StatusReturned   = "returned"
+)
+
+// Input is what the guards of a transition look at.
+type Input struct {
+	From   string
+	To     string
+	Reason string
+	// Items is the total_count of the order.
+	Items float64
+}
+
+// Guard is a named rule a transition must pass.
+type Guard struct {
+	Name  string
+	Check func(in Input) bool
+}
+
+// Transition moves an order from any of From, or from any status when From
+// is empty, to To.
+type Transition struct {
+	Name   string
+	From   []string
+	To     string
+	Guards []Guard
+}
+
+// Workflow is a state machine. Stages lists the regular path of an order
+// in order, which Before compares against; statuses off the path, such as
+// canceled, come after every stage. Closed lists the statuses of orders
+// that are done with: their lines no longer change or hold stock.
+type Workflow struct {
+	Stages      []string
+	Closed      []string
+	Transitions []Transition
+}
+
+// Before passes while the order has not reached status yet.
+func (w Workflow) Before(status string) Guard {
+	return Guard{
+		Name: "before " + status,
+		Check: func(in Input) bool {
+			return w.stage(in.From) < w.stage(status)
+		},
+	}
+}
+
+// HasItems passes when the order has at least one item.
+var HasItems = Guard{
+	Name: "has items",
+	Check: func(in Input) bool {
+		return in.Items > 0
+	},
+}
+
+// HasReason passes when the change is explained.
+var HasReason = Guard{
+	Name: "has a reason",
+	Check: func(in Input) bool {
+		return in.Reason != ""
+	},
+}
+
+// Default is the order workflow. Orders are picked and delivered, or
+// finished right after processing when they are collected at the branch.
+// They can be canceled until they go out for delivery, and returned once
+// delivered.
+var Default = newDefault()
+
+func newDefault() Workflow {
+	w := Workflow{
+		Stages: []string{
+			StatusNew,
+			StatusConfirmed,
+			StatusInProcess,
+			StatusPicking,
+			StatusDelivering,
+			StatusDelivered,
+			StatusFinished,
+		},
+		Closed: []string{StatusFinished, StatusCanceled, StatusReturned},
+	}
+
+	w.Transitions = []Transition{
+		{Name: "confirm", From: []string{StatusNew}, To: StatusConfirmed, Guards: []Guard{HasItems}},
+		{Name: "process", From: []string{StatusNew, StatusConfirmed}, To: StatusInProcess},
+		{Name: "pick", From: []string{StatusInProcess}, To: StatusPicking},
+		{Name: "ship", From: []string{StatusPicking}, To: StatusDelivering},
+		{Name: "deliver", From: []string{StatusDelivering}, To: StatusDelivered},
+		{Name: "finish", From: []string{StatusInProcess, StatusDelivered}, To: StatusFinished},
+		{Name: "cancel", To: StatusCanceled, Guards: []Guard{w.Before(StatusDelivering)}},
+		{Name: "return", From: []string{StatusDelivered, StatusFinished}, To: StatusReturned, Guards: []Guard{HasReason}},
+	}
+
+	return w
+}
+
+// Check returns the transition from in.From to in.To, or an error wrapping
+// ErrTransition when there is none or one of its guards fails.
+func (w Workflow) Check(in Input) (Transition, error) {
+
+	if !w.known(in.To) {
+		return Transition{}, fmt.Errorf("%w: unknown status '%s'", ErrTransition, in.To)
+	}
+
+	for _, transition := range w.Transitions {
+		if transition.To != in.To || !transition.from(in.From) {
+			continue
+		}
+
+		for _, guard := range transition.Guards {
+			if !guard.Check(in) {
+				return Transition{}, fmt.Errorf("%w: %s from '%s' to '%s': rule %q does not hold", ErrTransition, transition.Name, in.From, in.To, guard.Name)
+			}
+		}
+
+		return transition, nil
+	}
+
+	return Transition{}, fmt.Errorf("%w: from '%s' to '%s'", ErrTransition, in.From, in.To)
+}
+
+// Open reports whether an order in status is still being worked on.
+func (w Workflow) Open(status string) bool {
+
+	for _, closed := range w.Closed {
+		if closed == status {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t Transition) from(status string) bool {
+
+	if len(t.From) == 0 {
+		return status != t.To
+	}
+
+	for _, from := range t.From {
+		if from == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stage returns the position of status on the regular path, or the length
+// of the path for statuses off it.
+func (w Workflow) stage(status string) int {
+
+	for i, stage := range w.Stages {
+		if stage == status {
+			return i
+		}
+	}
+
+	return len(w.Stages)
+}
+
+func (w Workflow) known(status string) bool {
+
+	for _, stage := range w.Stages {
+		if stage == status {
+			return true
+		}
+	}
+
+	for _, transition := range w.Transitions {
+		if transition.To == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		in   Input
+		want string
+		ok   bool
+	}{
+		{Input{From: StatusNew, To: StatusInProcess}, "process", true},
+		{Input{From: StatusNew, To: StatusConfirmed, Items: 2}, "confirm", true},
+		{Input{From: StatusNew, To: StatusConfirmed}, "", false},
+		{Input{From: StatusNew, To: StatusFinished}, "", false},
+		{Input{From: StatusInProcess, To: StatusPicking}, "pick", true},
+		{Input{From: StatusInProcess, To: StatusFinished}, "finish", true},
+		{Input{From: StatusPicking, To: StatusDelivering}, "ship", true},
+		{Input{From: StatusDelivering, To: StatusDelivered}, "deliver", true},
+		{Input{From: StatusDelivered, To: StatusFinished}, "finish", true},
+		{Input{From: StatusPicking, To: StatusCanceled}, "cancel", true},
+		{Input{From: StatusDelivering, To: StatusCanceled}, "", false},
+		{Input{From: StatusFinished, To: StatusCanceled}, "", false},
+		{Input{From: StatusCanceled, To: StatusCanceled}, "", false},
+		{Input{From: StatusDelivered, To: StatusReturned, Reason: "damaged"}, "return", true},
+		{Input{From: StatusDelivered, To: StatusReturned}, "", false},
+		{Input{From: StatusCanceled, To: StatusInProcess}, "", false},
+		{Input{From: StatusFinished, To: StatusNew}, "", false},
+		{Input{From: StatusNew, To: "lost"}, "", false},
+	}
+
+	for _, tt := range tests {
+		transition, err := Default.Check(tt.in)
+		if !tt.ok {
+			if !errors.Is(err, ErrTransition) {
+				t.Fatalf("%s -> %s: want ErrTransition, got %v", tt.in.From, tt.in.To, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s -> %s: %v", tt.in.From, tt.in.To, err)
+		}
+
+		if transition.Name != tt.want {
+			t.Fatalf("%s -> %s: want transition %s, got %s", tt.in.From, tt.in.To, tt.want, transition.Name)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for status, want := range map[string]bool{
+		StatusNew:        true,
+		StatusDelivering: true,
+		StatusDelivered:  true,
+		StatusFinished:   false,
+		StatusCanceled:   false,
+		StatusReturned:   false,
+	} {
+		if got := Default.Open(status); got != want {
+			t.Fatalf("%s: want open %v, got %v", status, want, got)
+		}
+	}
+}
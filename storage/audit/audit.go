@@ -0,0 +1,222 @@
+// Package audit records every write made through a storage.StorageI in its
+// audit log. New wraps a backend; each write then runs in a transaction
+// together with its entry, so a write is never stored without its trace.
+//
+// Entries hold JSON snapshots of the row before and after the write, read
+// inside that transaction, and the fields that changed between them.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+const (
+	EntityCategory       = "category"
+	EntityProduct        = "product"
+	EntityClient         = "client"
+	EntityBranch         = "branch"
+	EntityOrder          = "order"
+	EntityOrderProduct   = "order_product"
+	EntityStock          = "stock"
+	EntityStockTransfer  = "stock_transfer"
+	EntityPromotion      = "promotion"
+	EntityTaxRate        = "tax_rate"
+	EntityDeliveryTariff = "delivery_tariff"
+)
+
+// SystemActor is recorded for writes whose context names no actor, such as
+// the purge command.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor returns a context whose writes are recorded as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor set by WithActor, or SystemActor.
+func Actor(ctx context.Context) string {
+
+	actor, _ := ctx.Value(actorKey{}).(string)
+	if actor == "" {
+		return SystemActor
+	}
+
+	return actor
+}
+
+type Store struct {
+	storage.StorageI
+}
+
+func New(strg storage.StorageI) *Store {
+	return &Store{StorageI: strg}
+}
+
+// Tx runs fn in a transaction of the wrapped storage; writes made through
+// the tx passed to fn are audited as well.
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+	return s.StorageI.Tx(ctx, func(tx storage.StorageI) error {
+		return fn(New(tx))
+	})
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return &categoryRepo{CategoryRepoI: s.StorageI.Category(), strg: s.StorageI}
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return &productRepo{ProductRepoI: s.StorageI.Product(), strg: s.StorageI}
+}
+
+func (s *Store) Client() storage.ClientRepoI {
+	return &clientRepo{ClientRepoI: s.StorageI.Client(), strg: s.StorageI}
+}
+
+func (s *Store) Branch() storage.BranchRepoI {
+	return &branchRepo{BranchRepoI: s.StorageI.Branch(), strg: s.StorageI}
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+	return &orderRepo{OrderRepoI: s.StorageI.Order(), strg: s.StorageI}
+}
+
+func (s *Store) OrderProduct() storage.OrderProductRepoI {
+	return &orderProductRepo{OrderProductRepoI: s.StorageI.OrderProduct(), strg: s.StorageI}
+}
+
+func (s *Store) Stock() storage.StockRepoI {
+	return &stockRepo{StockRepoI: s.StorageI.Stock(), strg: s.StorageI}
+}
+
+func (s *Store) Promotion() storage.PromotionRepoI {
+	return &promotionRepo{PromotionRepoI: s.StorageI.Promotion(), strg: s.StorageI}
+}
+
+func (s *Store) TaxRate() storage.TaxRateRepoI {
+	return &taxRateRepo{TaxRateRepoI: s.StorageI.TaxRate(), strg: s.StorageI}
+}
+
+// record stores one entry. before and after are the row snapshots, nil when
+// there is no row on that side.
+func record(ctx context.Context, tx storage.StorageI, entity, id, action string, before, after interface{}) error {
+
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	diff, err := changes(beforeJSON, afterJSON)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Audit().Create(ctx, &models.CreateAuditEntry{
+		Entity:   entity,
+		EntityId: id,
+		Action:   action,
+		Actor:    Actor(ctx),
+		Before:   beforeJSON,
+		After:    afterJSON,
+		Diff:     diff,
+	})
+	return err
+}
+
+func snapshot(row interface{}) (json.RawMessage, error) {
+
+	if row == nil || reflect.ValueOf(row).IsNil() {
+		return nil, nil
+	}
+
+	return json.Marshal(row)
+}
+
+type change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// changes returns the fields that differ between two snapshots as
+// {"field": {"before": ..., "after": ...}}. It is empty unless both
+// snapshots are there.
+func changes(before, after json.RawMessage) (json.RawMessage, error) {
+
+	if before == nil || after == nil {
+		return nil, nil
+	}
+
+	var beforeFields, afterFields map[string]interface{}
+	if err := json.Unmarshal(before, &beforeFields); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(after, &afterFields); err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]change)
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			diff[field] = change{Before: value, After: afterFields[field]}
+		}
+	}
+
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			diff[field] = change{After: value}
+		}
+	}
+
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(diff)
+}
+
+// found turns sql.ErrNoRows into a nil error, for before snapshots of rows
+// that may not exist.
+func found(err error) error {
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
+
+// purged records a Purge, which removes rows by age rather than by id.
+func purged(ctx context.Context, tx storage.StorageI, entity string, deletedBefore time.Time, n int64) error {
+
+	if n == 0 {
+		return nil
+	}
+
+	return record(ctx, tx, entity, "", ActionPurge, nil, &struct {
+		DeletedBefore string `json:"deleted_before"`
+		Rows          int64  `json:"rows"`
+	}{deletedBefore.UTC().Format(time.RFC3339), n})
+}
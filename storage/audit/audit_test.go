@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/ledger"
+	"market_system/pkg/money"
+	"market_system/storage"
+	"market_system/storage/memory"
+	"market_system/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return New(memory.NewConnectionMemory())
+	})
+}
+
+func TestRecord(t *testing.T) {
+	var (
+		strg = New(memory.NewConnectionMemory())
+		ctx  = WithActor(context.Background(), "cashier-1")
+	)
+
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "drinks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product, err := strg.Product().Create(ctx, &models.CreateProduct{Title: "tea", Price: money.Units(5000), CategoryId: category.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = strg.Product().Update(ctx, &models.UpdateProduct{Id: product.Id, Title: "tea", Price: money.Units(6000), CategoryId: category.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := strg.Product().Delete(context.Background(), &models.ProductPrimaryKey{Id: product.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := strg.Audit().GetList(ctx, &models.GetListAuditRequest{Entity: EntityProduct, EntityId: product.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actions, actors []string
+	for _, entry := range resp.Entries {
+		actions = append(actions, entry.Action)
+		actors = append(actors, entry.Actor)
+	}
+
+	if got, want := actions, []string{ActionDelete, ActionUpdate, ActionCreate}; !equal(got, want) {
+		t.Fatalf("actions: want %v, got %v", want, got)
+	}
+
+	if got, want := actors, []string{SystemActor, "cashier-1", "cashier-1"}; !equal(got, want) {
+		t.Fatalf("actors: want %v, got %v", want, got)
+	}
+
+	var diff map[string]change
+	if err := json.Unmarshal(resp.Entries[1].Diff, &diff); err != nil {
+		t.Fatal(err)
+	}
+
+	price, ok := diff["price"]
+	if !ok || price.Before != 5000.0 || price.After != 6000.0 {
+		t.Fatalf("price diff: got %+v", diff["price"])
+	}
+
+	if _, ok := diff["title"]; ok {
+		t.Fatal("unchanged title is in the diff")
+	}
+}
+
+func TestStatusActor(t *testing.T) {
+	var (
+		strg = New(memory.NewConnectionMemory())
+		ctx  = WithActor(context.Background(), "courier-7")
+	)
+
+	client, err := strg.Client().Create(ctx, &models.CreateClient{FirstName: "Ali", DateOfBirth: "2000-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	branch, err := strg.Branch().Create(ctx, &models.CreateBranch{Name: "Chilonzor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := strg.Order().Create(ctx, &models.CreateOrder{OrderId: "O-1", ClientId: client.ID, BranchId: branch.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: "in-process"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := strg.Order().History(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Count != 1 || resp.History[0].Actor != "courier-7" {
+		t.Fatalf("history should name the actor of the context, got %+v", resp.History)
+	}
+}
+
+func TestStockActor(t *testing.T) {
+	var (
+		strg = New(memory.NewConnectionMemory())
+		ctx  = WithActor(context.Background(), "storekeeper-2")
+	)
+
+	branch, err := strg.Branch().Create(ctx, &models.CreateBranch{Name: "Chilonzor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "Drinks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product, err := strg.Product().Create(ctx, &models.CreateProduct{Title: "Water", CategoryId: category.Id, Price: money.Units(3000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = strg.Stock().Adjust(ctx, &models.AdjustStock{BranchId: branch.ID, ProductId: product.Id, Type: ledger.Receipt, Delta: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := strg.Stock().Movements(ctx, &models.GetListStockMovementRequest{BranchId: branch.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Count != 1 || resp.Movements[0].Actor != "storekeeper-2" {
+		t.Fatalf("the movement should name the actor of the context, got %+v", resp.Movements)
+	}
+}
+
+func equal(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type branchRepo struct {
+	storage.BranchRepoI
+	strg storage.StorageI
+}
+
+func (r *branchRepo) Create(ctx context.Context, req *models.CreateBranch) (*models.Branch, error) {
+	var resp *models.Branch
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Branch().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityBranch, resp.ID, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *branchRepo) Update(ctx context.Context, req *models.UpdateBranch) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.BranchPrimaryKey{ID: req.ID}
+
+		before, err := tx.Branch().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Branch().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Branch().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityBranch, req.ID, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *branchRepo) Delete(ctx context.Context, req *models.BranchPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Branch().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Branch().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityBranch, req.ID, ActionDelete, before, nil)
+	})
+}
+
+func (r *branchRepo) Restore(ctx context.Context, req *models.BranchPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.Branch().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Branch().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityBranch, req.ID, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *branchRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.Branch().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityBranch, deletedBefore, n)
+	})
+
+	return n, err
+}
+
+// SetDeliveryTariff records the tariff under the id of its branch, as
+// created the first time and updated after that.
+func (r *branchRepo) SetDeliveryTariff(ctx context.Context, req *models.DeliveryTariff) (*models.DeliveryTariff, error) {
+	var resp *models.DeliveryTariff
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Branch().GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: req.BranchId})
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if resp, err = tx.Branch().SetDeliveryTariff(ctx, req); err != nil {
+			return err
+		}
+
+		if before == nil {
+			return record(ctx, tx, EntityDeliveryTariff, req.BranchId, ActionCreate, nil, resp)
+		}
+
+		return record(ctx, tx, EntityDeliveryTariff, req.BranchId, ActionUpdate, before, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *branchRepo) DeleteDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Branch().GetDeliveryTariff(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Branch().DeleteDeliveryTariff(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityDeliveryTariff, req.ID, ActionDelete, before, nil)
+	})
+}
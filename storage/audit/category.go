@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type categoryRepo struct {
+	storage.CategoryRepoI
+	strg storage.StorageI
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error) {
+	var resp *models.Category
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Category().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityCategory, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.CategoryPrimaryKey{Id: req.Id}
+
+		before, err := tx.Category().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Category().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Category().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityCategory, req.Id, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Category().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Category().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityCategory, req.Id, ActionDelete, before, nil)
+	})
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.Category().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Category().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityCategory, req.Id, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.Category().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityCategory, deletedBefore, n)
+	})
+
+	return n, err
+}
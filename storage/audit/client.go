@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type clientRepo struct {
+	storage.ClientRepoI
+	strg storage.StorageI
+}
+
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (*models.Client, error) {
+	var resp *models.Client
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Client().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityClient, resp.ID, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.ClientPrimaryKey{ID: req.ID}
+
+		before, err := tx.Client().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Client().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Client().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityClient, req.ID, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Client().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Client().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityClient, req.ID, ActionDelete, before, nil)
+	})
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.Client().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Client().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityClient, req.ID, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *clientRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.Client().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityClient, deletedBefore, n)
+	})
+
+	return n, err
+}
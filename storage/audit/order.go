@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type orderRepo struct {
+	storage.OrderRepoI
+	strg storage.StorageI
+}
+
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (*models.Order, error) {
+	var resp *models.Order
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Order().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrder, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.OrderPrimaryKey{Id: req.Id}
+
+		before, err := tx.Order().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Order().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Order().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrder, req.Id, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Order().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Order().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrder, req.Id, ActionDelete, before, nil)
+	})
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.Order().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Order().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrder, req.Id, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.Order().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityOrder, deletedBefore, n)
+	})
+
+	return n, err
+}
+
+func (r *orderRepo) StatusUpdate(ctx context.Context, req models.CheckStatus) (models.Order, error) {
+	var resp models.Order
+
+	if req.Actor == "" {
+		req.Actor = Actor(ctx)
+	}
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: req.Id})
+		if err != nil {
+			return err
+		}
+
+		if resp, err = tx.Order().StatusUpdate(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrder, req.Id, ActionUpdate, before, &resp)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type orderProductRepo struct {
+	storage.OrderProductRepoI
+	strg storage.StorageI
+}
+
+func (r *orderProductRepo) Create(ctx context.Context, req *models.CreateOrderProduct) (*models.OrderProduct, error) {
+	var resp *models.OrderProduct
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.OrderProduct().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrderProduct, resp.OrderProductID, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *orderProductRepo) Update(ctx context.Context, req *models.UpdateOrderProduct) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.OrderProductPrimaryKey{OrderProductID: req.OrderProductID}
+
+		before, err := tx.OrderProduct().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.OrderProduct().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.OrderProduct().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrderProduct, req.OrderProductID, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderProductRepo) Delete(ctx context.Context, req *models.OrderProductPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.OrderProduct().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.OrderProduct().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrderProduct, req.OrderProductID, ActionDelete, before, nil)
+	})
+}
+
+func (r *orderProductRepo) Restore(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.OrderProduct().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.OrderProduct().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityOrderProduct, req.OrderProductID, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderProductRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.OrderProduct().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityOrderProduct, deletedBefore, n)
+	})
+
+	return n, err
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type productRepo struct {
+	storage.ProductRepoI
+	strg storage.StorageI
+}
+
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (*models.Product, error) {
+	var resp *models.Product
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Product().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityProduct, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.ProductPrimaryKey{Id: req.Id}
+
+		before, err := tx.Product().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Product().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Product().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityProduct, req.Id, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Product().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Product().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityProduct, req.Id, ActionDelete, before, nil)
+	})
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rowsAffected, err = tx.Product().Restore(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Product().GetByID(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityProduct, req.Id, ActionRestore, nil, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *productRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if n, err = tx.Product().Purge(ctx, deletedBefore); err != nil {
+			return err
+		}
+
+		return purged(ctx, tx, EntityProduct, deletedBefore, n)
+	})
+
+	return n, err
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type promotionRepo struct {
+	storage.PromotionRepoI
+	strg storage.StorageI
+}
+
+func (r *promotionRepo) Create(ctx context.Context, req *models.CreatePromotion) (*models.Promotion, error) {
+	var resp *models.Promotion
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Promotion().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityPromotion, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *promotionRepo) Update(ctx context.Context, req *models.UpdatePromotion) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.PromotionPrimaryKey{Id: req.Id}
+
+		before, err := tx.Promotion().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.Promotion().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.Promotion().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityPromotion, req.Id, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *promotionRepo) Delete(ctx context.Context, req *models.PromotionPrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.Promotion().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.Promotion().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityPromotion, req.Id, ActionDelete, before, nil)
+	})
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type stockRepo struct {
+	storage.StockRepoI
+	strg storage.StorageI
+}
+
+// Adjust is recorded under the branch and product ids joined by a slash.
+// The ledger movement it writes names the actor as well.
+func (r *stockRepo) Adjust(ctx context.Context, req *models.AdjustStock) (*models.Stock, error) {
+	var resp *models.Stock
+
+	adjust := *req
+	if adjust.Actor == "" {
+		adjust.Actor = Actor(ctx)
+	}
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.StockPrimaryKey{BranchId: req.BranchId, ProductId: req.ProductId}
+
+		before, err := tx.Stock().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if resp, err = tx.Stock().Adjust(ctx, &adjust); err != nil {
+			return err
+		}
+
+		action := ActionUpdate
+		if before == nil {
+			action = ActionCreate
+		}
+
+		return record(ctx, tx, EntityStock, req.BranchId+"/"+req.ProductId, action, before, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *stockRepo) Transfer(ctx context.Context, req *models.CreateStockTransfer) (*models.StockTransfer, error) {
+	var resp *models.StockTransfer
+
+	transfer := *req
+	if transfer.Actor == "" {
+		transfer.Actor = Actor(ctx)
+	}
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.Stock().Transfer(ctx, &transfer); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityStockTransfer, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type taxRateRepo struct {
+	storage.TaxRateRepoI
+	strg storage.StorageI
+}
+
+func (r *taxRateRepo) Create(ctx context.Context, req *models.CreateTaxRate) (*models.TaxRate, error) {
+	var resp *models.TaxRate
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		var err error
+		if resp, err = tx.TaxRate().Create(ctx, req); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityTaxRate, resp.Id, ActionCreate, nil, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *taxRateRepo) Update(ctx context.Context, req *models.UpdateTaxRate) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		pk := &models.TaxRatePrimaryKey{Id: req.Id}
+
+		before, err := tx.TaxRate().GetByID(ctx, pk)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		rowsAffected, err = tx.TaxRate().Update(ctx, req)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		after, err := tx.TaxRate().GetByID(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityTaxRate, req.Id, ActionUpdate, before, after)
+	})
+
+	return rowsAffected, err
+}
+
+func (r *taxRateRepo) Delete(ctx context.Context, req *models.TaxRatePrimaryKey) error {
+	return r.strg.Tx(ctx, func(tx storage.StorageI) error {
+		before, err := tx.TaxRate().GetByID(ctx, req)
+		if err := found(err); err != nil {
+			return err
+		}
+
+		if err := tx.TaxRate().Delete(ctx, req); err != nil || before == nil {
+			return err
+		}
+
+		return record(ctx, tx, EntityTaxRate, req.Id, ActionDelete, before, nil)
+	})
+}
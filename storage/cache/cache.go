@@ -0,0 +1,68 @@
+// Package cache keeps catalog reads in memory. New wraps a
+// storage.StorageI and serves Category and Product GetByID and GetList from
+// an LRU cache with a TTL; every other call goes to the wrapped storage.
+//
+// A write through a Category or Product repo drops every cached read of
+// that entity, since any row change can move it in or out of a list. A Tx
+// may write anything, so the whole cache is dropped when it returns.
+// Writes that bypass the Store, such as another process sharing the
+// database, are seen once the TTL runs out.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"market_system/storage"
+)
+
+const (
+	groupCategory = "category"
+	groupProduct  = "product"
+)
+
+type Store struct {
+	storage.StorageI
+	cache *lru
+}
+
+// New caches up to size reads, each for ttl.
+func New(strg storage.StorageI, size int, ttl time.Duration) *Store {
+	return &Store{
+		StorageI: strg,
+		cache:    newLRU(size, ttl),
+	}
+}
+
+// Stats reports the hit and miss counters and the current fill.
+func (s *Store) Stats() Stats {
+	return s.cache.snapshot()
+}
+
+// Tx runs fn on the wrapped storage, so reads inside the transaction see
+// its own writes, and drops the cache afterwards.
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+	defer s.cache.invalidate(groupCategory, groupProduct)
+
+	return s.StorageI.Tx(ctx, fn)
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return &categoryRepo{CategoryRepoI: s.StorageI.Category(), cache: s.cache}
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return &productRepo{ProductRepoI: s.StorageI.Product(), cache: s.cache}
+}
+
+// listKey identifies a GetList request by its JSON encoding.
+func listKey(req interface{}) (string, error) {
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	return "list:" + string(body), nil
+}
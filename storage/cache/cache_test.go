@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+	"market_system/storage/memory"
+	"market_system/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return New(memory.NewConnectionMemory(), 100, time.Minute)
+	})
+}
+
+func TestReadThrough(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		strg = New(memory.NewConnectionMemory(), 100, time.Minute)
+	)
+
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "drinks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk := &models.CategoryPrimaryKey{Id: category.Id}
+	for i := 0; i < 3; i++ {
+		got, err := strg.Category().GetByID(ctx, pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Callers get their own copy.
+		got.Title = "changed by the caller"
+	}
+
+	assertStats(t, strg.Stats(), 2, 1)
+
+	_, err = strg.Category().Update(ctx, &models.UpdateCategory{Id: category.Id, Title: "juice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := strg.Category().GetByID(ctx, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "juice" {
+		t.Fatalf("title after update: want juice, got %s", got.Title)
+	}
+
+	assertStats(t, strg.Stats(), 2, 2)
+
+	req := &models.GetListCategoryRequest{Search: "juice"}
+	if _, err := strg.Category().GetList(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	err = strg.Tx(ctx, func(tx storage.StorageI) error {
+		_, err := tx.Category().Create(ctx, &models.CreateCategory{Title: "juice boxes"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := strg.Category().GetList(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list.Count != 2 {
+		t.Fatalf("count after tx: want 2, got %d", list.Count)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	var (
+		now   = time.Now()
+		cache = newLRU(2, time.Minute)
+	)
+	cache.now = func() time.Time { return now }
+
+	cache.add("g", "a", 1, 0)
+	cache.add("g", "b", 2, 0)
+	cache.get("g", "a")
+	cache.add("g", "c", 3, 0)
+
+	if _, ok := cache.get("g", "b"); ok {
+		t.Fatal("the least recently used entry was not evicted")
+	}
+
+	if _, ok := cache.get("g", "a"); !ok {
+		t.Fatal("a recently used entry was evicted")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("g", "a"); ok {
+		t.Fatal("an expired entry was served")
+	}
+
+	generation := cache.generation("g")
+	cache.invalidate("g")
+	cache.add("g", "d", 4, generation)
+
+	if _, ok := cache.get("g", "d"); ok {
+		t.Fatal("a read older than the last invalidation was cached")
+	}
+
+	stats := cache.snapshot()
+	if stats.Evictions != 1 {
+		t.Fatalf("evictions: want 1, got %d", stats.Evictions)
+	}
+}
+
+func assertStats(t *testing.T, stats Stats, hits, misses uint64) {
+	t.Helper()
+
+	if stats.Hits != hits || stats.Misses != misses {
+		t.Fatalf("want %d hits and %d misses, got %d and %d", hits, misses, stats.Hits, stats.Misses)
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type categoryRepo struct {
+	storage.CategoryRepoI
+	cache *lru
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
+
+	key := "id:" + req.Id
+	if value, ok := r.cache.get(groupCategory, key); ok {
+		resp := *value.(*models.Category)
+		return &resp, nil
+	}
+
+	generation := r.cache.generation(groupCategory)
+	resp, err := r.CategoryRepoI.GetByID(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *resp
+	r.cache.add(groupCategory, key, &cached, generation)
+
+	return resp, nil
+}
+
+func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+
+	key, err := listKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := r.cache.get(groupCategory, key); ok {
+		return copyCategoryList(value.(*models.GetListCategoryResponse)), nil
+	}
+
+	generation := r.cache.generation(groupCategory)
+	resp, err := r.CategoryRepoI.GetList(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.add(groupCategory, key, copyCategoryList(resp), generation)
+
+	return resp, nil
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error) {
+	defer r.cache.invalidate(groupCategory)
+
+	return r.CategoryRepoI.Create(ctx, req)
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	defer r.cache.invalidate(groupCategory)
+
+	return r.CategoryRepoI.Update(ctx, req)
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	defer r.cache.invalidate(groupCategory)
+
+	return r.CategoryRepoI.Delete(ctx, req)
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	defer r.cache.invalidate(groupCategory)
+
+	return r.CategoryRepoI.Restore(ctx, req)
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer r.cache.invalidate(groupCategory)
+
+	return r.CategoryRepoI.Purge(ctx, deletedBefore)
+}
+
+// copyCategoryList copies the response and its rows, so callers never share
+// a row with the cache.
+func copyCategoryList(resp *models.GetListCategoryResponse) *models.GetListCategoryResponse {
+
+	c := *resp
+	c.Categories = nil
+	for _, category := range resp.Categories {
+		row := *category
+		c.Categories = append(c.Categories, &row)
+	}
+
+	return &c
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats counts cache lookups since the cache was created.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Size      int    `json:"size"`
+	TTL       string `json:"ttl"`
+}
+
+type entry struct {
+	group     string
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// lru holds at most size entries for at most ttl each, dropping the least
+// recently used entry when full. Entries belong to a group, such as all
+// cached category reads, that is invalidated as a whole.
+//
+// Every invalidation bumps the generation of its group. A value read from
+// storage is only added if the generation is still the one seen before the
+// read, so a read that raced with a write never caches what the write
+// replaced.
+type lru struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	now         func() time.Time
+	order       *list.List
+	items       map[string]*list.Element
+	generations map[string]uint64
+	stats       Stats
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:        size,
+		ttl:         ttl,
+		now:         time.Now,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+		generations: make(map[string]uint64),
+	}
+}
+
+func (c *lru) get(group, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[group+":"+key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.remove(elem)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return e.value, true
+}
+
+func (c *lru) generation(group string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[group]
+}
+
+// add caches value unless group was invalidated after generation was taken.
+func (c *lru) add(group, key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[group] != generation {
+		return
+	}
+
+	id := group + ":" + key
+	if elem, ok := c.items[id]; ok {
+		c.remove(elem)
+	}
+
+	c.items[id] = c.order.PushFront(&entry{
+		group:     group,
+		key:       id,
+		value:     value,
+		expiresAt: c.now().Add(c.ttl),
+	})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops every entry of groups.
+func (c *lru) invalidate(groups ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	drop := make(map[string]bool)
+	for _, group := range groups {
+		c.generations[group]++
+		drop[group] = true
+	}
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if drop[elem.Value.(*entry).group] {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+func (c *lru) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+}
+
+func (c *lru) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Size = c.size
+	stats.TTL = c.ttl.String()
+	return stats
+}
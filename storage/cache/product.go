@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type productRepo struct {
+	storage.ProductRepoI
+	cache *lru
+}
+
+func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error) {
+
+	key := "id:" + req.Id
+	if value, ok := r.cache.get(groupProduct, key); ok {
+		resp := *value.(*models.Product)
+		return &resp, nil
+	}
+
+	generation := r.cache.generation(groupProduct)
+	resp, err := r.ProductRepoI.GetByID(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *resp
+	r.cache.add(groupProduct, key, &cached, generation)
+
+	return resp, nil
+}
+
+func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+
+	key, err := listKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := r.cache.get(groupProduct, key); ok {
+		return copyProductList(value.(*models.GetListProductResponse)), nil
+	}
+
+	generation := r.cache.generation(groupProduct)
+	resp, err := r.ProductRepoI.GetList(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.add(groupProduct, key, copyProductList(resp), generation)
+
+	return resp, nil
+}
+
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (*models.Product, error) {
+	defer r.cache.invalidate(groupProduct)
+
+	return r.ProductRepoI.Create(ctx, req)
+}
+
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	defer r.cache.invalidate(groupProduct)
+
+	return r.ProductRepoI.Update(ctx, req)
+}
+
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) error {
+	defer r.cache.invalidate(groupProduct)
+
+	return r.ProductRepoI.Delete(ctx, req)
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	defer r.cache.invalidate(groupProduct)
+
+	return r.ProductRepoI.Restore(ctx, req)
+}
+
+func (r *productRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer r.cache.invalidate(groupProduct)
+
+	return r.ProductRepoI.Purge(ctx, deletedBefore)
+}
+
+// copyProductList copies the response and its rows, so callers never share
+// a row with the cache.
+func copyProductList(resp *models.GetListProductResponse) *models.GetListProductResponse {
+
+	c := *resp
+	c.Products = nil
+	for _, product := range resp.Products {
+		row := *product
+		c.Products = append(c.Products, &row)
+	}
+
+	return &c
+}
@@ -0,0 +1,27 @@
+package jsonfile
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type auditRepo struct {
+	storage.AuditRepoI
+	strg *Store
+}
+
+func (r *auditRepo) Create(ctx context.Context, req *models.CreateAuditEntry) (*models.AuditEntry, error) {
+	var resp *models.AuditEntry
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Audit().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
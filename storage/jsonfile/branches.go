@@ -0,0 +1,87 @@
+package jsonfile
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type branchRepo struct {
+	storage.BranchRepoI
+	strg *Store
+}
+
+func (r *branchRepo) Create(ctx context.Context, req *models.CreateBranch) (*models.Branch, error) {
+	var resp *models.Branch
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Branch().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *branchRepo) Update(ctx context.Context, req *models.UpdateBranch) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Branch().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *branchRepo) Delete(ctx context.Context, req *models.BranchPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Branch().Delete(ctx, req)
+	})
+}
+
+func (r *branchRepo) Restore(ctx context.Context, req *models.BranchPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Branch().Restore(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *branchRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		n, err = mem.Branch().Purge(ctx, deletedBefore)
+		return err
+	})
+
+	return n, err
+}
+
+func (r *branchRepo) SetDeliveryTariff(ctx context.Context, req *models.DeliveryTariff) (*models.DeliveryTariff, error) {
+	var resp *models.DeliveryTariff
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Branch().SetDeliveryTariff(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *branchRepo) DeleteDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Branch().DeleteDeliveryTariff(ctx, req)
+	})
+}
@@ -0,0 +1,67 @@
+package jsonfile
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type categoryRepo struct {
+	storage.CategoryRepoI
+	strg *Store
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error) {
+	var resp *models.Category
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Category().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Category().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Category().Delete(ctx, req)
+	})
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Category().Restore(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		n, err = mem.Category().Purge(ctx, deletedBefore)
+		return err
+	})
+
+	return n, err
+}
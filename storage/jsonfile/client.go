@@ -0,0 +1,67 @@
+package jsonfile
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type clientRepo struct {
+	storage.ClientRepoI
+	strg *Store
+}
+
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (*models.Client, error) {
+	var resp *models.Client
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Client().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Client().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Client().Delete(ctx, req)
+	})
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Client().Restore(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *clientRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		n, err = mem.Client().Purge(ctx, deletedBefore)
+		return err
+	})
+
+	return n, err
+}
@@ -0,0 +1,253 @@
+// Package jsonfile is a storage.StorageI that keeps its data in JSON files,
+// one array per table, in a data directory. It serves everything from the
+// memory backend and writes the changed files after every write, so a
+// single binary can run without a database, and demo datasets can ship as
+// files.
+//
+// Each write runs in a memory transaction that only commits once its files
+// are saved; a failed save fails the write. Files are replaced atomically
+// one by one, so a crash in the middle of a save can leave the files of
+// one write partly updated. Timestamps in hand written files must use the
+// layout the memory backend writes, such as 2024-01-02T15:04:05.000000Z.
+//
+// Only one process may use a data directory at a time; Open locks it.
+package jsonfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"market_system/pkg/helpers"
+	"market_system/storage"
+	"market_system/storage/memory"
+)
+
+// tables lists the file of every table and where its rows go in a
+// snapshot.
+var tables = []struct {
+	file string
+	rows func(snapshot *memory.Snapshot) interface{}
+}{
+	{"category.json", func(s *memory.Snapshot) interface{} { return &s.Categories }},
+	{"product.json", func(s *memory.Snapshot) interface{} { return &s.Products }},
+	{"client.json", func(s *memory.Snapshot) interface{} { return &s.Clients }},
+	{"branches.json", func(s *memory.Snapshot) interface{} { return &s.Branches }},
+	{"order.json", func(s *memory.Snapshot) interface{} { return &s.Orders }},
+	{"order_products.json", func(s *memory.Snapshot) interface{} { return &s.OrderProducts }},
+	{"order_status_history.json", func(s *memory.Snapshot) interface{} { return &s.StatusHistory }},
+	{"audit_log.json", func(s *memory.Snapshot) interface{} { return &s.Audit }},
+	{"sequences.json", func(s *memory.Snapshot) interface{} { return &s.Sequences }},
+	{"stock.json", func(s *memory.Snapshot) interface{} { return &s.Stock }},
+	{"stock_movements.json", func(s *memory.Snapshot) interface{} { return &s.Movements }},
+	{"stock_transfers.json", func(s *memory.Snapshot) interface{} { return &s.Transfers }},
+	{"promotions.json", func(s *memory.Snapshot) interface{} { return &s.Promotions }},
+	{"tax_rates.json", func(s *memory.Snapshot) interface{} { return &s.TaxRates }},
+	{"delivery_tariffs.json", func(s *memory.Snapshot) interface{} { return &s.Tariffs }},
+}
+
+// files is the data directory, shared by a Store and the Stores scoped to
+// its transactions.
+type files struct {
+	dir  string
+	lock *os.File
+	// saved is the JSON of every file as last written, to skip files a
+	// write did not change.
+	saved map[string][]byte
+}
+
+type Store struct {
+	storage.StorageI
+	files *files
+	inTx  bool
+}
+
+// Open locks dir, creating it if needed, and loads the files in it. Missing
+// files are empty tables.
+func Open(dir string) (*Store, error) {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &files{
+		dir:   dir,
+		lock:  lock,
+		saved: make(map[string][]byte),
+	}
+
+	snapshot, err := f.load()
+	if err != nil {
+		unlockDir(lock)
+		return nil, err
+	}
+
+	return &Store{
+		StorageI: memory.Load(snapshot),
+		files:    f,
+	}, nil
+}
+
+// Tx runs fn in a memory transaction and saves the files before it
+// commits.
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+
+	if s.inTx {
+		return fn(s)
+	}
+
+	return s.StorageI.Tx(ctx, func(tx storage.StorageI) error {
+		if err := fn(&Store{StorageI: tx, files: s.files, inTx: true}); err != nil {
+			return err
+		}
+
+		return s.files.save(tx.(*memory.Store).Snapshot())
+	})
+}
+
+// Ping checks that the data directory is still there.
+func (s *Store) Ping(ctx context.Context) error {
+
+	if _, err := os.Stat(s.files.dir); err != nil {
+		return fmt.Errorf("data directory: %w", err)
+	}
+
+	return nil
+}
+
+// Close unlocks the data directory. A Store scoped to a transaction does
+// not own the lock and refuses.
+func (s *Store) Close() error {
+
+	if s.inTx {
+		return errors.New("close called on a transaction scoped store")
+	}
+
+	return unlockDir(s.files.lock)
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return &categoryRepo{CategoryRepoI: s.StorageI.Category(), strg: s}
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return &productRepo{ProductRepoI: s.StorageI.Product(), strg: s}
+}
+
+func (s *Store) Client() storage.ClientRepoI {
+	return &clientRepo{ClientRepoI: s.StorageI.Client(), strg: s}
+}
+
+func (s *Store) Branch() storage.BranchRepoI {
+	return &branchRepo{BranchRepoI: s.StorageI.Branch(), strg: s}
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+	return &orderRepo{OrderRepoI: s.StorageI.Order(), strg: s}
+}
+
+func (s *Store) OrderProduct() storage.OrderProductRepoI {
+	return &orderProductRepo{OrderProductRepoI: s.StorageI.OrderProduct(), strg: s}
+}
+
+func (s *Store) Audit() storage.AuditRepoI {
+	return &auditRepo{AuditRepoI: s.StorageI.Audit(), strg: s}
+}
+
+func (s *Store) Sequence() storage.SequenceRepoI {
+	return &sequenceRepo{SequenceRepoI: s.StorageI.Sequence(), strg: s}
+}
+
+func (s *Store) Stock() storage.StockRepoI {
+	return &stockRepo{StockRepoI: s.StorageI.Stock(), strg: s}
+}
+
+func (s *Store) Promotion() storage.PromotionRepoI {
+	return &promotionRepo{PromotionRepoI: s.StorageI.Promotion(), strg: s}
+}
+
+func (s *Store) TaxRate() storage.TaxRateRepoI {
+	return &taxRateRepo{TaxRateRepoI: s.StorageI.TaxRate(), strg: s}
+}
+
+// write runs fn against the memory store of a transaction, which saves the
+// files when fn succeeds.
+func (s *Store) write(ctx context.Context, fn func(mem storage.StorageI) error) error {
+	return s.Tx(ctx, func(tx storage.StorageI) error {
+		return fn(tx.(*Store).StorageI)
+	})
+}
+
+func (f *files) load() (memory.Snapshot, error) {
+
+	var snapshot memory.Snapshot
+	for _, table := range tables {
+		rows, err := helpers.Read(filepath.Join(f.dir, table.file))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return snapshot, fmt.Errorf("read %s: %w", table.file, err)
+		}
+
+		if err := helpers.StructToStruct(table.rows(&snapshot), rows); err != nil {
+			return snapshot, fmt.Errorf("decode %s: %w", table.file, err)
+		}
+
+		body, err := json.Marshal(table.rows(&snapshot))
+		if err != nil {
+			return snapshot, err
+		}
+
+		f.saved[table.file] = body
+	}
+
+	return snapshot, nil
+}
+
+func (f *files) save(snapshot memory.Snapshot) error {
+
+	for _, table := range tables {
+		body, err := json.Marshal(table.rows(&snapshot))
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(body, f.saved[table.file]) {
+			continue
+		}
+
+		if err := helpers.Write(filepath.Join(f.dir, table.file), toInterfaces(table.rows(&snapshot))); err != nil {
+			return fmt.Errorf("write %s: %w", table.file, err)
+		}
+
+		f.saved[table.file] = body
+	}
+
+	return nil
+}
+
+// toInterfaces turns a pointer to a slice into the []interface{} that
+// helpers.Write takes.
+func toInterfaces(rows interface{}) []interface{} {
+
+	v := reflect.ValueOf(rows).Elem()
+
+	data := make([]interface{}, v.Len())
+	for i := range data {
+		data[i] = v.Index(i).Interface()
+	}
+
+	return data
+}
@@ -0,0 +1,81 @@
+package jsonfile
+
+import (
+	"context"
+	"testing"
+
+	"market_system/models"
+	"market_system/storage"
+	"market_system/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		strg, err := Open(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { strg.Close() })
+
+		return strg
+	})
+}
+
+func TestReopen(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+	)
+
+	strg, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(dir); err == nil {
+		t.Fatal("a locked data directory was opened twice")
+	}
+
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "drinks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed transaction leaves nothing in the files.
+	_ = strg.Tx(ctx, func(tx storage.StorageI) error {
+		_, err := tx.Category().Create(ctx, &models.CreateCategory{Title: "rolled back"})
+		if err != nil {
+			return err
+		}
+
+		return context.Canceled
+	})
+
+	if err := strg.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	strg, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strg.Close()
+
+	got, err := strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: category.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "drinks" {
+		t.Fatalf("title after reopen: want drinks, got %s", got.Title)
+	}
+
+	list, err := strg.Category().GetList(ctx, &models.GetListCategoryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list.Count != 1 {
+		t.Fatalf("categories after reopen: want 1, got %d", list.Count)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !unix
+
+package jsonfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDir creates dir/.lock exclusively and unlockDir removes it. Unlike
+// the flock on unix, the file survives a crash and then has to be removed
+// by hand.
+func lockDir(dir string) (*os.File, error) {
+
+	path := filepath.Join(dir, ".lock")
+
+	lock, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("data directory %s is used by another process, or %s is left over from a crash: %w", dir, path, err)
+	}
+
+	return lock, nil
+}
+
+func unlockDir(lock *os.File) error {
+
+	if err := lock.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(lock.Name())
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package jsonfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir takes an exclusive flock on dir/.lock. The kernel drops it when
+// the process exits, so a crash never leaves the directory locked.
+func lockDir(dir string) (*os.File, error) {
+
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("data directory %s is used by another process: %w", dir, err)
+	}
+
+	return lock, nil
+}
+
+func unlockDir(lock *os.File) error {
+	return lock.Close()
+}
@@ -0,0 +1,81 @@
+package jsonfile
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type orderRepo struct {
+	storage.OrderRepoI
+	strg *Store
+}
+
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (*models.Order, error) {
+	var resp *models.Order
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Order().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Order().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Order().Delete(ctx, req)
+	})
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Order().Restore(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		n, err = mem.Order().Purge(ctx, deletedBefore)
+		return err
+	})
+
+	return n, err
+}
+
+func (r *orderRepo) StatusUpdate(ctx context.Context, req models.CheckStatus) (models.Order, error) {
+	var resp models.Order
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Order().StatusUpdate(ctx, req)
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package jsonfile
+
+import (
+	"context"
+	"time"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type orderProductRepo struct {
+	storage.OrderProductRepoI
+	strg *Store
+}
+
+func (r *orderProductRepo) Create(ctx context.Context, req *models.CreateOrderProduct) (*models.OrderProduct, error) {
+	var resp *models.OrderProduct
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.OrderProduct().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *orderProductRepo) Update(ctx context.Context, req *models.UpdateOrderProduct) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.OrderProduct().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderProductRepo) Delete(ctx context.Context, req *models.OrderProductPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.OrderProduct().Delete(ctx, req)
+	})
+}
+
+func (r *orderProductRepo) Restore(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.OrderProduct().Restore(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *orderProductRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var n int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		n, err = mem.OrderProduct().Purge(ctx, deletedBefore)
+		return err
+	})
+
+	return n, err
+}
@@ -0,0 +1,44 @@
+package jsonfile
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type promotionRepo struct {
+	storage.PromotionRepoI
+	strg *Store
+}
+
+func (r *promotionRepo) Create(ctx context.Context, req *models.CreatePromotion) (*models.Promotion, error) {
+	var resp *models.Promotion
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Promotion().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *promotionRepo) Update(ctx context.Context, req *models.UpdatePromotion) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.Promotion().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *promotionRepo) Delete(ctx context.Context, req *models.PromotionPrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.Promotion().Delete(ctx, req)
+	})
+}
@@ -0,0 +1,23 @@
+package jsonfile
+
+import (
+	"context"
+
+	"market_system/storage"
+)
+
+type sequenceRepo struct {
+	storage.SequenceRepoI
+	strg *Store
+}
+
+func (r *sequenceRepo) Next(ctx context.Context, name string) (int64, error) {
+	var value int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		value, err = mem.Sequence().Next(ctx, name)
+		return err
+	})
+
+	return value, err
+}
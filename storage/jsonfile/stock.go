@@ -0,0 +1,35 @@
+package jsonfile
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type stockRepo struct {
+	storage.StockRepoI
+	strg *Store
+}
+
+func (r *stockRepo) Adjust(ctx context.Context, req *models.AdjustStock) (*models.Stock, error) {
+	var resp *models.Stock
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Stock().Adjust(ctx, req)
+		return err
+	})
+
+	return resp, err
+}
+
+func (r *stockRepo) Transfer(ctx context.Context, req *models.CreateStockTransfer) (*models.StockTransfer, error) {
+	var resp *models.StockTransfer
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.Stock().Transfer(ctx, req)
+		return err
+	})
+
+	return resp, err
+}
@@ -0,0 +1,44 @@
+package jsonfile
+
+import (
+	"context"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+type taxRateRepo struct {
+	storage.TaxRateRepoI
+	strg *Store
+}
+
+func (r *taxRateRepo) Create(ctx context.Context, req *models.CreateTaxRate) (*models.TaxRate, error) {
+	var resp *models.TaxRate
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		resp, err = mem.TaxRate().Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *taxRateRepo) Update(ctx context.Context, req *models.UpdateTaxRate) (int64, error) {
+	var rowsAffected int64
+
+	err := r.strg.write(ctx, func(mem storage.StorageI) (err error) {
+		rowsAffected, err = mem.TaxRate().Update(ctx, req)
+		return err
+	})
+
+	return rowsAffected, err
+}
+
+func (r *taxRateRepo) Delete(ctx context.Context, req *models.TaxRatePrimaryKey) error {
+	return r.strg.write(ctx, func(mem storage.StorageI) error {
+		return mem.TaxRate().Delete(ctx, req)
+	})
+}
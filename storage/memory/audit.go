@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"market_system/models"
+
+	"github.com/google/uuid"
+)
+
+type auditRepo struct {
+	db *db
+}
+
+func NewAuditRepo(db *db) *auditRepo {
+	return &auditRepo{
+		db: db,
+	}
+}
+
+func (r *auditRepo) Create(ctx context.Context, req *models.CreateAuditEntry) (*models.AuditEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	entry := models.AuditEntry{
+		Id:        uuid.New().String(),
+		Entity:    req.Entity,
+		EntityId:  req.EntityId,
+		Action:    req.Action,
+		Actor:     req.Actor,
+		Before:    req.Before,
+		After:     req.After,
+		Diff:      req.Diff,
+		CreatedAt: now(),
+	}
+
+	r.db.audit[entry.Id] = &entry
+
+	resp := entry
+	return &resp, nil
+}
+
+func (r *auditRepo) GetList(ctx context.Context, req *models.GetListAuditRequest) (*models.GetListAuditResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListAuditResponse
+		filtered []*models.AuditEntry
+	)
+
+	for _, entry := range r.db.audit {
+		if req.Entity != "" && entry.Entity != req.Entity {
+			continue
+		}
+
+		if req.EntityId != "" && entry.EntityId != req.EntityId {
+			continue
+		}
+
+		filtered = append(filtered, entry)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range filtered[start:end] {
+		item := *entry
+		resp.Entries = append(resp.Entries, &item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
@@ -0,0 +1,299 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
+	"market_system/pkg/money"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+// defaultDeliveryPrice mirrors the column default of branches.delivery_price.
+var defaultDeliveryPrice = money.Units(10000)
+
+type branchRepo struct {
+	db *db
+}
+
+func NewBranchRepo(db *db) *branchRepo {
+	return &branchRepo{
+		db: db,
+	}
+}
+
+func (r *branchRepo) Create(ctx context.Context, req *models.CreateBranch) (*models.Branch, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		createdAt = now()
+		branch    = models.Branch{
+			ID:            uuid.New().String(),
+			Name:          req.Name,
+			Phone:         req.Phone,
+			Photo:         req.Photo,
+			WorkStartHour: req.WorkStartHour,
+			WorkEndHour:   req.WorkEndHour,
+			Timezone:      req.Timezone,
+			Schedule:      req.Schedule,
+			Holidays:      req.Holidays,
+			Address:       req.Address,
+			Latitude:      req.Latitude,
+			Longitude:     req.Longitude,
+			OrderPrefix:   req.OrderPrefix,
+			DeliveryPrice: defaultDeliveryPrice,
+			Active:        true,
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
+			Version:       1,
+		}
+	)
+
+	if _, err := branch.OpenHours(); err != nil {
+		return nil, err
+	}
+
+	r.db.branches[branch.ID] = branchRow(&branch)
+
+	resp := branchRow(&branch)
+	resp.SetOpenState(time.Now())
+	return resp, nil
+}
+
+func (r *branchRepo) GetByID(ctx context.Context, req *models.BranchPrimaryKey) (*models.Branch, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	branch, ok := r.db.branches[req.ID]
+	if !ok || branch.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := branchRow(branch)
+	resp.SetOpenState(time.Now())
+	return resp, nil
+}
+
+func (r *branchRepo) Update(ctx context.Context, req *models.UpdateBranch) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	branch, ok := r.db.branches[req.ID]
+	if !ok || branch.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != branch.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return 0, err
+	}
+
+	updated := branchRow(&models.Branch{
+		WorkStartHour: req.WorkStartHour,
+		WorkEndHour:   req.WorkEndHour,
+		Timezone:      req.Timezone,
+		Schedule:      req.Schedule,
+		Holidays:      req.Holidays,
+	})
+	if _, err := updated.OpenHours(); err != nil {
+		return 0, err
+	}
+
+	branch.Name = req.Name
+	branch.Phone = req.Phone
+	branch.Photo = req.Photo
+	branch.WorkStartHour = req.WorkStartHour
+	branch.WorkEndHour = req.WorkEndHour
+	branch.Timezone = updated.Timezone
+	branch.Schedule = updated.Schedule
+	branch.Holidays = updated.Holidays
+	branch.Address = req.Address
+	branch.Latitude = req.Latitude
+	branch.Longitude = req.Longitude
+	branch.Active = req.Active
+	branch.OrderPrefix = req.OrderPrefix
+	branch.UpdatedAt = now()
+	branch.Version++
+
+	return 1, nil
+}
+
+func (r *branchRepo) Delete(ctx context.Context, req *models.BranchPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if branch, ok := r.db.branches[req.ID]; ok && branch.DeletedAt == "" {
+		branch.DeletedAt = now()
+	}
+
+	return nil
+}
+
+func (r *branchRepo) Restore(ctx context.Context, req *models.BranchPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	branch, ok := r.db.branches[req.ID]
+	if !ok || branch.DeletedAt == "" {
+		return 0, nil
+	}
+
+	branch.DeletedAt = ""
+	return 1, nil
+}
+
+func (r *branchRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, branch := range r.db.branches {
+		if branch.DeletedAt == "" || branch.DeletedAt >= before || r.referenced(id) {
+			continue
+		}
+
+		delete(r.db.branches, id)
+		delete(r.db.tariffs, id)
+		for key, stock := range r.db.stock {
+			if stock.BranchId == id {
+				delete(r.db.stock, key)
+			}
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// referenced reports whether an order was placed with the branch or the
+// stock ledger has movements of it.
+func (r *branchRepo) referenced(id string) bool {
+	for _, order := range r.db.orders {
+		if order.BranchId == id {
+			return true
+		}
+	}
+
+	for _, movement := range r.db.movements {
+		if movement.BranchId == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *branchRepo) GetList(ctx context.Context, req *models.GetListBranchRequest) (*models.GetListBranchResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListBranchResponse
+		filtered []*models.Branch
+		conds    = []filter.Expr{req.Filter}
+		readAt   = time.Now()
+	)
+
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.ILike("name", req.Search))
+	}
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, branch := range r.db.branches {
+		ok, err := filter.Match(expr, branchFields(branch))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, branch)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].ID, filtered[j].CreatedAt, filtered[j].ID)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].ID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, branch := range filtered[start:end] {
+		item := branchRow(branch)
+		item.SetOpenState(readAt)
+
+		resp.Branches = append(resp.Branches, item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func branchFields(branch *models.Branch) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "id":
+			return branch.ID, true
+		case "name":
+			return branch.Name, true
+		case "phone":
+			return branch.Phone, true
+		case "address":
+			return branch.Address, true
+		case "latitude":
+			return branch.Latitude, true
+		case "longitude":
+			return branch.Longitude, true
+		case "order_prefix":
+			return branch.OrderPrefix, true
+		case "work_start_hour":
+			return branch.WorkStartHour, true
+		case "work_end_hour":
+			return branch.WorkEndHour, true
+		case "timezone":
+			return branch.Timezone, true
+		case "delivery_price":
+			return branch.DeliveryPrice.Float64(), true
+		case "active":
+			return branch.Active, true
+		case "created_at":
+			return branch.CreatedAt, true
+		case "updated_at":
+			return branch.UpdatedAt, true
+		case "deleted_at":
+			return branch.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type categoryRepo struct {
+	db *db
+}
+
+func NewCategoryRepo(db *db) *categoryRepo {
+	return &categoryRepo{
+		db: db,
+	}
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if req.ParentID != "" {
+		if _, ok := r.db.categories[req.ParentID]; !ok {
+			return nil, fmt.Errorf("category parent_id %s does not exist", req.ParentID)
+		}
+	}
+
+	if err := r.db.checkTaxRate("category", req.TaxRateId); err != nil {
+		return nil, err
+	}
+
+	var (
+		createdAt = now()
+		category  = models.Category{
+			Id:        uuid.New().String(),
+			Title:     req.Title,
+			ParentID:  req.ParentID,
+			Image:     req.Image,
+			TaxRateId: req.TaxRateId,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+			Version:   1,
+		}
+	)
+
+	r.db.categories[category.Id] = &category
+
+	resp := category
+	return &resp, nil
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	category, ok := r.db.categories[req.Id]
+	if !ok || category.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *category
+	return &resp, nil
+}
+
+func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListCategoryResponse
+		filtered []*models.Category
+		conds    = []filter.Expr{req.Filter}
+	)
+
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.ILike("title", req.Search))
+	}
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, category := range r.db.categories {
+		ok, err := filter.Match(expr, categoryFields(category))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, category)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, category := range filtered[start:end] {
+		item := *category
+		resp.Categories = append(resp.Categories, &item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	category, ok := r.db.categories[req.Id]
+	if !ok || category.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != category.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	if req.ParentID != "" {
+		if _, ok := r.db.categories[req.ParentID]; !ok {
+			return 0, fmt.Errorf("category parent_id %s does not exist", req.ParentID)
+		}
+	}
+
+	if err := r.db.checkTaxRate("category", req.TaxRateId); err != nil {
+		return 0, err
+	}
+
+	category.Title = req.Title
+	category.ParentID = req.ParentID
+	category.Image = req.Image
+	category.TaxRateId = req.TaxRateId
+	category.UpdatedAt = now()
+	category.Version++
+
+	return 1, nil
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if category, ok := r.db.categories[req.Id]; ok && category.DeletedAt == "" {
+		category.DeletedAt = now()
+	}
+
+	return nil
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	category, ok := r.db.categories[req.Id]
+	if !ok || category.DeletedAt == "" {
+		return 0, nil
+	}
+
+	category.DeletedAt = ""
+	return 1, nil
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, category := range r.db.categories {
+		if category.DeletedAt == "" || category.DeletedAt >= before || r.referenced(id) {
+			continue
+		}
+
+		delete(r.db.categories, id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// referenced reports whether a child category or a product still points at
+// the category.
+func (r *categoryRepo) referenced(id string) bool {
+	for _, category := range r.db.categories {
+		if category.ParentID == id {
+			return true
+		}
+	}
+
+	for _, product := range r.db.products {
+		if product.CategoryId == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func categoryFields(category *models.Category) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "id":
+			return category.Id, true
+		case "title":
+			return category.Title, true
+		case "parent_id":
+			return category.ParentID, true
+		case "image":
+			return category.Image, true
+		case "tax_rate_id":
+			return category.TaxRateId, true
+		case "created_at":
+			return category.CreatedAt, true
+		case "updated_at":
+			return category.UpdatedAt, true
+		case "deleted_at":
+			return category.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type clientRepo struct {
+	db *db
+}
+
+func NewClientRepo(db *db) *clientRepo {
+	return &clientRepo{
+		db: db,
+	}
+}
+
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (*models.Client, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	dateOfBirth, err := parseDate(req.DateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		createdAt = now()
+		client    = models.Client{
+			ID:          uuid.New().String(),
+			FirstName:   req.FirstName,
+			LastName:    req.LastName,
+			Phone:       req.Phone,
+			Photo:       req.Photo,
+			DateOfBirth: dateOfBirth,
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+			Version:     1,
+		}
+	)
+
+	r.db.clients[client.ID] = &client
+
+	resp := client
+	return &resp, nil
+}
+
+func (r *clientRepo) GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	client, ok := r.db.clients[req.ID]
+	if !ok || client.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *client
+	return &resp, nil
+}
+
+func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientRequest) (*models.GetListClientResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListClientResponse
+		filtered []*models.Client
+		conds    = []filter.Expr{req.Filter}
+	)
+
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.ILike("first_name", req.Search),
+			filter.ILike("last_name", req.Search),
+			filter.ILike("phone", req.Search),
+		))
+	}
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, client := range r.db.clients {
+		ok, err := filter.Match(expr, clientFields(client))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, client)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].ID, filtered[j].CreatedAt, filtered[j].ID)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].ID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, client := range filtered[start:end] {
+		item := *client
+		resp.Clients = append(resp.Clients, &item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	client, ok := r.db.clients[req.ID]
+	if !ok || client.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != client.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	dateOfBirth, err := parseDate(req.DateOfBirth)
+	if err != nil {
+		return 0, err
+	}
+
+	client.FirstName = req.FirstName
+	client.LastName = req.LastName
+	client.Phone = req.Phone
+	client.Photo = req.Photo
+	client.DateOfBirth = dateOfBirth
+	client.UpdatedAt = now()
+	client.Version++
+
+	return 1, nil
+}
+
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if client, ok := r.db.clients[req.ID]; ok && client.DeletedAt == "" {
+		client.DeletedAt = now()
+	}
+
+	return nil
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	client, ok := r.db.clients[req.ID]
+	if !ok || client.DeletedAt == "" {
+		return 0, nil
+	}
+
+	client.DeletedAt = ""
+	return 1, nil
+}
+
+func (r *clientRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, client := range r.db.clients {
+		if client.DeletedAt == "" || client.DeletedAt >= before || r.referenced(id) {
+			continue
+		}
+
+		delete(r.db.clients, id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// referenced reports whether an order still belongs to the client.
+func (r *clientRepo) referenced(id string) bool {
+	for _, order := range r.db.orders {
+		if order.ClientId == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDate accepts the same input as a postgres DATE column and returns it
+// in the form database/sql produces when scanning a DATE into a string.
+func parseDate(value string) (string, error) {
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format("2006-01-02") + "T00:00:00Z", nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid input syntax for type date: %q", value)
+}
+
+func clientFields(client *models.Client) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "id":
+			return client.ID, true
+		case "first_name":
+			return client.FirstName, true
+		case "last_name":
+			return client.LastName, true
+		case "phone":
+			return client.Phone, true
+		case "date_of_birth":
+			return client.DateOfBirth, true
+		case "created_at":
+			return client.CreatedAt, true
+		case "updated_at":
+			return client.UpdatedAt, true
+		case "deleted_at":
+			return client.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
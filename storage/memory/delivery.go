@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/geo"
+	"market_system/storage"
+)
+
+func (r *branchRepo) GetDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) (*models.DeliveryTariff, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	tariff, ok := r.db.tariffs[req.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return tariffRow(tariff), nil
+}
+
+func (r *branchRepo) SetDeliveryTariff(ctx context.Context, req *models.DeliveryTariff) (*models.DeliveryTariff, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if branch, ok := r.db.branches[req.BranchId]; !ok || branch.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	if err := req.Tariff().Validate(); err != nil {
+		return nil, err
+	}
+
+	current, ok := r.db.tariffs[req.BranchId]
+	if req.Version != 0 && (!ok || req.Version != current.Version) {
+		return nil, storage.ErrVersionConflict
+	}
+
+	var (
+		updatedAt = now()
+		tariff    = tariffRow(req)
+	)
+
+	tariff.CreatedAt, tariff.UpdatedAt, tariff.Version = updatedAt, updatedAt, 1
+	if ok {
+		tariff.CreatedAt, tariff.Version = current.CreatedAt, current.Version+1
+	}
+
+	r.db.tariffs[req.BranchId] = tariff
+
+	return tariffRow(tariff), nil
+}
+
+func (r *branchRepo) DeleteDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.tariffs, req.ID)
+	return nil
+}
+
+func (r *branchRepo) Nearest(ctx context.Context, req *models.NearestBranchRequest) (*models.NearestBranchResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp    models.NearestBranchResponse
+		address = geo.Point{Lat: req.Latitude, Lng: req.Longitude}
+		readAt  = time.Now()
+		at      = req.At
+	)
+
+	if err := address.Validate(); err != nil {
+		return nil, err
+	}
+
+	if at.IsZero() {
+		at = readAt
+	}
+
+	for _, branch := range r.db.branches {
+		if branch.DeletedAt != "" || !branch.Active || (geo.Point{Lat: branch.Latitude, Lng: branch.Longitude}).IsZero() {
+			continue
+		}
+
+		resp.Branches = append(resp.Branches, nearestBranch(branch, r.db.tariffs[branch.ID], address, at, readAt, req.Speed))
+	}
+
+	sort.Slice(resp.Branches, func(i, j int) bool {
+		if resp.Branches[i].DistanceKm != resp.Branches[j].DistanceKm {
+			return resp.Branches[i].DistanceKm < resp.Branches[j].DistanceKm
+		}
+
+		return resp.Branches[i].ID < resp.Branches[j].ID
+	})
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	if int64(len(resp.Branches)) > limit {
+		resp.Branches = resp.Branches[:limit]
+	}
+
+	return &resp, nil
+}
+
+// nearestBranch quotes the delivery from branch to address, see
+// delivery.NewQuote, and tells whether the branch is open at at. The
+// branch itself is as read at readAt.
+func nearestBranch(branch *models.Branch, tariff *models.DeliveryTariff, address geo.Point, at, readAt time.Time, speed delivery.Speed) *models.NearestBranch {
+
+	var branchTariff *delivery.Tariff
+	if tariff != nil {
+		t := tariff.Tariff()
+		branchTariff = &t
+	}
+
+	var (
+		quote = delivery.NewQuote(branchTariff, branch.DeliveryPrice, geo.Point{Lat: branch.Latitude, Lng: branch.Longitude}, address, speed)
+		resp  = models.NearestBranch{
+			Branch:          *branchRow(branch),
+			DistanceKm:      quote.Km,
+			Deliverable:     quote.Deliverable,
+			DeliveryFee:     quote.Fee,
+			DeliveryMinutes: int64(quote.Estimate / time.Minute),
+		}
+	)
+
+	if s, err := branch.OpenHours(); err == nil {
+		resp.IsOpen = s.Open(at)
+	}
+
+	resp.Branch.SetOpenState(readAt)
+
+	return &resp
+}
+
+// orderRoute returns the route the delivery of order is priced by, or nil
+// when the order pays a flat price: when its branch has no location or no
+// tariff. A branch with a tariff needs the location of the order, so an
+// order without one gets a delivery.ErrNoLocation error.
+func (d *db) orderRoute(order *models.Order) (*delivery.Route, error) {
+
+	var (
+		branch, ok = d.branches[order.BranchId]
+		tariff     = d.tariffs[order.BranchId]
+		address    = geo.Point{Lat: order.Latitude, Lng: order.Longitude}
+	)
+
+	if !ok || tariff == nil {
+		return nil, nil
+	}
+
+	location := geo.Point{Lat: branch.Latitude, Lng: branch.Longitude}
+	if location.IsZero() {
+		return nil, nil
+	}
+
+	if address.IsZero() {
+		return nil, fmt.Errorf("%w: branch %s prices deliveries by distance", delivery.ErrNoLocation, order.BranchId)
+	}
+
+	route := delivery.NewRoute(tariff.Tariff(), location, address)
+	return &route, nil
+}
+
+// tariffRow copies a tariff together with its bands.
+func tariffRow(tariff *models.DeliveryTariff) *models.DeliveryTariff {
+	row := *tariff
+	row.Bands = append([]models.DeliveryBand{}, tariff.Bands...)
+	return &row
+}
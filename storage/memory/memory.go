@@ -0,0 +1,374 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/storage"
+)
+
+// timeLayout keeps timestamps lexically sortable, so string comparison
+// gives the same order as "ORDER BY created_at" in postgres.
+const timeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+type db struct {
+	mu            sync.RWMutex
+	categories    map[string]*models.Category
+	products      map[string]*models.Product
+	clients       map[string]*models.Client
+	branches      map[string]*models.Branch
+	orders        map[string]*models.Order
+	orderProducts map[string]*models.OrderProduct
+	statusHistory map[string]*models.OrderStatusChange
+	audit         map[string]*models.AuditEntry
+	sequences     map[string]int64
+	stock         map[string]*models.Stock
+	movements     map[string]*models.StockMovement
+	transfers     map[string]*models.StockTransfer
+	promotions    map[string]*models.Promotion
+	taxRates      map[string]*models.TaxRate
+	tariffs       map[string]*models.DeliveryTariff
+}
+
+type Store struct {
+	db           *db
+	inTx         bool
+	category     storage.CategoryRepoI
+	product      storage.ProductRepoI
+	client       storage.ClientRepoI
+	branch       storage.BranchRepoI
+	order        storage.OrderRepoI
+	orderProduct storage.OrderProductRepoI
+	audit        storage.AuditRepoI
+	sequence     storage.SequenceRepoI
+	stock        storage.StockRepoI
+	promotion    storage.PromotionRepoI
+	taxRate      storage.TaxRateRepoI
+}
+
+func NewConnectionMemory() *Store {
+	return &Store{
+		db: newDB(),
+	}
+}
+
+func newDB() *db {
+	return &db{
+		categories:    make(map[string]*models.Category),
+		products:      make(map[string]*models.Product),
+		clients:       make(map[string]*models.Client),
+		branches:      make(map[string]*models.Branch),
+		orders:        make(map[string]*models.Order),
+		orderProducts: make(map[string]*models.OrderProduct),
+		statusHistory: make(map[string]*models.OrderStatusChange),
+		audit:         make(map[string]*models.AuditEntry),
+		sequences:     make(map[string]int64),
+		stock:         make(map[string]*models.Stock),
+		movements:     make(map[string]*models.StockMovement),
+		transfers:     make(map[string]*models.StockTransfer),
+		promotions:    make(map[string]*models.Promotion),
+		taxRates:      make(map[string]*models.TaxRate),
+		tariffs:       make(map[string]*models.DeliveryTariff),
+	}
+}
+
+// Tx runs fn against a copy of the data and swaps the copy in when fn
+// succeeds. The store stays write-locked meanwhile, so transactions are
+// serializable and other callers wait for them.
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	txDB := s.db.clone()
+	if err := fn(&Store{db: txDB, inTx: true}); err != nil {
+		return err
+	}
+
+	s.db.categories = txDB.categories
+	s.db.products = txDB.products
+	s.db.clients = txDB.clients
+	s.db.branches = txDB.branches
+	s.db.orders = txDB.orders
+	s.db.orderProducts = txDB.orderProducts
+	s.db.statusHistory = txDB.statusHistory
+	s.db.audit = txDB.audit
+	s.db.sequences = txDB.sequences
+	s.db.stock = txDB.stock
+	s.db.movements = txDB.movements
+	s.db.transfers = txDB.transfers
+	s.db.promotions = txDB.promotions
+	s.db.taxRates = txDB.taxRates
+	s.db.tariffs = txDB.tariffs
+
+	return nil
+}
+
+// Ping always succeeds; the data is in this process.
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+
+	if s.category == nil {
+		s.category = NewCategoryRepo(s.db)
+	}
+
+	return s.category
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+
+	if s.product == nil {
+		s.product = NewProductRepo(s.db)
+	}
+
+	return s.product
+}
+
+func (s *Store) Client() storage.ClientRepoI {
+
+	if s.client == nil {
+		s.client = NewClientRepo(s.db)
+	}
+
+	return s.client
+}
+
+func (s *Store) Branch() storage.BranchRepoI {
+
+	if s.branch == nil {
+		s.branch = NewBranchRepo(s.db)
+	}
+
+	return s.branch
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+
+	if s.order == nil {
+		s.order = NewOrderRepo(s.db)
+	}
+
+	return s.order
+}
+
+func (s *Store) OrderProduct() storage.OrderProductRepoI {
+
+	if s.orderProduct == nil {
+		s.orderProduct = NewOrderProductRepo(s.db)
+	}
+
+	return s.orderProduct
+}
+
+func (s *Store) Audit() storage.AuditRepoI {
+
+	if s.audit == nil {
+		s.audit = NewAuditRepo(s.db)
+	}
+
+	return s.audit
+}
+
+func (s *Store) Sequence() storage.SequenceRepoI {
+
+	if s.sequence == nil {
+		s.sequence = NewSequenceRepo(s.db)
+	}
+
+	return s.sequence
+}
+
+func (s *Store) Stock() storage.StockRepoI {
+
+	if s.stock == nil {
+		s.stock = NewStockRepo(s.db)
+	}
+
+	return s.stock
+}
+
+func (s *Store) Promotion() storage.PromotionRepoI {
+
+	if s.promotion == nil {
+		s.promotion = NewPromotionRepo(s.db)
+	}
+
+	return s.promotion
+}
+
+func (s *Store) TaxRate() storage.TaxRateRepoI {
+
+	if s.taxRate == nil {
+		s.taxRate = NewTaxRateRepo(s.db)
+	}
+
+	return s.taxRate
+}
+
+// clone copies every row, so writes to the copy never reach d.
+func (d *db) clone() *db {
+	c := newDB()
+
+	for id, category := range d.categories {
+		row := *category
+		c.categories[id] = &row
+	}
+
+	for id, product := range d.products {
+		row := *product
+		c.products[id] = &row
+	}
+
+	for id, client := range d.clients {
+		row := *client
+		c.clients[id] = &row
+	}
+
+	for id, branch := range d.branches {
+		row := *branch
+		c.branches[id] = &row
+	}
+
+	for id, order := range d.orders {
+		c.orders[id] = orderRow(order)
+	}
+
+	for id, orderProduct := range d.orderProducts {
+		row := *orderProduct
+		c.orderProducts[id] = &row
+	}
+
+	for id, change := range d.statusHistory {
+		row := *change
+		c.statusHistory[id] = &row
+	}
+
+	for id, entry := range d.audit {
+		row := *entry
+		c.audit[id] = &row
+	}
+
+	for name, value := range d.sequences {
+		c.sequences[name] = value
+	}
+
+	for key, stock := range d.stock {
+		row := *stock
+		c.stock[key] = &row
+	}
+
+	for id, movement := range d.movements {
+		row := *movement
+		c.movements[id] = &row
+	}
+
+	for id, transfer := range d.transfers {
+		row := *transfer
+		row.Items = append([]models.StockTransferItem(nil), transfer.Items...)
+		c.transfers[id] = &row
+	}
+
+	for id, promotion := range d.promotions {
+		c.promotions[id] = promotionRow(promotion)
+	}
+
+	for id, rate := range d.taxRates {
+		row := *rate
+		c.taxRates[id] = &row
+	}
+
+	for branchID, tariff := range d.tariffs {
+		c.tariffs[branchID] = tariffRow(tariff)
+	}
+
+	return c
+}
+
+// clock is the last time handed out by now.
+var clock struct {
+	sync.Mutex
+	last time.Time
+}
+
+// now returns the current time, at least a microsecond, the precision of
+// timeLayout, after the previous call, so rows written back to back, such
+// as the audit entries of one transaction, never share a timestamp.
+func now() string {
+	clock.Lock()
+	defer clock.Unlock()
+
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	if !t.After(clock.last) {
+		t = clock.last.Add(time.Microsecond)
+	}
+
+	clock.last = t
+	return t.Format(timeLayout)
+}
+
+// window selects one list page from n rows sorted by newerFirst, with the
+// same defaults and modes as the postgres listPage: OFFSET 0 and LIMIT 10,
+// or the rows right after a cursor, ignoring offset. key returns the sort
+// key of row i. It also returns the cursor of the next page, empty on the
+// last one.
+func window(offset, limit int64, token string, n int, key func(i int) (string, string)) (int, int, string, error) {
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var start int
+	if token != "" {
+		c, err := cursor.Decode(token)
+		if err != nil {
+			return 0, 0, "", err
+		}
+
+		start = sort.Search(n, func(i int) bool {
+			return !c.Before(key(i))
+		})
+	} else if offset > 0 {
+		start = int(offset)
+	}
+
+	if start > n {
+		start = n
+	}
+
+	end := start + int(limit)
+	if end > n {
+		end = n
+	}
+
+	var next string
+	if end < n && end > start {
+		next = cursor.Encode(key(end - 1))
+	}
+
+	return start, end, next, nil
+}
+
+// newerFirst mirrors "ORDER BY created_at DESC", breaking ties by id.
+func newerFirst(createdAtI, idI, createdAtJ, idJ string) bool {
+
+	if createdAtI != createdAtJ {
+		return createdAtI > createdAtJ
+	}
+
+	return idI > idJ
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"market_system/storage"
+	"market_system/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return NewConnectionMemory()
+	})
+}
@@ -0,0 +1,464 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type orderRepo struct {
+	db *db
+}
+
+func NewOrderRepo(db *db) *orderRepo {
+	return &orderRepo{
+		db: db,
+	}
+}
+
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (*models.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	branch, ok := r.db.branches[req.BranchId]
+	if !ok || branch.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	if err := r.checkReferences(req.ClientId, req.BranchId); err != nil {
+		return nil, err
+	}
+
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, order := range r.db.orders {
+		if order.OrderId == req.OrderId {
+			return nil, fmt.Errorf("order_id %s already exists", req.OrderId)
+		}
+	}
+
+	var (
+		createdAt = now()
+		order     = models.Order{
+			Id:            uuid.New().String(),
+			OrderId:       req.OrderId,
+			ClientId:      req.ClientId,
+			BranchId:      req.BranchId,
+			Address:       req.Address,
+			Latitude:      req.Latitude,
+			Longitude:     req.Longitude,
+			DeliveryPrice: branch.DeliveryPrice,
+			Status:        "new",
+			CreatedAt:     createdAt,
+			UpdatedAt:     createdAt,
+			Version:       1,
+		}
+	)
+
+	// An order priced by distance must be in the delivery range of the
+	// branch. Until it has lines it shows the fee for no goods.
+	route, err := r.db.orderRoute(&order)
+	if err != nil {
+		return nil, err
+	}
+
+	if route != nil {
+		if err := route.Check(); err != nil {
+			return nil, err
+		}
+
+		order.DeliveryPrice, order.DeliveryDistance = route.Fee(0), route.Km
+	}
+
+	r.db.orders[order.Id] = &order
+
+	resp := order
+	return &resp, nil
+}
+
+func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	order, ok := r.db.orders[req.Id]
+	if !ok || order.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	return orderRow(order), nil
+}
+
+func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListOrderResponse
+		filtered []*models.Order
+		conds    = []filter.Expr{req.Filter}
+	)
+
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.Eq("client_id", req.Search),
+			filter.Eq("branch_id", req.Search),
+		))
+	}
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, order := range r.db.orders {
+		ok, err := filter.Match(expr, orderFields(order))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, order)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range filtered[start:end] {
+		resp.Orders = append(resp.Orders, orderRow(order))
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[req.Id]
+	if !ok || order.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != order.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	if !workflow.Default.Open(order.Status) {
+		return 0, fmt.Errorf("%w: order %s is %s", storage.ErrOrderClosed, order.Id, order.Status)
+	}
+
+	if err := r.checkReferences(req.ClientId, req.BranchId); err != nil {
+		return 0, err
+	}
+
+	// Status changes go through StatusUpdate, which checks the workflow.
+	if req.Status != "" && req.Status != order.Status {
+		return 0, fmt.Errorf("%w: change the status of order %s through the status update", workflow.ErrTransition, order.Id)
+	}
+
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return 0, err
+	}
+
+	code := order.PromoCode
+	if req.PromoCode != nil {
+		code = *req.PromoCode
+	}
+
+	promotion, err := r.db.orderPromotion(order, req.ClientId, code)
+	if err != nil {
+		return 0, err
+	}
+
+	// The delivery of an order at a new address or branch is priced again,
+	// and must be in range.
+	located := *order
+	located.BranchId, located.Latitude, located.Longitude = req.BranchId, req.Latitude, req.Longitude
+
+	route, err := r.db.orderRoute(&located)
+	if err != nil {
+		return 0, err
+	}
+
+	if route != nil {
+		if err := route.Check(); err != nil {
+			return 0, err
+		}
+	}
+
+	// A new code must apply to the order; one the order has already is
+	// kept even when it gives no discount at the moment.
+	totals, taxed, err := r.db.orderTotals(order.Id, req.BranchId, req.DeliveryPrice, route, promotion)
+	if errors.Is(err, promo.ErrNotApplicable) && promotion.Id == order.PromotionId {
+		err = nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if req.BranchId != order.BranchId {
+		if err := r.db.moveStock(order, req.BranchId); err != nil {
+			return 0, err
+		}
+	}
+
+	order.ClientId = req.ClientId
+	order.BranchId = req.BranchId
+	order.Address = req.Address
+	order.Latitude = req.Latitude
+	order.Longitude = req.Longitude
+	order.DeliveryPrice, order.DeliveryDistance = req.DeliveryPrice, 0
+	if route != nil {
+		order.DeliveryPrice, order.DeliveryDistance = route.Fee(totals.Goods()), route.Km
+	}
+	order.TotalCount = totals.Count
+	order.TotalPrice = totals.Total
+	order.PromotionId, order.PromoCode = "", ""
+	if promotion != nil {
+		order.PromotionId, order.PromoCode = promotion.Id, promotion.Code
+	}
+	order.Discount = totals.Discount
+	order.Tax = totals.Tax
+	order.Taxes = orderTaxes(taxed.buckets)
+	r.db.setLineTaxes(taxed.lines)
+	order.UpdatedAt = now()
+	order.Version++
+
+	return 1, nil
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[req.Id]
+	if !ok || order.DeletedAt != "" {
+		return nil
+	}
+
+	// Nobody works on a deleted order, so an open one gives its stock back
+	// the way a canceled one does.
+	if workflow.Default.Open(order.Status) {
+		r.db.settleStock(order, workflow.StatusCanceled, "")
+	}
+
+	order.DeletedAt = now()
+	return nil
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[req.Id]
+	if !ok || order.DeletedAt == "" {
+		return 0, nil
+	}
+
+	if workflow.Default.Open(order.Status) {
+		if err := r.db.reserveOrder(order); err != nil {
+			return 0, err
+		}
+	}
+
+	order.DeletedAt = ""
+	return 1, nil
+}
+
+func (r *orderRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, order := range r.db.orders {
+		if order.DeletedAt == "" || order.DeletedAt >= before || r.referenced(id) {
+			continue
+		}
+
+		delete(r.db.orders, id)
+		for changeID, change := range r.db.statusHistory {
+			if change.OrderId == id {
+				delete(r.db.statusHistory, changeID)
+			}
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// referenced reports whether the order still has lines.
+func (r *orderRepo) referenced(id string) bool {
+	for _, orderProduct := range r.db.orderProducts {
+		if orderProduct.OrderID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// status check
+
+func (r *orderRepo) StatusUpdate(ctx context.Context, req models.CheckStatus) (models.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[req.Id]
+	if !ok || order.DeletedAt != "" {
+		return models.Order{}, sql.ErrNoRows
+	}
+
+	if req.Version != 0 && req.Version != order.Version {
+		return models.Order{}, storage.ErrVersionConflict
+	}
+
+	_, err := workflow.Default.Check(workflow.Input{
+		From:   order.Status,
+		To:     req.Status,
+		Reason: req.Reason,
+		Items:  order.TotalCount,
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	change := models.OrderStatusChange{
+		Id:         uuid.New().String(),
+		OrderId:    order.Id,
+		FromStatus: order.Status,
+		ToStatus:   req.Status,
+		Actor:      req.Actor,
+		Reason:     req.Reason,
+		CreatedAt:  now(),
+	}
+	r.db.statusHistory[change.Id] = &change
+
+	if workflow.Default.Open(order.Status) && !workflow.Default.Open(req.Status) {
+		r.db.settleStock(order, req.Status, req.Actor)
+	}
+
+	if order.Status == workflow.StatusFinished && req.Status == workflow.StatusReturned {
+		r.db.restock(order, req.Actor)
+	}
+
+	order.Status = req.Status
+	order.UpdatedAt = change.CreatedAt
+	order.Version++
+
+	return *orderRow(order), nil
+}
+
+func (r *orderRepo) History(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetOrderHistoryResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if order, ok := r.db.orders[req.Id]; !ok || order.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	var resp models.GetOrderHistoryResponse
+	for _, change := range r.db.statusHistory {
+		if change.OrderId == req.Id {
+			item := *change
+			resp.History = append(resp.History, &item)
+		}
+	}
+
+	sort.Slice(resp.History, func(i, j int) bool {
+		return newerFirst(resp.History[j].CreatedAt, resp.History[j].Id, resp.History[i].CreatedAt, resp.History[i].Id)
+	})
+
+	resp.Count = len(resp.History)
+	return &resp, nil
+}
+
+func (r *orderRepo) checkReferences(clientID, branchID string) error {
+
+	if _, ok := r.db.clients[clientID]; !ok {
+		return fmt.Errorf("order client_id %s does not exist", clientID)
+	}
+
+	if _, ok := r.db.branches[branchID]; !ok {
+		return fmt.Errorf("order branch_id %s does not exist", branchID)
+	}
+
+	return nil
+}
+
+func orderFields(order *models.Order) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "id":
+			return order.Id, true
+		case "order_id":
+			return order.OrderId, true
+		case "client_id":
+			return order.ClientId, true
+		case "branch_id":
+			return order.BranchId, true
+		case "address":
+			return order.Address, true
+		case "delivery_price":
+			return order.DeliveryPrice.Float64(), true
+		case "delivery_distance":
+			return order.DeliveryDistance, true
+		case "total_count":
+			return order.TotalCount, true
+		case "total_price":
+			return order.TotalPrice.Float64(), true
+		case "promotion_id":
+			return order.PromotionId, true
+		case "promo_code":
+			return order.PromoCode, true
+		case "discount":
+			return order.Discount.Float64(), true
+		case "tax":
+			return order.Tax.Float64(), true
+		case "status":
+			return order.Status, true
+		case "created_at":
+			return order.CreatedAt, true
+		case "updated_at":
+			return order.UpdatedAt, true
+		case "deleted_at":
+			return order.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
@@ -0,0 +1,467 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+	"market_system/pkg/pricing"
+	"market_system/pkg/promo"
+	"market_system/pkg/tax"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type orderProductRepo struct {
+	db *db
+}
+
+func NewOrderProductRepo(db *db) *orderProductRepo {
+	return &orderProductRepo{
+		db: db,
+	}
+}
+
+func (r *orderProductRepo) Create(ctx context.Context, req *models.CreateOrderProduct) (*models.OrderProduct, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, err := r.db.openOrder(req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	product, ok := r.db.products[req.ProductID]
+	if !ok {
+		return nil, fmt.Errorf("order product product_id %s does not exist", req.ProductID)
+	}
+
+	var (
+		createdAt    = now()
+		orderProduct = models.OrderProduct{
+			OrderProductID: uuid.New().String(),
+			OrderID:        req.OrderID,
+			ProductID:      req.ProductID,
+			DiscountType:   req.DiscountType,
+			DiscountAmount: req.DiscountAmount,
+			Quantity:       req.Quantity,
+			Price:          product.Price,
+			CreatedAt:      createdAt,
+			UpdatedAt:      createdAt,
+			Version:        1,
+		}
+	)
+
+	r.db.setLineTaxRate(&orderProduct, product)
+
+	sum, lineTax, err := pricingLine(&orderProduct).Taxed()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.reserve(order.BranchId, req.ProductID, req.Quantity, 0); err != nil {
+		return nil, err
+	}
+
+	orderProduct.Sum = sum
+	orderProduct.Tax = lineTax
+	orderProduct.Reserved = req.Quantity
+	r.db.orderProducts[orderProduct.OrderProductID] = &orderProduct
+	if err := r.db.recalculateOrder(orderProduct.OrderID); err != nil {
+		return nil, err
+	}
+
+	resp := orderProduct
+	return &resp, nil
+}
+
+func (r *orderProductRepo) GetByID(ctx context.Context, req *models.OrderProductPrimaryKey) (*models.OrderProduct, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	orderProduct, ok := r.db.orderProducts[req.OrderProductID]
+	if !ok || orderProduct.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *orderProduct
+	return &resp, nil
+}
+
+func (r *orderProductRepo) GetList(ctx context.Context, req *models.GetListOrderProductRequest) (*models.GetListOrderProductResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListOrderProductResponse
+		filtered []*models.OrderProduct
+		conds    = []filter.Expr{req.Filter}
+	)
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, orderProduct := range r.db.orderProducts {
+		ok, err := filter.Match(expr, orderProductFields(orderProduct))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, orderProduct)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].OrderProductID, filtered[j].CreatedAt, filtered[j].OrderProductID)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].OrderProductID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, orderProduct := range filtered[start:end] {
+		item := *orderProduct
+		resp.OrderProducts = append(resp.OrderProducts, &item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func (r *orderProductRepo) Update(ctx context.Context, req *models.UpdateOrderProduct) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	orderProduct, ok := r.db.orderProducts[req.OrderProductID]
+	if !ok || orderProduct.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != orderProduct.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	order, err := r.db.openOrder(orderProduct.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	product, ok := r.db.products[req.ProductID]
+	if !ok {
+		return 0, fmt.Errorf("order product product_id %s does not exist", req.ProductID)
+	}
+
+	// The line keeps the price and the tax rate it was added at unless it
+	// is switched to another product.
+	updated := *orderProduct
+	updated.ProductID = req.ProductID
+	updated.DiscountType = req.DiscountType
+	updated.DiscountAmount = req.DiscountAmount
+	updated.Quantity = req.Quantity
+	if req.ProductID != orderProduct.ProductID {
+		updated.Price = product.Price
+		r.db.setLineTaxRate(&updated, product)
+	}
+
+	sum, lineTax, err := pricingLine(&updated).Taxed()
+	if err != nil {
+		return 0, err
+	}
+
+	if req.ProductID == orderProduct.ProductID {
+		err = r.db.reserve(order.BranchId, req.ProductID, req.Quantity, orderProduct.Reserved)
+	} else if err = r.db.reserve(order.BranchId, req.ProductID, req.Quantity, 0); err == nil {
+		r.db.release(order.BranchId, orderProduct.ProductID, orderProduct.Reserved)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	updated.Sum = sum
+	updated.Tax = lineTax
+	updated.Reserved = req.Quantity
+	updated.UpdatedAt = now()
+	updated.Version++
+	*orderProduct = updated
+
+	if err := r.db.recalculateOrder(orderProduct.OrderID); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+func (r *orderProductRepo) Delete(ctx context.Context, req *models.OrderProductPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	orderProduct, ok := r.db.orderProducts[req.OrderProductID]
+	if !ok || orderProduct.DeletedAt != "" {
+		return nil
+	}
+
+	// The lines of a closed order settled its stock and its totals.
+	order, err := r.db.openOrder(orderProduct.OrderID)
+	if err != nil {
+		return err
+	}
+
+	r.db.release(order.BranchId, orderProduct.ProductID, orderProduct.Reserved)
+
+	orderProduct.DeletedAt = now()
+	orderProduct.Reserved = 0
+	return r.db.recalculateOrder(orderProduct.OrderID)
+}
+
+func (r *orderProductRepo) Restore(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	orderProduct, ok := r.db.orderProducts[req.OrderProductID]
+	if !ok || orderProduct.DeletedAt == "" {
+		return 0, nil
+	}
+
+	order, err := r.db.openOrder(orderProduct.OrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.db.reserve(order.BranchId, orderProduct.ProductID, orderProduct.Quantity, 0); err != nil {
+		return 0, err
+	}
+
+	orderProduct.DeletedAt = ""
+	orderProduct.Reserved = orderProduct.Quantity
+	if err := r.db.recalculateOrder(orderProduct.OrderID); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+func (r *orderProductRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, orderProduct := range r.db.orderProducts {
+		if orderProduct.DeletedAt == "" || orderProduct.DeletedAt >= before {
+			continue
+		}
+
+		delete(r.db.orderProducts, id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+func pricingLine(orderProduct *models.OrderProduct) pricing.Line {
+	return pricing.Line{
+		Price:          orderProduct.Price,
+		Quantity:       orderProduct.Quantity,
+		DiscountType:   orderProduct.DiscountType,
+		DiscountAmount: orderProduct.DiscountAmount,
+		Tax:            tax.Rate{Percent: orderProduct.TaxPercent, Inclusive: orderProduct.TaxInclusive},
+	}
+}
+
+// orderTotals prices the lines of an order that are not deleted and takes
+// the discount of promotion off, if there is one, for an order of branchID.
+// The delivery costs deliveryPrice, or what route charges for the goods
+// when there is a route. It returns the tax of the discounted lines too,
+// by line and by rate. When the order does not qualify for the promotion it returns
+// the totals without a discount together with a promo.ErrNotApplicable
+// error.
+func (d *db) orderTotals(orderID, branchID string, deliveryPrice money.Amount, route *delivery.Route, promotion *models.Promotion) (pricing.Totals, taxation, error) {
+
+	var (
+		lines      []pricing.Line
+		promoLines []promo.Line
+		rateIDs    []string
+		lineIDs    []string
+	)
+
+	for _, orderProduct := range d.orderProducts {
+		if orderProduct.OrderID != orderID || orderProduct.DeletedAt != "" {
+			continue
+		}
+
+		line := pricingLine(orderProduct)
+		lines = append(lines, line)
+		rateIDs = append(rateIDs, orderProduct.TaxRateId)
+		lineIDs = append(lineIDs, orderProduct.OrderProductID)
+
+		sum, err := line.Sum()
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		var category string
+		if product, ok := d.products[orderProduct.ProductID]; ok {
+			category = product.CategoryId
+		}
+
+		promoLines = append(promoLines, promo.Line{Category: category, Sum: sum})
+	}
+
+	var notApplicable error
+	if promotion != nil {
+		rule, err := promotionRule(promotion)
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		discount, err := rule.Discount(branchID, promoLines)
+		switch {
+		case errors.Is(err, promo.ErrNotApplicable):
+			notApplicable = err
+		case err != nil:
+			return pricing.Totals{}, taxation{}, err
+		default:
+			lines, err = pricing.Spread(lines, discount, func(i int) bool { return rule.Applies(promoLines[i].Category) })
+			if err != nil {
+				return pricing.Totals{}, taxation{}, err
+			}
+		}
+	}
+
+	totals, err := pricing.Order(lines, deliveryPrice)
+	if err != nil {
+		return pricing.Totals{}, taxation{}, err
+	}
+
+	if route != nil {
+		if totals, err = totals.Delivered(route.Fee(totals.Goods()), len(lines)); err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+	}
+
+	taxLines := make([]tax.Line, len(lines))
+	lineTaxes := make(map[string]money.Amount, len(lines))
+	for i, line := range lines {
+		taxable, err := line.Taxable()
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		if _, lineTaxes[lineIDs[i]], err = line.Taxed(); err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		taxLines[i] = tax.Line{RateId: rateIDs[i], Rate: line.Tax, Sum: taxable}
+	}
+
+	return totals, taxation{buckets: tax.Summarize(taxLines), lines: lineTaxes}, notApplicable
+}
+
+// recalculateOrder stores the totals, the tax breakdown, the line taxes and
+// the delivery price of an order after one of its lines changed. An order
+// that no longer qualifies for its promotion keeps it without a discount,
+// until its lines qualify again. The order gets a new version only when
+// the totals actually differ.
+func (d *db) recalculateOrder(orderID string) error {
+
+	order, ok := d.orders[orderID]
+	if !ok {
+		return nil
+	}
+
+	// An order placed before its branch got a tariff may have no location;
+	// it keeps its flat price until it is updated with one.
+	route, err := d.orderRoute(order)
+	if err != nil && !errors.Is(err, delivery.ErrNoLocation) {
+		return err
+	}
+
+	totals, taxed, err := d.orderTotals(orderID, order.BranchId, order.DeliveryPrice, route, d.promotions[order.PromotionId])
+	if err != nil && !errors.Is(err, promo.ErrNotApplicable) {
+		return err
+	}
+
+	d.setLineTaxes(taxed.lines)
+
+	deliveryPrice, distance := order.DeliveryPrice, 0.0
+	if route != nil {
+		deliveryPrice, distance = route.Fee(totals.Goods()), route.Km
+	}
+
+	taxes := orderTaxes(taxed.buckets)
+
+	if order.TotalCount == totals.Count && order.TotalPrice == totals.Total && order.Discount == totals.Discount &&
+		order.Tax == totals.Tax && sameTaxes(order.Taxes, taxes) &&
+		order.DeliveryPrice == deliveryPrice && order.DeliveryDistance == distance {
+		return nil
+	}
+
+	order.DeliveryPrice = deliveryPrice
+	order.DeliveryDistance = distance
+
+	order.TotalCount = totals.Count
+	order.TotalPrice = totals.Total
+	order.Discount = totals.Discount
+	order.Tax = totals.Tax
+	order.Taxes = taxes
+	order.UpdatedAt = now()
+	order.Version++
+
+	return nil
+}
+
+func orderProductFields(orderProduct *models.OrderProduct) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "order_product_id":
+			return orderProduct.OrderProductID, true
+		case "order_id":
+			return orderProduct.OrderID, true
+		case "product_id":
+			return orderProduct.ProductID, true
+		case "discount_type":
+			return orderProduct.DiscountType, true
+		case "discount_amount":
+			return orderProduct.DiscountAmount.Float64(), true
+		case "quantity":
+			return orderProduct.Quantity, true
+		case "price":
+			return orderProduct.Price.Float64(), true
+		case "sum":
+			return orderProduct.Sum.Float64(), true
+		case "tax_rate_id":
+			return orderProduct.TaxRateId, true
+		case "tax":
+			return orderProduct.Tax.Float64(), true
+		case "created_at":
+			return orderProduct.CreatedAt, true
+		case "updated_at":
+			return orderProduct.UpdatedAt, true
+		case "deleted_at":
+			return orderProduct.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
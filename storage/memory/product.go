@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type productRepo struct {
+	db *db
+}
+
+func NewProductRepo(db *db) *productRepo {
+	return &productRepo{
+		db: db,
+	}
+}
+
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (*models.Product, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.checkCategory(req.CategoryId); err != nil {
+		return nil, err
+	}
+
+	if err := r.db.checkTaxRate("product", req.TaxRateId); err != nil {
+		return nil, err
+	}
+
+	for _, product := range r.db.products {
+		if product.ProductId == req.ProductId {
+			return nil, fmt.Errorf("product_id %s already exists", req.ProductId)
+		}
+	}
+
+	var (
+		createdAt = now()
+		product   = models.Product{
+			Id:          uuid.New().String(),
+			ProductId:   req.ProductId,
+			Title:       req.Title,
+			Photo:       req.Photo,
+			Price:       req.Price,
+			Description: req.Description,
+			CategoryId:  req.CategoryId,
+			TaxRateId:   req.TaxRateId,
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+			Version:     1,
+		}
+	)
+
+	r.db.products[product.Id] = &product
+
+	resp := product
+	return &resp, nil
+}
+
+func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	product, ok := r.db.products[req.Id]
+	if !ok || product.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *product
+	return &resp, nil
+}
+
+func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListProductResponse
+		filtered []*models.Product
+		conds    = []filter.Expr{req.Filter}
+	)
+
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.ILike("title", req.Search),
+			filter.ILike("category_id", req.Search),
+		))
+	}
+
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	expr := filter.And(conds...)
+	for _, product := range r.db.products {
+		ok, err := filter.Match(expr, productFields(product))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			filtered = append(filtered, product)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, next, err := window(req.Offset, req.Limit, req.Cursor, len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range filtered[start:end] {
+		item := *product
+		resp.Products = append(resp.Products, &item)
+	}
+
+	if req.Cursor == "" {
+		resp.Count = len(filtered)
+	}
+
+	resp.NextCursor = next
+	return &resp, nil
+}
+
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	product, ok := r.db.products[req.Id]
+	if !ok || product.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != product.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	if err := r.checkCategory(req.CategoryId); err != nil {
+		return 0, err
+	}
+
+	if err := r.db.checkTaxRate("product", req.TaxRateId); err != nil {
+		return 0, err
+	}
+
+	product.Title = req.Title
+	product.Photo = req.Photo
+	product.Description = req.Description
+	product.Price = req.Price
+	product.CategoryId = req.CategoryId
+	product.TaxRateId = req.TaxRateId
+	product.UpdatedAt = now()
+	product.Version++
+
+	return 1, nil
+}
+
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if product, ok := r.db.products[req.Id]; ok && product.DeletedAt == "" {
+		product.DeletedAt = now()
+	}
+
+	return nil
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	product, ok := r.db.products[req.Id]
+	if !ok || product.DeletedAt == "" {
+		return 0, nil
+	}
+
+	product.DeletedAt = ""
+	return 1, nil
+}
+
+func (r *productRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		before = deletedBefore.UTC().Format(timeLayout)
+		purged int64
+	)
+
+	for id, product := range r.db.products {
+		if product.DeletedAt == "" || product.DeletedAt >= before || r.referenced(id) {
+			continue
+		}
+
+		delete(r.db.products, id)
+		for key, stock := range r.db.stock {
+			if stock.ProductId == id {
+				delete(r.db.stock, key)
+			}
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// referenced reports whether an order line or the stock ledger still
+// points at the product.
+func (r *productRepo) referenced(id string) bool {
+	for _, orderProduct := range r.db.orderProducts {
+		if orderProduct.ProductID == id {
+			return true
+		}
+	}
+
+	for _, movement := range r.db.movements {
+		if movement.ProductId == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkCategory mirrors the NOT NULL foreign key on product.category_id.
+func (r *productRepo) checkCategory(categoryID string) error {
+
+	if categoryID == "" {
+		return errors.New("product category_id is required")
+	}
+
+	if _, ok := r.db.categories[categoryID]; !ok {
+		return fmt.Errorf("product category_id %s does not exist", categoryID)
+	}
+
+	return nil
+}
+
+func productFields(product *models.Product) filter.Getter {
+	return func(field string) (interface{}, bool) {
+		switch field {
+		case "id":
+			return product.Id, true
+		case "product_id":
+			return product.ProductId, true
+		case "title":
+			return product.Title, true
+		case "description":
+			return product.Description, true
+		case "price":
+			return product.Price.Float64(), true
+		case "category_id":
+			return product.CategoryId, true
+		case "tax_rate_id":
+			return product.TaxRateId, true
+		case "created_at":
+			return product.CreatedAt, true
+		case "updated_at":
+			return product.UpdatedAt, true
+		case "deleted_at":
+			return product.DeletedAt, true
+		}
+
+		return nil, false
+	}
+}
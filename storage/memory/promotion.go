@@ -0,0 +1,295 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type promotionRepo struct {
+	db *db
+}
+
+func NewPromotionRepo(db *db) *promotionRepo {
+	return &promotionRepo{
+		db: db,
+	}
+}
+
+func (r *promotionRepo) Create(ctx context.Context, req *models.CreatePromotion) (*models.Promotion, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		createdAt = now()
+		promotion = models.Promotion{
+			Id:             uuid.New().String(),
+			Code:           promo.Normalize(req.Code),
+			Type:           req.Type,
+			Value:          req.Value,
+			StartsAt:       formatTime(req.StartsAt),
+			EndsAt:         formatTime(req.EndsAt),
+			UsageLimit:     req.UsageLimit,
+			PerClientLimit: req.PerClientLimit,
+			MinOrderTotal:  req.MinOrderTotal,
+			BranchIds:      append([]string(nil), req.BranchIds...),
+			CategoryIds:    append([]string(nil), req.CategoryIds...),
+			CreatedAt:      createdAt,
+			UpdatedAt:      createdAt,
+			Version:        1,
+		}
+	)
+
+	if err := r.check(&promotion); err != nil {
+		return nil, err
+	}
+
+	r.db.promotions[promotion.Id] = &promotion
+
+	return promotionRow(&promotion), nil
+}
+
+func (r *promotionRepo) GetByID(ctx context.Context, req *models.PromotionPrimaryKey) (*models.Promotion, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	promotion, ok := r.db.promotions[req.Id]
+	if !ok || promotion.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	return promotionRow(promotion), nil
+}
+
+func (r *promotionRepo) GetList(ctx context.Context, req *models.GetListPromotionRequest) (*models.GetListPromotionResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListPromotionResponse
+		filtered []*models.Promotion
+		search   = promo.Normalize(req.Search)
+	)
+
+	for _, promotion := range r.db.promotions {
+		if promotion.DeletedAt != "" && !req.IncludeDeleted {
+			continue
+		}
+
+		if strings.HasPrefix(promotion.Code, search) {
+			filtered = append(filtered, promotion)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, _, err := window(req.Offset, req.Limit, "", len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, promotion := range filtered[start:end] {
+		resp.Promotions = append(resp.Promotions, promotionRow(promotion))
+	}
+
+	resp.Count = len(filtered)
+	return &resp, nil
+}
+
+func (r *promotionRepo) Update(ctx context.Context, req *models.UpdatePromotion) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	promotion, ok := r.db.promotions[req.Id]
+	if !ok || promotion.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != promotion.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	updated := *promotion
+	updated.Code = promo.Normalize(req.Code)
+	updated.Type = req.Type
+	updated.Value = req.Value
+	updated.StartsAt = formatTime(req.StartsAt)
+	updated.EndsAt = formatTime(req.EndsAt)
+	updated.UsageLimit = req.UsageLimit
+	updated.PerClientLimit = req.PerClientLimit
+	updated.MinOrderTotal = req.MinOrderTotal
+	updated.BranchIds = append([]string(nil), req.BranchIds...)
+	updated.CategoryIds = append([]string(nil), req.CategoryIds...)
+
+	if err := r.check(&updated); err != nil {
+		return 0, err
+	}
+
+	updated.UpdatedAt = now()
+	updated.Version++
+	*promotion = updated
+
+	return 1, nil
+}
+
+func (r *promotionRepo) Delete(ctx context.Context, req *models.PromotionPrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if promotion, ok := r.db.promotions[req.Id]; ok && promotion.DeletedAt == "" {
+		promotion.DeletedAt = now()
+	}
+
+	return nil
+}
+
+// check validates a promotion about to be written: its rules, that its
+// code is not taken and that its branches and categories exist.
+func (r *promotionRepo) check(promotion *models.Promotion) error {
+
+	rule, err := promotionRule(promotion)
+	if err != nil {
+		return err
+	}
+
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	for _, other := range r.db.promotions {
+		if other.Id != promotion.Id && other.DeletedAt == "" && other.Code == promotion.Code {
+			return fmt.Errorf("%w: code %s is taken", promo.ErrInvalid, promotion.Code)
+		}
+	}
+
+	for _, branchID := range promotion.BranchIds {
+		if _, ok := r.db.branches[branchID]; !ok {
+			return fmt.Errorf("%w: branch %s does not exist", promo.ErrInvalid, branchID)
+		}
+	}
+
+	for _, categoryID := range promotion.CategoryIds {
+		if _, ok := r.db.categories[categoryID]; !ok {
+			return fmt.Errorf("%w: category %s does not exist", promo.ErrInvalid, categoryID)
+		}
+	}
+
+	return nil
+}
+
+// orderPromotion returns the promotion of code for order, nil for no code.
+// A code the order has already is kept; a new one must be valid now and
+// have uses left, counting the orders that are neither deleted nor
+// canceled.
+func (d *db) orderPromotion(order *models.Order, clientID, code string) (*models.Promotion, error) {
+
+	code = promo.Normalize(code)
+	if code == "" {
+		return nil, nil
+	}
+
+	if order.PromotionId != "" && code == order.PromoCode {
+		return d.promotions[order.PromotionId], nil
+	}
+
+	var promotion *models.Promotion
+	for _, candidate := range d.promotions {
+		if candidate.Code == code && candidate.DeletedAt == "" {
+			promotion = candidate
+		}
+	}
+
+	if promotion == nil {
+		return nil, fmt.Errorf("%w: unknown code %s", promo.ErrNotApplicable, code)
+	}
+
+	var uses, clientUses int
+	for _, other := range d.orders {
+		if other.PromotionId != promotion.Id || other.Id == order.Id || other.DeletedAt != "" || other.Status == workflow.StatusCanceled {
+			continue
+		}
+
+		uses++
+		if other.ClientId == clientID {
+			clientUses++
+		}
+	}
+
+	rule, err := promotionRule(promotion)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rule.Check(time.Now(), uses, clientUses); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+func promotionRule(promotion *models.Promotion) (promo.Rule, error) {
+
+	startsAt, err := parseTime(promotion.StartsAt)
+	if err != nil {
+		return promo.Rule{}, err
+	}
+
+	endsAt, err := parseTime(promotion.EndsAt)
+	if err != nil {
+		return promo.Rule{}, err
+	}
+
+	return promo.Rule{
+		Code:           promotion.Code,
+		Type:           promotion.Type,
+		Value:          promotion.Value,
+		StartsAt:       startsAt,
+		EndsAt:         endsAt,
+		UsageLimit:     promotion.UsageLimit,
+		PerClientLimit: promotion.PerClientLimit,
+		MinOrderTotal:  promotion.MinOrderTotal,
+		Branches:       promotion.BranchIds,
+		Categories:     promotion.CategoryIds,
+	}, nil
+}
+
+// promotionRow copies a promotion together with its id lists.
+func promotionRow(promotion *models.Promotion) *models.Promotion {
+	row := *promotion
+	row.BranchIds = append([]string(nil), promotion.BranchIds...)
+	row.CategoryIds = append([]string(nil), promotion.CategoryIds...)
+	return &row
+}
+
+// formatTime stores t in timeLayout, and the zero time as empty.
+func formatTime(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(value string) (time.Time, error) {
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(timeLayout, value)
+}
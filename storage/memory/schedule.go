@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"market_system/models"
+)
+
+// branchRow copies a branch together with its shifts and holidays.
+func branchRow(branch *models.Branch) *models.Branch {
+	row := *branch
+	row.Schedule = append([]models.BranchHours{}, branch.Schedule...)
+	row.Holidays = append([]models.BranchHoliday{}, branch.Holidays...)
+	return &row
+}
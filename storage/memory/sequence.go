@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+)
+
+type sequenceRepo struct {
+	db *db
+}
+
+func NewSequenceRepo(db *db) *sequenceRepo {
+	return &sequenceRepo{
+		db: db,
+	}
+}
+
+func (r *sequenceRepo) Next(ctx context.Context, name string) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.sequences[name]++
+	return r.db.sequences[name], nil
+}
@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"sort"
+
+	"market_system/models"
+)
+
+// Snapshot holds every row of a Store, oldest first, for backends that
+// persist the data somewhere.
+type Snapshot struct {
+	Categories    []*models.Category
+	Products      []*models.Product
+	Clients       []*models.Client
+	Branches      []*models.Branch
+	Orders        []*models.Order
+	OrderProducts []*models.OrderProduct
+	StatusHistory []*models.OrderStatusChange
+	Audit         []*models.AuditEntry
+	Sequences     []*models.Sequence
+	Stock         []*models.Stock
+	Movements     []*models.StockMovement
+	Transfers     []*models.StockTransfer
+	Promotions    []*models.Promotion
+	TaxRates      []*models.TaxRate
+	Tariffs       []*models.DeliveryTariff
+}
+
+// Load returns a Store holding the rows of snapshot.
+func Load(snapshot Snapshot) *Store {
+	d := newDB()
+
+	for _, category := range snapshot.Categories {
+		row := *category
+		d.categories[row.Id] = &row
+	}
+
+	for _, product := range snapshot.Products {
+		row := *product
+		d.products[row.Id] = &row
+	}
+
+	for _, client := range snapshot.Clients {
+		row := *client
+		d.clients[row.ID] = &row
+	}
+
+	for _, branch := range snapshot.Branches {
+		row := *branch
+		d.branches[row.ID] = &row
+	}
+
+	for _, order := range snapshot.Orders {
+		d.orders[order.Id] = orderRow(order)
+	}
+
+	for _, orderProduct := range snapshot.OrderProducts {
+		row := *orderProduct
+		d.orderProducts[row.OrderProductID] = &row
+	}
+
+	for _, change := range snapshot.StatusHistory {
+		row := *change
+		d.statusHistory[row.Id] = &row
+	}
+
+	for _, entry := range snapshot.Audit {
+		row := *entry
+		d.audit[row.Id] = &row
+	}
+
+	for _, sequence := range snapshot.Sequences {
+		d.sequences[sequence.Name] = sequence.Value
+	}
+
+	for _, stock := range snapshot.Stock {
+		row := *stock
+		row.Available = 0
+		d.stock[stockKey(row.BranchId, row.ProductId)] = &row
+	}
+
+	for _, movement := range snapshot.Movements {
+		row := *movement
+		d.movements[row.Id] = &row
+	}
+
+	for _, transfer := range snapshot.Transfers {
+		row := *transfer
+		row.Items = append([]models.StockTransferItem(nil), transfer.Items...)
+		d.transfers[row.Id] = &row
+	}
+
+	for _, promotion := range snapshot.Promotions {
+		d.promotions[promotion.Id] = promotionRow(promotion)
+	}
+
+	for _, rate := range snapshot.TaxRates {
+		row := *rate
+		d.taxRates[row.Id] = &row
+	}
+
+	for _, tariff := range snapshot.Tariffs {
+		d.tariffs[tariff.BranchId] = tariffRow(tariff)
+	}
+
+	return &Store{db: d}
+}
+
+// Snapshot copies every row of the store.
+func (s *Store) Snapshot() Snapshot {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var snapshot Snapshot
+
+	for _, category := range s.db.categories {
+		row := *category
+		snapshot.Categories = append(snapshot.Categories, &row)
+	}
+	sort.Slice(snapshot.Categories, func(i, j int) bool {
+		return newerFirst(snapshot.Categories[j].CreatedAt, snapshot.Categories[j].Id, snapshot.Categories[i].CreatedAt, snapshot.Categories[i].Id)
+	})
+
+	for _, product := range s.db.products {
+		row := *product
+		snapshot.Products = append(snapshot.Products, &row)
+	}
+	sort.Slice(snapshot.Products, func(i, j int) bool {
+		return newerFirst(snapshot.Products[j].CreatedAt, snapshot.Products[j].Id, snapshot.Products[i].CreatedAt, snapshot.Products[i].Id)
+	})
+
+	for _, client := range s.db.clients {
+		row := *client
+		snapshot.Clients = append(snapshot.Clients, &row)
+	}
+	sort.Slice(snapshot.Clients, func(i, j int) bool {
+		return newerFirst(snapshot.Clients[j].CreatedAt, snapshot.Clients[j].ID, snapshot.Clients[i].CreatedAt, snapshot.Clients[i].ID)
+	})
+
+	for _, branch := range s.db.branches {
+		row := *branch
+		snapshot.Branches = append(snapshot.Branches, &row)
+	}
+	sort.Slice(snapshot.Branches, func(i, j int) bool {
+		return newerFirst(snapshot.Branches[j].CreatedAt, snapshot.Branches[j].ID, snapshot.Branches[i].CreatedAt, snapshot.Branches[i].ID)
+	})
+
+	for _, order := range s.db.orders {
+		snapshot.Orders = append(snapshot.Orders, orderRow(order))
+	}
+	sort.Slice(snapshot.Orders, func(i, j int) bool {
+		return newerFirst(snapshot.Orders[j].CreatedAt, snapshot.Orders[j].Id, snapshot.Orders[i].CreatedAt, snapshot.Orders[i].Id)
+	})
+
+	for _, orderProduct := range s.db.orderProducts {
+		row := *orderProduct
+		snapshot.OrderProducts = append(snapshot.OrderProducts, &row)
+	}
+	sort.Slice(snapshot.OrderProducts, func(i, j int) bool {
+		return newerFirst(snapshot.OrderProducts[j].CreatedAt, snapshot.OrderProducts[j].OrderProductID, snapshot.OrderProducts[i].CreatedAt, snapshot.OrderProducts[i].OrderProductID)
+	})
+
+	for _, change := range s.db.statusHistory {
+		row := *change
+		snapshot.StatusHistory = append(snapshot.StatusHistory, &row)
+	}
+	sort.Slice(snapshot.StatusHistory, func(i, j int) bool {
+		return newerFirst(snapshot.StatusHistory[j].CreatedAt, snapshot.StatusHistory[j].Id, snapshot.StatusHistory[i].CreatedAt, snapshot.StatusHistory[i].Id)
+	})
+
+	for _, entry := range s.db.audit {
+		row := *entry
+		snapshot.Audit = append(snapshot.Audit, &row)
+	}
+	sort.Slice(snapshot.Audit, func(i, j int) bool {
+		return newerFirst(snapshot.Audit[j].CreatedAt, snapshot.Audit[j].Id, snapshot.Audit[i].CreatedAt, snapshot.Audit[i].Id)
+	})
+
+	for name, value := range s.db.sequences {
+		snapshot.Sequences = append(snapshot.Sequences, &models.Sequence{Name: name, Value: value})
+	}
+	sort.Slice(snapshot.Sequences, func(i, j int) bool {
+		return snapshot.Sequences[i].Name < snapshot.Sequences[j].Name
+	})
+
+	for _, stock := range s.db.stock {
+		snapshot.Stock = append(snapshot.Stock, stockRow(stock))
+	}
+	sort.Slice(snapshot.Stock, func(i, j int) bool {
+		return stockKey(snapshot.Stock[i].BranchId, snapshot.Stock[i].ProductId) < stockKey(snapshot.Stock[j].BranchId, snapshot.Stock[j].ProductId)
+	})
+
+	for _, movement := range s.db.movements {
+		row := *movement
+		snapshot.Movements = append(snapshot.Movements, &row)
+	}
+	sort.Slice(snapshot.Movements, func(i, j int) bool {
+		return newerFirst(snapshot.Movements[j].CreatedAt, snapshot.Movements[j].Id, snapshot.Movements[i].CreatedAt, snapshot.Movements[i].Id)
+	})
+
+	for _, transfer := range s.db.transfers {
+		row := *transfer
+		row.Items = append([]models.StockTransferItem(nil), transfer.Items...)
+		snapshot.Transfers = append(snapshot.Transfers, &row)
+	}
+	sort.Slice(snapshot.Transfers, func(i, j int) bool {
+		return newerFirst(snapshot.Transfers[j].CreatedAt, snapshot.Transfers[j].Id, snapshot.Transfers[i].CreatedAt, snapshot.Transfers[i].Id)
+	})
+
+	for _, promotion := range s.db.promotions {
+		snapshot.Promotions = append(snapshot.Promotions, promotionRow(promotion))
+	}
+	sort.Slice(snapshot.Promotions, func(i, j int) bool {
+		return newerFirst(snapshot.Promotions[j].CreatedAt, snapshot.Promotions[j].Id, snapshot.Promotions[i].CreatedAt, snapshot.Promotions[i].Id)
+	})
+
+	for _, rate := range s.db.taxRates {
+		row := *rate
+		snapshot.TaxRates = append(snapshot.TaxRates, &row)
+	}
+	sort.Slice(snapshot.TaxRates, func(i, j int) bool {
+		return newerFirst(snapshot.TaxRates[j].CreatedAt, snapshot.TaxRates[j].Id, snapshot.TaxRates[i].CreatedAt, snapshot.TaxRates[i].Id)
+	})
+
+	for _, tariff := range s.db.tariffs {
+		snapshot.Tariffs = append(snapshot.Tariffs, tariffRow(tariff))
+	}
+	sort.Slice(snapshot.Tariffs, func(i, j int) bool {
+		return newerFirst(snapshot.Tariffs[j].CreatedAt, snapshot.Tariffs[j].BranchId, snapshot.Tariffs[i].CreatedAt, snapshot.Tariffs[i].BranchId)
+	})
+
+	return snapshot
+}
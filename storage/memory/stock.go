@@ -0,0 +1,467 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/ledger"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type stockRepo struct {
+	db *db
+}
+
+func NewStockRepo(db *db) *stockRepo {
+	return &stockRepo{
+		db: db,
+	}
+}
+
+func (r *stockRepo) GetByID(ctx context.Context, req *models.StockPrimaryKey) (*models.Stock, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stock, ok := r.db.stock[stockKey(req.BranchId, req.ProductId)]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return stockRow(stock), nil
+}
+
+func (r *stockRepo) GetList(ctx context.Context, req *models.GetListStockRequest) (*models.GetListStockResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListStockResponse
+		filtered []*models.Stock
+	)
+
+	for _, stock := range r.db.stock {
+		if req.BranchId == "" || stock.BranchId == req.BranchId {
+			filtered = append(filtered, stock)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].BranchId != filtered[j].BranchId {
+			return filtered[i].BranchId < filtered[j].BranchId
+		}
+
+		return filtered[i].ProductId < filtered[j].ProductId
+	})
+
+	start, end, _, err := window(req.Offset, req.Limit, "", len(filtered), func(i int) (string, string) {
+		return filtered[i].BranchId, filtered[i].ProductId
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stock := range filtered[start:end] {
+		resp.Stocks = append(resp.Stocks, stockRow(stock))
+	}
+
+	resp.Count = len(filtered)
+	return &resp, nil
+}
+
+func (r *stockRepo) Adjust(ctx context.Context, req *models.AdjustStock) (*models.Stock, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := ledger.CheckAdjust(req.Type, req.Delta); err != nil {
+		return nil, err
+	}
+
+	if branch, ok := r.db.branches[req.BranchId]; !ok || branch.DeletedAt != "" {
+		return nil, fmt.Errorf("stock branch_id %s does not exist", req.BranchId)
+	}
+
+	if product, ok := r.db.products[req.ProductId]; !ok || product.DeletedAt != "" {
+		return nil, fmt.Errorf("stock product_id %s does not exist", req.ProductId)
+	}
+
+	var stock models.Stock
+	if current, ok := r.db.stock[stockKey(req.BranchId, req.ProductId)]; ok {
+		stock = *current
+	}
+
+	if quantity := stock.Quantity + req.Delta; quantity < stock.Reserved {
+		return nil, fmt.Errorf("%w: %v of product %s is reserved at branch %s, the quantity cannot go down to %v",
+			storage.ErrInsufficientStock, stock.Reserved, req.ProductId, req.BranchId, quantity)
+	}
+
+	r.db.recordMovement(req.BranchId, req.ProductId, req.Type, req.Delta, req.Reference, req.Actor)
+
+	return stockRow(r.db.stock[stockKey(req.BranchId, req.ProductId)]), nil
+}
+
+func (r *stockRepo) Movements(ctx context.Context, req *models.GetListStockMovementRequest) (*models.GetListStockMovementResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListStockMovementResponse
+		filtered []*models.StockMovement
+		from, to = period(req.From, req.To)
+	)
+
+	for _, movement := range r.db.movements {
+		if req.BranchId != "" && movement.BranchId != req.BranchId ||
+			req.ProductId != "" && movement.ProductId != req.ProductId ||
+			req.Type != "" && movement.Type != req.Type ||
+			req.Reference != "" && movement.Reference != req.Reference ||
+			movement.CreatedAt < from || to != "" && movement.CreatedAt >= to {
+			continue
+		}
+
+		filtered = append(filtered, movement)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[j].CreatedAt, filtered[j].Id, filtered[i].CreatedAt, filtered[i].Id)
+	})
+
+	start, end, _, err := window(req.Offset, req.Limit, "", len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, movement := range filtered[start:end] {
+		row := *movement
+		resp.Movements = append(resp.Movements, &row)
+	}
+
+	resp.Count = len(filtered)
+	return &resp, nil
+}
+
+func (r *stockRepo) Reconcile(ctx context.Context, req *models.StockReconciliationRequest) (*models.StockReconciliation, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     = models.StockReconciliation{BranchId: req.BranchId}
+		lines    = make(map[string]*models.StockReconciliationLine)
+		from, to = period(req.From, req.To)
+	)
+
+	for _, movement := range r.db.movements {
+		if movement.BranchId != req.BranchId || to != "" && movement.CreatedAt >= to {
+			continue
+		}
+
+		line, ok := lines[movement.ProductId]
+		if !ok {
+			line = &models.StockReconciliationLine{ProductId: movement.ProductId, Movements: make(map[string]float64)}
+			lines[movement.ProductId] = line
+		}
+
+		if movement.CreatedAt < from {
+			line.Opening += movement.Quantity
+		} else {
+			line.Movements[movement.Type] += movement.Quantity
+		}
+
+		line.Closing += movement.Quantity
+	}
+
+	for _, line := range lines {
+		if line.Opening != 0 || len(line.Movements) > 0 {
+			resp.Products = append(resp.Products, line)
+		}
+	}
+
+	sort.Slice(resp.Products, func(i, j int) bool {
+		return resp.Products[i].ProductId < resp.Products[j].ProductId
+	})
+
+	return &resp, nil
+}
+
+func (r *stockRepo) Transfer(ctx context.Context, req *models.CreateStockTransfer) (*models.StockTransfer, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: a transfer needs items", ledger.ErrInvalid)
+	}
+
+	for _, branchID := range []string{req.FromBranchId, req.ToBranchId} {
+		if branch, ok := r.db.branches[branchID]; !ok || branch.DeletedAt != "" {
+			return nil, fmt.Errorf("stock transfer branch %s does not exist", branchID)
+		}
+	}
+
+	// Every item is checked before any moves, so a failing item leaves no
+	// half written transfer behind.
+	requested := make(map[string]float64)
+	for _, item := range req.Items {
+		if err := ledger.CheckTransfer(req.FromBranchId, req.ToBranchId, item.Quantity); err != nil {
+			return nil, err
+		}
+
+		if product, ok := r.db.products[item.ProductId]; !ok || product.DeletedAt != "" {
+			return nil, fmt.Errorf("stock transfer product_id %s does not exist", item.ProductId)
+		}
+
+		requested[item.ProductId] += item.Quantity
+	}
+
+	for productID, quantity := range requested {
+		if available := r.db.available(req.FromBranchId, productID); quantity > available {
+			return nil, fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+				storage.ErrInsufficientStock, available, productID, req.FromBranchId, quantity)
+		}
+	}
+
+	transfer := models.StockTransfer{
+		Id:           uuid.New().String(),
+		FromBranchId: req.FromBranchId,
+		ToBranchId:   req.ToBranchId,
+		Reference:    req.Reference,
+		Actor:        req.Actor,
+		Items:        append([]models.StockTransferItem(nil), req.Items...),
+		CreatedAt:    now(),
+	}
+
+	for _, item := range req.Items {
+		r.db.recordMovement(req.FromBranchId, item.ProductId, ledger.TransferOut, -item.Quantity, transfer.Id, req.Actor)
+		r.db.recordMovement(req.ToBranchId, item.ProductId, ledger.TransferIn, item.Quantity, transfer.Id, req.Actor)
+	}
+
+	r.db.transfers[transfer.Id] = &transfer
+
+	resp := transfer
+	resp.Items = append([]models.StockTransferItem(nil), transfer.Items...)
+	return &resp, nil
+}
+
+func (r *stockRepo) GetTransfer(ctx context.Context, req *models.StockTransferPrimaryKey) (*models.StockTransfer, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	transfer, ok := r.db.transfers[req.Id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *transfer
+	resp.Items = append([]models.StockTransferItem(nil), transfer.Items...)
+	return &resp, nil
+}
+
+// period formats the bounds of a ledger query for comparing with
+// created_at. Zero bounds are empty and do not limit the query.
+func period(from, to time.Time) (string, string) {
+	return formatTime(from), formatTime(to)
+}
+
+func stockKey(branchID, productID string) string {
+	return branchID + "/" + productID
+}
+
+// stockRow copies stock and works out what is available.
+func stockRow(stock *models.Stock) *models.Stock {
+	row := *stock
+	row.Available = row.Quantity - row.Reserved
+	return &row
+}
+
+func (d *db) available(branchID, productID string) float64 {
+
+	stock, ok := d.stock[stockKey(branchID, productID)]
+	if !ok {
+		return 0
+	}
+
+	return stock.Quantity - stock.Reserved
+}
+
+// reserve holds quantity of a product at a branch. freed is what the
+// caller is about to release of the same product, such as the old
+// quantity of an updated line.
+func (d *db) reserve(branchID, productID string, quantity, freed float64) error {
+
+	if available := d.available(branchID, productID) + freed; quantity > available {
+		return fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+			storage.ErrInsufficientStock, available, productID, branchID, quantity)
+	}
+
+	d.release(branchID, productID, freed)
+	if stock, ok := d.stock[stockKey(branchID, productID)]; ok {
+		stock.Reserved += quantity
+		stock.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+// release gives back reserved stock.
+func (d *db) release(branchID, productID string, quantity float64) {
+
+	stock, ok := d.stock[stockKey(branchID, productID)]
+	if !ok || quantity == 0 {
+		return
+	}
+
+	stock.Reserved -= quantity
+	stock.UpdatedAt = now()
+}
+
+// recordMovement changes the quantity a branch holds of a product and
+// writes the change to the ledger.
+func (d *db) recordMovement(branchID, productID, movementType string, quantity float64, reference, actor string) {
+
+	key := stockKey(branchID, productID)
+	stock, ok := d.stock[key]
+	if !ok {
+		stock = &models.Stock{BranchId: branchID, ProductId: productID}
+		d.stock[key] = stock
+	}
+
+	movement := models.StockMovement{
+		Id:        uuid.New().String(),
+		BranchId:  branchID,
+		ProductId: productID,
+		Type:      movementType,
+		Quantity:  quantity,
+		Reference: reference,
+		Actor:     actor,
+		CreatedAt: now(),
+	}
+	d.movements[movement.Id] = &movement
+
+	stock.Quantity += quantity
+	stock.UpdatedAt = movement.CreatedAt
+}
+
+// openOrder returns the order of a line write. Lines of closed orders no
+// longer change, as their stock is settled.
+func (d *db) openOrder(orderID string) (*models.Order, error) {
+
+	order, ok := d.orders[orderID]
+	if !ok || order.DeletedAt != "" {
+		return nil, fmt.Errorf("order product order_id %s does not exist", orderID)
+	}
+
+	if !workflow.Default.Open(order.Status) {
+		return nil, fmt.Errorf("%w: order %s is %s, its lines cannot change", workflow.ErrTransition, orderID, order.Status)
+	}
+
+	return order, nil
+}
+
+// settleStock ends the reservations of an order that is closed: finished
+// orders sell their stock, others give it back.
+func (d *db) settleStock(order *models.Order, status, actor string) {
+
+	sold := make(map[string]float64)
+	for _, orderProduct := range d.orderProducts {
+		if orderProduct.OrderID != order.Id || orderProduct.Reserved == 0 {
+			continue
+		}
+
+		d.release(order.BranchId, orderProduct.ProductID, orderProduct.Reserved)
+		if status == workflow.StatusFinished {
+			sold[orderProduct.ProductID] += orderProduct.Reserved
+		}
+
+		orderProduct.Reserved = 0
+	}
+
+	for productID, quantity := range sold {
+		d.recordMovement(order.BranchId, productID, ledger.Sale, -quantity, order.Id, actor)
+	}
+}
+
+// reserveOrder reserves the lines of a restored order again, or fails
+// without reserving any when its branch has too little.
+func (d *db) reserveOrder(order *models.Order) error {
+
+	requested := make(map[string]float64)
+	for _, orderProduct := range d.orderProducts {
+		if orderProduct.OrderID == order.Id && orderProduct.DeletedAt == "" {
+			requested[orderProduct.ProductID] += orderProduct.Quantity
+		}
+	}
+
+	for productID, quantity := range requested {
+		if available := d.available(order.BranchId, productID); quantity > available {
+			return fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+				storage.ErrInsufficientStock, available, productID, order.BranchId, quantity)
+		}
+	}
+
+	for _, orderProduct := range d.orderProducts {
+		if orderProduct.OrderID != order.Id || orderProduct.DeletedAt != "" {
+			continue
+		}
+
+		if err := d.reserve(order.BranchId, orderProduct.ProductID, orderProduct.Quantity, 0); err != nil {
+			return err
+		}
+
+		orderProduct.Reserved = orderProduct.Quantity
+	}
+
+	return nil
+}
+
+// restock brings back what a finished order sold, to the branches it was
+// sold from, when the order is returned.
+func (d *db) restock(order *models.Order, actor string) {
+
+	type branchProduct struct{ branchID, productID string }
+
+	sold := make(map[branchProduct]float64)
+	for _, movement := range d.movements {
+		if movement.Type == ledger.Sale && movement.Reference == order.Id {
+			sold[branchProduct{movement.BranchId, movement.ProductId}] += movement.Quantity
+		}
+	}
+
+	for key, quantity := range sold {
+		d.recordMovement(key.branchID, key.productID, ledger.Return, -quantity, order.Id, actor)
+	}
+}
+
+// moveStock moves the reservations of an order to another branch, or
+// fails without moving any when that branch has too little.
+func (d *db) moveStock(order *models.Order, branchID string) error {
+
+	reserved := make(map[string]float64)
+	for _, orderProduct := range d.orderProducts {
+		if orderProduct.OrderID == order.Id {
+			reserved[orderProduct.ProductID] += orderProduct.Reserved
+		}
+	}
+
+	for productID, quantity := range reserved {
+		if available := d.available(branchID, productID); quantity > available {
+			return fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+				storage.ErrInsufficientStock, available, productID, branchID, quantity)
+		}
+	}
+
+	for productID, quantity := range reserved {
+		d.release(order.BranchId, productID, quantity)
+		if err := d.reserve(branchID, productID, quantity, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
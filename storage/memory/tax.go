@@ -0,0 +1,314 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/tax"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type taxRateRepo struct {
+	db *db
+}
+
+func NewTaxRateRepo(db *db) *taxRateRepo {
+	return &taxRateRepo{
+		db: db,
+	}
+}
+
+func (r *taxRateRepo) Create(ctx context.Context, req *models.CreateTaxRate) (*models.TaxRate, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var (
+		createdAt = now()
+		rate      = models.TaxRate{
+			Id:        uuid.New().String(),
+			Name:      req.Name,
+			Percent:   req.Percent,
+			Inclusive: req.Inclusive,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+			Version:   1,
+		}
+	)
+
+	if err := taxRate(&rate).Validate(); err != nil {
+		return nil, err
+	}
+
+	r.db.taxRates[rate.Id] = &rate
+
+	resp := rate
+	return &resp, nil
+}
+
+func (r *taxRateRepo) GetByID(ctx context.Context, req *models.TaxRatePrimaryKey) (*models.TaxRate, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	rate, ok := r.db.taxRates[req.Id]
+	if !ok || rate.DeletedAt != "" {
+		return nil, sql.ErrNoRows
+	}
+
+	resp := *rate
+	return &resp, nil
+}
+
+func (r *taxRateRepo) GetList(ctx context.Context, req *models.GetListTaxRateRequest) (*models.GetListTaxRateResponse, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.GetListTaxRateResponse
+		filtered []*models.TaxRate
+		search   = strings.ToLower(req.Search)
+	)
+
+	for _, rate := range r.db.taxRates {
+		if rate.DeletedAt != "" && !req.IncludeDeleted {
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(rate.Name), search) {
+			filtered = append(filtered, rate)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return newerFirst(filtered[i].CreatedAt, filtered[i].Id, filtered[j].CreatedAt, filtered[j].Id)
+	})
+
+	start, end, _, err := window(req.Offset, req.Limit, "", len(filtered), func(i int) (string, string) {
+		return filtered[i].CreatedAt, filtered[i].Id
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rate := range filtered[start:end] {
+		item := *rate
+		resp.TaxRates = append(resp.TaxRates, &item)
+	}
+
+	resp.Count = len(filtered)
+	return &resp, nil
+}
+
+func (r *taxRateRepo) Update(ctx context.Context, req *models.UpdateTaxRate) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rate, ok := r.db.taxRates[req.Id]
+	if !ok || rate.DeletedAt != "" {
+		return 0, nil
+	}
+
+	if req.Version != 0 && req.Version != rate.Version {
+		return 0, storage.ErrVersionConflict
+	}
+
+	updated := *rate
+	updated.Name = req.Name
+	updated.Percent = req.Percent
+	updated.Inclusive = req.Inclusive
+
+	if err := taxRate(&updated).Validate(); err != nil {
+		return 0, err
+	}
+
+	updated.UpdatedAt = now()
+	updated.Version++
+	*rate = updated
+
+	return 1, nil
+}
+
+func (r *taxRateRepo) Delete(ctx context.Context, req *models.TaxRatePrimaryKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if rate, ok := r.db.taxRates[req.Id]; ok && rate.DeletedAt == "" {
+		rate.DeletedAt = now()
+	}
+
+	return nil
+}
+
+// Report adds up the stored breakdowns of the completed sales, one line per
+// branch and rate, ordered by branch and then as tax.Summarize orders
+// rates. A returned order was sold and refunded, which nets to nothing, so
+// it is left out like orders that are not sold yet.
+func (r *taxRateRepo) Report(ctx context.Context, req *models.TaxReportRequest) (*models.TaxReport, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var (
+		resp     models.TaxReport
+		lines    = make(map[models.TaxReportLine]*models.TaxReportLine)
+		from, to = period(req.From, req.To)
+	)
+
+	for _, order := range r.db.orders {
+		if order.DeletedAt != "" || order.Status != workflow.StatusDelivered && order.Status != workflow.StatusFinished {
+			continue
+		}
+
+		if req.BranchId != "" && order.BranchId != req.BranchId || order.CreatedAt < from || to != "" && order.CreatedAt >= to {
+			continue
+		}
+
+		for _, orderTax := range order.Taxes {
+			key := models.TaxReportLine{
+				BranchId:  order.BranchId,
+				TaxRateId: orderTax.TaxRateId,
+				Percent:   orderTax.Percent,
+				Inclusive: orderTax.Inclusive,
+			}
+
+			line, ok := lines[key]
+			if !ok {
+				line = &key
+				lines[key] = line
+				resp.Lines = append(resp.Lines, line)
+			}
+
+			line.Orders++
+			line.Base += orderTax.Base
+			line.Tax += orderTax.Tax
+		}
+	}
+
+	sort.Slice(resp.Lines, func(i, j int) bool {
+		a, b := resp.Lines[i], resp.Lines[j]
+		if a.BranchId != b.BranchId {
+			return a.BranchId < b.BranchId
+		}
+
+		if a.Percent != b.Percent {
+			return a.Percent < b.Percent
+		}
+
+		if a.TaxRateId != b.TaxRateId {
+			return a.TaxRateId < b.TaxRateId
+		}
+
+		return !a.Inclusive && b.Inclusive
+	})
+
+	return &resp, nil
+}
+
+// checkTaxRate mirrors the foreign keys on the tax_rate_id columns: an
+// empty id is no rate, any other must name a rate that is not deleted.
+func (d *db) checkTaxRate(table, taxRateID string) error {
+
+	if taxRateID == "" {
+		return nil
+	}
+
+	if rate, ok := d.taxRates[taxRateID]; !ok || rate.DeletedAt != "" {
+		return fmt.Errorf("%s tax_rate_id %s does not exist", table, taxRateID)
+	}
+
+	return nil
+}
+
+// productTaxRate returns the rate a new line of product is taxed at: the
+// rate of the product, or else of its category, or nil for none. Deleted
+// rates no longer apply.
+func (d *db) productTaxRate(product *models.Product) *models.TaxRate {
+
+	taxRateID := product.TaxRateId
+	if taxRateID == "" {
+		if category, ok := d.categories[product.CategoryId]; ok {
+			taxRateID = category.TaxRateId
+		}
+	}
+
+	if rate, ok := d.taxRates[taxRateID]; ok && rate.DeletedAt == "" {
+		return rate
+	}
+
+	return nil
+}
+
+// setLineTaxRate copies the rate of product onto a line, which keeps it
+// from then on.
+func (d *db) setLineTaxRate(orderProduct *models.OrderProduct, product *models.Product) {
+
+	orderProduct.TaxRateId, orderProduct.TaxPercent, orderProduct.TaxInclusive = "", 0, false
+	if rate := d.productTaxRate(product); rate != nil {
+		orderProduct.TaxRateId, orderProduct.TaxPercent, orderProduct.TaxInclusive = rate.Id, rate.Percent, rate.Inclusive
+	}
+}
+
+// taxation is the tax orderTotals works out for the discounted lines of an
+// order: the breakdown by rate and the tax of every line by its id.
+type taxation struct {
+	buckets []tax.Bucket
+	lines   map[string]money.Amount
+}
+
+// setLineTaxes stores the tax of every line of an order, so the lines add
+// up to the tax of the order once a promotion shares its discount out.
+func (d *db) setLineTaxes(lines map[string]money.Amount) {
+	for id, lineTax := range lines {
+		d.orderProducts[id].Tax = lineTax
+	}
+}
+
+// orderTaxes turns the breakdown orderTotals returns into the one an order
+// stores.
+func orderTaxes(buckets []tax.Bucket) []models.OrderTax {
+
+	var taxes []models.OrderTax
+	for _, bucket := range buckets {
+		taxes = append(taxes, models.OrderTax{
+			TaxRateId: bucket.RateId,
+			Percent:   bucket.Percent,
+			Inclusive: bucket.Inclusive,
+			Base:      bucket.Base,
+			Tax:       bucket.Tax,
+		})
+	}
+
+	return taxes
+}
+
+func sameTaxes(a, b []models.OrderTax) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func taxRate(rate *models.TaxRate) tax.Rate {
+	return tax.Rate{Percent: rate.Percent, Inclusive: rate.Inclusive}
+}
+
+// orderRow copies an order together with its tax breakdown.
+func orderRow(order *models.Order) *models.Order {
+	row := *order
+	row.Taxes = append([]models.OrderTax(nil), order.Taxes...)
+	return &row
+}
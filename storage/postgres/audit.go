@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+
+	"github.com/google/uuid"
+)
+
+var auditColumns = filter.Columns{
+	"entity":    `"entity"`,
+	"entity_id": `"entity_id"`,
+}
+
+type auditRepo struct {
+	db dbtx
+}
+
+func NewAuditRepo(db dbtx) *auditRepo {
+	return &auditRepo{
+		db: db,
+	}
+}
+
+func (r *auditRepo) Create(ctx context.Context, req *models.CreateAuditEntry) (*models.AuditEntry, error) {
+	var (
+		entry = models.AuditEntry{
+			Id:       uuid.New().String(),
+			Entity:   req.Entity,
+			EntityId: req.EntityId,
+			Action:   req.Action,
+			Actor:    req.Actor,
+			Before:   req.Before,
+			After:    req.After,
+			Diff:     req.Diff,
+		}
+		query = `
+			INSERT INTO "audit_log"(
+				"id",
+				"entity",
+				"entity_id",
+				"action",
+				"actor",
+				"before",
+				"after",
+				"diff"
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING "created_at"`
+	)
+
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		entry.Id,
+		entry.Entity,
+		entry.EntityId,
+		entry.Action,
+		entry.Actor,
+		jsonb(entry.Before),
+		jsonb(entry.After),
+		jsonb(entry.Diff),
+	).Scan(&entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *auditRepo) GetList(ctx context.Context, req *models.GetListAuditRequest) (*models.GetListAuditResponse, error) {
+	var (
+		resp  models.GetListAuditResponse
+		conds []filter.Expr
+	)
+
+	if req.Entity != "" {
+		conds = append(conds, filter.Eq("entity", req.Entity))
+	}
+
+	if req.EntityId != "" {
+		conds = append(conds, filter.Eq("entity_id", req.EntityId))
+	}
+
+	where, args, err := filter.Where(filter.And(conds...), auditColumns, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var query = `
+		SELECT
+			` + page.count + `,
+			"id",
+			"entity",
+			"entity_id",
+			"action",
+			"actor",
+			"before",
+			"after",
+			"diff",
+			"created_at"
+		FROM "audit_log"
+	`
+
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry               models.AuditEntry
+			before, after, diff []byte
+		)
+
+		err = rows.Scan(
+			&resp.Count,
+			&entry.Id,
+			&entry.Entity,
+			&entry.EntityId,
+			&entry.Action,
+			&entry.Actor,
+			&before,
+			&after,
+			&diff,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Before, entry.After, entry.Diff = before, after, diff
+
+		resp.Entries = append(resp.Entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.Entries), func(i int) (string, string) {
+		return resp.Entries[i].CreatedAt, resp.Entries[i].Id
+	})
+	resp.Entries, resp.NextCursor = resp.Entries[:n], next
+
+	return &resp, nil
+}
+
+// jsonb passes an empty snapshot as NULL.
+func jsonb(value json.RawMessage) interface{} {
+
+	if len(value) == 0 {
+		return nil
+	}
+
+	return string(value)
+}
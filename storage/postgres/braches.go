@@ -1,27 +1,46 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"strconv"
 	"time"
 
 	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
 
 	"github.com/google/uuid"
 )
 
+var branchColumns = filter.Columns{
+	"id":              `"id"`,
+	"name":            `"name"`,
+	"phone":           `"phone"`,
+	"address":         `"address"`,
+	"latitude":        `"latitude"`,
+	"longitude":       `"longitude"`,
+	"order_prefix":    `"order_prefix"`,
+	"work_start_hour": `"work_start_hour"`,
+	"work_end_hour":   `"work_end_hour"`,
+	"timezone":        `"timezone"`,
+	"delivery_price":  `"delivery_price"`,
+	"active":          `"active"`,
+	"created_at":      `"created_at"`,
+	"updated_at":      `"updated_at"`,
+	"deleted_at":      `"deleted_at"`,
+}
+
 type branchRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewBranchRepo(db *sql.DB) *branchRepo {
+func NewBranchRepo(db dbtx) *branchRepo {
 	return &branchRepo{
 		db: db,
 	}
 }
 
-func (r *branchRepo) Create(req *models.CreateBranch) (*models.Branch, error) {
+func (r *branchRepo) Create(ctx context.Context, req *models.CreateBranch) (*models.Branch, error) {
 	branchID := uuid.New().String()
 	query := `
 		INSERT INTO "branches"(
@@ -31,13 +50,43 @@ func (r *branchRepo) Create(req *models.CreateBranch) (*models.Branch, error) {
 			"photo",
 			"work_start_hour",
 			"work_end_hour",
+			"timezone",
+			"schedule",
+			"holidays",
 			"address",
+			"order_prefix",
+			"latitude",
+			"longitude",
+			"timezone",
+			"schedule",
+			"holidays",
 			"created_at",
 			"updated_at"
-		) VALUES ($1, $2, $3, $4, $5, $6, $7,  NOW(), NOW())
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW(), NOW())
 	`
 
-	_, err := r.db.Exec(
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return nil, err
+	}
+
+	_, err := (&models.Branch{
+		WorkStartHour: req.WorkStartHour,
+		WorkEndHour:   req.WorkEndHour,
+		Timezone:      req.Timezone,
+		Schedule:      req.Schedule,
+		Holidays:      req.Holidays,
+	}).OpenHours()
+	if err != nil {
+		return nil, err
+	}
+
+	shifts, holidays, err := scheduleColumns(req.Schedule, req.Holidays)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
 		query,
 		branchID,
 		req.Name,
@@ -46,19 +95,27 @@ func (r *branchRepo) Create(req *models.CreateBranch) (*models.Branch, error) {
 		req.WorkStartHour,
 		req.WorkEndHour,
 		req.Address,
+		req.OrderPrefix,
+		req.Latitude,
+		req.Longitude,
+		req.Timezone,
+		shifts,
+		holidays,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetByID(&models.BranchPrimaryKey{ID: branchID})
+	return r.GetByID(ctx, &models.BranchPrimaryKey{ID: branchID})
 }
 
-func (r *branchRepo) GetByID(req *models.BranchPrimaryKey) (*models.Branch, error) {
+func (r *branchRepo) GetByID(ctx context.Context, req *models.BranchPrimaryKey) (*models.Branch, error) {
 	var (
-		branch models.Branch
-		query  = `
+		branch   models.Branch
+		shifts   []byte
+		holidays []byte
+		query    = `
 			SELECT
 				"id",
 				"name",
@@ -66,38 +123,56 @@ func (r *branchRepo) GetByID(req *models.BranchPrimaryKey) (*models.Branch, erro
 				"photo",
 				"work_start_hour",
 				"work_end_hour",
+				"timezone",
+				"schedule",
+				"holidays",
 				"address",
+				"latitude",
+				"longitude",
+				"order_prefix",
 				"delivery_price",
 				"active",
 				"created_at",
-				"updated_at"	
+				"updated_at",
+				"version"
 			FROM "branches"
-			WHERE "id" = $1
+			WHERE "id" = $1 AND "deleted_at" IS NULL
 		`
 	)
 
-	err := r.db.QueryRow(query, req.ID).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.ID).Scan(
 		&branch.ID,
 		&branch.Name,
 		&branch.Phone,
 		&branch.Photo,
 		&branch.WorkStartHour,
 		&branch.WorkEndHour,
+		&branch.Timezone,
+		&shifts,
+		&holidays,
 		&branch.Address,
+		&branch.Latitude,
+		&branch.Longitude,
+		&branch.OrderPrefix,
 		&branch.DeliveryPrice,
 		&branch.Active,
 		&branch.CreatedAt,
 		&branch.UpdatedAt,
+		&branch.Version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err := scanSchedule(&branch, shifts, holidays, time.Now()); err != nil {
+		return nil, err
+	}
+
 	return &branch, nil
 }
 
-func (r *branchRepo) Update(req *models.UpdateBranch) (int64, error) {
+func (r *branchRepo) Update(ctx context.Context, req *models.UpdateBranch) (int64, error) {
 	query := `
 		UPDATE branches
 			SET
@@ -108,11 +183,39 @@ func (r *branchRepo) Update(req *models.UpdateBranch) (int64, error) {
 				"work_end_hour" = $6,
 				"address" = $7,
 				"active" = $8,
+				"order_prefix" = $9,
+				"latitude" = $11,
+				"longitude" = $12,
+				"timezone" = $13,
+				"schedule" = $14,
+				"holidays" = $15,
+				"version" = "version" + 1,
 				"updated_at" = NOW()
-		WHERE id = $1
+		WHERE id = $1 AND "deleted_at" IS NULL AND ($10::BIGINT = 0 OR "version" = $10)
 	`
 
-	result, err := r.db.Exec(
+	if err := (geo.Point{Lat: req.Latitude, Lng: req.Longitude}).Validate(); err != nil {
+		return 0, err
+	}
+
+	_, err := (&models.Branch{
+		WorkStartHour: req.WorkStartHour,
+		WorkEndHour:   req.WorkEndHour,
+		Timezone:      req.Timezone,
+		Schedule:      req.Schedule,
+		Holidays:      req.Holidays,
+	}).OpenHours()
+	if err != nil {
+		return 0, err
+	}
+
+	shifts, holidays, err := scheduleColumns(req.Schedule, req.Holidays)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.ExecContext(
+		ctx,
 		query,
 		req.ID,
 		req.Name,
@@ -122,6 +225,13 @@ func (r *branchRepo) Update(req *models.UpdateBranch) (int64, error) {
 		req.WorkEndHour,
 		req.Address,
 		req.Active,
+		req.OrderPrefix,
+		req.Version,
+		req.Latitude,
+		req.Longitude,
+		req.Timezone,
+		shifts,
+		holidays,
 	)
 	if err != nil {
 		return 0, err
@@ -132,45 +242,75 @@ func (r *branchRepo) Update(req *models.UpdateBranch) (int64, error) {
 		return 0, err
 	}
 
+	if rowsAffected == 0 && req.Version != 0 {
+		return 0, checkVersion(ctx, r.db, "branches", "id", req.ID)
+	}
+
 	return rowsAffected, nil
 }
 
-func (r *branchRepo) Delete(req *models.BranchPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM branches WHERE id = $1", req.ID)
+func (r *branchRepo) Delete(ctx context.Context, req *models.BranchPrimaryKey) error {
+	query := `UPDATE "branches" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.ID)
 	return err
 }
 
-func (r *branchRepo) GetList(req *models.GetListBranchRequest) (*models.GetListBranchResponse, error) {
+func (r *branchRepo) Restore(ctx context.Context, req *models.BranchPrimaryKey) (int64, error) {
+	query := `UPDATE "branches" SET "deleted_at" = NULL WHERE "id" = $1 AND "deleted_at" IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, req.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *branchRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "branches" AS deleted
+		WHERE deleted."deleted_at" < $1
+			AND NOT EXISTS (SELECT 1 FROM "order" WHERE "order"."branch_id" = deleted."id")
+			AND NOT EXISTS (SELECT 1 FROM "stock_movements" WHERE "stock_movements"."branch_id" = deleted."id")
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *branchRepo) GetList(ctx context.Context, req *models.GetListBranchRequest) (*models.GetListBranchResponse, error) {
 	var (
 		resp   models.GetListBranchResponse
-		where  = " WHERE TRUE"
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		conds  = []filter.Expr{req.Filter}
+		readAt = time.Now()
 	)
 
-	hour := strconv.Itoa(time.Now().Hour())
-	minute := strconv.Itoa(time.Now().Minute())
-
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.ILike("name", req.Search))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
 	}
-	currentTime := hour + ":" + minute
-	if len(req.Search) > 0 {
-		where += " AND name ILIKE '%" + req.Search + "%' AND '" + currentTime + "' BETWEEN work_start_hour AND work_end_hour"
+
+	where, args, err := filter.Where(filter.And(conds...), branchColumns, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(req.Query) > 0 {
-		where += req.Query
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var query = `
 		SELECT
-			COUNT(*) OVER(),
+			` + page.count + `,
 			"id",
 			"name",
 			"phone",
@@ -178,22 +318,32 @@ func (r *branchRepo) GetList(req *models.GetListBranchRequest) (*models.GetListB
 			"work_start_hour",
 			"work_end_hour",
 			"address",
+			"latitude",
+			"longitude",
+			"order_prefix",
 			"delivery_price",
 			"active",
 			"created_at",
-			"updated_at"
+			"updated_at",
+			"deleted_at",
+			"version"
 		FROM "branches"
 	`
 
-	query += where + sort + offset + limit
-	rows, err := r.db.Query(query)
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var branch models.Branch
+		var (
+			branch    models.Branch
+			shifts    []byte
+			holidays  []byte
+			deletedAt sql.NullString
+		)
 
 		err = rows.Scan(
 			&resp.Count,
@@ -203,18 +353,28 @@ func (r *branchRepo) GetList(req *models.GetListBranchRequest) (*models.GetListB
 			&branch.Photo,
 			&branch.WorkStartHour,
 			&branch.WorkEndHour,
+			&branch.Timezone,
+			&shifts,
+			&holidays,
 			&branch.Address,
+			&branch.Latitude,
+			&branch.Longitude,
+			&branch.OrderPrefix,
 			&branch.DeliveryPrice,
 			&branch.Active,
 			&branch.CreatedAt,
 			&branch.UpdatedAt,
+			&deletedAt,
+			&branch.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		if !isBranchOpen(&branch, currentTime) {
-			branch.Active = false
+		branch.DeletedAt = deletedAt.String
+
+		if err := scanSchedule(&branch, shifts, holidays, readAt); err != nil {
+			return nil, err
 		}
 
 		resp.Branches = append(resp.Branches, &branch)
@@ -224,10 +384,10 @@ func (r *branchRepo) GetList(req *models.GetListBranchRequest) (*models.GetListB
 		return nil, err
 	}
 
-	return &resp, nil
-}
+	n, next := page.next(len(resp.Branches), func(i int) (string, string) {
+		return resp.Branches[i].CreatedAt, resp.Branches[i].ID
+	})
+	resp.Branches, resp.NextCursor = resp.Branches[:n], next
 
-// isBranchOpen проверяет, открыт ли бранч в текущее время
-func isBranchOpen(branch *models.Branch, currentTime string) bool {
-	return currentTime >= branch.WorkStartHour && currentTime <= branch.WorkEndHour
+	return &resp, nil
 }
@@ -1,26 +1,39 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"time"
 
 	"market_system/models"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
 
 	"github.com/google/uuid"
 )
 
+var categoryColumns = filter.Columns{
+	"id":          `"id"`,
+	"title":       `"title"`,
+	"parent_id":   `"parent_id"`,
+	"image":       `"image"`,
+	"tax_rate_id": `"tax_rate_id"`,
+	"created_at":  `"created_at"`,
+	"updated_at":  `"updated_at"`,
+	"deleted_at":  `"deleted_at"`,
+}
+
 type categoryRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewCategoryRepo(db *sql.DB) *categoryRepo {
+func NewCategoryRepo(db dbtx) *categoryRepo {
 	return &categoryRepo{
 		db: db,
 	}
 }
 
-func (r *categoryRepo) Create(req *models.CreateCategory) (*models.Category, error) {
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error) {
 	categoryID := uuid.New().String()
 	query := `
 		INSERT INTO "category"(
@@ -28,26 +41,33 @@ func (r *categoryRepo) Create(req *models.CreateCategory) (*models.Category, err
 			"title", 
 			"parent_id", 
 			"image",
+			"tax_rate_id",
 			"created_at",
 			"updated_at"
-		) VALUES ($1, $2, $3, $4, NOW(), NOW())`
+		) VALUES ($1, $2, $3, $4, $5, NOW(), NOW())`
+
+	if err := checkTaxRate(ctx, r.db, "category", req.TaxRateId); err != nil {
+		return nil, err
+	}
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
 		categoryID,
 		req.Title,
 		helpers.NewNullString(req.ParentID),
 		req.Image,
+		helpers.NewNullString(req.TaxRateId),
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetByID(&models.CategoryPrimaryKey{Id: categoryID})
+	return r.GetByID(ctx, &models.CategoryPrimaryKey{Id: categoryID})
 }
 
-func (r *categoryRepo) GetByID(req *models.CategoryPrimaryKey) (*models.Category, error) {
+func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
 	var (
 		category models.Category
 		query    = `
@@ -56,20 +76,24 @@ func (r *categoryRepo) GetByID(req *models.CategoryPrimaryKey) (*models.Category
 				"title",
 				COALESCE(CAST("parent_id" AS VARCHAR), ''),
 				"image",
+				COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
 				"created_at",
-				"updated_at"	
+				"updated_at",
+				"version"
 			FROM "category"
-			WHERE "id" = $1
+			WHERE "id" = $1 AND "deleted_at" IS NULL
 		`
 	)
 
-	err := r.db.QueryRow(query, req.Id).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
 		&category.Id,
 		&category.Title,
 		&category.ParentID,
 		&category.Image,
+		&category.TaxRateId,
 		&category.CreatedAt,
 		&category.UpdatedAt,
+		&category.Version,
 	)
 
 	if err != nil {
@@ -79,52 +103,57 @@ func (r *categoryRepo) GetByID(req *models.CategoryPrimaryKey) (*models.Category
 	return &category, nil
 }
 
-func (r *categoryRepo) GetList(req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
 	var (
-		resp   models.GetListCategoryResponse
-		where  = " WHERE TRUE"
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		resp  models.GetListCategoryResponse
+		conds = []filter.Expr{req.Filter}
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.ILike("title", req.Search))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
+	}
+
+	where, args, err := filter.Where(filter.And(conds...), categoryColumns, nil)
+	if err != nil {
+		return nil, err
 	}
-	if len(req.Search) > 0 {
-		where += " AND title ILIKE '%" + req.Search + "%'"
-	  }
 
-	if len(req.Query) > 0 {
-		where += req.Query
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var query = `
 		SELECT
-			COUNT(*) OVER(),
+			` + page.count + `,
 			"id",
 			"title",
 			"parent_id",
 			"image",
+			COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
 			"created_at",
-			"updated_at"
+			"updated_at",
+			"deleted_at",
+			"version"
 		FROM "category"
 	`
 
-	query += where + sort + offset + limit
-	rows, err := r.db.Query(query)
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
-			category models.Category
-			parentID sql.NullString
+			category  models.Category
+			parentID  sql.NullString
+			deletedAt sql.NullString
 		)
 
 		err = rows.Scan(
@@ -133,37 +162,59 @@ func (r *categoryRepo) GetList(req *models.GetListCategoryRequest) (*models.GetL
 			&category.Title,
 			&parentID,
 			&category.Image,
+			&category.TaxRateId,
 			&category.CreatedAt,
 			&category.UpdatedAt,
+			&deletedAt,
+			&category.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		category.ParentID = parentID.String
+		category.DeletedAt = deletedAt.String
 		resp.Categories = append(resp.Categories, &category)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.Categories), func(i int) (string, string) {
+		return resp.Categories[i].CreatedAt, resp.Categories[i].Id
+	})
+	resp.Categories, resp.NextCursor = resp.Categories[:n], next
+
 	return &resp, nil
 }
 
-func (r *categoryRepo) Update(req *models.UpdateCategory) (int64, error) {
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
 	query := `
 		UPDATE category
 			SET
 				title = $2,
 				parent_id = $3,
 				image = $4,
+				tax_rate_id = $6,
+				"version" = "version" + 1,
 				updated_at = NOW()
-		WHERE id = $1
+		WHERE id = $1 AND "deleted_at" IS NULL AND ($5::BIGINT = 0 OR "version" = $5)
 	`
 
-	result, err := r.db.Exec(
+	if err := checkTaxRate(ctx, r.db, "category", req.TaxRateId); err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.ExecContext(
+		ctx,
 		query,
 		req.Id,
 		req.Title,
 		helpers.NewNullString(req.ParentID),
 		req.Image,
+		req.Version,
+		helpers.NewNullString(req.TaxRateId),
 	)
 	if err != nil {
 		return 0, err
@@ -174,10 +225,43 @@ func (r *categoryRepo) Update(req *models.UpdateCategory) (int64, error) {
 		return 0, err
 	}
 
+	if rowsAffected == 0 && req.Version != 0 {
+		return 0, checkVersion(ctx, r.db, "category", "id", req.Id)
+	}
+
 	return rowsAffected, nil
 }
 
-func (r *categoryRepo) Delete(req *models.CategoryPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM category WHERE id = $1", req.Id)
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) error {
+	query := `UPDATE "category" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.Id)
 	return err
 }
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	query := `UPDATE "category" SET "deleted_at" = NULL WHERE "id" = $1 AND "deleted_at" IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "category" AS deleted
+		WHERE deleted."deleted_at" < $1
+			AND NOT EXISTS (SELECT 1 FROM "category" AS child WHERE child."parent_id" = deleted."id")
+			AND NOT EXISTS (SELECT 1 FROM "product" WHERE "product"."category_id" = deleted."id")
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -1,25 +1,38 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"time"
 
 	"market_system/models"
+	"market_system/pkg/filter"
 
 	"github.com/google/uuid"
 )
 
+var clientColumns = filter.Columns{
+	"id":            `"id"`,
+	"first_name":    `"first_name"`,
+	"last_name":     `"last_name"`,
+	"phone":         `"phone"`,
+	"date_of_birth": `"date_of_birth"`,
+	"created_at":    `"created_at"`,
+	"updated_at":    `"updated_at"`,
+	"deleted_at":    `"deleted_at"`,
+}
+
 type clientRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewClientRepo(db *sql.DB) *clientRepo {
+func NewClientRepo(db dbtx) *clientRepo {
 	return &clientRepo{
 		db: db,
 	}
 }
 
-func (r *clientRepo) Create(req *models.CreateClient) (*models.Client, error) {
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (*models.Client, error) {
 	clientID := uuid.New().String()
 	query := `
 		INSERT INTO "client"(
@@ -33,7 +46,8 @@ func (r *clientRepo) Create(req *models.CreateClient) (*models.Client, error) {
 			"updated_at"
 		) VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
 
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
 		clientID,
 		req.FirstName,
@@ -47,10 +61,10 @@ func (r *clientRepo) Create(req *models.CreateClient) (*models.Client, error) {
 		return nil, err
 	}
 
-	return r.GetByID(&models.ClientPrimaryKey{ID: clientID})
+	return r.GetByID(ctx, &models.ClientPrimaryKey{ID: clientID})
 }
 
-func (r *clientRepo) GetByID(req *models.ClientPrimaryKey) (*models.Client, error) {
+func (r *clientRepo) GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error) {
 	var (
 		client models.Client
 		query  = `
@@ -62,13 +76,14 @@ func (r *clientRepo) GetByID(req *models.ClientPrimaryKey) (*models.Client, erro
 				"photo",
 				"date_of_birth",
 				"created_at",
-				"updated_at"	
+				"updated_at",
+				"version"
 			FROM "client"
-			WHERE "id" = $1
+			WHERE "id" = $1 AND "deleted_at" IS NULL
 		`
 	)
 
-	err := r.db.QueryRow(query, req.ID).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.ID).Scan(
 		&client.ID,
 		&client.FirstName,
 		&client.LastName,
@@ -77,6 +92,7 @@ func (r *clientRepo) GetByID(req *models.ClientPrimaryKey) (*models.Client, erro
 		&client.DateOfBirth,
 		&client.CreatedAt,
 		&client.UpdatedAt,
+		&client.Version,
 	)
 
 	if err != nil {
@@ -85,33 +101,37 @@ func (r *clientRepo) GetByID(req *models.ClientPrimaryKey) (*models.Client, erro
 
 	return &client, nil
 }
-func (r *clientRepo) GetList(req *models.GetListClientRequest) (*models.GetListClientResponse, error) {
+func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientRequest) (*models.GetListClientResponse, error) {
 	var (
-		resp   models.GetListClientResponse
-		where  = " WHERE TRUE"
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		resp  models.GetListClientResponse
+		conds = []filter.Expr{req.Filter}
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.ILike("first_name", req.Search),
+			filter.ILike("last_name", req.Search),
+			filter.ILike("phone", req.Search),
+		))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
 	}
-	if len(req.Search) > 0 {
-		where += " AND first_name ILIKE" + " '%" + req.Search + "%'" + "OR last_name ILIKE" + "'%" + req.Search + "%'" + "OR phone ILIKE" + "'%" + req.Search + "%'"
+
+	where, args, err := filter.Where(filter.And(conds...), clientColumns, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(req.Query) > 0 {
-		where += req.Query
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var query = `
 		SELECT
-			COUNT(*) OVER(),
+			` + page.count + `,
 			"id",
 			"first_name",
 			"last_name",
@@ -119,18 +139,24 @@ func (r *clientRepo) GetList(req *models.GetListClientRequest) (*models.GetListC
 			"photo",
 			"date_of_birth",
 			"created_at",
-			"updated_at"
+			"updated_at",
+			"deleted_at",
+			"version"
 		FROM "client"
 	`
 
-	query += where + sort + offset + limit
-	rows, err := r.db.Query(query)
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var client models.Client
+		var (
+			client    models.Client
+			deletedAt sql.NullString
+		)
 
 		err = rows.Scan(
 			&resp.Count,
@@ -142,18 +168,31 @@ func (r *clientRepo) GetList(req *models.GetListClientRequest) (*models.GetListC
 			&client.DateOfBirth,
 			&client.CreatedAt,
 			&client.UpdatedAt,
+			&deletedAt,
+			&client.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		client.DeletedAt = deletedAt.String
+
 		resp.Clients = append(resp.Clients, &client)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.Clients), func(i int) (string, string) {
+		return resp.Clients[i].CreatedAt, resp.Clients[i].ID
+	})
+	resp.Clients, resp.NextCursor = resp.Clients[:n], next
+
 	return &resp, nil
 }
 
-func (r *clientRepo) Update(req *models.UpdateClient) (int64, error) {
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
 	query := `
 		UPDATE client
 			SET
@@ -162,11 +201,13 @@ func (r *clientRepo) Update(req *models.UpdateClient) (int64, error) {
 				phone = $4,
 				photo = $5,
 				date_of_birth = $6,
+				"version" = "version" + 1,
 				updated_at = NOW()
-		WHERE id = $1
+		WHERE id = $1 AND "deleted_at" IS NULL AND ($7::BIGINT = 0 OR "version" = $7)
 	`
 
-	result, err := r.db.Exec(
+	result, err := r.db.ExecContext(
+		ctx,
 		query,
 		req.ID,
 		req.FirstName,
@@ -174,6 +215,7 @@ func (r *clientRepo) Update(req *models.UpdateClient) (int64, error) {
 		req.Phone,
 		req.Photo,
 		req.DateOfBirth,
+		req.Version,
 	)
 	if err != nil {
 		return 0, err
@@ -184,10 +226,42 @@ func (r *clientRepo) Update(req *models.UpdateClient) (int64, error) {
 		return 0, err
 	}
 
+	if rowsAffected == 0 && req.Version != 0 {
+		return 0, checkVersion(ctx, r.db, "client", "id", req.ID)
+	}
+
 	return rowsAffected, nil
 }
 
-func (r *clientRepo) Delete(req *models.ClientPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM client WHERE id = $1", req.ID)
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) error {
+	query := `UPDATE "client" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.ID)
 	return err
 }
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	query := `UPDATE "client" SET "deleted_at" = NULL WHERE "id" = $1 AND "deleted_at" IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, req.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *clientRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "client" AS deleted
+		WHERE deleted."deleted_at" < $1
+			AND NOT EXISTS (SELECT 1 FROM "order" WHERE "order"."client_id" = deleted."id")
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,361 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/geo"
+	"market_system/storage"
+)
+
+func (r *branchRepo) GetDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) (*models.DeliveryTariff, error) {
+	var (
+		tariff models.DeliveryTariff
+		bands  []byte
+		query  = `
+			SELECT
+				CAST("branch_id" AS VARCHAR),
+				"base_fee",
+				"per_km",
+				"bands",
+				"free_from",
+				"max_radius_km",
+				"created_at",
+				"updated_at",
+				"version"
+			FROM "delivery_tariffs"
+			WHERE CAST("branch_id" AS VARCHAR) = $1
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, query, req.ID).Scan(
+		&tariff.BranchId,
+		&tariff.BaseFee,
+		&tariff.PerKm,
+		&bands,
+		&tariff.FreeFrom,
+		&tariff.MaxRadiusKm,
+		&tariff.CreatedAt,
+		&tariff.UpdatedAt,
+		&tariff.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tariff.Bands = []models.DeliveryBand{}
+	if err := json.Unmarshal(bands, &tariff.Bands); err != nil {
+		return nil, err
+	}
+
+	return &tariff, nil
+}
+
+func (r *branchRepo) SetDeliveryTariff(ctx context.Context, req *models.DeliveryTariff) (*models.DeliveryTariff, error) {
+
+	if err := req.Tariff().Validate(); err != nil {
+		return nil, err
+	}
+
+	bands := req.Bands
+	if bands == nil {
+		bands = []models.DeliveryBand{}
+	}
+
+	body, err := json.Marshal(bands)
+	if err != nil {
+		return nil, err
+	}
+
+	err = inTx(ctx, r.db, func(tx dbtx) error {
+
+		var exists bool
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM "branches" WHERE "id" = $1 AND "deleted_at" IS NULL)
+		`, req.BranchId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return sql.ErrNoRows
+		}
+
+		var version int64
+
+		err = tx.QueryRowContext(ctx, `
+			SELECT "version" FROM "delivery_tariffs" WHERE "branch_id" = $1 FOR UPDATE
+		`, req.BranchId).Scan(&version)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if req.Version != 0 && req.Version != version {
+			return storage.ErrVersionConflict
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO "delivery_tariffs"(
+				"branch_id",
+				"base_fee",
+				"per_km",
+				"bands",
+				"free_from",
+				"max_radius_km",
+				"created_at",
+				"updated_at"
+			) VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+			ON CONFLICT ("branch_id") DO UPDATE
+				SET
+					"base_fee" = EXCLUDED."base_fee",
+					"per_km" = EXCLUDED."per_km",
+					"bands" = EXCLUDED."bands",
+					"free_from" = EXCLUDED."free_from",
+					"max_radius_km" = EXCLUDED."max_radius_km",
+					"version" = "delivery_tariffs"."version" + 1,
+					"updated_at" = NOW()
+		`,
+			req.BranchId,
+			req.BaseFee,
+			req.PerKm,
+			string(body),
+			req.FreeFrom,
+			req.MaxRadiusKm,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: req.BranchId})
+}
+
+func (r *branchRepo) DeleteDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) error {
+	query := `DELETE FROM "delivery_tariffs" WHERE CAST("branch_id" AS VARCHAR) = $1`
+
+	_, err := r.db.ExecContext(ctx, query, req.ID)
+	return err
+}
+
+// Nearest mirrors the memory store. Distances are measured the way orders
+// are priced, so the branches are sorted here rather than in the query.
+// Every branch is read, and by default checked for being open, at one
+// readAt.
+func (r *branchRepo) Nearest(ctx context.Context, req *models.NearestBranchRequest) (*models.NearestBranchResponse, error) {
+	var (
+		resp    models.NearestBranchResponse
+		address = geo.Point{Lat: req.Latitude, Lng: req.Longitude}
+		readAt  = time.Now()
+		at      = req.At
+		query   = `
+			SELECT
+				b."id",
+				b."name",
+				b."phone",
+				b."photo",
+				b."work_start_hour",
+				b."work_end_hour",
+				b."timezone",
+				b."schedule",
+				b."holidays",
+				b."address",
+				b."latitude",
+				b."longitude",
+				b."order_prefix",
+				b."delivery_price",
+				b."active",
+				b."created_at",
+				b."updated_at",
+				b."version",
+				t."branch_id" IS NOT NULL,
+				COALESCE(t."base_fee", 0),
+				COALESCE(t."per_km", 0),
+				COALESCE(t."bands", '[]'),
+				COALESCE(t."free_from", 0),
+				COALESCE(t."max_radius_km", 0)
+			FROM "branches" AS b
+			LEFT JOIN "delivery_tariffs" AS t ON t."branch_id" = b."id"
+			WHERE b."deleted_at" IS NULL AND b."active" AND (b."latitude" <> 0 OR b."longitude" <> 0)
+		`
+	)
+
+	if err := address.Validate(); err != nil {
+		return nil, err
+	}
+
+	if at.IsZero() {
+		at = readAt
+	}
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			branch    models.Branch
+			shifts    []byte
+			holidays  []byte
+			tariff    models.DeliveryTariff
+			hasTariff bool
+			bands     []byte
+		)
+
+		err := rows.Scan(
+			&branch.ID,
+			&branch.Name,
+			&branch.Phone,
+			&branch.Photo,
+			&branch.WorkStartHour,
+			&branch.WorkEndHour,
+			&branch.Timezone,
+			&shifts,
+			&holidays,
+			&branch.Address,
+			&branch.Latitude,
+			&branch.Longitude,
+			&branch.OrderPrefix,
+			&branch.DeliveryPrice,
+			&branch.Active,
+			&branch.CreatedAt,
+			&branch.UpdatedAt,
+			&branch.Version,
+			&hasTariff,
+			&tariff.BaseFee,
+			&tariff.PerKm,
+			&bands,
+			&tariff.FreeFrom,
+			&tariff.MaxRadiusKm,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(bands, &tariff.Bands); err != nil {
+			return nil, err
+		}
+
+		if err := scanSchedule(&branch, shifts, holidays, readAt); err != nil {
+			return nil, err
+		}
+
+		var branchTariff *delivery.Tariff
+		if hasTariff {
+			t := tariff.Tariff()
+			branchTariff = &t
+		}
+
+		resp.Branches = append(resp.Branches, nearestBranch(&branch, branchTariff, address, at, req.Speed))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(resp.Branches, func(i, j int) bool {
+		if resp.Branches[i].DistanceKm != resp.Branches[j].DistanceKm {
+			return resp.Branches[i].DistanceKm < resp.Branches[j].DistanceKm
+		}
+
+		return resp.Branches[i].ID < resp.Branches[j].ID
+	})
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	if int64(len(resp.Branches)) > limit {
+		resp.Branches = resp.Branches[:limit]
+	}
+
+	return &resp, nil
+}
+
+// nearestBranch quotes the delivery from branch to address, see
+// delivery.NewQuote.
+func nearestBranch(branch *models.Branch, tariff *delivery.Tariff, address geo.Point, at time.Time, speed delivery.Speed) *models.NearestBranch {
+
+	var (
+		quote = delivery.NewQuote(tariff, branch.DeliveryPrice, geo.Point{Lat: branch.Latitude, Lng: branch.Longitude}, address, speed)
+		resp  = models.NearestBranch{
+			Branch:          *branch,
+			DistanceKm:      quote.Km,
+			Deliverable:     quote.Deliverable,
+			DeliveryFee:     quote.Fee,
+			DeliveryMinutes: int64(quote.Estimate / time.Minute),
+		}
+	)
+
+	if s, err := branch.OpenHours(); err == nil {
+		resp.IsOpen = s.Open(at)
+	}
+
+	return &resp
+}
+
+// orderRoute mirrors the memory store: it returns the route the delivery
+// to address from branchID is priced by, or nil when the branch has no
+// location or no tariff. A branch with a tariff needs the location of the
+// address, so one without gets a delivery.ErrNoLocation error.
+func orderRoute(ctx context.Context, db dbtx, branchID string, address geo.Point) (*delivery.Route, error) {
+	var (
+		location geo.Point
+		bands    []byte
+		tariff   models.DeliveryTariff
+		query    = `
+			SELECT
+				b."latitude",
+				b."longitude",
+				t."base_fee",
+				t."per_km",
+				t."bands",
+				t."free_from",
+				t."max_radius_km"
+			FROM "branches" AS b
+			JOIN "delivery_tariffs" AS t ON t."branch_id" = b."id"
+			WHERE CAST(b."id" AS VARCHAR) = $1
+		`
+	)
+
+	err := db.QueryRowContext(ctx, query, branchID).Scan(
+		&location.Lat,
+		&location.Lng,
+		&tariff.BaseFee,
+		&tariff.PerKm,
+		&bands,
+		&tariff.FreeFrom,
+		&tariff.MaxRadiusKm,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if location.IsZero() {
+		return nil, nil
+	}
+
+	if address.IsZero() {
+		return nil, fmt.Errorf("%w: branch %s prices deliveries by distance", delivery.ErrNoLocation, branchID)
+	}
+
+	if err := json.Unmarshal(bands, &tariff.Bands); err != nil {
+		return nil, err
+	}
+
+	route := delivery.NewRoute(tariff.Tariff(), location, address)
+	return &route, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"fmt"
+
+	"market_system/pkg/cursor"
+)
+
+// listPage is the paging part of a GetList query. Lists are ordered by
+// created_at DESC and the id column. In offset mode it renders
+// OFFSET/LIMIT and selects the total count. With a cursor it continues
+// after the cursor's row through a keyset condition, which stays fast on
+// deep pages, and skips the count. Both modes fetch one row more than the
+// page size to tell whether a next page exists.
+type listPage struct {
+	count  string
+	where  string
+	tail   string
+	args   []interface{}
+	limit  int
+	cursor bool
+}
+
+func newListPage(where string, args []interface{}, idColumn string, offset, limit int64, token string) (*listPage, error) {
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	page := &listPage{
+		count: "COUNT(*) OVER()",
+		where: where,
+		args:  args,
+		limit: int(limit),
+	}
+
+	if token != "" {
+		c, err := cursor.Decode(token)
+		if err != nil {
+			return nil, err
+		}
+
+		page.args = append(page.args, c.CreatedAt, c.ID)
+		page.where += fmt.Sprintf(
+			` AND ("created_at", %s) < ($%d::timestamp, $%d::uuid)`,
+			idColumn, len(page.args)-1, len(page.args),
+		)
+		page.count = "0"
+		page.cursor = true
+		offset = 0
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	page.tail = fmt.Sprintf(
+		` ORDER BY "created_at" DESC, %s DESC OFFSET %d LIMIT %d`,
+		idColumn, offset, limit+1,
+	)
+
+	return page, nil
+}
+
+// next trims the extra row off the n fetched rows. It returns how many rows
+// belong to the page and the cursor of the next page, empty on the last one.
+// key returns the sort key of row i.
+func (p *listPage) next(n int, key func(i int) (string, string)) (int, string) {
+
+	if n <= p.limit {
+		return n, ""
+	}
+
+	createdAt, id := key(p.limit - 1)
+	return p.limit, cursor.Encode(createdAt, id)
+}
@@ -1,6 +1,7 @@
-
+-- IF NOT EXISTS lets databases created from the old sql_model/market.sql
+-- script adopt this migration without changes.
 --table for category
-CREATE TABLE "category" (
+CREATE TABLE IF NOT EXISTS "category" (
     "id" UUID NOT NULL PRIMARY KEY,
     "title" VARCHAR(46) NOT NULL,
     "parent_id" UUID REFERENCES "category" ("id"),
@@ -9,7 +10,7 @@ CREATE TABLE "category" (
     "updated_at" TIMESTAMP
 );
 
-CREATE TABLE "product" (
+CREATE TABLE IF NOT EXISTS "product" (
     "id" UUID NOT NULL PRIMARY KEY,
     "product_id" VARCHAR(255) NOT NULL UNIQUE,
     "title" VARCHAR(46) NOT NULL,
@@ -22,7 +23,7 @@ CREATE TABLE "product" (
 );
 
 
-CREATE TABLE "client" (
+CREATE TABLE IF NOT EXISTS "client" (
     "id" UUID NOT NULL PRIMARY KEY,
     "first_name" VARCHAR(50) NOT NULL,
     "last_name" VARCHAR(50) NOT NULL,
@@ -35,7 +36,7 @@ CREATE TABLE "client" (
 
 
 -- Table for branches
-CREATE TABLE "branches" (
+CREATE TABLE IF NOT EXISTS "branches" (
     "id" UUID NOT NULL PRIMARY KEY,
     "name" VARCHAR(100) NOT NULL,
     "phone" VARCHAR(20) NOT NULL,
@@ -51,7 +52,7 @@ CREATE TABLE "branches" (
 
 
 
-CREATE TABLE "order" (
+CREATE TABLE IF NOT EXISTS "order" (
     "id" UUID NOT NULL PRIMARY KEY,
     "order_id"  VARCHAR(255) NOT NULL UNIQUE ,
     "client_id" uuid not null REFERENCES "client"("id"),
@@ -66,7 +67,7 @@ CREATE TABLE "order" (
 );
 
 
-CREATE TABLE "order_products" (
+CREATE TABLE IF NOT EXISTS "order_products" (
     "order_product_id" UUID NOT NULL PRIMARY KEY,
     "order_id" UUID NOT NULL REFERENCES "order"("id"),
     "product_id" UUID NOT NULL REFERENCES "product"("id"),
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trigger_update_order_product_price ON "order_products";
+DROP TRIGGER IF EXISTS calculate_order_product_sum_trigger ON "order_products";
+DROP TRIGGER IF EXISTS update_totals ON "order_products";
+
+DROP FUNCTION IF EXISTS update_order_product_price();
+DROP FUNCTION IF EXISTS calculate_order_product_sum();
+DROP FUNCTION IF EXISTS calculate_order_totals();
@@ -43,7 +43,7 @@ BEGIN
 END;
 $$;
 
-CREATE TRIGGER calculate_order_product_sum_trigger
+CREATE OR REPLACE TRIGGER calculate_order_product_sum_trigger
 BEFORE INSERT OR UPDATE ON "order_products"
 FOR EACH ROW
 EXECUTE FUNCTION calculate_order_product_sum();
@@ -69,8 +69,7 @@ BEGIN
 END;
 $$;
 
-CREATE TRIGGER trigger_update_order_product_price
+CREATE OR REPLACE TRIGGER trigger_update_order_product_price
 BEFORE INSERT ON "order_products"
 FOR EACH ROW
 EXECUTE FUNCTION update_order_product_price();
-
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "order_products_created_at_id_idx";
+DROP INDEX IF EXISTS "order_created_at_id_idx";
+DROP INDEX IF EXISTS "branches_created_at_id_idx";
+DROP INDEX IF EXISTS "client_created_at_id_idx";
+DROP INDEX IF EXISTS "product_created_at_id_idx";
+DROP INDEX IF EXISTS "category_created_at_id_idx";
@@ -0,0 +1,8 @@
+-- Lists are ordered by created_at DESC and the primary key. These indexes
+-- serve both the first page and the keyset condition of cursor pages.
+CREATE INDEX IF NOT EXISTS "category_created_at_id_idx" ON "category" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "product_created_at_id_idx" ON "product" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "client_created_at_id_idx" ON "client" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "branches_created_at_id_idx" ON "branches" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "order_created_at_id_idx" ON "order" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "order_products_created_at_id_idx" ON "order_products" ("created_at" DESC, "order_product_id" DESC);
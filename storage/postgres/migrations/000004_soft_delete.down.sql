@@ -0,0 +1,7 @@
+-- Soft deleted rows become visible again.
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "client" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "product" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "category" DROP COLUMN IF EXISTS "deleted_at";
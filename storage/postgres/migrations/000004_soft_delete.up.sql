@@ -0,0 +1,9 @@
+-- Delete stamps deleted_at instead of removing the row, so orders keep
+-- their clients, branches and products. Rows are removed for good by the
+-- purge command once they are past the retention period.
+ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
+ALTER TABLE "client" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP;
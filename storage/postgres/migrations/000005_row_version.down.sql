@@ -0,0 +1,6 @@
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "client" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "product" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "category" DROP COLUMN IF EXISTS "version";
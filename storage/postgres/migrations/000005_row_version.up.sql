@@ -0,0 +1,8 @@
+-- version is bumped by every update. Clients send the version they read
+-- (as If-Match) and an update of a row that moved on is rejected.
+ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "client" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "version" BIGINT NOT NULL DEFAULT 1;
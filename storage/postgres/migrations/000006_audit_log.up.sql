@@ -0,0 +1,16 @@
+-- One row per write through the audit package. before, after and diff are
+-- JSON snapshots, NULL when there is nothing on that side.
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "entity" VARCHAR NOT NULL,
+    "entity_id" VARCHAR NOT NULL,
+    "action" VARCHAR NOT NULL,
+    "actor" VARCHAR NOT NULL,
+    "before" JSONB,
+    "after" JSONB,
+    "diff" JSONB,
+    "created_at" TIMESTAMP NOT NULL DEFAULT CLOCK_TIMESTAMP()
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_entity_idx" ON "audit_log" ("entity", "entity_id", "created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS "audit_log_created_at_id_idx" ON "audit_log" ("created_at" DESC, "id" DESC);
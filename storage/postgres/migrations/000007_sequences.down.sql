@@ -0,0 +1,2 @@
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "order_prefix";
+DROP TABLE IF EXISTS "sequences";
@@ -0,0 +1,20 @@
+-- Counters behind the human readable order and product numbers. A counter
+-- is named after everything its numbers share, such as "O-" or
+-- "O-TAS-20240102-", and incremented with an upsert, so instances never
+-- hand out the same number.
+CREATE TABLE IF NOT EXISTS "sequences" (
+    "name" VARCHAR NOT NULL PRIMARY KEY,
+    "value" BIGINT NOT NULL
+);
+
+-- Continue after the numbers the in-process counters gave out before.
+INSERT INTO "sequences" ("name", "value")
+SELECT 'O-', COALESCE(MAX(SUBSTRING("order_id" FROM '^O-(\d+)$')::BIGINT), 0) FROM "order"
+ON CONFLICT ("name") DO NOTHING;
+
+INSERT INTO "sequences" ("name", "value")
+SELECT 'P-', COALESCE(MAX(SUBSTRING("product_id" FROM '^P-(\d+)$')::BIGINT), 0) FROM "product"
+ON CONFLICT ("name") DO NOTHING;
+
+-- Optional per branch part of order numbers.
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "order_prefix" VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,47 @@
+-- Line sums and order totals are computed by the pricing package and
+-- written by the repositories, so the triggers doing the same go away.
+DROP TRIGGER IF EXISTS trigger_update_order_product_price ON "order_products";
+DROP TRIGGER IF EXISTS calculate_order_product_sum_trigger ON "order_products";
+DROP TRIGGER IF EXISTS update_totals ON "order_products";
+
+DROP FUNCTION IF EXISTS update_order_product_price();
+DROP FUNCTION IF EXISTS calculate_order_product_sum();
+DROP FUNCTION IF EXISTS calculate_order_totals();
+
+-- Reprice the orders that are still open with the new rules: percent
+-- discounts charge the discounted price, deleted lines do not count and
+-- delivery is part of the total. Finished and canceled orders keep the
+-- totals they were closed with. The arithmetic is that of
+-- pricing.Line.Sum, so repricing an order later gives the same sums: the
+-- discount is taken off the unit price in cents, rounded half up, and the
+-- discounted unit price times the quantity is rounded half up again.
+UPDATE "order_products" AS line
+SET "sum" = ROUND(
+    (
+        ROUND(line."price", 2) - CASE line."discount_type"
+            WHEN 'fix' THEN ROUND(line."discount_amount", 2)
+            WHEN 'percent' THEN ROUND(ROUND(line."price", 2) * ROUND(line."discount_amount", 2) / 100, 2)
+            ELSE 0
+        END
+    ) * line."quantity",
+    2
+)
+FROM "order"
+WHERE "order"."id" = line."order_id"
+    AND "order"."status" IN ('new', 'in-process');
+
+UPDATE "order"
+SET
+    "total_count" = totals."count",
+    "total_price" = totals."subtotal" + CASE WHEN totals."lines" > 0 THEN ROUND(COALESCE("order"."delivery_price", 0), 2) ELSE 0 END
+FROM (
+    SELECT
+        "order"."id",
+        COUNT(line."order_product_id") AS "lines",
+        ROUND(COALESCE(SUM(line."quantity"), 0)) AS "count",
+        COALESCE(SUM(line."sum"), 0) AS "subtotal"
+    FROM "order"
+    LEFT JOIN "order_products" AS line ON line."order_id" = "order"."id" AND line."deleted_at" IS NULL
+    GROUP BY "order"."id"
+) AS totals
+WHERE totals."id" = "order"."id" AND "order"."status" IN ('new', 'in-process');
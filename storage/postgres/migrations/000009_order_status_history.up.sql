@@ -0,0 +1,12 @@
+-- One row per order status change, written together with the change.
+CREATE TABLE IF NOT EXISTS "order_status_history" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "order_id" UUID NOT NULL REFERENCES "order"("id") ON DELETE CASCADE,
+    "from_status" VARCHAR(20) NOT NULL,
+    "to_status" VARCHAR(20) NOT NULL,
+    "actor" VARCHAR NOT NULL,
+    "reason" VARCHAR NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT CLOCK_TIMESTAMP()
+);
+
+CREATE INDEX IF NOT EXISTS "order_status_history_order_id_idx" ON "order_status_history" ("order_id", "created_at", "id");
@@ -0,0 +1,15 @@
+-- What every branch holds of a product. reserved is held for the lines of
+-- open orders; the stock of a purged branch or product goes with it.
+CREATE TABLE IF NOT EXISTS "stock" (
+    "branch_id" UUID NOT NULL REFERENCES "branches"("id") ON DELETE CASCADE,
+    "product_id" UUID NOT NULL REFERENCES "product"("id") ON DELETE CASCADE,
+    "quantity" NUMERIC NOT NULL DEFAULT 0,
+    "reserved" NUMERIC NOT NULL DEFAULT 0,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY ("branch_id", "product_id"),
+    CHECK ("reserved" >= 0 AND "reserved" <= "quantity")
+);
+
+-- What each line holds. Lines written before stock was tracked hold
+-- nothing, so settling their orders leaves the stock alone.
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "reserved" NUMERIC NOT NULL DEFAULT 0;
@@ -0,0 +1,42 @@
+-- The stock ledger. Movements are only ever inserted, and the quantity in
+-- "stock" is kept equal to the sum of the movements of its branch and
+-- product. Branches and products with movements are not purged.
+CREATE TABLE IF NOT EXISTS "stock_movements" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "branch_id" UUID NOT NULL REFERENCES "branches"("id"),
+    "product_id" UUID NOT NULL REFERENCES "product"("id"),
+    "type" VARCHAR(20) NOT NULL
+        CHECK ("type" IN ('receipt', 'sale', 'return', 'transfer-out', 'transfer-in', 'write-off', 'count')),
+    "quantity" NUMERIC NOT NULL,
+    "reference" VARCHAR NOT NULL DEFAULT '',
+    "actor" VARCHAR NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT CLOCK_TIMESTAMP()
+);
+
+CREATE INDEX IF NOT EXISTS "stock_movements_branch_id_idx" ON "stock_movements" ("branch_id", "created_at", "id");
+CREATE INDEX IF NOT EXISTS "stock_movements_product_id_idx" ON "stock_movements" ("product_id", "created_at", "id");
+CREATE INDEX IF NOT EXISTS "stock_movements_reference_idx" ON "stock_movements" ("reference");
+
+CREATE TABLE IF NOT EXISTS "stock_transfers" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "from_branch_id" UUID NOT NULL REFERENCES "branches"("id"),
+    "to_branch_id" UUID NOT NULL REFERENCES "branches"("id"),
+    "reference" VARCHAR NOT NULL DEFAULT '',
+    "actor" VARCHAR NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT CLOCK_TIMESTAMP()
+);
+
+-- Stock counted before the ledger existed opens it.
+INSERT INTO "stock_movements" ("id", "branch_id", "product_id", "type", "quantity", "reference", "actor", "created_at")
+SELECT
+    md5("branch_id"::TEXT || "product_id"::TEXT || 'opening')::UUID,
+    "branch_id",
+    "product_id",
+    'count',
+    "quantity",
+    'opening balance',
+    'system',
+    "updated_at"
+FROM "stock"
+WHERE "quantity" <> 0
+ON CONFLICT ("id") DO NOTHING;
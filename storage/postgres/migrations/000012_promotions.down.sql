@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "order_promotion_id_idx";
+
+ALTER TABLE "order" DROP COLUMN IF EXISTS "discount";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "promo_code";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "promotion_id";
+
+DROP TABLE IF EXISTS "promotions";
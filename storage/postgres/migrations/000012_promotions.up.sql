@@ -0,0 +1,30 @@
+-- Promo codes. Zero limits and empty id lists mean no restriction, and a
+-- NULL bound leaves the validity window open on that side. Codes are
+-- stored upper-case and are unique among promotions that are not deleted.
+CREATE TABLE IF NOT EXISTS "promotions" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "code" VARCHAR(64) NOT NULL,
+    "type" VARCHAR(10) NOT NULL CHECK ("type" IN ('percent', 'fix')),
+    "value" NUMERIC NOT NULL CHECK ("value" > 0),
+    "starts_at" TIMESTAMP,
+    "ends_at" TIMESTAMP,
+    "usage_limit" INT NOT NULL DEFAULT 0 CHECK ("usage_limit" >= 0),
+    "per_client_limit" INT NOT NULL DEFAULT 0 CHECK ("per_client_limit" >= 0),
+    "min_order_total" NUMERIC NOT NULL DEFAULT 0,
+    "branch_ids" UUID[] NOT NULL DEFAULT '{}',
+    "category_ids" UUID[] NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "deleted_at" TIMESTAMP,
+    "version" BIGINT NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "promotions_code_idx" ON "promotions" ("code") WHERE "deleted_at" IS NULL;
+
+-- The promotion applied to an order, its code as applied and the money it
+-- takes off. total_price is the subtotal minus the discount plus delivery.
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "promotion_id" UUID REFERENCES "promotions"("id");
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "promo_code" VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "discount" NUMERIC NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS "order_promotion_id_idx" ON "order" ("promotion_id") WHERE "promotion_id" IS NOT NULL;
@@ -0,0 +1,19 @@
+ALTER TABLE "promotions"
+    ALTER COLUMN "value" TYPE NUMERIC,
+    ALTER COLUMN "min_order_total" TYPE NUMERIC;
+
+ALTER TABLE "order_products"
+    ALTER COLUMN "price" TYPE NUMERIC,
+    ALTER COLUMN "sum" TYPE NUMERIC,
+    ALTER COLUMN "discount_amount" TYPE NUMERIC;
+
+ALTER TABLE "order"
+    ALTER COLUMN "delivery_price" TYPE NUMERIC,
+    ALTER COLUMN "total_price" TYPE NUMERIC,
+    ALTER COLUMN "discount" TYPE NUMERIC;
+
+ALTER TABLE "branches"
+    ALTER COLUMN "delivery_price" TYPE NUMERIC;
+
+ALTER TABLE "product"
+    ALTER COLUMN "price" TYPE NUMERIC;
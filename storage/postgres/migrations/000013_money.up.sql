@@ -0,0 +1,22 @@
+-- Money is stored in cents: two decimal places, rounded half up the way
+-- the money package rounds. Percent discounts share the columns and keep
+-- two decimal places as well.
+ALTER TABLE "product"
+    ALTER COLUMN "price" TYPE NUMERIC(14, 2) USING ROUND("price", 2);
+
+ALTER TABLE "branches"
+    ALTER COLUMN "delivery_price" TYPE NUMERIC(14, 2) USING ROUND("delivery_price", 2);
+
+ALTER TABLE "order"
+    ALTER COLUMN "delivery_price" TYPE NUMERIC(14, 2) USING ROUND("delivery_price", 2),
+    ALTER COLUMN "total_price" TYPE NUMERIC(14, 2) USING ROUND("total_price", 2),
+    ALTER COLUMN "discount" TYPE NUMERIC(14, 2) USING ROUND("discount", 2);
+
+ALTER TABLE "order_products"
+    ALTER COLUMN "price" TYPE NUMERIC(14, 2) USING ROUND("price", 2),
+    ALTER COLUMN "sum" TYPE NUMERIC(14, 2) USING ROUND("sum", 2),
+    ALTER COLUMN "discount_amount" TYPE NUMERIC(14, 2) USING ROUND("discount_amount", 2);
+
+ALTER TABLE "promotions"
+    ALTER COLUMN "value" TYPE NUMERIC(14, 2) USING ROUND("value", 2),
+    ALTER COLUMN "min_order_total" TYPE NUMERIC(14, 2) USING ROUND("min_order_total", 2);
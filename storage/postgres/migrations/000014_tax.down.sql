@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "order_taxes";
+
+ALTER TABLE "order" DROP COLUMN IF EXISTS "tax";
+
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "tax";
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "tax_inclusive";
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "tax_percent";
+ALTER TABLE "order_products" DROP COLUMN IF EXISTS "tax_rate_id";
+
+ALTER TABLE "product" DROP COLUMN IF EXISTS "tax_rate_id";
+ALTER TABLE "category" DROP COLUMN IF EXISTS "tax_rate_id";
+
+DROP TABLE IF EXISTS "tax_rates";
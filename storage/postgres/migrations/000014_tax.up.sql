@@ -0,0 +1,36 @@
+-- VAT rates. An inclusive rate is already in the prices it applies to; an
+-- exclusive one is charged on top of them.
+CREATE TABLE IF NOT EXISTS "tax_rates" (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "name" VARCHAR(64) NOT NULL,
+    "percent" NUMERIC(14, 2) NOT NULL CHECK ("percent" >= 0 AND "percent" <= 100),
+    "inclusive" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "deleted_at" TIMESTAMP,
+    "version" BIGINT NOT NULL DEFAULT 1
+);
+
+-- A product is taxed at its own rate, or else at the rate of its category.
+ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "tax_rate_id" UUID REFERENCES "tax_rates"("id");
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "tax_rate_id" UUID REFERENCES "tax_rates"("id");
+
+-- A line keeps the rate it was added at and the tax computed on its sum.
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "tax_rate_id" UUID REFERENCES "tax_rates"("id");
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "tax_percent" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "tax_inclusive" BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE "order_products" ADD COLUMN IF NOT EXISTS "tax" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+-- The tax of all lines of an order, and its breakdown by rate. total_price
+-- includes the tax of exclusive rates.
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "tax" NUMERIC(14, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "order_taxes" (
+    "order_id" UUID NOT NULL REFERENCES "order"("id") ON DELETE CASCADE,
+    "tax_rate_id" UUID NOT NULL REFERENCES "tax_rates"("id"),
+    "percent" NUMERIC(14, 2) NOT NULL,
+    "inclusive" BOOLEAN NOT NULL,
+    "base" NUMERIC(14, 2) NOT NULL,
+    "tax" NUMERIC(14, 2) NOT NULL,
+    PRIMARY KEY ("order_id", "tax_rate_id", "percent", "inclusive")
+);
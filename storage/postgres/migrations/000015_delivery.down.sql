@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "delivery_tariffs";
+
+ALTER TABLE "order" DROP COLUMN IF EXISTS "delivery_distance";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "longitude";
+ALTER TABLE "order" DROP COLUMN IF EXISTS "latitude";
+
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "longitude";
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "latitude";
@@ -0,0 +1,25 @@
+-- Locations of branches and order addresses, in decimal degrees. 0, 0
+-- stands for no location.
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "latitude" DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "longitude" DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "latitude" DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "longitude" DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- The distance in kilometers the delivery of an order is priced by, 0 for
+-- an order that pays the flat delivery_price of its branch.
+ALTER TABLE "order" ADD COLUMN IF NOT EXISTS "delivery_distance" DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- How a branch prices deliveries by distance. Bands are a JSON array of
+-- {"up_to_km", "fee"} ordered by distance.
+CREATE TABLE IF NOT EXISTS "delivery_tariffs" (
+    "branch_id" UUID NOT NULL PRIMARY KEY REFERENCES "branches"("id") ON DELETE CASCADE,
+    "base_fee" NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK ("base_fee" >= 0),
+    "per_km" NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK ("per_km" >= 0),
+    "bands" JSONB NOT NULL DEFAULT '[]',
+    "free_from" NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK ("free_from" >= 0),
+    "max_radius_km" DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK ("max_radius_km" >= 0),
+    "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "version" BIGINT NOT NULL DEFAULT 1
+);
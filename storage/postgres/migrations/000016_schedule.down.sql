@@ -0,0 +1,3 @@
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "holidays";
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "schedule";
+ALTER TABLE "branches" DROP COLUMN IF EXISTS "timezone";
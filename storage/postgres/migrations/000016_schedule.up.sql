@@ -0,0 +1,6 @@
+-- Opening hours of branches: an IANA timezone, '' for the local time of
+-- the server, shifts as a JSON array of {"weekday", "open", "close"} and
+-- holidays as a JSON array of {"date", "open", "close"}.
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "timezone" VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "schedule" JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE "branches" ADD COLUMN IF NOT EXISTS "holidays" JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,339 @@
+// Package migrations applies the versioned schema files embedded next to it.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt string `json:"applied_at"`
+	Applied   bool   `json:"applied"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not match <version>_<name>.(up|down).sql", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest is the schema version this binary expects.
+func (m *Migrator) Latest() int64 {
+
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration, each in its own transaction.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ok, err := m.apply(ctx, migration, true)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the latest n applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < n; i-- {
+		migration := m.migrations[i]
+
+		ok, err := m.apply(ctx, migration, false)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		if ok {
+			reverted = append(reverted, migration)
+		}
+	}
+
+	return reverted, nil
+}
+
+// Status lists every known migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.status(ctx)
+}
+
+// status is Status on a database that has the bookkeeping table. It only
+// reads, so health checks can call it.
+func (m *Migrator) status(ctx context.Context) ([]Status, error) {
+
+	rows, err := m.db.QueryContext(ctx, `SELECT "version", "applied_at" FROM "schema_migrations"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int64]string)
+	for rows.Next() {
+		var (
+			version int64
+			at      string
+		)
+
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		appliedAt[version] = at
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		at, ok := appliedAt[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: at,
+			Applied:   ok,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that still have to be applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.pending(statuses), nil
+}
+
+func (m *Migrator) pending(statuses []Status) []Migration {
+
+	var pending []Migration
+	for i, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, m.migrations[i])
+		}
+	}
+
+	return pending
+}
+
+// CheckUpToDate fails when the database misses any migration of this
+// binary. It runs on every readiness probe, so it only reads: a database
+// without the bookkeeping table has no migration applied, and the table is
+// left for "migrate up" to create.
+func (m *Migrator) CheckUpToDate(ctx context.Context) error {
+
+	var exists bool
+
+	err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return errors.New(`database schema is out of date: no migration is applied, run "migrate up"`)
+	}
+
+	statuses, err := m.status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pending := m.pending(statuses); len(pending) > 0 {
+		return fmt.Errorf(
+			"database schema is out of date: %d pending migration(s) starting at %d_%s, run \"migrate up\"",
+			len(pending), pending[0].Version, pending[0].Name,
+		)
+	}
+
+	return nil
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS "schema_migrations" (
+			"version" BIGINT NOT NULL PRIMARY KEY,
+			"name" VARCHAR NOT NULL,
+			"applied_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// apply runs one direction of a migration together with its bookkeeping
+// row. The table lock makes concurrent "migrate" runs wait for each other,
+// and the applied check is repeated under it. It reports whether the
+// migration actually ran.
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) (bool, error) {
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE "schema_migrations" IN EXCLUSIVE MODE`)
+	if err != nil {
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM "schema_migrations" WHERE "version" = $1)`,
+		migration.Version,
+	).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO "schema_migrations" ("version", "name") VALUES ($1, $2)`,
+			migration.Version, migration.Name,
+		)
+	} else {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return false, err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM "schema_migrations" WHERE "version" = $1`, migration.Version)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -1,40 +1,69 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
+	"market_system/pkg/helpers"
+	"market_system/pkg/money"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
 
 	"github.com/google/uuid"
 )
 
+var orderColumns = filter.Columns{
+	"id":                `"id"`,
+	"order_id":          `"order_id"`,
+	"client_id":         `"client_id"`,
+	"branch_id":         `"branch_id"`,
+	"address":           `"address"`,
+	"delivery_price":    `"delivery_price"`,
+	"delivery_distance": `"delivery_distance"`,
+	"total_count":       `"total_count"`,
+	"total_price":       `"total_price"`,
+	"promotion_id":      `"promotion_id"`,
+	"promo_code":        `"promo_code"`,
+	"discount":          `"discount"`,
+	"tax":               `"tax"`,
+	"status":            `"status"`,
+	"created_at":        `"created_at"`,
+	"updated_at":        `"updated_at"`,
+	"deleted_at":        `"deleted_at"`,
+}
+
 type orderRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewOrderRepo(db *sql.DB) *orderRepo {
+func NewOrderRepo(db dbtx) *orderRepo {
 	return &orderRepo{
 		db: db,
 	}
 }
-func (r *orderRepo) Create(req *models.CreateOrder) (*models.Order, error) {
-	orderID := uuid.New().String()
-	deliveryPrice := 0
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (*models.Order, error) {
+	var (
+		orderID       = uuid.New().String()
+		deliveryPrice money.Amount
+		distance      float64
+	)
 
 	deliveryPriceQuery := `
 		SELECT 
 		COALESCE(delivery_price, 0)
 		FROM
 			branches
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR SHARE
 	`
 
-	err := r.db.QueryRow(deliveryPriceQuery, req.BranchId).Scan(&deliveryPrice)
-	if err != nil {
-		return nil, err
-	}
-
 	query := `
 		INSERT INTO "order"(
 			"id",
@@ -42,33 +71,68 @@ func (r *orderRepo) Create(req *models.CreateOrder) (*models.Order, error) {
 			"client_id",
 			"branch_id",
 			"address",
+			"latitude",
+			"longitude",
 			"delivery_price",
+			"delivery_distance",
 			"total_count",
 			"total_price",
 			"updated_at"
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8,  NOW())
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,  NOW())
 	`
 
-	_, err = r.db.Exec(
-		query,
-		orderID,
-		req.OrderId,
-		req.ClientId,
-		req.BranchId,
-		req.Address,
-		deliveryPrice,
-		req.TotalCount,
-		req.TotalPrice,
-	)
+	address := geo.Point{Lat: req.Latitude, Lng: req.Longitude}
+	if err := address.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		err := tx.QueryRowContext(ctx, deliveryPriceQuery, req.BranchId).Scan(&deliveryPrice)
+		if err != nil {
+			return err
+		}
+
+		// An order priced by distance must be in the delivery range of the
+		// branch. Until it has lines it shows the fee for no goods.
+		route, err := orderRoute(ctx, tx, req.BranchId, address)
+		if err != nil {
+			return err
+		}
+
+		if route != nil {
+			if err := route.Check(); err != nil {
+				return err
+			}
+
+			deliveryPrice, distance = route.Fee(0), route.Km
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			orderID,
+			req.OrderId,
+			req.ClientId,
+			req.BranchId,
+			req.Address,
+			req.Latitude,
+			req.Longitude,
+			deliveryPrice,
+			distance,
+			0,
+			0,
+		)
+		return err
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetByID(&models.OrderPrimaryKey{Id: orderID})
+	return r.GetByID(ctx, &models.OrderPrimaryKey{Id: orderID})
 }
 
-func (r *orderRepo) GetByID(req *models.OrderPrimaryKey) (*models.Order, error) {
+func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
 	var (
 		order models.Order
 		query = `
@@ -78,88 +142,125 @@ func (r *orderRepo) GetByID(req *models.OrderPrimaryKey) (*models.Order, error)
 				"client_id",
 				"branch_id",
 				"address",
+				"latitude",
+				"longitude",
 				"delivery_price",
+				"delivery_distance",
 				COALESCE("total_count",0),
 				COALESCE("total_price",0),
+				COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+				"promo_code",
+				"discount",
+				"tax",
 				"status",
 				"created_at",
-				"updated_at"
+				"updated_at",
+				"version"
 			FROM "order"
-			WHERE "id" = $1
+			WHERE "id" = $1 AND "deleted_at" IS NULL
 		`
 	)
 
-	err := r.db.QueryRow(query, req.Id).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
 		&order.Id,
 		&order.OrderId,
 		&order.ClientId,
 		&order.BranchId,
 		&order.Address,
+		&order.Latitude,
+		&order.Longitude,
 		&order.DeliveryPrice,
+		&order.DeliveryDistance,
 		&order.TotalCount,
 		&order.TotalPrice,
+		&order.PromotionId,
+		&order.PromoCode,
+		&order.Discount,
+		&order.Tax,
 		&order.Status,
 		&order.CreatedAt,
 		&order.UpdatedAt,
+		&order.Version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	taxes, err := orderTaxes(ctx, r.db, []string{order.Id})
+	if err != nil {
+		return nil, err
+	}
+	order.Taxes = taxes[order.Id]
+
 	return &order, nil
 }
 
-func (r *orderRepo) GetList(req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
 	var (
-		resp   models.GetListOrderResponse
-		where  = " WHERE TRUE"
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		resp  models.GetListOrderResponse
+		conds = []filter.Expr{req.Filter}
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.Eq("client_id", req.Search),
+			filter.Eq("branch_id", req.Search),
+		))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
 	}
 
-	if len(req.Search) > 0 {
-		where += fmt.Sprintf(`
-			AND (
-				client_id = '%s'
-				OR branch_id = '%s'
-			)`, req.Search, req.Search)
+	where, args, err := filter.Where(filter.And(conds...), orderColumns, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var query = `
 		SELECT
-			COUNT(*) OVER(),
+			` + page.count + `,
 			"id", 
 			"order_id",
 			"client_id",
 			"branch_id",
 			"address",
+			"latitude",
+			"longitude",
 			"delivery_price",
+			"delivery_distance",
 			COALESCE("total_count", 0),
 			COALESCE("total_price", 0),
+			COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+			"promo_code",
+			"discount",
+			"tax",
 			"status",
 			"created_at",
-			"updated_at"
+			"updated_at",
+			"deleted_at",
+			"version"
 		FROM "order"
 	`
 
-	query += where + sort + offset + limit
-	rows, err := r.db.Query(query)
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var order models.Order
+		var (
+			order     models.Order
+			deletedAt sql.NullString
+		)
 
 		err = rows.Scan(
 			&resp.Count,
@@ -168,25 +269,72 @@ func (r *orderRepo) GetList(req *models.GetListOrderRequest) (*models.GetListOrd
 			&order.ClientId,
 			&order.BranchId,
 			&order.Address,
+			&order.Latitude,
+			&order.Longitude,
 			&order.DeliveryPrice,
+			&order.DeliveryDistance,
 			&order.TotalCount,
 			&order.TotalPrice,
+			&order.PromotionId,
+			&order.PromoCode,
+			&order.Discount,
+			&order.Tax,
 			&order.Status,
 			&order.CreatedAt,
 			&order.UpdatedAt,
+			&deletedAt,
+			&order.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		order.DeletedAt = deletedAt.String
+
 		resp.Orders = append(resp.Orders, &order)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.Orders), func(i int) (string, string) {
+		return resp.Orders[i].CreatedAt, resp.Orders[i].Id
+	})
+	resp.Orders, resp.NextCursor = resp.Orders[:n], next
+
+	orderIDs := make([]string, 0, len(resp.Orders))
+	for _, order := range resp.Orders {
+		orderIDs = append(orderIDs, order.Id)
+	}
+
+	taxes, err := orderTaxes(ctx, r.db, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, order := range resp.Orders {
+		order.Taxes = taxes[order.Id]
+	}
+
 	return &resp, nil
 }
 
-func (r *orderRepo) Update(req *models.UpdateOrder) (int64, error) {
-	query := `
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	var (
+		rowsAffected int64
+		selectQuery  = `
+			SELECT
+				"branch_id",
+				COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+				"promo_code",
+				"status",
+				"version"
+			FROM "order"
+			WHERE "id" = $1 AND "deleted_at" IS NULL
+			FOR UPDATE
+		`
+		query = `
 		UPDATE "order"
 			SET
 				client_id = $2,
@@ -195,27 +343,199 @@ func (r *orderRepo) Update(req *models.UpdateOrder) (int64, error) {
 				delivery_price = $5,
 				total_count = $6,
 				total_price = $7,
-				status = $8,
+				promotion_id = $8,
+				promo_code = $9,
+				discount = $10,
+				tax = $11,
+				latitude = $12,
+				longitude = $13,
+				delivery_distance = $14,
+				"version" = "version" + 1,
 				updated_at = NOW()
 		WHERE id = $1
 	`
-
-	result, err := r.db.Exec(
-		query,
-		req.Id,
-		req.ClientId,
-		req.BranchId,
-		req.Address,
-		req.DeliveryPrice,
-		req.TotalCount,
-		req.TotalPrice,
-		req.Status,
 	)
+
+	address := geo.Point{Lat: req.Latitude, Lng: req.Longitude}
+	if err := address.Validate(); err != nil {
+		return 0, err
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var (
+			branchID    string
+			promotionID string
+			promoCode   string
+			status      string
+			version     int64
+		)
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.Id).Scan(&branchID, &promotionID, &promoCode, &status, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.Version != 0 && req.Version != version {
+			return storage.ErrVersionConflict
+		}
+
+		if !workflow.Default.Open(status) {
+			return fmt.Errorf("%w: order %s is %s", storage.ErrOrderClosed, req.Id, status)
+		}
+
+		// Status changes go through StatusUpdate, which checks the workflow.
+		if req.Status != "" && req.Status != status {
+			return fmt.Errorf("%w: change the status of order %s through the status update", workflow.ErrTransition, req.Id)
+		}
+
+		// The reservations of the order follow it to its new branch.
+		if req.BranchId != branchID {
+			if err := moveStock(ctx, tx, req.Id, branchID, req.BranchId); err != nil {
+				return err
+			}
+		}
+
+		code := promoCode
+		if req.PromoCode != nil {
+			code = *req.PromoCode
+		}
+
+		newPromotionID, newPromoCode, err := orderPromotion(ctx, tx, req.Id, req.ClientId, promotionID, promoCode, code)
+		if err != nil {
+			return err
+		}
+
+		// The delivery of an order at a new address or branch is priced
+		// again, and must be in range.
+		route, err := orderRoute(ctx, tx, req.BranchId, address)
+		if err != nil {
+			return err
+		}
+
+		if route != nil {
+			if err := route.Check(); err != nil {
+				return err
+			}
+		}
+
+		// A new delivery price or promotion changes the total. A new code
+		// must apply to the order; one the order has already is kept even
+		// when it gives no discount at the moment.
+		totals, taxed, err := orderTotals(ctx, tx, req.Id, req.BranchId, req.DeliveryPrice, route, newPromotionID)
+		if errors.Is(err, promo.ErrNotApplicable) && newPromotionID == promotionID {
+			err = nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		deliveryPrice, distance := req.DeliveryPrice, 0.0
+		if route != nil {
+			deliveryPrice, distance = route.Fee(totals.Goods()), route.Km
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			query,
+			req.Id,
+			req.ClientId,
+			req.BranchId,
+			req.Address,
+			deliveryPrice,
+			totals.Count,
+			totals.Total,
+			helpers.NewNullString(newPromotionID),
+			newPromoCode,
+			totals.Discount,
+			totals.Tax,
+			req.Latitude,
+			req.Longitude,
+			distance,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		// The promotion is spread over the lines, so the breakdown and
+		// the line taxes change with it.
+		return storeOrderTaxes(ctx, tx, req.Id, taxed)
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return rowsAffected, nil
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) error {
+	var (
+		selectQuery = `SELECT "branch_id", "status" FROM "order" WHERE "id" = $1 AND "deleted_at" IS NULL FOR UPDATE`
+		query       = `UPDATE "order" SET "deleted_at" = NOW() WHERE "id" = $1`
+	)
+
+	return inTx(ctx, r.db, func(tx dbtx) error {
+		var branchID, status string
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.Id).Scan(&branchID, &status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Nobody works on a deleted order, so an open one gives its stock
+		// back the way a canceled one does.
+		if workflow.Default.Open(status) {
+			if err := settleStock(ctx, tx, req.Id, branchID, workflow.StatusCanceled, ""); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, query, req.Id)
+		return err
+	})
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	var (
+		rowsAffected int64
+		selectQuery  = `SELECT "branch_id", "status" FROM "order" WHERE "id" = $1 AND "deleted_at" IS NOT NULL FOR UPDATE`
+		query        = `UPDATE "order" SET "deleted_at" = NULL WHERE "id" = $1`
+	)
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var branchID, status string
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.Id).Scan(&branchID, &status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if workflow.Default.Open(status) {
+			if err := reserveOrder(ctx, tx, req.Id, branchID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, query, req.Id); err != nil {
+			return err
+		}
+
+		rowsAffected = 1
+		return nil
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -223,16 +543,47 @@ func (r *orderRepo) Update(req *models.UpdateOrder) (int64, error) {
 	return rowsAffected, nil
 }
 
-func (r *orderRepo) Delete(req *models.OrderPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM \"order\" WHERE id = $1", req.Id)
-	return err
+func (r *orderRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "order" AS deleted
+		WHERE deleted."deleted_at" < $1
+			AND NOT EXISTS (SELECT 1 FROM "order_products" WHERE "order_products"."order_id" = deleted."id")
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
 }
 
 // status check
 
-func (r *orderRepo) StatusUpdate(req models.CheckStatus) (models.Order, error) {
+func (r *orderRepo) StatusUpdate(ctx context.Context, req models.CheckStatus) (models.Order, error) {
 	var order models.Order
 
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		if err := r.statusUpdate(ctx, tx, req, &order); err != nil {
+			return err
+		}
+
+		taxes, err := orderTaxes(ctx, tx, []string{order.Id})
+		order.Taxes = taxes[order.Id]
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// statusUpdate locks the order row, so concurrent status changes of one
+// order are applied one after another, checks the change against the
+// workflow and records it in the status history.
+func (r *orderRepo) statusUpdate(ctx context.Context, tx dbtx, req models.CheckStatus, order *models.Order) error {
+
 	selectQuery := `
 		SELECT
 			"id",
@@ -240,117 +591,201 @@ func (r *orderRepo) StatusUpdate(req models.CheckStatus) (models.Order, error) {
 			"client_id",
 			"branch_id",
 			"address",
+			"latitude",
+			"longitude",
 			"delivery_price",
+			"delivery_distance",
 			COALESCE("total_count", 0),
 			COALESCE("total_price", 0),
+			COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+			"promo_code",
+			"discount",
+			"tax",
 			"status",
 			"created_at",
-			"updated_at"
+			"updated_at",
+			"version"
 		FROM "order"
-		WHERE "id" = $1
+		WHERE "id" = $1 AND "deleted_at" IS NULL
+		FOR UPDATE
 	`
 
-	err := r.db.QueryRow(selectQuery, req.Id).Scan(
+	err := tx.QueryRowContext(ctx, selectQuery, req.Id).Scan(
 		&order.Id,
 		&order.OrderId,
 		&order.ClientId,
 		&order.BranchId,
 		&order.Address,
+		&order.Latitude,
+		&order.Longitude,
 		&order.DeliveryPrice,
+		&order.DeliveryDistance,
 		&order.TotalCount,
 		&order.TotalPrice,
+		&order.PromotionId,
+		&order.PromoCode,
+		&order.Discount,
+		&order.Tax,
 		&order.Status,
 		&order.CreatedAt,
 		&order.UpdatedAt,
+		&order.Version,
 	)
 	if err != nil {
-		return models.Order{}, err
+		return err
 	}
 
-	switch order.Status {
-	case "new":
-		if req.Status == "canceled" || req.Status == "in-process" {
-			updateQuery := `
-				UPDATE "order"
-				SET
-					status = $2,
-					updated_at = NOW()
-				WHERE id = $1
-				RETURNING
-					"id",
-					"order_id",
-					"client_id",
-					"branch_id",
-					"address",
-					"delivery_price",
-					COALESCE("total_count", 0),
-					COALESCE("total_price", 0),
-					"status",
-					"created_at",
-					"updated_at"
-			`
-			err := r.db.QueryRow(updateQuery, order.Id, req.Status).Scan(
-				&order.Id,
-				&order.OrderId,
-				&order.ClientId,
-				&order.BranchId,
-				&order.Address,
-				&order.DeliveryPrice,
-				&order.TotalCount,
-				&order.TotalPrice,
-				&order.Status,
-				&order.CreatedAt,
-				&order.UpdatedAt,
-			)
-			if err != nil {
-				return models.Order{}, err
-			}
-		} else {
-			return models.Order{}, fmt.Errorf("invalid status transition from 'new' to '%s'", req.Status)
+	if req.Version != 0 && req.Version != order.Version {
+		return storage.ErrVersionConflict
+	}
+
+	_, err = workflow.Default.Check(workflow.Input{
+		From:   order.Status,
+		To:     req.Status,
+		Reason: req.Reason,
+		Items:  order.TotalCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	if workflow.Default.Open(order.Status) && !workflow.Default.Open(req.Status) {
+		if err := settleStock(ctx, tx, order.Id, order.BranchId, req.Status, req.Actor); err != nil {
+			return err
 		}
-	case "in-process":
-		if req.Status == "finished"  {
-			updateQuery := `
-				UPDATE "order"
-				SET
-					status = $2,
-					updated_at = NOW()
-				WHERE id = $1
-				RETURNING
-					"id",
-					"order_id",
-					"client_id",
-					"branch_id",
-					"address",
-					"delivery_price",
-					COALESCE("total_count", 0),
-					COALESCE("total_price", 0),
-					"status",
-					"created_at",
-					"updated_at"
-			`
-			err := r.db.QueryRow(updateQuery, order.Id, req.Status).Scan(
-				&order.Id,
-				&order.OrderId,
-				&order.ClientId,
-				&order.BranchId,
-				&order.Address,
-				&order.DeliveryPrice,
-				&order.TotalCount,
-				&order.TotalPrice,
-				&order.Status,
-				&order.CreatedAt,
-				&order.UpdatedAt,
-			)
-			if err != nil {
-				return models.Order{}, err
-			}
-		} else {
-			return models.Order{}, fmt.Errorf("invalid status transition from 'in-process' to '%s'", req.Status)
+	}
+
+	if order.Status == workflow.StatusFinished && req.Status == workflow.StatusReturned {
+		if err := restock(ctx, tx, order.Id, req.Actor); err != nil {
+			return err
 		}
-	default:
-		return models.Order{}, fmt.Errorf("unsupported status: %s", order.Status)
 	}
 
-	return order, nil
+	historyQuery := `
+		INSERT INTO "order_status_history"(
+			"id",
+			"order_id",
+			"from_status",
+			"to_status",
+			"actor",
+			"reason"
+		) VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = tx.ExecContext(ctx, historyQuery, uuid.New().String(), order.Id, order.Status, req.Status, req.Actor, req.Reason)
+	if err != nil {
+		return err
+	}
+
+	updateQuery := `
+		UPDATE "order"
+		SET
+			status = $2,
+			"version" = "version" + 1,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING
+			"id",
+			"order_id",
+			"client_id",
+			"branch_id",
+			"address",
+			"latitude",
+			"longitude",
+			"delivery_price",
+			"delivery_distance",
+			COALESCE("total_count", 0),
+			COALESCE("total_price", 0),
+			COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+			"promo_code",
+			"discount",
+			"tax",
+			"status",
+			"created_at",
+			"updated_at",
+			"version"
+	`
+
+	return tx.QueryRowContext(ctx, updateQuery, order.Id, req.Status).Scan(
+		&order.Id,
+		&order.OrderId,
+		&order.ClientId,
+		&order.BranchId,
+		&order.Address,
+		&order.Latitude,
+		&order.Longitude,
+		&order.DeliveryPrice,
+		&order.DeliveryDistance,
+		&order.TotalCount,
+		&order.TotalPrice,
+		&order.PromotionId,
+		&order.PromoCode,
+		&order.Discount,
+		&order.Tax,
+		&order.Status,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+		&order.Version,
+	)
+}
+
+func (r *orderRepo) History(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetOrderHistoryResponse, error) {
+	var (
+		resp   models.GetOrderHistoryResponse
+		exists bool
+		query  = `
+			SELECT
+				"id",
+				"order_id",
+				"from_status",
+				"to_status",
+				"actor",
+				"reason",
+				"created_at"
+			FROM "order_status_history"
+			WHERE "order_id" = $1
+			ORDER BY "created_at", "id"
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "order" WHERE "id" = $1 AND "deleted_at" IS NULL)`, req.Id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.OrderStatusChange
+
+		err = rows.Scan(
+			&change.Id,
+			&change.OrderId,
+			&change.FromStatus,
+			&change.ToStatus,
+			&change.Actor,
+			&change.Reason,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.History = append(resp.History, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resp.Count = len(resp.History)
+	return &resp, nil
 }
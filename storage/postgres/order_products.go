@@ -1,26 +1,54 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/filter"
+	"market_system/pkg/geo"
 	"market_system/pkg/helpers"
+	"market_system/pkg/money"
+	"market_system/pkg/pricing"
+	"market_system/pkg/promo"
+	"market_system/pkg/tax"
+	"market_system/storage"
 
 	"github.com/google/uuid"
 )
 
+var orderProductColumns = filter.Columns{
+	"order_product_id": `"order_product_id"`,
+	"order_id":         `"order_id"`,
+	"product_id":       `"product_id"`,
+	"discount_type":    `"discount_type"`,
+	"discount_amount":  `"discount_amount"`,
+	"quantity":         `"quantity"`,
+	"price":            `"price"`,
+	"sum":              `"sum"`,
+	"tax_rate_id":      `"tax_rate_id"`,
+	"tax":              `"tax"`,
+	"reserved":         `"reserved"`,
+	"created_at":       `"created_at"`,
+	"updated_at":       `"updated_at"`,
+	"deleted_at":       `"deleted_at"`,
+}
+
 type orderProductRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewOrderProductRepo(db *sql.DB) *orderProductRepo {
+func NewOrderProductRepo(db dbtx) *orderProductRepo {
 	return &orderProductRepo{
 		db: db,
 	}
 }
 
-func (r *orderProductRepo) Create(req *models.CreateOrderProduct) (*models.OrderProduct, error) {
+func (r *orderProductRepo) Create(ctx context.Context, req *models.CreateOrderProduct) (*models.OrderProduct, error) {
 	orderProductID := uuid.New().String()
 
 	query := `
@@ -33,125 +61,251 @@ func (r *orderProductRepo) Create(req *models.CreateOrderProduct) (*models.Order
             "quantity",
             "price",
             "sum",
+            "reserved",
+            "tax_rate_id",
+            "tax_percent",
+            "tax_inclusive",
+            "tax",
             "created_at",
             "updated_at"
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7,$8, DEFAULT, DEFAULT)
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7,$8, $9, $10, $11, $12, $13, NOW(), NOW())
     `
 
-	_, err := r.db.Exec(
-		query,
-		orderProductID,
-		req.ProductID,
-
-		helpers.NewNullString(req.OrderID),
-		req.DiscountType,
-		req.DiscountAmount,
-		req.Quantity,
-		req.Price,
-		req.Sum,
-	)
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		branchID, err := openOrder(ctx, tx, req.OrderID)
+		if err != nil {
+			return err
+		}
+
+		price, err := productPrice(ctx, tx, req.ProductID)
+		if err != nil {
+			return err
+		}
+
+		taxRateID, taxRate, err := productTaxRate(ctx, tx, req.ProductID)
+		if err != nil {
+			return err
+		}
+
+		sum, lineTax, err := pricing.Line{
+			Price:          price,
+			Quantity:       req.Quantity,
+			DiscountType:   req.DiscountType,
+			DiscountAmount: req.DiscountAmount,
+			Tax:            taxRate,
+		}.Taxed()
+		if err != nil {
+			return err
+		}
+
+		if err := reserveStock(ctx, tx, branchID, req.ProductID, req.Quantity); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			orderProductID,
+			req.ProductID,
+
+			helpers.NewNullString(req.OrderID),
+			req.DiscountType,
+			req.DiscountAmount,
+			req.Quantity,
+			price,
+			sum,
+			req.Quantity,
+			helpers.NewNullString(taxRateID),
+			taxRate.Percent,
+			taxRate.Inclusive,
+			lineTax,
+		)
+		if err != nil {
+			return err
+		}
+
+		return recalculateOrder(ctx, tx, req.OrderID)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetByID(&models.OrderProductPrimaryKey{OrderProductID: orderProductID})
+	return r.GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: orderProductID})
 }
 
-func (r *orderProductRepo) GetByID(req *models.OrderProductPrimaryKey) (*models.OrderProduct, error) {
+func (r *orderProductRepo) GetByID(ctx context.Context, req *models.OrderProductPrimaryKey) (*models.OrderProduct, error) {
 	var (
 		orderProduct models.OrderProduct
 		query        = `
             SELECT
                 "order_product_id",
+                "order_id",
                 "product_id",
                 "discount_type",
                 "discount_amount",
                 "quantity",
                 "price",
-                "sum"
+                "sum",
+                "reserved",
+                COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
+                "tax_percent",
+                "tax_inclusive",
+                "tax",
+                "created_at",
+                "updated_at",
+                "version"
             FROM "order_products"
-            WHERE "order_product_id" = $1
+            WHERE "order_product_id" = $1 AND "deleted_at" IS NULL
         `
 	)
 
-	err := r.db.QueryRow(query, req.OrderProductID).Scan(
+	var updatedAt sql.NullString
+	err := r.db.QueryRowContext(ctx, query, req.OrderProductID).Scan(
 		&orderProduct.OrderProductID,
+		&orderProduct.OrderID,
 		&orderProduct.ProductID,
 		&orderProduct.DiscountType,
 		&orderProduct.DiscountAmount,
 		&orderProduct.Quantity,
 		&orderProduct.Price,
 		&orderProduct.Sum,
+		&orderProduct.Reserved,
+		&orderProduct.TaxRateId,
+		&orderProduct.TaxPercent,
+		&orderProduct.TaxInclusive,
+		&orderProduct.Tax,
+		&orderProduct.CreatedAt,
+		&updatedAt,
+		&orderProduct.Version,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	orderProduct.UpdatedAt = updatedAt.String
+
 	return &orderProduct, nil
 }
 
-func (r *orderProductRepo) GetList(req *models.GetListOrderProductRequest) (*models.GetListOrderProductResponse, error) {
+func (r *orderProductRepo) GetList(ctx context.Context, req *models.GetListOrderProductRequest) (*models.GetListOrderProductResponse, error) {
 	var (
-		resp   models.GetListOrderProductResponse
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		resp  models.GetListOrderProductResponse
+		conds = []filter.Expr{req.Filter}
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	where, args, err := filter.Where(filter.And(conds...), orderProductColumns, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := newListPage(where, args, `"order_product_id"`, req.Offset, req.Limit, req.Cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	var query = `
         SELECT
-            COUNT(*) OVER(),
+            ` + page.count + `,
             "order_product_id",
+            "order_id",
             "product_id",
             "discount_type",
             "discount_amount",
             "quantity",
             "price",
-            "sum"
+            "sum",
+            "reserved",
+            COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
+            "tax_percent",
+            "tax_inclusive",
+            "tax",
+            "created_at",
+            "updated_at",
+            "deleted_at",
+            "version"
         FROM "order_products"
     `
 
-	query += sort + offset + limit
-	rows, err := r.db.Query(query)
+	query += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, query, page.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var orderProduct models.OrderProduct
+		var (
+			orderProduct models.OrderProduct
+			updatedAt    sql.NullString
+			deletedAt    sql.NullString
+		)
 
 		err = rows.Scan(
 			&resp.Count,
 			&orderProduct.OrderProductID,
+			&orderProduct.OrderID,
 			&orderProduct.ProductID,
 			&orderProduct.DiscountType,
 			&orderProduct.DiscountAmount,
 			&orderProduct.Quantity,
 			&orderProduct.Price,
 			&orderProduct.Sum,
+			&orderProduct.Reserved,
+			&orderProduct.TaxRateId,
+			&orderProduct.TaxPercent,
+			&orderProduct.TaxInclusive,
+			&orderProduct.Tax,
+			&orderProduct.CreatedAt,
+			&updatedAt,
+			&deletedAt,
+			&orderProduct.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		orderProduct.UpdatedAt = updatedAt.String
+		orderProduct.DeletedAt = deletedAt.String
+
 		resp.OrderProducts = append(resp.OrderProducts, &orderProduct)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.OrderProducts), func(i int) (string, string) {
+		return resp.OrderProducts[i].CreatedAt, resp.OrderProducts[i].OrderProductID
+	})
+	resp.OrderProducts, resp.NextCursor = resp.OrderProducts[:n], next
+
 	return &resp, nil
 }
 
-func (r *orderProductRepo) Update(req *models.UpdateOrderProduct) (int64, error) {
-	query := `
+func (r *orderProductRepo) Update(ctx context.Context, req *models.UpdateOrderProduct) (int64, error) {
+	var (
+		rowsAffected int64
+		selectQuery  = `
+        SELECT
+            "order_id",
+            "product_id",
+            "price",
+            COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
+            "tax_percent",
+            "tax_inclusive",
+            "reserved",
+            "version"
+        FROM "order_products"
+        WHERE "order_product_id" = $1 AND "deleted_at" IS NULL
+        FOR UPDATE
+    `
+		query = `
         UPDATE "order_products"
             SET
                 "product_id" = $2,
@@ -160,26 +314,212 @@ func (r *orderProductRepo) Update(req *models.UpdateOrderProduct) (int64, error)
                 "quantity" = $5,
                 "price" = $6,
                 "sum" = $7,
-                "updated_at" = DEFAULT
+                "reserved" = $8,
+                "tax_rate_id" = $9,
+                "tax_percent" = $10,
+                "tax_inclusive" = $11,
+                "tax" = $12,
+                "version" = "version" + 1,
+                "updated_at" = NOW()
         WHERE "order_product_id" = $1
     `
-
-	result, err := r.db.Exec(
-		query,
-		req.OrderProductID,
-		req.ProductID,
-		req.DiscountType,
-		req.DiscountAmount,
-		req.Quantity,
-		req.Price,
-		req.Sum,
 	)
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var (
+			orderID   sql.NullString
+			productID string
+			price     money.Amount
+			taxRateID string
+			taxRate   tax.Rate
+			reserved  float64
+			version   int64
+		)
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.OrderProductID).Scan(
+			&orderID,
+			&productID,
+			&price,
+			&taxRateID,
+			&taxRate.Percent,
+			&taxRate.Inclusive,
+			&reserved,
+			&version,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.Version != 0 && req.Version != version {
+			return storage.ErrVersionConflict
+		}
+
+		branchID, err := openOrder(ctx, tx, orderID.String)
+		if err != nil {
+			return err
+		}
+
+		// The line keeps the price and the tax rate it was added at unless
+		// it is switched to another product.
+		if req.ProductID != productID {
+			price, err = productPrice(ctx, tx, req.ProductID)
+			if err != nil {
+				return err
+			}
+
+			taxRateID, taxRate, err = productTaxRate(ctx, tx, req.ProductID)
+			if err != nil {
+				return err
+			}
+		}
+
+		sum, lineTax, err := pricing.Line{
+			Price:          price,
+			Quantity:       req.Quantity,
+			DiscountType:   req.DiscountType,
+			DiscountAmount: req.DiscountAmount,
+			Tax:            taxRate,
+		}.Taxed()
+		if err != nil {
+			return err
+		}
+
+		if err := releaseStock(ctx, tx, branchID, productID, reserved); err != nil {
+			return err
+		}
+
+		if err := reserveStock(ctx, tx, branchID, req.ProductID, req.Quantity); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			query,
+			req.OrderProductID,
+			req.ProductID,
+			req.DiscountType,
+			req.DiscountAmount,
+			req.Quantity,
+			price,
+			sum,
+			req.Quantity,
+			helpers.NewNullString(taxRateID),
+			taxRate.Percent,
+			taxRate.Inclusive,
+			lineTax,
+		)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		return recalculateOrder(ctx, tx, orderID.String)
+	})
 	if err != nil {
 		return 0, err
 	}
 
+	return rowsAffected, nil
+}
+
+func (r *orderProductRepo) Delete(ctx context.Context, req *models.OrderProductPrimaryKey) error {
+	var (
+		selectQuery = `
+		SELECT "order_id", "product_id", "reserved" FROM "order_products"
+		WHERE "order_product_id" = $1 AND "deleted_at" IS NULL
+		FOR UPDATE
+	`
+		query = `
+		UPDATE "order_products" SET "deleted_at" = NOW(), "reserved" = 0
+		WHERE "order_product_id" = $1
+	`
+	)
+
+	return inTx(ctx, r.db, func(tx dbtx) error {
+		var (
+			orderID   string
+			productID string
+			reserved  float64
+		)
 
-	rowsAffected, err := result.RowsAffected()
+		err := tx.QueryRowContext(ctx, selectQuery, req.OrderProductID).Scan(&orderID, &productID, &reserved)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// The lines of a closed order settled its stock and its totals.
+		branchID, err := openOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if err := releaseStock(ctx, tx, branchID, productID, reserved); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, req.OrderProductID); err != nil {
+			return err
+		}
+
+		return recalculateOrder(ctx, tx, orderID)
+	})
+}
+
+func (r *orderProductRepo) Restore(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	var (
+		rowsAffected int64
+		selectQuery  = `
+		SELECT "order_id", "product_id", "quantity" FROM "order_products"
+		WHERE "order_product_id" = $1 AND "deleted_at" IS NOT NULL
+		FOR UPDATE
+	`
+		query = `
+		UPDATE "order_products" SET "deleted_at" = NULL, "reserved" = "quantity"
+		WHERE "order_product_id" = $1
+	`
+	)
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var (
+			orderID   string
+			productID string
+			quantity  float64
+		)
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.OrderProductID).Scan(&orderID, &productID, &quantity)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		branchID, err := openOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if err := reserveStock(ctx, tx, branchID, productID, quantity); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, req.OrderProductID); err != nil {
+			return err
+		}
+
+		rowsAffected = 1
+		return recalculateOrder(ctx, tx, orderID)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -187,9 +527,257 @@ func (r *orderProductRepo) Update(req *models.UpdateOrderProduct) (int64, error)
 	return rowsAffected, nil
 }
 
-func (r *orderProductRepo) Delete(req *models.OrderProductPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM \"order_products\" WHERE \"order_product_id\" = $1", req.OrderProductID)
-	return err
+func (r *orderProductRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "order_products" AS deleted
+		WHERE deleted."deleted_at" < $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
 }
 
+func productPrice(ctx context.Context, db dbtx, productID string) (money.Amount, error) {
+	var price money.Amount
+
+	err := db.QueryRowContext(ctx, `SELECT "price" FROM "product" WHERE "id" = $1`, productID).Scan(&price)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("order product product_id %s does not exist", productID)
+	}
+
+	return price, err
+}
+
+// productTaxRate returns the rate a new line of the product is taxed at:
+// the rate of the product, or else of its category. It returns no id and
+// the zero rate when there is none or the rate is deleted.
+func productTaxRate(ctx context.Context, db dbtx, productID string) (string, tax.Rate, error) {
+	var (
+		taxRateID string
+		rate      tax.Rate
+		query     = `
+			SELECT
+				COALESCE(CAST(r."id" AS VARCHAR), ''),
+				COALESCE(r."percent", 0),
+				COALESCE(r."inclusive", FALSE)
+			FROM "product" AS p
+			LEFT JOIN "category" AS c ON c."id" = p."category_id"
+			LEFT JOIN "tax_rates" AS r ON r."id" = COALESCE(p."tax_rate_id", c."tax_rate_id") AND r."deleted_at" IS NULL
+			WHERE p."id" = $1
+		`
+	)
+
+	err := db.QueryRowContext(ctx, query, productID).Scan(&taxRateID, &rate.Percent, &rate.Inclusive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", tax.Rate{}, fmt.Errorf("order product product_id %s does not exist", productID)
+	}
+
+	return taxRateID, rate, err
+}
+
+// orderTotals prices the lines of an order that are not deleted and takes
+// the discount of its promotion off, if promotionID is set, for an order
+// of branchID. It returns the tax of the discounted lines too, by line and
+// by rate.
+// When the order does not qualify for the promotion it returns the totals
+// without a discount together with a promo.ErrNotApplicable error. With a
+// route the delivery is priced by the route instead of deliveryPrice.
+func orderTotals(ctx context.Context, db dbtx, orderID, branchID string, deliveryPrice money.Amount, route *delivery.Route, promotionID string) (pricing.Totals, taxation, error) {
+	var (
+		rule  promo.Rule
+		query = `
+			SELECT
+				op."order_product_id",
+				op."price",
+				op."quantity",
+				COALESCE(op."discount_type", ''),
+				COALESCE(op."discount_amount", 0),
+				op."tax_percent",
+				op."tax_inclusive",
+				COALESCE(CAST(op."tax_rate_id" AS VARCHAR), ''),
+				COALESCE(CAST(p."category_id" AS VARCHAR), '')
+			FROM "order_products" AS op
+			LEFT JOIN "product" AS p ON p."id" = op."product_id"
+			WHERE op."order_id" = $1 AND op."deleted_at" IS NULL
+		`
+	)
+
+	if promotionID != "" {
+		var err error
+		if rule, err = promotionRule(ctx, db, promotionID); err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return pricing.Totals{}, taxation{}, err
+	}
+	defer rows.Close()
+
+	var (
+		lines      []pricing.Line
+		promoLines []promo.Line
+		rateIDs    []string
+		lineIDs    []string
+	)
+
+	for rows.Next() {
+		var (
+			lineID    string
+			line      pricing.Line
+			taxRateID string
+			category  string
+		)
 
+		err := rows.Scan(
+			&lineID,
+			&line.Price,
+			&line.Quantity,
+			&line.DiscountType,
+			&line.DiscountAmount,
+			&line.Tax.Percent,
+			&line.Tax.Inclusive,
+			&taxRateID,
+			&category,
+		)
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		sum, err := line.Sum()
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		lines = append(lines, line)
+		rateIDs = append(rateIDs, taxRateID)
+		lineIDs = append(lineIDs, lineID)
+		promoLines = append(promoLines, promo.Line{Category: category, Sum: sum})
+	}
+
+	if err := rows.Err(); err != nil {
+		return pricing.Totals{}, taxation{}, err
+	}
+
+	var notApplicable error
+	if promotionID != "" {
+		discount, err := rule.Discount(branchID, promoLines)
+		switch {
+		case errors.Is(err, promo.ErrNotApplicable):
+			notApplicable = err
+		case err != nil:
+			return pricing.Totals{}, taxation{}, err
+		default:
+			lines, err = pricing.Spread(lines, discount, func(i int) bool { return rule.Applies(promoLines[i].Category) })
+			if err != nil {
+				return pricing.Totals{}, taxation{}, err
+			}
+		}
+	}
+
+	totals, err := pricing.Order(lines, deliveryPrice)
+	if err != nil {
+		return pricing.Totals{}, taxation{}, err
+	}
+
+	if route != nil {
+		if totals, err = totals.Delivered(route.Fee(totals.Goods()), len(lines)); err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+	}
+
+	taxLines := make([]tax.Line, len(lines))
+	lineTaxes := make(map[string]money.Amount, len(lines))
+	for i, line := range lines {
+		taxable, err := line.Taxable()
+		if err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		if _, lineTaxes[lineIDs[i]], err = line.Taxed(); err != nil {
+			return pricing.Totals{}, taxation{}, err
+		}
+
+		taxLines[i] = tax.Line{RateId: rateIDs[i], Rate: line.Tax, Sum: taxable}
+	}
+
+	return totals, taxation{buckets: tax.Summarize(taxLines), lines: lineTaxes}, notApplicable
+}
+
+// recalculateOrder stores the totals, the tax breakdown and the delivery
+// price of an order after one of its lines changed. It locks the order row first, so concurrent line writes of one
+// order are totalled one after another. An order that no longer qualifies
+// for its promotion keeps it without a discount, until its lines qualify
+// again. The order gets a new version only when the totals actually
+// differ.
+func recalculateOrder(ctx context.Context, tx dbtx, orderID string) error {
+	var (
+		deliveryPrice money.Amount
+		branchID      string
+		promotionID   string
+		address       geo.Point
+		selectQuery   = `
+			SELECT
+				COALESCE("delivery_price", 0),
+				"branch_id",
+				COALESCE(CAST("promotion_id" AS VARCHAR), ''),
+				"latitude",
+				"longitude"
+			FROM "order"
+			WHERE "id" = $1
+			FOR UPDATE
+		`
+		updateQuery = `
+			UPDATE "order"
+				SET
+					"total_count" = $2,
+					"total_price" = $3,
+					"discount" = $4,
+					"tax" = $5,
+					"delivery_price" = $6,
+					"delivery_distance" = $7,
+					"version" = "version" + 1,
+					"updated_at" = NOW()
+			WHERE "id" = $1
+				AND ("total_count", "total_price", "discount", "tax", "delivery_price", "delivery_distance")
+					IS DISTINCT FROM ($2::INT, $3::NUMERIC, $4::NUMERIC, $5::NUMERIC, $6::NUMERIC, $7::DOUBLE PRECISION)
+		`
+	)
+
+	err := tx.QueryRowContext(ctx, selectQuery, orderID).Scan(&deliveryPrice, &branchID, &promotionID, &address.Lat, &address.Lng)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// An order placed before its branch got a tariff may have no location;
+	// it keeps its flat price until it is updated with one.
+	route, err := orderRoute(ctx, tx, branchID, address)
+	if err != nil && !errors.Is(err, delivery.ErrNoLocation) {
+		return err
+	}
+
+	totals, taxed, err := orderTotals(ctx, tx, orderID, branchID, deliveryPrice, route, promotionID)
+	if err != nil && !errors.Is(err, promo.ErrNotApplicable) {
+		return err
+	}
+
+	distance := 0.0
+	if route != nil {
+		deliveryPrice, distance = route.Fee(totals.Goods()), route.Km
+	}
+
+	_, err = tx.ExecContext(ctx, updateQuery, orderID, totals.Count, totals.Total, totals.Discount, totals.Tax, deliveryPrice, distance)
+	if err != nil {
+		return err
+	}
+
+	return storeOrderTaxes(ctx, tx, orderID, taxed)
+}
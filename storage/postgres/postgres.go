@@ -1,27 +1,81 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"market_system/config"
 	"market_system/storage"
+	"market_system/storage/postgres/migrations"
 
 	_ "github.com/lib/pq"
 )
 
+// dbtx is the part of *sql.DB and *sql.Tx the repos use, so the same repo
+// code runs with or without a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type Store struct {
-	db       *sql.DB
-	category storage.CategoryRepoI
-	product  storage.ProductRepoI
-	client   storage.ClientRepoI
-	branch   storage.BranchRepoI
-	order storage.OrderRepoI
+	db           *sql.DB
+	conn         dbtx
+	migrator     *migrations.Migrator
+	category     storage.CategoryRepoI
+	product      storage.ProductRepoI
+	client       storage.ClientRepoI
+	branch       storage.BranchRepoI
+	order        storage.OrderRepoI
 	orderProduct storage.OrderProductRepoI
+	audit        storage.AuditRepoI
+	sequence     storage.SequenceRepoI
+	stock        storage.StockRepoI
+	promotion    storage.PromotionRepoI
+	taxRate      storage.TaxRateRepoI
 }
 
 func NewConnectionPostgres(cfg *config.Config) (storage.StorageI, error) {
 
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	strg := &Store{
+		db:       db,
+		conn:     db,
+		migrator: migrator,
+	}
+
+	ctx := context.Background()
+	if cfg.DBTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DBTimeout)
+		defer cancel()
+	}
+
+	if err := strg.Ping(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return strg, nil
+}
+
+// Open connects to the database from cfg without checking its schema, for
+// callers such as the migrate command that have to work on older versions.
+func Open(cfg *config.Config) (*sql.DB, error) {
+
 	connect := fmt.Sprintf(
 		"host=%s user=%s dbname=%s password=%s port=%s sslmode=disable",
 		cfg.PostgresHost,
@@ -33,18 +87,59 @@ func NewConnectionPostgres(cfg *config.Config) (storage.StorageI, error) {
 
 	db, err := sql.Open("postgres", connect)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.PostgresMaxOpenConns)
+	db.SetMaxIdleConns(cfg.PostgresMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.PostgresConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.PostgresConnMaxIdleTime)
+
+	return db, nil
+}
+
+// Ping checks the connection and that no migration is pending.
+func (s *Store) Ping(ctx context.Context) error {
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping postgres: %w", err)
+	}
+
+	return s.migrator.CheckUpToDate(ctx)
+}
+
+// Close closes the connection pool. A Store scoped to a transaction does
+// not own the pool and refuses.
+func (s *Store) Close() error {
+
+	if _, ok := s.conn.(*sql.Tx); ok {
+		return errors.New("close called on a transaction scoped store")
+	}
+
+	return s.db.Close()
+}
+
+// Tx runs fn in one database transaction. A Store that is already scoped
+// to a transaction runs fn inside it.
+func (s *Store) Tx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+
+	if _, ok := s.conn.(*sql.Tx); ok {
+		return fn(s)
 	}
 
-	return &Store{
-		db: db,
-	}, nil
+	return inTx(ctx, s.conn, func(tx dbtx) error {
+		return fn(&Store{
+			db:       s.db,
+			conn:     tx,
+			migrator: s.migrator,
+		})
+	})
 }
 
 func (s *Store) Category() storage.CategoryRepoI {
 
 	if s.category == nil {
-		s.category = NewCategoryRepo(s.db)
+		s.category = NewCategoryRepo(s.conn)
 	}
 
 	return s.category
@@ -53,7 +148,7 @@ func (s *Store) Category() storage.CategoryRepoI {
 func (s *Store) Product() storage.ProductRepoI {
 
 	if s.product == nil {
-		s.product = NewProductRepo(s.db)
+		s.product = NewProductRepo(s.conn)
 	}
 
 	return s.product
@@ -62,7 +157,7 @@ func (s *Store) Product() storage.ProductRepoI {
 func (s *Store) Client() storage.ClientRepoI {
 
 	if s.client == nil {
-		s.client = NewClientRepo(s.db)
+		s.client = NewClientRepo(s.conn)
 	}
 
 	return s.client
@@ -71,7 +166,7 @@ func (s *Store) Client() storage.ClientRepoI {
 func (s *Store) Branch() storage.BranchRepoI {
 
 	if s.branch == nil {
-		s.branch = NewBranchRepo(s.db)
+		s.branch = NewBranchRepo(s.conn)
 	}
 
 	return s.branch
@@ -80,19 +175,106 @@ func (s *Store) Branch() storage.BranchRepoI {
 func (s *Store) Order() storage.OrderRepoI {
 
 	if s.order == nil {
-		s.order = NewOrderRepo(s.db)
+		s.order = NewOrderRepo(s.conn)
 	}
 
 	return s.order
 }
 
-
-
 func (s *Store) OrderProduct() storage.OrderProductRepoI {
 
 	if s.orderProduct == nil {
-		s.orderProduct = NewOrderProductRepo(s.db)
+		s.orderProduct = NewOrderProductRepo(s.conn)
 	}
 
 	return s.orderProduct
-}
\ No newline at end of file
+}
+
+func (s *Store) Audit() storage.AuditRepoI {
+
+	if s.audit == nil {
+		s.audit = NewAuditRepo(s.conn)
+	}
+
+	return s.audit
+}
+
+func (s *Store) Sequence() storage.SequenceRepoI {
+
+	if s.sequence == nil {
+		s.sequence = NewSequenceRepo(s.conn)
+	}
+
+	return s.sequence
+}
+
+func (s *Store) Stock() storage.StockRepoI {
+
+	if s.stock == nil {
+		s.stock = NewStockRepo(s.conn)
+	}
+
+	return s.stock
+}
+
+func (s *Store) Promotion() storage.PromotionRepoI {
+
+	if s.promotion == nil {
+		s.promotion = NewPromotionRepo(s.conn)
+	}
+
+	return s.promotion
+}
+
+func (s *Store) TaxRate() storage.TaxRateRepoI {
+
+	if s.taxRate == nil {
+		s.taxRate = NewTaxRateRepo(s.conn)
+	}
+
+	return s.taxRate
+}
+
+// inTx runs fn in a transaction. When db already is a *sql.Tx, fn joins it
+// and the outer caller decides whether to commit.
+func inTx(ctx context.Context, db dbtx, fn func(tx dbtx) error) error {
+
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.(*sql.DB).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkVersion explains an update that carried a version but matched no
+// row: storage.ErrVersionConflict when the row is still there, so the
+// version was stale, and nil when the row is missing.
+func checkVersion(ctx context.Context, db dbtx, table, idColumn, id string) error {
+
+	var (
+		exists bool
+		query  = fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s" WHERE "%s" = $1 AND "deleted_at" IS NULL)`, table, idColumn)
+	)
+
+	if err := db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return storage.ErrVersionConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"market_system/config"
+	"market_system/storage"
+	"market_system/storage/postgres/migrations"
+	"market_system/storage/storagetest"
+)
+
+// TestStorage migrates the database named by POSTGRES_TEST_DATABASE and
+// runs the shared suite against it. The other connection settings come
+// from the usual POSTGRES_* variables.
+func TestStorage(t *testing.T) {
+
+	database, ok := os.LookupEnv("POSTGRES_TEST_DATABASE")
+	if !ok {
+		t.Skip("POSTGRES_TEST_DATABASE is not set")
+	}
+
+	cfg := config.Load()
+	cfg.PostgresDatabase = database
+
+	db, err := Open(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	strg, err := NewConnectionPostgres(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return strg
+	})
+}
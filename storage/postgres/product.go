@@ -1,26 +1,43 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"market_system/models"
+	"market_system/pkg/filter"
 	"market_system/pkg/helpers"
+	"market_system/pkg/money"
 
 	"github.com/google/uuid"
 )
 
+var productColumns = filter.Columns{
+	"id":          `"id"`,
+	"product_id":  `"product_id"`,
+	"title":       `"title"`,
+	"description": `"description"`,
+	"price":       `"price"`,
+	"category_id": `"category_id"`,
+	"tax_rate_id": `"tax_rate_id"`,
+	"created_at":  `"created_at"`,
+	"updated_at":  `"updated_at"`,
+	"deleted_at":  `"deleted_at"`,
+}
+
 type productRepo struct {
-	db *sql.DB
+	db dbtx
 }
 
-func NewProductRepo(db *sql.DB) *productRepo {
+func NewProductRepo(db dbtx) *productRepo {
 	return &productRepo{
 		db: db,
 	}
 }
 
-func (r *productRepo) Create(req *models.CreateProduct) (*models.Product, error) {
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (*models.Product, error) {
 
 	query := `
             INSERT INTO "product"(
@@ -31,12 +48,18 @@ func (r *productRepo) Create(req *models.CreateProduct) (*models.Product, error)
                 "price",
                 "photo",
                 "category_id",
+                "tax_rate_id",
                 "created_at",
 				"updated_at"
-            ) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())`
+            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())`
 	productId := uuid.New().String()
 
-	_, err := r.db.Exec(
+	if err := checkTaxRate(ctx, r.db, "product", req.TaxRateId); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
 		productId,
 		req.ProductId,
@@ -45,16 +68,17 @@ func (r *productRepo) Create(req *models.CreateProduct) (*models.Product, error)
 		req.Price,
 		req.Photo,
 		helpers.NewNullString(req.CategoryId),
+		helpers.NewNullString(req.TaxRateId),
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при создании продукта: %v", err)
 	}
 
-	return r.GetByID(&models.ProductPrimaryKey{Id: productId})
+	return r.GetByID(ctx, &models.ProductPrimaryKey{Id: productId})
 }
 
-func (r *productRepo) GetByID(req *models.ProductPrimaryKey) (*models.Product, error) {
+func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error) {
 
 	var (
 		query = `
@@ -66,10 +90,12 @@ func (r *productRepo) GetByID(req *models.ProductPrimaryKey) (*models.Product, e
         "photo",   
         "price",  
         "category_id",
+        COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
         "updated_at",
-        "created_at"
+        "created_at",
+        "version"
     FROM "product"
-    WHERE "id" = $1
+    WHERE "id" = $1 AND "deleted_at" IS NULL
     `
 	)
 
@@ -79,13 +105,15 @@ func (r *productRepo) GetByID(req *models.ProductPrimaryKey) (*models.Product, e
 		description sql.NullString
 		title       sql.NullString
 		photo       sql.NullString
-		price       sql.NullFloat64
+		price       money.Amount
 		category_id sql.NullString
+		tax_rate_id string
 		updated_at  sql.NullString
 		created_at  sql.NullString
+		version     int64
 	)
 
-	err := r.db.QueryRow(query, req.Id).Scan(
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
 		&id,
 		&title,
 		&product_id,
@@ -93,8 +121,10 @@ func (r *productRepo) GetByID(req *models.ProductPrimaryKey) (*models.Product, e
 		&photo,
 		&price,
 		&category_id,
+		&tax_rate_id,
 		&updated_at,
 		&created_at,
+		&version,
 	)
 
 	if err != nil {
@@ -102,50 +132,59 @@ func (r *productRepo) GetByID(req *models.ProductPrimaryKey) (*models.Product, e
 	}
 
 	return &models.Product{
-		Id:         id.String,
-		Title:      title.String,
-		Photo:      photo.String,
-		ProductId:  product_id.String,
+		Id:          id.String,
+		Title:       title.String,
+		Photo:       photo.String,
+		ProductId:   product_id.String,
 		Description: description.String,
-		Price:      price.Float64,
-		CategoryId: category_id.String,
-		UpdatedAt:  updated_at.String,
-		CreatedAt:  created_at.String,
+		Price:       price,
+		CategoryId:  category_id.String,
+		TaxRateId:   tax_rate_id,
+		UpdatedAt:   updated_at.String,
+		CreatedAt:   created_at.String,
+		Version:     version,
 	}, nil
 }
 
-func (r *productRepo) GetList(req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
 	var (
-		resp   models.GetListProductResponse
-		where  = " WHERE TRUE"
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
-		sort   = " ORDER BY created_at DESC"
+		resp  models.GetListProductResponse
+		conds = []filter.Expr{req.Filter}
 	)
 
-	if req.Offset > 0 {
-		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	if len(req.Search) > 0 {
+		conds = append(conds, filter.Or(
+			filter.ILike("title", req.Search),
+			filter.ILike("category_id", req.Search),
+		))
 	}
 
-	if req.Limit > 0 {
-		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	if !req.IncludeDeleted {
+		conds = append(conds, filter.IsNull("deleted_at"))
 	}
 
-	if len(req.Search) > 0 {
-		where += " AND (title ILIKE '%" + req.Search + "%' OR category_id::text ILIKE '%" + req.Search + "%')"
+	where, args, err := filter.Where(filter.And(conds...), productColumns, nil)
+	if err != nil {
+		return nil, err
 	}
-	
-	
-	var countQuery = `
-		SELECT COUNT(*) FROM "product"
-	`
-	countQuery += where
 
-	err := r.db.QueryRow(countQuery).Scan(&resp.Count)
+	page, err := newListPage(where, args, `"id"`, req.Offset, req.Limit, req.Cursor)
 	if err != nil {
 		return nil, err
 	}
 
+	if !page.cursor {
+		var countQuery = `
+			SELECT COUNT(*) FROM "product"
+		`
+		countQuery += where
+
+		err = r.db.QueryRowContext(ctx, countQuery, args...).Scan(&resp.Count)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var selectQuery = `
 		SELECT
 			"id",
@@ -155,16 +194,20 @@ func (r *productRepo) GetList(req *models.GetListProductRequest) (*models.GetLis
 			"photo",   
 			"price",
 			"category_id",
+			COALESCE(CAST("tax_rate_id" AS VARCHAR), ''),
 			"updated_at",
-			"created_at"
+			"created_at",
+			"deleted_at",
+			"version"
 		FROM "product"
 	`
 
-	selectQuery += where + sort + offset + limit
-	rows, err := r.db.Query(selectQuery)
+	selectQuery += page.where + page.tail
+	rows, err := r.db.QueryContext(ctx, selectQuery, page.args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
@@ -173,42 +216,62 @@ func (r *productRepo) GetList(req *models.GetListProductRequest) (*models.GetLis
 			photo       sql.NullString
 			productId   sql.NullString
 			description sql.NullString
-			price       sql.NullFloat64
+			price       money.Amount
 			category_id sql.NullString
+			tax_rate_id string
 			updated_at  sql.NullString
 			created_at  sql.NullString
+			deleted_at  sql.NullString
+			version     int64
 		)
 
 		err := rows.Scan(
 			&id,
 			&title,
-			&photo,
 			&productId,
 			&description,
+			&photo,
 			&price,
 			&category_id,
+			&tax_rate_id,
 			&updated_at,
 			&created_at,
+			&deleted_at,
+			&version,
 		)
 		if err != nil {
 			return nil, err
 		}
 
 		resp.Products = append(resp.Products, &models.Product{
-			Id:         id.String,
-			Title:      title.String,
-			Photo:      photo.String,
-			Price:      price.Float64,
-			CategoryId: category_id.String,
-			UpdatedAt:  updated_at.String,
-			CreatedAt:  created_at.String,
+			Id:          id.String,
+			Title:       title.String,
+			ProductId:   productId.String,
+			Description: description.String,
+			Photo:       photo.String,
+			Price:       price,
+			CategoryId:  category_id.String,
+			TaxRateId:   tax_rate_id,
+			UpdatedAt:   updated_at.String,
+			CreatedAt:   created_at.String,
+			DeletedAt:   deleted_at.String,
+			Version:     version,
 		})
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := page.next(len(resp.Products), func(i int) (string, string) {
+		return resp.Products[i].CreatedAt, resp.Products[i].Id
+	})
+	resp.Products, resp.NextCursor = resp.Products[:n], next
+
 	return &resp, nil
 }
 
-func (r *productRepo) Update(req *models.UpdateProduct) (int64, error) {
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
 	query := `
 		UPDATE product
 			SET
@@ -217,10 +280,18 @@ func (r *productRepo) Update(req *models.UpdateProduct) (int64, error) {
 				description = $4,
 				price = $5,
 				category_id = $6,
+				tax_rate_id = $8,
+				"version" = "version" + 1,
 				updated_at = NOW()  
-		WHERE id = $1
+		WHERE id = $1 AND "deleted_at" IS NULL AND ($7::BIGINT = 0 OR "version" = $7)
 	`
-	result, err := r.db.Exec(
+
+	if err := checkTaxRate(ctx, r.db, "product", req.TaxRateId); err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.ExecContext(
+		ctx,
 		query,
 		req.Id,
 		req.Title,
@@ -228,6 +299,8 @@ func (r *productRepo) Update(req *models.UpdateProduct) (int64, error) {
 		req.Description,
 		req.Price,
 		helpers.NewNullString(req.CategoryId),
+		req.Version,
+		helpers.NewNullString(req.TaxRateId),
 	)
 	if err != nil {
 		return 0, err
@@ -238,10 +311,43 @@ func (r *productRepo) Update(req *models.UpdateProduct) (int64, error) {
 		return 0, err
 	}
 
+	if rowsAffected == 0 && req.Version != 0 {
+		return 0, checkVersion(ctx, r.db, "product", "id", req.Id)
+	}
+
 	return rowsAffected, nil
 }
 
-func (r *productRepo) Delete(req *models.ProductPrimaryKey) error {
-	_, err := r.db.Exec("DELETE FROM product WHERE id = $1", req.Id)
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) error {
+	query := `UPDATE "product" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.Id)
 	return err
 }
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	query := `UPDATE "product" SET "deleted_at" = NULL WHERE "id" = $1 AND "deleted_at" IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *productRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM "product" AS deleted
+		WHERE deleted."deleted_at" < $1
+			AND NOT EXISTS (SELECT 1 FROM "order_products" WHERE "order_products"."product_id" = deleted."id")
+			AND NOT EXISTS (SELECT 1 FROM "stock_movements" WHERE "stock_movements"."product_id" = deleted."id")
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,497 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/helpers"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type promotionRepo struct {
+	db dbtx
+}
+
+func NewPromotionRepo(db dbtx) *promotionRepo {
+	return &promotionRepo{
+		db: db,
+	}
+}
+
+func (r *promotionRepo) Create(ctx context.Context, req *models.CreatePromotion) (*models.Promotion, error) {
+	var (
+		promotionID = uuid.New().String()
+		code        = promo.Normalize(req.Code)
+		query       = `
+			INSERT INTO "promotions"(
+				"id",
+				"code",
+				"type",
+				"value",
+				"starts_at",
+				"ends_at",
+				"usage_limit",
+				"per_client_limit",
+				"min_order_total",
+				"branch_ids",
+				"category_ids",
+				"created_at",
+				"updated_at"
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		`
+	)
+
+	rule := promo.Rule{
+		Code:           code,
+		Type:           req.Type,
+		Value:          req.Value,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		UsageLimit:     req.UsageLimit,
+		PerClientLimit: req.PerClientLimit,
+		MinOrderTotal:  req.MinOrderTotal,
+		Branches:       req.BranchIds,
+		Categories:     req.CategoryIds,
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		if err := checkPromotion(ctx, tx, promotionID, rule); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			promotionID,
+			code,
+			req.Type,
+			req.Value,
+			nullTime(req.StartsAt),
+			nullTime(req.EndsAt),
+			req.UsageLimit,
+			req.PerClientLimit,
+			req.MinOrderTotal,
+			pq.Array(ids(req.BranchIds)),
+			pq.Array(ids(req.CategoryIds)),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, &models.PromotionPrimaryKey{Id: promotionID})
+}
+
+func (r *promotionRepo) GetByID(ctx context.Context, req *models.PromotionPrimaryKey) (*models.Promotion, error) {
+	var (
+		promotion models.Promotion
+		startsAt  sql.NullString
+		endsAt    sql.NullString
+		query     = `
+			SELECT
+				"id",
+				"code",
+				"type",
+				"value",
+				"starts_at",
+				"ends_at",
+				"usage_limit",
+				"per_client_limit",
+				"min_order_total",
+				"branch_ids",
+				"category_ids",
+				"created_at",
+				"updated_at",
+				"version"
+			FROM "promotions"
+			WHERE "id" = $1 AND "deleted_at" IS NULL
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
+		&promotion.Id,
+		&promotion.Code,
+		&promotion.Type,
+		&promotion.Value,
+		&startsAt,
+		&endsAt,
+		&promotion.UsageLimit,
+		&promotion.PerClientLimit,
+		&promotion.MinOrderTotal,
+		pq.Array(&promotion.BranchIds),
+		pq.Array(&promotion.CategoryIds),
+		&promotion.CreatedAt,
+		&promotion.UpdatedAt,
+		&promotion.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	promotion.StartsAt = startsAt.String
+	promotion.EndsAt = endsAt.String
+
+	return &promotion, nil
+}
+
+func (r *promotionRepo) GetList(ctx context.Context, req *models.GetListPromotionRequest) (*models.GetListPromotionResponse, error) {
+	var (
+		resp   models.GetListPromotionResponse
+		where  = ` WHERE "code" LIKE $1 || '%'`
+		offset = req.Offset
+		limit  = req.Limit
+		query  = `
+			SELECT
+				COUNT(*) OVER(),
+				"id",
+				"code",
+				"type",
+				"value",
+				"starts_at",
+				"ends_at",
+				"usage_limit",
+				"per_client_limit",
+				"min_order_total",
+				"branch_ids",
+				"category_ids",
+				"created_at",
+				"updated_at",
+				"deleted_at",
+				"version"
+			FROM "promotions"
+		`
+	)
+
+	if !req.IncludeDeleted {
+		where += ` AND "deleted_at" IS NULL`
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query += where + fmt.Sprintf(` ORDER BY "created_at" DESC, "id" DESC OFFSET %d LIMIT %d`, offset, limit)
+	rows, err := r.db.QueryContext(ctx, query, promo.Normalize(req.Search))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			promotion models.Promotion
+			startsAt  sql.NullString
+			endsAt    sql.NullString
+			deletedAt sql.NullString
+		)
+
+		err = rows.Scan(
+			&resp.Count,
+			&promotion.Id,
+			&promotion.Code,
+			&promotion.Type,
+			&promotion.Value,
+			&startsAt,
+			&endsAt,
+			&promotion.UsageLimit,
+			&promotion.PerClientLimit,
+			&promotion.MinOrderTotal,
+			pq.Array(&promotion.BranchIds),
+			pq.Array(&promotion.CategoryIds),
+			&promotion.CreatedAt,
+			&promotion.UpdatedAt,
+			&deletedAt,
+			&promotion.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		promotion.StartsAt = startsAt.String
+		promotion.EndsAt = endsAt.String
+		promotion.DeletedAt = deletedAt.String
+
+		resp.Promotions = append(resp.Promotions, &promotion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (r *promotionRepo) Update(ctx context.Context, req *models.UpdatePromotion) (int64, error) {
+	var (
+		rowsAffected int64
+		code         = promo.Normalize(req.Code)
+		selectQuery  = `SELECT "version" FROM "promotions" WHERE "id" = $1 AND "deleted_at" IS NULL FOR UPDATE`
+		query        = `
+			UPDATE "promotions"
+				SET
+					"code" = $2,
+					"type" = $3,
+					"value" = $4,
+					"starts_at" = $5,
+					"ends_at" = $6,
+					"usage_limit" = $7,
+					"per_client_limit" = $8,
+					"min_order_total" = $9,
+					"branch_ids" = $10,
+					"category_ids" = $11,
+					"version" = "version" + 1,
+					"updated_at" = NOW()
+			WHERE "id" = $1
+		`
+	)
+
+	rule := promo.Rule{
+		Code:           code,
+		Type:           req.Type,
+		Value:          req.Value,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		UsageLimit:     req.UsageLimit,
+		PerClientLimit: req.PerClientLimit,
+		MinOrderTotal:  req.MinOrderTotal,
+		Branches:       req.BranchIds,
+		Categories:     req.CategoryIds,
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var version int64
+
+		err := tx.QueryRowContext(ctx, selectQuery, req.Id).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.Version != 0 && req.Version != version {
+			return storage.ErrVersionConflict
+		}
+
+		if err := checkPromotion(ctx, tx, req.Id, rule); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(
+			ctx,
+			query,
+			req.Id,
+			code,
+			req.Type,
+			req.Value,
+			nullTime(req.StartsAt),
+			nullTime(req.EndsAt),
+			req.UsageLimit,
+			req.PerClientLimit,
+			req.MinOrderTotal,
+			pq.Array(ids(req.BranchIds)),
+			pq.Array(ids(req.CategoryIds)),
+		)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+func (r *promotionRepo) Delete(ctx context.Context, req *models.PromotionPrimaryKey) error {
+	query := `UPDATE "promotions" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.Id)
+	return err
+}
+
+// checkPromotion validates a promotion about to be written: its rules, that
+// its code is not taken by another promotion and that its branches and
+// categories exist.
+func checkPromotion(ctx context.Context, tx dbtx, promotionID string, rule promo.Rule) error {
+
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	var taken bool
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM "promotions"
+			WHERE "code" = $1 AND "id" <> $2 AND "deleted_at" IS NULL
+		)
+	`, rule.Code, promotionID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return fmt.Errorf("%w: code %s is taken", promo.ErrInvalid, rule.Code)
+	}
+
+	for _, ref := range []struct {
+		table string
+		ids   []string
+	}{
+		{"branches", rule.Branches},
+		{"category", rule.Categories},
+	} {
+		for _, id := range ref.ids {
+			if !helpers.IsValidUUID(id) {
+				return fmt.Errorf("%w: %s id %s is not uuid", promo.ErrInvalid, ref.table, id)
+			}
+
+			var exists bool
+
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "`+ref.table+`" WHERE "id" = $1)`, id).Scan(&exists)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				return fmt.Errorf("%w: %s %s does not exist", promo.ErrInvalid, ref.table, id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// promotionRule reads the rules of a promotion, deleted or not, since
+// orders keep the promotions they were given.
+func promotionRule(ctx context.Context, db dbtx, promotionID string) (promo.Rule, error) {
+	var (
+		rule     promo.Rule
+		startsAt sql.NullTime
+		endsAt   sql.NullTime
+		query    = `
+			SELECT
+				"code",
+				"type",
+				"value",
+				"starts_at",
+				"ends_at",
+				"usage_limit",
+				"per_client_limit",
+				"min_order_total",
+				"branch_ids",
+				"category_ids"
+			FROM "promotions"
+			WHERE "id" = $1
+		`
+	)
+
+	err := db.QueryRowContext(ctx, query, promotionID).Scan(
+		&rule.Code,
+		&rule.Type,
+		&rule.Value,
+		&startsAt,
+		&endsAt,
+		&rule.UsageLimit,
+		&rule.PerClientLimit,
+		&rule.MinOrderTotal,
+		pq.Array(&rule.Branches),
+		pq.Array(&rule.Categories),
+	)
+	if err != nil {
+		return promo.Rule{}, err
+	}
+
+	rule.StartsAt = startsAt.Time
+	rule.EndsAt = endsAt.Time
+
+	return rule, nil
+}
+
+// orderPromotion returns the id and code of the promotion of code for an
+// order, empty for no code. A code the order has already (appliedID and
+// appliedCode) is kept; a new one must be valid now and have uses left,
+// counting the orders that are neither deleted nor canceled. The
+// promotion row is locked, so concurrent orders cannot use it past its
+// limits.
+func orderPromotion(ctx context.Context, tx dbtx, orderID, clientID, appliedID, appliedCode, code string) (string, string, error) {
+
+	code = promo.Normalize(code)
+	if code == "" {
+		return "", "", nil
+	}
+
+	if appliedID != "" && code == appliedCode {
+		return appliedID, appliedCode, nil
+	}
+
+	var promotionID string
+
+	err := tx.QueryRowContext(ctx, `SELECT "id" FROM "promotions" WHERE "code" = $1 AND "deleted_at" IS NULL FOR UPDATE`, code).Scan(&promotionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", fmt.Errorf("%w: unknown code %s", promo.ErrNotApplicable, code)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	var (
+		uses, clientUses int
+		usesQuery        = `
+			SELECT
+				COUNT(*),
+				COUNT(*) FILTER (WHERE "client_id" = $3)
+			FROM "order"
+			WHERE "promotion_id" = $1 AND "id" <> $2 AND "deleted_at" IS NULL AND "status" <> $4
+		`
+	)
+
+	err = tx.QueryRowContext(ctx, usesQuery, promotionID, orderID, clientID, workflow.StatusCanceled).Scan(&uses, &clientUses)
+	if err != nil {
+		return "", "", err
+	}
+
+	rule, err := promotionRule(ctx, tx, promotionID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := rule.Check(time.Now(), uses, clientUses); err != nil {
+		return "", "", err
+	}
+
+	return promotionID, code, nil
+}
+
+// nullTime stores the zero time as NULL, an open bound.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// ids turns a nil list into an empty one for the NOT NULL array columns.
+func ids(list []string) []string {
+
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"encoding/json"
+	"time"
+
+	"market_system/models"
+)
+
+// scheduleColumns encodes the shifts and holidays of a branch for the
+// JSON columns, as empty arrays when there are none.
+func scheduleColumns(shifts []models.BranchHours, holidays []models.BranchHoliday) (string, string, error) {
+
+	if shifts == nil {
+		shifts = []models.BranchHours{}
+	}
+
+	if holidays == nil {
+		holidays = []models.BranchHoliday{}
+	}
+
+	shiftsJSON, err := json.Marshal(shifts)
+	if err != nil {
+		return "", "", err
+	}
+
+	holidaysJSON, err := json.Marshal(holidays)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(shiftsJSON), string(holidaysJSON), nil
+}
+
+// scanSchedule decodes the JSON columns of a branch read at readAt and
+// sets whether it is open.
+func scanSchedule(branch *models.Branch, shifts, holidays []byte, readAt time.Time) error {
+
+	branch.Schedule, branch.Holidays = []models.BranchHours{}, []models.BranchHoliday{}
+
+	if err := json.Unmarshal(shifts, &branch.Schedule); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(holidays, &branch.Holidays); err != nil {
+		return err
+	}
+
+	branch.SetOpenState(readAt)
+	return nil
+}
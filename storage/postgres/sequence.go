@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+)
+
+type sequenceRepo struct {
+	db dbtx
+}
+
+func NewSequenceRepo(db dbtx) *sequenceRepo {
+	return &sequenceRepo{
+		db: db,
+	}
+}
+
+// Next increments the counter in one upsert. The row stays locked until
+// the surrounding transaction ends, so concurrent callers never read the
+// same value.
+func (r *sequenceRepo) Next(ctx context.Context, name string) (int64, error) {
+	var (
+		value int64
+		query = `
+			INSERT INTO "sequences" ("name", "value") VALUES ($1, 1)
+			ON CONFLICT ("name") DO UPDATE SET "value" = "sequences"."value" + 1
+			RETURNING "value"
+		`
+	)
+
+	if err := r.db.QueryRowContext(ctx, query, name).Scan(&value); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,752 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"market_system/models"
+	"market_system/pkg/ledger"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+type stockRepo struct {
+	db dbtx
+}
+
+func NewStockRepo(db dbtx) *stockRepo {
+	return &stockRepo{
+		db: db,
+	}
+}
+
+func (r *stockRepo) GetByID(ctx context.Context, req *models.StockPrimaryKey) (*models.Stock, error) {
+	var (
+		stock models.Stock
+		query = `
+			SELECT
+				"branch_id",
+				"product_id",
+				"quantity",
+				"reserved",
+				"quantity" - "reserved",
+				"updated_at"
+			FROM "stock"
+			WHERE "branch_id" = $1 AND "product_id" = $2
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, query, req.BranchId, req.ProductId).Scan(
+		&stock.BranchId,
+		&stock.ProductId,
+		&stock.Quantity,
+		&stock.Reserved,
+		&stock.Available,
+		&stock.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stock, nil
+}
+
+func (r *stockRepo) GetList(ctx context.Context, req *models.GetListStockRequest) (*models.GetListStockResponse, error) {
+	var (
+		resp   models.GetListStockResponse
+		args   []interface{}
+		where  = " WHERE TRUE"
+		offset = req.Offset
+		limit  = req.Limit
+		query  = `
+			SELECT
+				COUNT(*) OVER(),
+				"branch_id",
+				"product_id",
+				"quantity",
+				"reserved",
+				"quantity" - "reserved",
+				"updated_at"
+			FROM "stock"
+		`
+	)
+
+	if req.BranchId != "" {
+		args = append(args, req.BranchId)
+		where += ` AND "branch_id" = $1`
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query += where + fmt.Sprintf(` ORDER BY "branch_id", "product_id" OFFSET %d LIMIT %d`, offset, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stock models.Stock
+
+		err = rows.Scan(
+			&resp.Count,
+			&stock.BranchId,
+			&stock.ProductId,
+			&stock.Quantity,
+			&stock.Reserved,
+			&stock.Available,
+			&stock.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Stocks = append(resp.Stocks, &stock)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (r *stockRepo) Adjust(ctx context.Context, req *models.AdjustStock) (*models.Stock, error) {
+	selectQuery := `
+		SELECT "quantity", "reserved" FROM "stock"
+		WHERE "branch_id" = $1 AND "product_id" = $2
+		FOR UPDATE
+	`
+
+	if err := ledger.CheckAdjust(req.Type, req.Delta); err != nil {
+		return nil, err
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		var exists bool
+
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "branches" WHERE "id" = $1 AND "deleted_at" IS NULL)`, req.BranchId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("stock branch_id %s does not exist", req.BranchId)
+		}
+
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "product" WHERE "id" = $1 AND "deleted_at" IS NULL)`, req.ProductId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("stock product_id %s does not exist", req.ProductId)
+		}
+
+		var quantity, reserved float64
+
+		err = tx.QueryRowContext(ctx, selectQuery, req.BranchId, req.ProductId).Scan(&quantity, &reserved)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if quantity+req.Delta < reserved {
+			return fmt.Errorf("%w: %v of product %s is reserved at branch %s, the quantity cannot go down to %v",
+				storage.ErrInsufficientStock, reserved, req.ProductId, req.BranchId, quantity+req.Delta)
+		}
+
+		return recordMovement(ctx, tx, req.BranchId, req.ProductId, req.Type, req.Delta, req.Reference, req.Actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, &models.StockPrimaryKey{BranchId: req.BranchId, ProductId: req.ProductId})
+}
+
+func (r *stockRepo) Movements(ctx context.Context, req *models.GetListStockMovementRequest) (*models.GetListStockMovementResponse, error) {
+	var (
+		resp   models.GetListStockMovementResponse
+		args   []interface{}
+		where  = " WHERE TRUE"
+		offset = req.Offset
+		limit  = req.Limit
+		query  = `
+			SELECT
+				COUNT(*) OVER(),
+				"id",
+				"branch_id",
+				"product_id",
+				"type",
+				"quantity",
+				"reference",
+				"actor",
+				"created_at"
+			FROM "stock_movements"
+		`
+	)
+
+	for _, cond := range []struct {
+		column string
+		value  interface{}
+		set    bool
+	}{
+		{`"branch_id" =`, req.BranchId, req.BranchId != ""},
+		{`"product_id" =`, req.ProductId, req.ProductId != ""},
+		{`"type" =`, req.Type, req.Type != ""},
+		{`"reference" =`, req.Reference, req.Reference != ""},
+		{`"created_at" >=`, req.From, !req.From.IsZero()},
+		{`"created_at" <`, req.To, !req.To.IsZero()},
+	} {
+		if cond.set {
+			args = append(args, cond.value)
+			where += fmt.Sprintf(" AND %s $%d", cond.column, len(args))
+		}
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query += where + fmt.Sprintf(` ORDER BY "created_at", "id" OFFSET %d LIMIT %d`, offset, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movement models.StockMovement
+
+		err = rows.Scan(
+			&resp.Count,
+			&movement.Id,
+			&movement.BranchId,
+			&movement.ProductId,
+			&movement.Type,
+			&movement.Quantity,
+			&movement.Reference,
+			&movement.Actor,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Movements = append(resp.Movements, &movement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Reconcile sums the movements of the branch by product and type, split
+// at the start of the period, and folds the sums into lines.
+func (r *stockRepo) Reconcile(ctx context.Context, req *models.StockReconciliationRequest) (*models.StockReconciliation, error) {
+	var (
+		resp  = models.StockReconciliation{BranchId: req.BranchId}
+		to    sql.NullTime
+		query = `
+			SELECT
+				"product_id",
+				"type",
+				COALESCE(SUM("quantity") FILTER (WHERE "created_at" < $2), 0),
+				SUM("quantity") FILTER (WHERE "created_at" >= $2)
+			FROM "stock_movements"
+			WHERE "branch_id" = $1 AND ($3::TIMESTAMP IS NULL OR "created_at" < $3)
+			GROUP BY "product_id", "type"
+			ORDER BY "product_id", "type"
+		`
+	)
+
+	if !req.To.IsZero() {
+		to = sql.NullTime{Time: req.To.UTC(), Valid: true}
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, req.BranchId, req.From.UTC(), to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var line *models.StockReconciliationLine
+	for rows.Next() {
+		var (
+			productID    string
+			movementType string
+			opening      float64
+			moved        sql.NullFloat64
+		)
+
+		if err := rows.Scan(&productID, &movementType, &opening, &moved); err != nil {
+			return nil, err
+		}
+
+		if line == nil || line.ProductId != productID {
+			line = &models.StockReconciliationLine{ProductId: productID, Movements: make(map[string]float64)}
+			resp.Products = append(resp.Products, line)
+		}
+
+		line.Opening += opening
+		line.Closing += opening
+		if moved.Valid {
+			line.Movements[movementType] += moved.Float64
+			line.Closing += moved.Float64
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	products := resp.Products[:0]
+	for _, line := range resp.Products {
+		if line.Opening != 0 || len(line.Movements) > 0 {
+			products = append(products, line)
+		}
+	}
+	resp.Products = products
+
+	return &resp, nil
+}
+
+func (r *stockRepo) Transfer(ctx context.Context, req *models.CreateStockTransfer) (*models.StockTransfer, error) {
+	var (
+		transferID  = uuid.New().String()
+		selectQuery = `
+			SELECT "quantity" - "reserved" FROM "stock"
+			WHERE "branch_id" = $1 AND "product_id" = $2
+			FOR UPDATE
+		`
+		query = `
+			INSERT INTO "stock_transfers"(
+				"id",
+				"from_branch_id",
+				"to_branch_id",
+				"reference",
+				"actor"
+			) VALUES ($1, $2, $3, $4, $5)
+		`
+	)
+
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: a transfer needs items", ledger.ErrInvalid)
+	}
+
+	err := inTx(ctx, r.db, func(tx dbtx) error {
+		for _, branchID := range []string{req.FromBranchId, req.ToBranchId} {
+			var exists bool
+
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "branches" WHERE "id" = $1 AND "deleted_at" IS NULL)`, branchID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("stock transfer branch %s does not exist", branchID)
+			}
+		}
+
+		requested := make(map[string]float64)
+		for _, item := range req.Items {
+			if err := ledger.CheckTransfer(req.FromBranchId, req.ToBranchId, item.Quantity); err != nil {
+				return err
+			}
+
+			var exists bool
+
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM "product" WHERE "id" = $1 AND "deleted_at" IS NULL)`, item.ProductId).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("stock transfer product_id %s does not exist", item.ProductId)
+			}
+
+			requested[item.ProductId] += item.Quantity
+		}
+
+		for productID, quantity := range requested {
+			var available float64
+
+			err := tx.QueryRowContext(ctx, selectQuery, req.FromBranchId, productID).Scan(&available)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+
+			if quantity > available {
+				return fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+					storage.ErrInsufficientStock, available, productID, req.FromBranchId, quantity)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx, query, transferID, req.FromBranchId, req.ToBranchId, req.Reference, req.Actor)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range req.Items {
+			err := recordMovement(ctx, tx, req.FromBranchId, item.ProductId, ledger.TransferOut, -item.Quantity, transferID, req.Actor)
+			if err != nil {
+				return err
+			}
+
+			err = recordMovement(ctx, tx, req.ToBranchId, item.ProductId, ledger.TransferIn, item.Quantity, transferID, req.Actor)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetTransfer(ctx, &models.StockTransferPrimaryKey{Id: transferID})
+}
+
+// GetTransfer reads the items of a transfer back from its transfer-out
+// movements.
+func (r *stockRepo) GetTransfer(ctx context.Context, req *models.StockTransferPrimaryKey) (*models.StockTransfer, error) {
+	var (
+		transfer models.StockTransfer
+		query    = `
+			SELECT
+				"id",
+				"from_branch_id",
+				"to_branch_id",
+				"reference",
+				"actor",
+				"created_at"
+			FROM "stock_transfers"
+			WHERE "id" = $1
+		`
+		itemsQuery = `
+			SELECT "product_id", -"quantity"
+			FROM "stock_movements"
+			WHERE "reference" = $1 AND "type" = $2
+			ORDER BY "created_at", "id"
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
+		&transfer.Id,
+		&transfer.FromBranchId,
+		&transfer.ToBranchId,
+		&transfer.Reference,
+		&transfer.Actor,
+		&transfer.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, itemsQuery, req.Id, ledger.TransferOut)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.StockTransferItem
+		if err := rows.Scan(&item.ProductId, &item.Quantity); err != nil {
+			return nil, err
+		}
+
+		transfer.Items = append(transfer.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// reserveStock holds quantity of a product at a branch, in one update that
+// only matches while enough of it is available.
+func reserveStock(ctx context.Context, tx dbtx, branchID, productID string, quantity float64) error {
+	var (
+		available float64
+		query     = `
+			UPDATE "stock"
+				SET
+					"reserved" = "reserved" + $3,
+					"updated_at" = NOW()
+			WHERE "branch_id" = $1 AND "product_id" = $2 AND "quantity" - "reserved" >= $3
+		`
+	)
+
+	if quantity == 0 {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx, query, branchID, productID, quantity)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected > 0 {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT "quantity" - "reserved" FROM "stock" WHERE "branch_id" = $1 AND "product_id" = $2), 0)
+	`, branchID, productID).Scan(&available)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v of product %s available at branch %s, %v requested",
+		storage.ErrInsufficientStock, available, productID, branchID, quantity)
+}
+
+// releaseStock gives back reserved stock.
+func releaseStock(ctx context.Context, tx dbtx, branchID, productID string, quantity float64) error {
+	query := `
+		UPDATE "stock"
+			SET
+				"reserved" = "reserved" - $3,
+				"updated_at" = NOW()
+		WHERE "branch_id" = $1 AND "product_id" = $2
+	`
+
+	if quantity == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, query, branchID, productID, quantity)
+	return err
+}
+
+// openOrder locks the order of a line write and returns its branch. Lines
+// of closed orders no longer change, as their stock is settled.
+func openOrder(ctx context.Context, tx dbtx, orderID string) (string, error) {
+	var (
+		branchID string
+		status   string
+		query    = `SELECT "branch_id", "status" FROM "order" WHERE "id" = $1 AND "deleted_at" IS NULL FOR UPDATE`
+	)
+
+	err := tx.QueryRowContext(ctx, query, orderID).Scan(&branchID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("order product order_id %s does not exist", orderID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !workflow.Default.Open(status) {
+		return "", fmt.Errorf("%w: order %s is %s, its lines cannot change", workflow.ErrTransition, orderID, status)
+	}
+
+	return branchID, nil
+}
+
+// settleStock ends the reservations of an order that is closed: finished
+// orders sell their stock, others give it back.
+func settleStock(ctx context.Context, tx dbtx, orderID, branchID, status, actor string) error {
+	var (
+		linesQuery = `UPDATE "order_products" SET "reserved" = 0 WHERE "order_id" = $1 AND "reserved" > 0`
+		query      = `
+			SELECT "product_id", SUM("reserved")
+			FROM "order_products"
+			WHERE "order_id" = $1 AND "reserved" > 0
+			GROUP BY "product_id"
+		`
+	)
+
+	reserved, err := sumByProduct(ctx, tx, query, orderID)
+	if err != nil {
+		return err
+	}
+
+	for productID, quantity := range reserved {
+		if err := releaseStock(ctx, tx, branchID, productID, quantity); err != nil {
+			return err
+		}
+
+		if status != workflow.StatusFinished {
+			continue
+		}
+
+		if err := recordMovement(ctx, tx, branchID, productID, ledger.Sale, -quantity, orderID, actor); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, linesQuery, orderID)
+	return err
+}
+
+// reserveOrder reserves the lines of a restored order again. The caller's
+// transaction is rolled back when its branch has too little.
+func reserveOrder(ctx context.Context, tx dbtx, orderID, branchID string) error {
+	var (
+		linesQuery = `UPDATE "order_products" SET "reserved" = "quantity" WHERE "order_id" = $1 AND "deleted_at" IS NULL`
+		query      = `
+			SELECT "product_id", SUM("quantity")
+			FROM "order_products"
+			WHERE "order_id" = $1 AND "deleted_at" IS NULL
+			GROUP BY "product_id"
+		`
+	)
+
+	requested, err := sumByProduct(ctx, tx, query, orderID)
+	if err != nil {
+		return err
+	}
+
+	for productID, quantity := range requested {
+		if err := reserveStock(ctx, tx, branchID, productID, quantity); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, linesQuery, orderID)
+	return err
+}
+
+// restock brings back what a finished order sold, to the branches it was
+// sold from, when the order is returned.
+func restock(ctx context.Context, tx dbtx, orderID, actor string) error {
+	query := `
+		SELECT "branch_id", "product_id", SUM("quantity")
+		FROM "stock_movements"
+		WHERE "reference" = $1 AND "type" = $2
+		GROUP BY "branch_id", "product_id"
+	`
+
+	rows, err := tx.QueryContext(ctx, query, orderID, ledger.Sale)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var sold []models.StockMovement
+	for rows.Next() {
+		var movement models.StockMovement
+		if err := rows.Scan(&movement.BranchId, &movement.ProductId, &movement.Quantity); err != nil {
+			return err
+		}
+
+		sold = append(sold, movement)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movement := range sold {
+		err := recordMovement(ctx, tx, movement.BranchId, movement.ProductId, ledger.Return, -movement.Quantity, orderID, actor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordMovement changes the quantity a branch holds of a product and
+// writes the change to the ledger.
+func recordMovement(ctx context.Context, tx dbtx, branchID, productID, movementType string, quantity float64, reference, actor string) error {
+	var (
+		movementQuery = `
+			INSERT INTO "stock_movements"(
+				"id",
+				"branch_id",
+				"product_id",
+				"type",
+				"quantity",
+				"reference",
+				"actor"
+			) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`
+		stockQuery = `
+			INSERT INTO "stock"("branch_id", "product_id", "quantity") VALUES ($1, $2, $3)
+			ON CONFLICT ("branch_id", "product_id") DO UPDATE
+				SET
+					"quantity" = "stock"."quantity" + $3,
+					"updated_at" = NOW()
+		`
+	)
+
+	_, err := tx.ExecContext(ctx, movementQuery, uuid.New().String(), branchID, productID, movementType, quantity, reference, actor)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stockQuery, branchID, productID, quantity)
+	return err
+}
+
+// sumByProduct runs query, which selects product ids and quantities, and
+// reads all of its rows before the caller writes through tx again.
+func sumByProduct(ctx context.Context, tx dbtx, query string, args ...interface{}) (map[string]float64, error) {
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sums := make(map[string]float64)
+	for rows.Next() {
+		var (
+			productID string
+			quantity  float64
+		)
+
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+
+		sums[productID] += quantity
+	}
+
+	return sums, rows.Err()
+}
+
+// moveStock moves the reservations of an order to another branch. The
+// caller's transaction is rolled back when that branch has too little.
+func moveStock(ctx context.Context, tx dbtx, orderID, from, to string) error {
+	query := `
+		SELECT "product_id", SUM("reserved")
+		FROM "order_products"
+		WHERE "order_id" = $1 AND "reserved" > 0
+		GROUP BY "product_id"
+	`
+
+	reserved, err := sumByProduct(ctx, tx, query, orderID)
+	if err != nil {
+		return err
+	}
+
+	for productID, quantity := range reserved {
+		if err := releaseStock(ctx, tx, from, productID, quantity); err != nil {
+			return err
+		}
+
+		if err := reserveStock(ctx, tx, to, productID, quantity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,388 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/tax"
+	"market_system/pkg/workflow"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type taxRateRepo struct {
+	db dbtx
+}
+
+func NewTaxRateRepo(db dbtx) *taxRateRepo {
+	return &taxRateRepo{
+		db: db,
+	}
+}
+
+func (r *taxRateRepo) Create(ctx context.Context, req *models.CreateTaxRate) (*models.TaxRate, error) {
+	var (
+		taxRateID = uuid.New().String()
+		query     = `
+			INSERT INTO "tax_rates"(
+				"id",
+				"name",
+				"percent",
+				"inclusive",
+				"created_at",
+				"updated_at"
+			) VALUES ($1, $2, $3, $4, NOW(), NOW())
+		`
+	)
+
+	if err := (tax.Rate{Percent: req.Percent, Inclusive: req.Inclusive}).Validate(); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.ExecContext(ctx, query, taxRateID, req.Name, req.Percent, req.Inclusive)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, &models.TaxRatePrimaryKey{Id: taxRateID})
+}
+
+func (r *taxRateRepo) GetByID(ctx context.Context, req *models.TaxRatePrimaryKey) (*models.TaxRate, error) {
+	var (
+		rate  models.TaxRate
+		query = `
+			SELECT
+				"id",
+				"name",
+				"percent",
+				"inclusive",
+				"created_at",
+				"updated_at",
+				"version"
+			FROM "tax_rates"
+			WHERE "id" = $1 AND "deleted_at" IS NULL
+		`
+	)
+
+	err := r.db.QueryRowContext(ctx, query, req.Id).Scan(
+		&rate.Id,
+		&rate.Name,
+		&rate.Percent,
+		&rate.Inclusive,
+		&rate.CreatedAt,
+		&rate.UpdatedAt,
+		&rate.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (r *taxRateRepo) GetList(ctx context.Context, req *models.GetListTaxRateRequest) (*models.GetListTaxRateResponse, error) {
+	var (
+		resp   models.GetListTaxRateResponse
+		where  = ` WHERE "name" ILIKE '%' || $1 || '%'`
+		offset = req.Offset
+		limit  = req.Limit
+		query  = `
+			SELECT
+				COUNT(*) OVER(),
+				"id",
+				"name",
+				"percent",
+				"inclusive",
+				"created_at",
+				"updated_at",
+				"deleted_at",
+				"version"
+			FROM "tax_rates"
+		`
+	)
+
+	if !req.IncludeDeleted {
+		where += ` AND "deleted_at" IS NULL`
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query += where + fmt.Sprintf(` ORDER BY "created_at" DESC, "id" DESC OFFSET %d LIMIT %d`, offset, limit)
+	rows, err := r.db.QueryContext(ctx, query, req.Search)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			rate      models.TaxRate
+			deletedAt sql.NullString
+		)
+
+		err = rows.Scan(
+			&resp.Count,
+			&rate.Id,
+			&rate.Name,
+			&rate.Percent,
+			&rate.Inclusive,
+			&rate.CreatedAt,
+			&rate.UpdatedAt,
+			&deletedAt,
+			&rate.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		rate.DeletedAt = deletedAt.String
+
+		resp.TaxRates = append(resp.TaxRates, &rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (r *taxRateRepo) Update(ctx context.Context, req *models.UpdateTaxRate) (int64, error) {
+	var (
+		query = `
+			UPDATE "tax_rates"
+				SET
+					"name" = $2,
+					"percent" = $3,
+					"inclusive" = $4,
+					"version" = "version" + 1,
+					"updated_at" = NOW()
+			WHERE "id" = $1 AND "deleted_at" IS NULL AND ($5::BIGINT = 0 OR "version" = $5)
+		`
+	)
+
+	if err := (tax.Rate{Percent: req.Percent, Inclusive: req.Inclusive}).Validate(); err != nil {
+		return 0, err
+	}
+
+	result, err := r.db.ExecContext(ctx, query, req.Id, req.Name, req.Percent, req.Inclusive, req.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if rowsAffected == 0 && req.Version != 0 {
+		return 0, checkVersion(ctx, r.db, "tax_rates", "id", req.Id)
+	}
+
+	return rowsAffected, nil
+}
+
+func (r *taxRateRepo) Delete(ctx context.Context, req *models.TaxRatePrimaryKey) error {
+	query := `UPDATE "tax_rates" SET "deleted_at" = NOW() WHERE "id" = $1 AND "deleted_at" IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, req.Id)
+	return err
+}
+
+// Report adds up the stored breakdowns of the completed sales, one line per
+// branch and rate, ordered by branch and then as tax.Summarize orders
+// rates. A returned order was sold and refunded, which nets to nothing, so
+// it is left out like orders that are not sold yet.
+func (r *taxRateRepo) Report(ctx context.Context, req *models.TaxReportRequest) (*models.TaxReport, error) {
+	var (
+		resp  models.TaxReport
+		query = `
+			SELECT
+				CAST(o."branch_id" AS VARCHAR),
+				CAST(t."tax_rate_id" AS VARCHAR),
+				t."percent",
+				t."inclusive",
+				COUNT(*),
+				SUM(t."base"),
+				SUM(t."tax")
+			FROM "order_taxes" AS t
+			JOIN "order" AS o ON o."id" = t."order_id"
+			WHERE o."deleted_at" IS NULL
+				AND o."status" = ANY($1)
+				AND ($2 = '' OR CAST(o."branch_id" AS VARCHAR) = $2)
+				AND o."created_at" >= $3
+				AND ($4::TIMESTAMP IS NULL OR o."created_at" < $4)
+			GROUP BY o."branch_id", t."tax_rate_id", t."percent", t."inclusive"
+			ORDER BY CAST(o."branch_id" AS VARCHAR), t."percent", CAST(t."tax_rate_id" AS VARCHAR), t."inclusive"
+		`
+	)
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		pq.Array([]string{workflow.StatusDelivered, workflow.StatusFinished}),
+		req.BranchId,
+		req.From.UTC(),
+		nullTime(req.To),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.TaxReportLine
+
+		err := rows.Scan(
+			&line.BranchId,
+			&line.TaxRateId,
+			&line.Percent,
+			&line.Inclusive,
+			&line.Orders,
+			&line.Base,
+			&line.Tax,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Lines = append(resp.Lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// checkTaxRate mirrors the memory store: an empty id is no rate, any other
+// must name a rate that is not deleted.
+func checkTaxRate(ctx context.Context, db dbtx, table, taxRateID string) error {
+
+	if taxRateID == "" {
+		return nil
+	}
+
+	var exists bool
+
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM "tax_rates" WHERE "id" = $1 AND "deleted_at" IS NULL)
+	`, taxRateID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("%s tax_rate_id %s does not exist", table, taxRateID)
+	}
+
+	return nil
+}
+
+// taxation is the tax orderTotals works out for the discounted lines of an
+// order: the breakdown by rate and the tax of every line by its id.
+type taxation struct {
+	buckets []tax.Bucket
+	lines   map[string]money.Amount
+}
+
+// storeOrderTaxes replaces the tax breakdown of an order with the one
+// orderTotals returns for its discounted lines, and stores the tax of
+// every line, so the lines add up to the tax of the order once a
+// promotion shares its discount out.
+func storeOrderTaxes(ctx context.Context, tx dbtx, orderID string, taxed taxation) error {
+
+	for id, lineTax := range taxed.lines {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE "order_products" SET "tax" = $2
+			WHERE "order_product_id" = $1 AND "tax" <> $2
+		`, id, lineTax)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "order_taxes" WHERE "order_id" = $1`, orderID); err != nil {
+		return err
+	}
+
+	for _, bucket := range taxed.buckets {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO "order_taxes"("order_id", "tax_rate_id", "percent", "inclusive", "base", "tax")
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, orderID, bucket.RateId, bucket.Percent, bucket.Inclusive, bucket.Base, bucket.Tax)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// orderTaxes loads the tax breakdowns of orders, keyed by order id and
+// ordered as tax.Summarize orders them.
+func orderTaxes(ctx context.Context, db dbtx, orderIDs []string) (map[string][]models.OrderTax, error) {
+	var (
+		taxes = make(map[string][]models.OrderTax)
+		query = `
+			SELECT
+				CAST("order_id" AS VARCHAR),
+				CAST("tax_rate_id" AS VARCHAR),
+				"percent",
+				"inclusive",
+				"base",
+				"tax"
+			FROM "order_taxes"
+			WHERE CAST("order_id" AS VARCHAR) = ANY($1)
+			ORDER BY "order_id", "percent", CAST("tax_rate_id" AS VARCHAR), "inclusive"
+		`
+	)
+
+	if len(orderIDs) == 0 {
+		return taxes, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID  string
+			orderTax models.OrderTax
+		)
+
+		err := rows.Scan(
+			&orderID,
+			&orderTax.TaxRateId,
+			&orderTax.Percent,
+			&orderTax.Inclusive,
+			&orderTax.Base,
+			&orderTax.Tax,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		taxes[orderID] = append(taxes[orderID], orderTax)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return taxes, nil
+}
@@ -1,6 +1,25 @@
 package storage
 
-import "market_system/models"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"market_system/models"
+)
+
+// ErrVersionConflict is returned by Update and StatusUpdate when the request
+// carries a version and the row has moved on since it was read.
+var ErrVersionConflict = errors.New("version conflict: the row was changed by someone else")
+
+// ErrInsufficientStock is returned when an order line asks for more than
+// its branch has available, and when an adjustment would leave less stock
+// than is reserved.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrOrderClosed is returned by Update for an order that is finished,
+// canceled or returned: its totals and stock are settled.
+var ErrOrderClosed = errors.New("order is closed")
 
 type StorageI interface {
 	Category() CategoryRepoI
@@ -9,56 +28,158 @@ type StorageI interface {
 	Branch() BranchRepoI
 	Order()	OrderRepoI
 	OrderProduct() OrderProductRepoI
+	Audit() AuditRepoI
+	Sequence() SequenceRepoI
+	Stock() StockRepoI
+	Promotion() PromotionRepoI
+	TaxRate() TaxRateRepoI
+
+	// Tx runs fn in one transaction. The StorageI passed to fn is scoped to
+	// that transaction; a non-nil error from fn rolls back every write made
+	// through it.
+	Tx(ctx context.Context, fn func(tx StorageI) error) error
+
+	// Ping reports whether the storage can serve requests: for a database,
+	// that it is reachable and its schema is up to date.
+	Ping(ctx context.Context) error
+
+	// Close releases the connections once the storage is no longer used.
+	Close() error
 }
 
+// Delete in each repo below is a soft delete: it stamps deleted_at, and the row
+// disappears from GetByID, Update and GetList unless IncludeDeleted is set.
+// Restore clears deleted_at and reports the rows affected. Purge removes
+// rows deleted before deletedBefore for good, except rows other rows still
+// reference, so history such as order lines stays intact.
 type CategoryRepoI interface {
-	Create(req *models.CreateCategory) (*models.Category, error)
-	GetByID(req *models.CategoryPrimaryKey) (*models.Category, error)
-	GetList(req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error)
-	Update(req *models.UpdateCategory) (int64, error)
-	Delete(req *models.CategoryPrimaryKey) error
+	Create(ctx context.Context, req *models.CreateCategory) (*models.Category, error)
+	GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error)
+	GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error)
+	Update(ctx context.Context, req *models.UpdateCategory) (int64, error)
+	Delete(ctx context.Context, req *models.CategoryPrimaryKey) error
+	Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type ProductRepoI interface {
-	Create(req *models.CreateProduct) (*models.Product, error)
-	GetByID(req *models.ProductPrimaryKey) (*models.Product, error)
-	GetList(req *models.GetListProductRequest) (*models.GetListProductResponse, error)
-	Update(req *models.UpdateProduct) (int64, error)
-	Delete(req *models.ProductPrimaryKey) error
+	Create(ctx context.Context, req *models.CreateProduct) (*models.Product, error)
+	GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error)
+	GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error)
+	Update(ctx context.Context, req *models.UpdateProduct) (int64, error)
+	Delete(ctx context.Context, req *models.ProductPrimaryKey) error
+	Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type ClientRepoI interface {
-	Create(req *models.CreateClient) (*models.Client, error)
-	GetByID(req *models.ClientPrimaryKey) (*models.Client, error)
-	GetList(req *models.GetListClientRequest) (*models.GetListClientResponse, error)
-	Update(req *models.UpdateClient) (int64, error)
-	Delete(req *models.ClientPrimaryKey) error
+	Create(ctx context.Context, req *models.CreateClient) (*models.Client, error)
+	GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error)
+	GetList(ctx context.Context, req *models.GetListClientRequest) (*models.GetListClientResponse, error)
+	Update(ctx context.Context, req *models.UpdateClient) (int64, error)
+	Delete(ctx context.Context, req *models.ClientPrimaryKey) error
+	Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 type BranchRepoI interface {
-	Create(req *models.CreateBranch) (*models.Branch, error)
-	GetByID(req *models.BranchPrimaryKey) (*models.Branch, error)
-	GetList(req *models.GetListBranchRequest) (*models.GetListBranchResponse, error)
-	Update(req *models.UpdateBranch) (int64, error)
-	Delete(req *models.BranchPrimaryKey) error
+	Create(ctx context.Context, req *models.CreateBranch) (*models.Branch, error)
+	GetByID(ctx context.Context, req *models.BranchPrimaryKey) (*models.Branch, error)
+	GetList(ctx context.Context, req *models.GetListBranchRequest) (*models.GetListBranchResponse, error)
+	Update(ctx context.Context, req *models.UpdateBranch) (int64, error)
+	Delete(ctx context.Context, req *models.BranchPrimaryKey) error
+	Restore(ctx context.Context, req *models.BranchPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// GetDeliveryTariff returns sql.ErrNoRows for a branch without a
+	// tariff. SetDeliveryTariff creates or replaces the tariff of a branch;
+	// a Version other than 0 must match the one it replaces.
+	GetDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) (*models.DeliveryTariff, error)
+	SetDeliveryTariff(ctx context.Context, req *models.DeliveryTariff) (*models.DeliveryTariff, error)
+	DeleteDeliveryTariff(ctx context.Context, req *models.BranchPrimaryKey) error
+	// Nearest lists branches by distance from a location, nearest first,
+	// priced by their tariff or flat delivery price for an order without
+	// goods yet.
+	Nearest(ctx context.Context, req *models.NearestBranchRequest) (*models.NearestBranchResponse, error)
 }
 
 
 
 type OrderRepoI interface {
-	Create(req *models.CreateOrder) (*models.Order, error)
-	GetByID(req *models.OrderPrimaryKey) (*models.Order, error)
-	GetList(req *models.GetListOrderRequest) (*models.GetListOrderResponse, error)
-	Update(req *models.UpdateOrder) (int64, error)
-	Delete(req *models.OrderPrimaryKey) error
-	StatusUpdate(models.CheckStatus) (models.Order, error)
+	Create(ctx context.Context, req *models.CreateOrder) (*models.Order, error)
+	GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error)
+	GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error)
+	Update(ctx context.Context, req *models.UpdateOrder) (int64, error)
+	Delete(ctx context.Context, req *models.OrderPrimaryKey) error
+	Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	StatusUpdate(ctx context.Context, req models.CheckStatus) (models.Order, error)
+	// History lists the status changes of an order, oldest first.
+	History(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetOrderHistoryResponse, error)
 }
 
 
 type OrderProductRepoI interface {
-	Create(req *models.CreateOrderProduct) (*models.OrderProduct, error)
-	GetByID(req *models.OrderProductPrimaryKey) (*models.OrderProduct, error)
-	GetList(req *models.GetListOrderProductRequest) (*models.GetListOrderProductResponse, error)
-	Update(req *models.UpdateOrderProduct) (int64, error)
-	Delete(req *models.OrderProductPrimaryKey) error
-}
\ No newline at end of file
+	Create(ctx context.Context, req *models.CreateOrderProduct) (*models.OrderProduct, error)
+	GetByID(ctx context.Context, req *models.OrderProductPrimaryKey) (*models.OrderProduct, error)
+	GetList(ctx context.Context, req *models.GetListOrderProductRequest) (*models.GetListOrderProductResponse, error)
+	Update(ctx context.Context, req *models.UpdateOrderProduct) (int64, error)
+	Delete(ctx context.Context, req *models.OrderProductPrimaryKey) error
+	Restore(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// AuditRepoI stores the audit log written by the audit package. Entries
+// are never changed once created. GetList filters by Entity and EntityId
+// when they are set, newest first.
+type AuditRepoI interface {
+	Create(ctx context.Context, req *models.CreateAuditEntry) (*models.AuditEntry, error)
+	GetList(ctx context.Context, req *models.GetListAuditRequest) (*models.GetListAuditResponse, error)
+}
+
+// SequenceRepoI hands out numbers from named counters that survive restarts
+// and are shared by every instance. Next returns 1 for a new name. Numbers
+// taken in a transaction that rolls back are given out again.
+type SequenceRepoI interface {
+	Next(ctx context.Context, name string) (int64, error)
+}
+
+// StockRepoI keeps the stock of every branch. Order lines reserve stock
+// when they are written, and StatusUpdate releases it when an order is
+// canceled or returned and deducts it when the order is finished. Every
+// change to a quantity is written to the ledger in the same transaction:
+// adjustments, transfers, the sales of finished orders and the returns of
+// finished orders that come back.
+type StockRepoI interface {
+	GetByID(ctx context.Context, req *models.StockPrimaryKey) (*models.Stock, error)
+	GetList(ctx context.Context, req *models.GetListStockRequest) (*models.GetListStockResponse, error)
+	Adjust(ctx context.Context, req *models.AdjustStock) (*models.Stock, error)
+	Movements(ctx context.Context, req *models.GetListStockMovementRequest) (*models.GetListStockMovementResponse, error)
+	Reconcile(ctx context.Context, req *models.StockReconciliationRequest) (*models.StockReconciliation, error)
+	Transfer(ctx context.Context, req *models.CreateStockTransfer) (*models.StockTransfer, error)
+	GetTransfer(ctx context.Context, req *models.StockTransferPrimaryKey) (*models.StockTransfer, error)
+}
+
+// PromotionRepoI keeps the promo codes. Codes are stored upper-case and are
+// unique among promotions that are not deleted. Order Update applies a
+// code by it, and orders keep their discount when the promotion is
+// deleted later.
+type PromotionRepoI interface {
+	Create(ctx context.Context, req *models.CreatePromotion) (*models.Promotion, error)
+	GetByID(ctx context.Context, req *models.PromotionPrimaryKey) (*models.Promotion, error)
+	GetList(ctx context.Context, req *models.GetListPromotionRequest) (*models.GetListPromotionResponse, error)
+	Update(ctx context.Context, req *models.UpdatePromotion) (int64, error)
+	Delete(ctx context.Context, req *models.PromotionPrimaryKey) error
+}
+
+// TaxRateRepoI keeps the VAT rates. An order line takes the rate of its
+// product, or of the product category when the product has none, and
+// keeps it when the rate changes or is deleted later. Report sums up the
+// tax breakdowns of the orders of a period.
+type TaxRateRepoI interface {
+	Create(ctx context.Context, req *models.CreateTaxRate) (*models.TaxRate, error)
+	GetByID(ctx context.Context, req *models.TaxRatePrimaryKey) (*models.TaxRate, error)
+	GetList(ctx context.Context, req *models.GetListTaxRateRequest) (*models.GetListTaxRateResponse, error)
+	Update(ctx context.Context, req *models.UpdateTaxRate) (int64, error)
+	Delete(ctx context.Context, req *models.TaxRatePrimaryKey) error
+	Report(ctx context.Context, req *models.TaxReportRequest) (*models.TaxReport, error)
+}
@@ -0,0 +1,58 @@
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+func testAudit(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		entity = "test_" + token()
+		id     = missingID()
+	)
+
+	for _, action := range []string{"create", "update", "delete"} {
+		_, err := strg.Audit().Create(ctx, &models.CreateAuditEntry{
+			Entity:   entity,
+			EntityId: id,
+			Action:   action,
+			Actor:    "tester",
+			After:    json.RawMessage(`{"title":"` + action + `"}`),
+		})
+		must(t, err)
+	}
+
+	_, err := strg.Audit().Create(ctx, &models.CreateAuditEntry{Entity: entity, EntityId: missingID(), Action: "create", Actor: "tester"})
+	must(t, err)
+
+	resp, err := strg.Audit().GetList(ctx, &models.GetListAuditRequest{Entity: entity, EntityId: id, Limit: 2})
+	must(t, err)
+	assertEqual(t, "count", 3, resp.Count)
+	assertEqual(t, "page length", 2, len(resp.Entries))
+	assertEqual(t, "newest action", "delete", resp.Entries[0].Action)
+	assertEqual(t, "actor", "tester", resp.Entries[0].Actor)
+
+	var after struct {
+		Title string `json:"title"`
+	}
+	must(t, json.Unmarshal(resp.Entries[0].After, &after))
+	assertEqual(t, "after", "delete", after.Title)
+
+	if len(resp.Entries[0].Before) != 0 {
+		t.Fatalf("expected no before snapshot, got %s", resp.Entries[0].Before)
+	}
+
+	resp, err = strg.Audit().GetList(ctx, &models.GetListAuditRequest{Entity: entity, EntityId: id, Cursor: resp.NextCursor})
+	must(t, err)
+	assertEqual(t, "oldest action", "create", resp.Entries[0].Action)
+
+	resp, err = strg.Audit().GetList(ctx, &models.GetListAuditRequest{Entity: entity})
+	must(t, err)
+	assertEqual(t, "count of the entity", 4, resp.Count)
+}
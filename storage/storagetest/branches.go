@@ -0,0 +1,129 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/schedule"
+	"market_system/storage"
+)
+
+func testBranch(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	created := createBranch(t, strg, "branch "+token())
+
+	if !created.Active {
+		t.Fatal("new branch must be active")
+	}
+
+	// Work hours without shifts hold every day.
+	assertEqual(t, "open by work hours", true, created.IsOpenNow)
+
+	got, err := strg.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: created.ID})
+	must(t, err)
+	assertEqual(t, "branch", *created, *got)
+
+	rowsAffected, err := strg.Branch().Update(ctx, &models.UpdateBranch{
+		ID:            created.ID,
+		Name:          "renamed",
+		Phone:         created.Phone,
+		Photo:         created.Photo,
+		WorkStartHour: "08:00",
+		WorkEndHour:   "20:00",
+		Address:       "Samarkand",
+		Active:        false,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: created.ID})
+	must(t, err)
+	assertEqual(t, "address", "Samarkand", got.Address)
+	assertEqual(t, "active", false, got.Active)
+
+	rowsAffected, err = strg.Branch().Update(ctx, &models.UpdateBranch{ID: missingID()})
+	assertAffected(t, 0, rowsAffected, err)
+
+	resp, err := strg.Branch().GetList(ctx, &models.GetListBranchRequest{Limit: 1})
+	must(t, err)
+	assertPage(t, 1, len(resp.Branches), resp.Count, resp.Count, nil)
+
+	must(t, strg.Branch().Delete(ctx, &models.BranchPrimaryKey{ID: created.ID}))
+
+	_, err = strg.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: created.ID})
+	assertNoRows(t, err)
+}
+
+func testBranchSchedule(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		name     = "branch " + token()
+		everyDay []models.BranchHours
+		now      = time.Now().UTC()
+		today    = now.Format("2006-01-02")
+		tomorrow = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	)
+
+	for weekday := 0; weekday < 7; weekday++ {
+		everyDay = append(everyDay, models.BranchHours{Weekday: weekday, Open: "00:00", Close: "00:00"})
+	}
+
+	for reason, req := range map[string]*models.CreateBranch{
+		"unknown timezone": {Name: name, Timezone: "Mars/Olympus_Mons"},
+		"weekday 7":        {Name: name, Schedule: []models.BranchHours{{Weekday: 7, Open: "09:00", Close: "18:00"}}},
+		"hour 25":          {Name: name, Schedule: []models.BranchHours{{Weekday: 1, Open: "25:00", Close: "18:00"}}},
+		"holiday date":     {Name: name, Holidays: []models.BranchHoliday{{Date: "01.01.2024"}}},
+		"work hours":       {Name: name, WorkStartHour: "9am", WorkEndHour: "18:00"},
+	} {
+		if _, err := strg.Branch().Create(ctx, req); !errors.Is(err, schedule.ErrInvalid) {
+			t.Fatalf("%s: expected schedule.ErrInvalid, got %v", reason, err)
+		}
+	}
+
+	created, err := strg.Branch().Create(ctx, &models.CreateBranch{
+		Name:     name,
+		Timezone: "UTC",
+		Schedule: everyDay,
+	})
+	must(t, err)
+	assertEqual(t, "schedule", everyDay, created.Schedule)
+	assertEqual(t, "holidays", []models.BranchHoliday{}, created.Holidays)
+	assertEqual(t, "open around the clock", true, created.IsOpenNow)
+	assertEqual(t, "no next opening while open", "", created.NextOpenAt)
+
+	// A holiday closes the branch until its next shift, tomorrow.
+	holidays := []models.BranchHoliday{{Date: today}}
+	rowsAffected, err := strg.Branch().Update(ctx, &models.UpdateBranch{
+		ID:       created.ID,
+		Name:     name,
+		Timezone: "UTC",
+		Schedule: everyDay,
+		Holidays: holidays,
+		Active:   true,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err := strg.Branch().GetByID(ctx, &models.BranchPrimaryKey{ID: created.ID})
+	must(t, err)
+	assertEqual(t, "updated holidays", holidays, got.Holidays)
+	assertEqual(t, "closed on a holiday", false, got.IsOpenNow)
+	assertEqual(t, "next opening", tomorrow.Format(time.RFC3339), got.NextOpenAt)
+
+	// Searching no longer leaves out closed branches or marks them
+	// inactive.
+	list, err := strg.Branch().GetList(ctx, &models.GetListBranchRequest{Search: name[7:]})
+	must(t, err)
+	assertEqual(t, "listed", 1, list.Count)
+	assertEqual(t, "listed active", true, list.Branches[0].Active)
+	assertEqual(t, "listed is open", false, list.Branches[0].IsOpenNow)
+
+	// Without shifts or work hours a branch never opens.
+	closed, err := strg.Branch().Create(ctx, &models.CreateBranch{Name: "branch " + token()})
+	must(t, err)
+	assertEqual(t, "never open", false, closed.IsOpenNow)
+	assertEqual(t, "never opens", "", closed.NextOpenAt)
+}
@@ -0,0 +1,98 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+func testCategory(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	parent := createCategory(t, strg, "parent "+token())
+
+	created, err := strg.Category().Create(ctx, &models.CreateCategory{
+		Title:    "child " + token(),
+		ParentID: parent.Id,
+		Image:    "child.png",
+	})
+	must(t, err)
+
+	if created.Id == "" || created.CreatedAt == "" {
+		t.Fatalf("created category is missing id or created_at: %+v", created)
+	}
+
+	got, err := strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "category", *created, *got)
+	assertEqual(t, "parent_id", parent.Id, got.ParentID)
+
+	rowsAffected, err := strg.Category().Update(ctx, &models.UpdateCategory{
+		Id:    created.Id,
+		Title: "renamed " + token(),
+		Image: "renamed.png",
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "image", "renamed.png", got.Image)
+	assertEqual(t, "parent_id", "", got.ParentID)
+
+	rowsAffected, err = strg.Category().Update(ctx, &models.UpdateCategory{Id: missingID(), Title: "missing"})
+	assertAffected(t, 0, rowsAffected, err)
+
+	must(t, strg.Category().Delete(ctx, &models.CategoryPrimaryKey{Id: created.Id}))
+
+	_, err = strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: created.Id})
+	assertNoRows(t, err)
+
+	if _, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "orphan", ParentID: missingID()}); err == nil {
+		t.Fatal("expected an error for a missing parent category")
+	}
+}
+
+func testCategoryList(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	search := token()
+	for i := 0; i < 5; i++ {
+		createCategory(t, strg, "list "+search)
+	}
+
+	pages := []struct {
+		offset, limit int64
+		want          int
+	}{
+		{0, 2, 2},
+		{2, 2, 2},
+		{4, 2, 1},
+		{10, 2, 0},
+		{0, 0, 5},
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		resp, err := strg.Category().GetList(ctx, &models.GetListCategoryRequest{
+			Offset: p.offset,
+			Limit:  p.limit,
+			Search: search,
+		})
+		must(t, err)
+
+		var createdAt []string
+		for _, category := range resp.Categories {
+			createdAt = append(createdAt, category.CreatedAt)
+			if p.limit > 0 {
+				if seen[category.Id] {
+					t.Fatalf("category %s returned on two pages", category.Id)
+				}
+				seen[category.Id] = true
+			}
+		}
+
+		assertPage(t, p.want, len(resp.Categories), 5, resp.Count, createdAt)
+	}
+}
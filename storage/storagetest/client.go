@@ -0,0 +1,64 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"market_system/models"
+	"market_system/storage"
+)
+
+func testClient(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	created := createClient(t, strg, "client "+token())
+
+	got, err := strg.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: created.ID})
+	must(t, err)
+	assertEqual(t, "client", *created, *got)
+
+	rowsAffected, err := strg.Client().Update(ctx, &models.UpdateClient{
+		ID:          created.ID,
+		FirstName:   "renamed",
+		LastName:    "Client",
+		Phone:       "+998900000000",
+		Photo:       "client.png",
+		DateOfBirth: "1999-12-31",
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: created.ID})
+	must(t, err)
+	assertEqual(t, "first_name", "renamed", got.FirstName)
+	assertEqual(t, "phone", "+998900000000", got.Phone)
+
+	rowsAffected, err = strg.Client().Update(ctx, &models.UpdateClient{ID: missingID(), DateOfBirth: "2000-01-01"})
+	assertAffected(t, 0, rowsAffected, err)
+
+	must(t, strg.Client().Delete(ctx, &models.ClientPrimaryKey{ID: created.ID}))
+
+	_, err = strg.Client().GetByID(ctx, &models.ClientPrimaryKey{ID: created.ID})
+	assertNoRows(t, err)
+}
+
+func testClientList(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	search := token()
+	for i := 0; i < 3; i++ {
+		createClient(t, strg, "list "+search)
+	}
+
+	resp, err := strg.Client().GetList(ctx, &models.GetListClientRequest{Limit: 2, Search: search})
+	must(t, err)
+
+	var createdAt []string
+	for _, client := range resp.Clients {
+		createdAt = append(createdAt, client.CreatedAt)
+	}
+	assertPage(t, 2, len(resp.Clients), 3, resp.Count, createdAt)
+
+	resp, err = strg.Client().GetList(ctx, &models.GetListClientRequest{Offset: 2, Limit: 2, Search: search})
+	must(t, err)
+	assertPage(t, 1, len(resp.Clients), 3, resp.Count, nil)
+}
@@ -0,0 +1,68 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/cursor"
+	"market_system/storage"
+)
+
+func testCursor(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	search := token()
+	want := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		want[createCategory(t, strg, "cursor "+search).Id] = true
+	}
+
+	resp, err := strg.Category().GetList(ctx, &models.GetListCategoryRequest{Limit: 2, Search: search})
+	must(t, err)
+	assertEqual(t, "count", 5, resp.Count)
+
+	var (
+		seen  = make(map[string]bool)
+		pages int
+	)
+
+	for {
+		pages++
+		for _, category := range resp.Categories {
+			if seen[category.Id] {
+				t.Fatalf("category %s is listed twice", category.Id)
+			}
+			seen[category.Id] = true
+		}
+
+		if resp.NextCursor == "" {
+			break
+		}
+
+		// Rows inserted while paging are newer than the cursor and must
+		// neither shift nor repeat the rows of the following pages.
+		createCategory(t, strg, "cursor "+search)
+
+		resp, err = strg.Category().GetList(ctx, &models.GetListCategoryRequest{
+			Limit:  2,
+			Search: search,
+			Cursor: resp.NextCursor,
+		})
+		must(t, err)
+		assertEqual(t, "count on a cursor page", 0, resp.Count)
+	}
+
+	assertEqual(t, "pages", 3, pages)
+	for id := range want {
+		if !seen[id] {
+			t.Fatalf("category %s was skipped", id)
+		}
+	}
+
+	_, err = strg.Category().GetList(ctx, &models.GetListCategoryRequest{Cursor: "not-a-cursor"})
+	if !errors.Is(err, cursor.ErrInvalid) {
+		t.Fatalf("expected cursor.ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/delivery"
+	"market_system/pkg/geo"
+	"market_system/pkg/money"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+// tashkent is where the located branches of the tests stand. A hundredth
+// of a degree of latitude away is about 1.112 km.
+var tashkent = geo.Point{Lat: 41.3111, Lng: 69.2797}
+
+func createLocatedBranch(t *testing.T, strg storage.StorageI) *models.Branch {
+	t.Helper()
+
+	branch, err := strg.Branch().Create(context.Background(), &models.CreateBranch{
+		Name:          "branch " + token(),
+		Phone:         "+998711234567",
+		WorkStartHour: "00:00",
+		WorkEndHour:   "00:00",
+		Address:       "Tashkent",
+		Latitude:      tashkent.Lat,
+		Longitude:     tashkent.Lng,
+	})
+	must(t, err)
+
+	return branch
+}
+
+func testDeliveryTariff(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	if _, err := strg.Branch().Create(ctx, &models.CreateBranch{Name: "branch " + token(), Latitude: 91}); !errors.Is(err, geo.ErrInvalid) {
+		t.Fatalf("expected geo.ErrInvalid for a latitude above 90, got %v", err)
+	}
+
+	branch := createLocatedBranch(t, strg)
+	assertEqual(t, "latitude", tashkent.Lat, branch.Latitude)
+	assertEqual(t, "longitude", tashkent.Lng, branch.Longitude)
+
+	_, err := strg.Branch().GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branch.ID})
+	assertNoRows(t, err)
+
+	if _, err := strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{BranchId: missingID()}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows for a missing branch, got %v", err)
+	}
+
+	invalid := &models.DeliveryTariff{
+		BranchId: branch.ID,
+		Bands:    []models.DeliveryBand{{UpToKm: 5, Fee: money.Units(10)}, {UpToKm: 3, Fee: money.Units(5)}},
+	}
+	if _, err := strg.Branch().SetDeliveryTariff(ctx, invalid); !errors.Is(err, delivery.ErrInvalid) {
+		t.Fatalf("expected delivery.ErrInvalid for bands out of order, got %v", err)
+	}
+
+	created, err := strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{
+		BranchId:    branch.ID,
+		BaseFee:     money.Units(5000),
+		PerKm:       money.Units(2000),
+		Bands:       []models.DeliveryBand{{UpToKm: 3, Fee: money.Units(5000)}},
+		MaxRadiusKm: 20,
+	})
+	must(t, err)
+	assertEqual(t, "version", int64(1), created.Version)
+	assertEqual(t, "bands", []models.DeliveryBand{{UpToKm: 3, Fee: money.Units(5000)}}, created.Bands)
+
+	updated, err := strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{
+		BranchId: branch.ID,
+		BaseFee:  money.Units(4000),
+		Version:  created.Version,
+	})
+	must(t, err)
+	assertEqual(t, "updated version", int64(2), updated.Version)
+	assertEqual(t, "updated base fee", money.Units(4000), updated.BaseFee)
+	assertEqual(t, "updated bands", []models.DeliveryBand{}, updated.Bands)
+
+	if _, err := strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{BranchId: branch.ID, Version: created.Version}); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a stale version, got %v", err)
+	}
+
+	got, err := strg.Branch().GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branch.ID})
+	must(t, err)
+	assertEqual(t, "tariff", *updated, *got)
+
+	must(t, strg.Branch().DeleteDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branch.ID}))
+
+	_, err = strg.Branch().GetDeliveryTariff(ctx, &models.BranchPrimaryKey{ID: branch.ID})
+	assertNoRows(t, err)
+}
+
+func testOrderDelivery(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		branch  = createLocatedBranch(t, strg)
+		client  = createClient(t, strg, "client "+token())
+		cheap   = createProduct(t, strg, "cheap "+token(), money.Units(1000))
+		pricey  = createProduct(t, strg, "pricey "+token(), money.Units(200000))
+		near    = geo.Point{Lat: tashkent.Lat + 0.01, Lng: tashkent.Lng}
+		far     = geo.Point{Lat: tashkent.Lat + 0.05, Lng: tashkent.Lng}
+		outside = geo.Point{Lat: tashkent.Lat + 0.2, Lng: tashkent.Lng}
+	)
+
+	for _, product := range []*models.Product{cheap, pricey} {
+		addStock(t, strg, branch.ID, product.Id, 10)
+	}
+
+	createAt := func(address geo.Point) (*models.Order, error) {
+		return strg.Order().Create(ctx, &models.CreateOrder{
+			OrderId:   "O-" + uuid.New().String(),
+			ClientId:  client.ID,
+			BranchId:  branch.ID,
+			Address:   "Yunusobod 1",
+			Latitude:  address.Lat,
+			Longitude: address.Lng,
+		})
+	}
+
+	// Without a tariff a located order pays the flat price of its branch.
+	flat, err := createAt(near)
+	must(t, err)
+	assertEqual(t, "flat delivery price", branch.DeliveryPrice, flat.DeliveryPrice)
+	assertEqual(t, "flat distance", 0.0, flat.DeliveryDistance)
+
+	_, err = strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{
+		BranchId:    branch.ID,
+		BaseFee:     money.Units(5000),
+		PerKm:       money.Units(2000),
+		Bands:       []models.DeliveryBand{{UpToKm: 3, Fee: money.Units(5000)}},
+		FreeFrom:    money.Units(200000),
+		MaxRadiusKm: 20,
+	})
+	must(t, err)
+
+	if _, err := createAt(geo.Point{Lat: 41, Lng: 181}); !errors.Is(err, geo.ErrInvalid) {
+		t.Fatalf("expected geo.ErrInvalid for a longitude above 180, got %v", err)
+	}
+
+	if _, err := createAt(outside); !errors.Is(err, delivery.ErrOutOfRange) {
+		t.Fatalf("expected delivery.ErrOutOfRange beyond the radius, got %v", err)
+	}
+
+	// A near order pays the base fee and the fee of its band.
+	order, err := createAt(near)
+	must(t, err)
+	assertEqual(t, "near distance", 1.112, order.DeliveryDistance)
+	assertEqual(t, "band fee", money.Units(10000), order.DeliveryPrice)
+
+	_, err = strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{OrderID: order.Id, ProductID: cheap.Id, Quantity: 1})
+	must(t, err)
+
+	order, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total with the band fee", money.Units(11000), order.TotalPrice)
+
+	// Beyond the last band every kilometer is paid: 5000 + 2000 * 5.56.
+	update := &models.UpdateOrder{
+		Id:        order.Id,
+		OrderId:   order.OrderId,
+		ClientId:  order.ClientId,
+		BranchId:  order.BranchId,
+		Address:   order.Address,
+		Latitude:  far.Lat,
+		Longitude: far.Lng,
+	}
+
+	rowsAffected, err := strg.Order().Update(ctx, update)
+	assertAffected(t, 1, rowsAffected, err)
+
+	order, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "far distance", 5.56, order.DeliveryDistance)
+	assertEqual(t, "per km fee", money.Units(16120), order.DeliveryPrice)
+	assertEqual(t, "total with the per km fee", money.Units(17120), order.TotalPrice)
+
+	update.Latitude, update.Longitude = outside.Lat, outside.Lng
+	if _, err := strg.Order().Update(ctx, update); !errors.Is(err, delivery.ErrOutOfRange) {
+		t.Fatalf("expected delivery.ErrOutOfRange for an update beyond the radius, got %v", err)
+	}
+
+	// Goods worth the free delivery threshold are delivered for free.
+	_, err = strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{OrderID: order.Id, ProductID: pricey.Id, Quantity: 1})
+	must(t, err)
+
+	order, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "free delivery", money.Amount(0), order.DeliveryPrice)
+	assertEqual(t, "total without delivery", money.Units(201000), order.TotalPrice)
+
+	// A branch that prices deliveries by distance needs the location of
+	// the order, so the radius is always checked.
+	if _, err := createAt(geo.Point{}); !errors.Is(err, delivery.ErrNoLocation) {
+		t.Fatalf("expected delivery.ErrNoLocation for an order without a location, got %v", err)
+	}
+
+	update.Latitude, update.Longitude = 0, 0
+	if _, err := strg.Order().Update(ctx, update); !errors.Is(err, delivery.ErrNoLocation) {
+		t.Fatalf("expected delivery.ErrNoLocation for an update without a location, got %v", err)
+	}
+}
+
+func testNearestBranch(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	// Branches of other runs stay in a shared database, so this run looks
+	// around a point of its own in the southern Pacific.
+	var (
+		base   = geo.Point{Lat: -50 + rand.Float64(), Lng: -150 + rand.Float64()}
+		client = geo.Point{Lat: base.Lat + 0.03, Lng: base.Lng}
+		speed  = delivery.Speed{Prep: 10 * time.Minute, KmPerHour: 30}
+		night  = time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	)
+
+	newBranch := func(lat float64, start, end string) *models.Branch {
+		t.Helper()
+
+		branch, err := strg.Branch().Create(ctx, &models.CreateBranch{
+			Name:          "branch " + token(),
+			WorkStartHour: start,
+			WorkEndHour:   end,
+			Latitude:      lat,
+			Longitude:     base.Lng,
+		})
+		must(t, err)
+
+		return branch
+	}
+
+	var (
+		day       = newBranch(base.Lat, "9:00", "18:00")
+		overnight = newBranch(base.Lat+0.02, "22:00", "02:00")
+		inactive  = newBranch(base.Lat+0.03, "00:00", "00:00")
+		deleted   = newBranch(base.Lat+0.03, "00:00", "00:00")
+	)
+
+	_, err := strg.Branch().SetDeliveryTariff(ctx, &models.DeliveryTariff{BranchId: day.ID, BaseFee: money.Units(5000), MaxRadiusKm: 2})
+	must(t, err)
+
+	rowsAffected, err := strg.Branch().Update(ctx, &models.UpdateBranch{
+		ID:        inactive.ID,
+		Name:      inactive.Name,
+		Latitude:  inactive.Latitude,
+		Longitude: inactive.Longitude,
+		Active:    false,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	must(t, strg.Branch().Delete(ctx, &models.BranchPrimaryKey{ID: deleted.ID}))
+
+	if _, err := strg.Branch().Nearest(ctx, &models.NearestBranchRequest{Latitude: 100}); !errors.Is(err, geo.ErrInvalid) {
+		t.Fatalf("expected geo.ErrInvalid for a latitude above 90, got %v", err)
+	}
+
+	resp, err := strg.Branch().Nearest(ctx, &models.NearestBranchRequest{
+		Latitude:  client.Lat,
+		Longitude: client.Lng,
+		At:        night,
+		Limit:     2,
+		Speed:     speed,
+	})
+	must(t, err)
+	assertEqual(t, "nearest", 2, len(resp.Branches))
+
+	// The overnight branch is 1.112 km away, open at 23:00 and delivers at
+	// its flat price in 10 minutes and 2.2 on the road.
+	nearest := resp.Branches[0]
+	assertEqual(t, "nearest id", overnight.ID, nearest.ID)
+	assertEqual(t, "nearest distance", 1.112, nearest.DistanceKm)
+	assertEqual(t, "nearest is open", true, nearest.IsOpen)
+	assertEqual(t, "nearest deliverable", true, nearest.Deliverable)
+	assertEqual(t, "nearest fee", overnight.DeliveryPrice, nearest.DeliveryFee)
+	assertEqual(t, "nearest minutes", int64(13), nearest.DeliveryMinutes)
+
+	// The day branch is closed at night and the client is beyond its
+	// radius.
+	further := resp.Branches[1]
+	assertEqual(t, "further id", day.ID, further.ID)
+	assertEqual(t, "further distance", 3.336, further.DistanceKm)
+	assertEqual(t, "further is open", false, further.IsOpen)
+	assertEqual(t, "further deliverable", false, further.Deliverable)
+	assertEqual(t, "further fee", money.Amount(0), further.DeliveryFee)
+
+	// At noon the day branch opens and the overnight branch closes.
+	resp, err = strg.Branch().Nearest(ctx, &models.NearestBranchRequest{
+		Latitude:  client.Lat,
+		Longitude: client.Lng,
+		At:        time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Limit:     2,
+	})
+	must(t, err)
+	assertEqual(t, "overnight at noon", false, resp.Branches[0].IsOpen)
+	assertEqual(t, "day at noon", true, resp.Branches[1].IsOpen)
+}
@@ -0,0 +1,69 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/filter"
+	"market_system/pkg/money"
+	"market_system/storage"
+)
+
+func testFilter(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		search = token()
+		cheap  = createProduct(t, strg, "filter "+search, money.Units(1000))
+		middle = createProduct(t, strg, "filter "+search, money.Units(5000))
+		pricey = createProduct(t, strg, "filter "+search, money.Units(9000))
+	)
+
+	resp, err := strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Search: search,
+		Filter: filter.In("id", []string{cheap.Id, pricey.Id}),
+	})
+	must(t, err)
+	assertEqual(t, "count of id in set", 2, resp.Count)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Search: search,
+		Filter: filter.Between("price", 2000, 9000),
+	})
+	must(t, err)
+	assertEqual(t, "count of price between", 2, resp.Count)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Search: search,
+		Filter: filter.Or(
+			filter.Eq("category_id", middle.CategoryId),
+			filter.Lt("price", 2000),
+		),
+	})
+	must(t, err)
+	assertEqual(t, "count of or group", 2, resp.Count)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Search: search,
+		Filter: filter.In("id", []string{}),
+	})
+	must(t, err)
+	assertEqual(t, "count of empty in", 0, resp.Count)
+
+	// A value that looks like SQL must only ever be compared as data.
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Search: search,
+		Filter: filter.Eq("title", "x' OR '1'='1"),
+	})
+	must(t, err)
+	assertEqual(t, "count of quoted value", 0, resp.Count)
+
+	_, err = strg.Product().GetList(ctx, &models.GetListProductRequest{
+		Filter: filter.Eq("1=1; --", "x"),
+	})
+	if !errors.Is(err, filter.ErrInvalid) {
+		t.Fatalf("expected filter.ErrInvalid for an unknown field, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+)
+
+func testOrder(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client = createClient(t, strg, "client "+token())
+		branch = createBranch(t, strg, "branch "+token())
+	)
+
+	created := createOrder(t, strg, client, branch)
+	assertEqual(t, "status", "new", created.Status)
+	assertEqual(t, "delivery_price", branch.DeliveryPrice, created.DeliveryPrice)
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "order", *created, *got)
+
+	rowsAffected, err := strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:            created.Id,
+		ClientId:      client.ID,
+		BranchId:      branch.ID,
+		Address:       "Yunusobod 4",
+		DeliveryPrice: money.Units(5000),
+		Status:        "new",
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "address", "Yunusobod 4", got.Address)
+	assertEqual(t, "delivery_price", money.Units(5000), got.DeliveryPrice)
+	assertEqual(t, "order_id", created.OrderId, got.OrderId)
+
+	rowsAffected, err = strg.Order().Update(ctx, &models.UpdateOrder{Id: missingID(), ClientId: client.ID, BranchId: branch.ID, Status: "new"})
+	assertAffected(t, 0, rowsAffected, err)
+
+	if _, err := strg.Order().Create(ctx, &models.CreateOrder{
+		OrderId:  created.OrderId,
+		ClientId: client.ID,
+		BranchId: branch.ID,
+	}); err == nil {
+		t.Fatal("expected an error for a duplicate order_id")
+	}
+
+	if _, err := strg.Order().Create(ctx, &models.CreateOrder{
+		OrderId:  "O-" + token(),
+		ClientId: client.ID,
+		BranchId: missingID(),
+	}); err == nil {
+		t.Fatal("expected an error for a missing branch")
+	}
+
+	must(t, strg.Order().Delete(ctx, &models.OrderPrimaryKey{Id: created.Id}))
+
+	_, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: created.Id})
+	assertNoRows(t, err)
+}
+
+func testOrderList(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client = createClient(t, strg, "client "+token())
+		branch = createBranch(t, strg, "branch "+token())
+	)
+
+	for i := 0; i < 3; i++ {
+		createOrder(t, strg, client, branch)
+	}
+
+	for _, search := range []string{client.ID, branch.ID} {
+		resp, err := strg.Order().GetList(ctx, &models.GetListOrderRequest{Limit: 2, Search: search})
+		must(t, err)
+
+		var createdAt []string
+		for _, order := range resp.Orders {
+			createdAt = append(createdAt, order.CreatedAt)
+		}
+		assertPage(t, 2, len(resp.Orders), 3, resp.Count, createdAt)
+
+		resp, err = strg.Order().GetList(ctx, &models.GetListOrderRequest{Offset: 2, Limit: 2, Search: search})
+		must(t, err)
+		assertPage(t, 1, len(resp.Orders), 3, resp.Count, nil)
+	}
+}
+
+func testOrderStatusUpdate(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	transitions := []struct {
+		from, to string
+		ok       bool
+	}{
+		{"new", "in-process", true},
+		{"new", "canceled", true},
+		{"new", "finished", false},
+		{"new", "confirmed", false},
+		{"in-process", "finished", true},
+		{"in-process", "canceled", true},
+		{"in-process", "picking", true},
+		{"picking", "delivering", true},
+		{"delivering", "canceled", false},
+		{"delivering", "delivered", true},
+		{"delivered", "returned", false},
+		{"delivered", "finished", true},
+		{"finished", "new", false},
+		{"canceled", "in-process", false},
+		{"new", "lost", false},
+	}
+
+	for _, tr := range transitions {
+		order := newOrder(t, strg)
+		if tr.from != "new" {
+			order = setStatus(t, strg, order, tr.from)
+		}
+
+		updated, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: tr.to})
+		if !tr.ok {
+			if err == nil {
+				t.Fatalf("%s -> %s: expected an error", tr.from, tr.to)
+			}
+
+			got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+			must(t, err)
+			assertEqual(t, "status after rejected transition", tr.from, got.Status)
+			continue
+		}
+
+		must(t, err)
+		assertEqual(t, "status", tr.to, updated.Status)
+
+		got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+		must(t, err)
+		assertEqual(t, "stored status", tr.to, got.Status)
+	}
+
+	if _, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: missingID(), Status: "in-process"}); err == nil {
+		t.Fatal("expected an error for a missing order")
+	}
+}
+
+// setStatus walks an order from "new" to status through allowed transitions.
+func setStatus(t *testing.T, strg storage.StorageI, order *models.Order, status string) *models.Order {
+	t.Helper()
+
+	ctx := context.Background()
+	path := map[string][]string{
+		"in-process": {"in-process"},
+		"picking":    {"in-process", "picking"},
+		"delivering": {"in-process", "picking", "delivering"},
+		"delivered":  {"in-process", "picking", "delivering", "delivered"},
+		"finished":   {"in-process", "finished"},
+		"canceled":   {"canceled"},
+	}
+
+	for _, next := range path[status] {
+		updated, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: next})
+		must(t, err)
+		order = &updated
+	}
+
+	return order
+}
+
+func testOrderHistory(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	order := newOrder(t, strg)
+
+	_, err := strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:       order.Id,
+		ClientId: order.ClientId,
+		BranchId: order.BranchId,
+		Status:   "in-process",
+	})
+	if !errors.Is(err, workflow.ErrTransition) {
+		t.Fatalf("update must not change the status, got %v", err)
+	}
+
+	for _, req := range []models.CheckStatus{
+		{Id: order.Id, Status: "in-process", Actor: "cashier"},
+		{Id: order.Id, Status: "canceled", Actor: "manager", Reason: "out of stock"},
+	} {
+		_, err := strg.Order().StatusUpdate(ctx, req)
+		must(t, err)
+	}
+
+	if _, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: "finished"}); !errors.Is(err, workflow.ErrTransition) {
+		t.Fatalf("expected workflow.ErrTransition, got %v", err)
+	}
+
+	resp, err := strg.Order().History(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "count", 2, resp.Count)
+
+	first, second := *resp.History[0], *resp.History[1]
+	assertEqual(t, "first change", [3]string{"new", "in-process", "cashier"}, [3]string{first.FromStatus, first.ToStatus, first.Actor})
+	assertEqual(t, "second change", [3]string{"in-process", "canceled", "manager"}, [3]string{second.FromStatus, second.ToStatus, second.Actor})
+	assertEqual(t, "reason", "out of stock", second.Reason)
+
+	if second.CreatedAt < first.CreatedAt {
+		t.Fatalf("history is not oldest first: %s before %s", first.CreatedAt, second.CreatedAt)
+	}
+
+	_, err = strg.Order().History(ctx, &models.OrderPrimaryKey{Id: missingID()})
+	assertNoRows(t, err)
+}
@@ -0,0 +1,277 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/pricing"
+	"market_system/storage"
+)
+
+func testOrderProduct(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		order   = newOrder(t, strg)
+		product = createProduct(t, strg, "product "+token(), money.Units(10000))
+	)
+
+	addStock(t, strg, order.BranchId, product.Id, 10)
+
+	created, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   order.Id,
+		ProductID: product.Id,
+		Quantity:  2,
+	})
+	must(t, err)
+	assertEqual(t, "order_id", order.Id, created.OrderID)
+	assertEqual(t, "price is taken from the product", money.Units(10000), created.Price)
+	assertEqual(t, "sum", money.Units(20000), created.Sum)
+
+	got, err := strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: created.OrderProductID})
+	must(t, err)
+	assertEqual(t, "order product", *created, *got)
+
+	rowsAffected, err := strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{
+		OrderProductID: created.OrderProductID,
+		ProductID:      product.Id,
+		DiscountType:   "fix",
+		DiscountAmount: money.Units(1000),
+		Quantity:       3,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: created.OrderProductID})
+	must(t, err)
+	assertEqual(t, "quantity", 3.0, got.Quantity)
+	assertEqual(t, "sum", money.Units(27000), got.Sum)
+
+	rowsAffected, err = strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{OrderProductID: missingID(), ProductID: product.Id})
+	assertAffected(t, 0, rowsAffected, err)
+
+	if _, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   missingID(),
+		ProductID: product.Id,
+		Quantity:  1,
+	}); err == nil {
+		t.Fatal("expected an error for a missing order")
+	}
+
+	must(t, strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: created.OrderProductID}))
+
+	_, err = strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: created.OrderProductID})
+	assertNoRows(t, err)
+}
+
+func testOrderProductList(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		order   = newOrder(t, strg)
+		product = createProduct(t, strg, "product "+token(), money.Units(500))
+	)
+
+	addStock(t, strg, order.BranchId, product.Id, 3)
+
+	for i := 0; i < 3; i++ {
+		_, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:   order.Id,
+			ProductID: product.Id,
+			Quantity:  1,
+		})
+		must(t, err)
+	}
+
+	resp, err := strg.OrderProduct().GetList(ctx, &models.GetListOrderProductRequest{Limit: 2})
+	must(t, err)
+
+	var createdAt []string
+	for _, orderProduct := range resp.OrderProducts {
+		createdAt = append(createdAt, orderProduct.CreatedAt)
+	}
+	assertPage(t, 2, len(resp.OrderProducts), resp.Count, resp.Count, createdAt)
+
+	if resp.Count < 3 {
+		t.Fatalf("count: want at least 3, got %d", resp.Count)
+	}
+}
+
+func testOrderTotals(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		order  = newOrder(t, strg)
+		first  = createProduct(t, strg, "product "+token(), money.Units(10000))
+		second = createProduct(t, strg, "product "+token(), money.Units(2500))
+	)
+
+	addStock(t, strg, order.BranchId, first.Id, 2)
+	addStock(t, strg, order.BranchId, second.Id, 4)
+
+	lines := []models.CreateOrderProduct{
+		{OrderID: order.Id, ProductID: first.Id, Quantity: 2},
+		{OrderID: order.Id, ProductID: second.Id, Quantity: 4, DiscountType: "fix", DiscountAmount: money.Units(500)},
+	}
+
+	var created []*models.OrderProduct
+	for i := range lines {
+		orderProduct, err := strg.OrderProduct().Create(ctx, &lines[i])
+		must(t, err)
+		created = append(created, orderProduct)
+	}
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_count", 6.0, got.TotalCount)
+	assertEqual(t, "total_price includes delivery", money.Units(28000)+order.DeliveryPrice, got.TotalPrice)
+
+	_, err = strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{
+		OrderProductID: created[0].OrderProductID,
+		ProductID:      first.Id,
+		Quantity:       1,
+	})
+	must(t, err)
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_count after update", 5.0, got.TotalCount)
+	assertEqual(t, "total_price after update", money.Units(18000)+order.DeliveryPrice, got.TotalPrice)
+}
+
+func testOrderPricing(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		order   = newOrder(t, strg)
+		product = createProduct(t, strg, "product "+token(), money.Units(10000))
+	)
+
+	addStock(t, strg, order.BranchId, product.Id, 10)
+
+	if _, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:        order.Id,
+		ProductID:      product.Id,
+		Quantity:       1,
+		DiscountType:   "percent",
+		DiscountAmount: money.Units(120),
+	}); !errors.Is(err, pricing.ErrInvalid) {
+		t.Fatalf("expected pricing.ErrInvalid for a discount above 100%%, got %v", err)
+	}
+
+	percent, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:        order.Id,
+		ProductID:      product.Id,
+		Quantity:       3,
+		DiscountType:   "percent",
+		DiscountAmount: money.Units(10),
+	})
+	must(t, err)
+	assertEqual(t, "percent sum is the discounted price", money.Units(27000), percent.Sum)
+
+	plain, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   order.Id,
+		ProductID: product.Id,
+		Quantity:  1,
+	})
+	must(t, err)
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price", money.Units(37000)+order.DeliveryPrice, got.TotalPrice)
+
+	if got.Version <= order.Version {
+		t.Fatalf("new totals should bump the order version past %d, got %d", order.Version, got.Version)
+	}
+
+	_, err = strg.Product().Update(ctx, &models.UpdateProduct{
+		Id:         product.Id,
+		Title:      product.Title,
+		CategoryId: product.CategoryId,
+		Price:      money.Units(20000),
+	})
+	must(t, err)
+
+	rowsAffected, err := strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{
+		OrderProductID: plain.OrderProductID,
+		ProductID:      product.Id,
+		Quantity:       2,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	line, err := strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: plain.OrderProductID})
+	must(t, err)
+	assertEqual(t, "price is kept for the same product", money.Units(10000), line.Price)
+
+	must(t, strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: percent.OrderProductID}))
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_count without the deleted line", 2.0, got.TotalCount)
+	assertEqual(t, "total_price without the deleted line", money.Units(20000)+order.DeliveryPrice, got.TotalPrice)
+
+	rowsAffected, err = strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:            order.Id,
+		ClientId:      order.ClientId,
+		BranchId:      order.BranchId,
+		Address:       order.Address,
+		DeliveryPrice: money.Units(5000),
+		Status:        order.Status,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price with the new delivery price", money.Units(25000), got.TotalPrice)
+
+	must(t, strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: plain.OrderProductID}))
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price without lines", money.Amount(0), got.TotalPrice)
+
+	rowsAffected, err = strg.OrderProduct().Restore(ctx, &models.OrderProductPrimaryKey{OrderProductID: percent.OrderProductID})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price after restore", money.Units(32000), got.TotalPrice)
+}
+
+// testOrderCents adds up amounts that float64 cannot hold exactly, which
+// must still total to the cent.
+func testOrderCents(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		order       = newOrder(t, strg)
+		tenCents    = createProduct(t, strg, "ten cents "+token(), 10)
+		twentyCents = createProduct(t, strg, "twenty cents "+token(), 20)
+	)
+
+	addStock(t, strg, order.BranchId, tenCents.Id, 10)
+	addStock(t, strg, order.BranchId, twentyCents.Id, 10)
+
+	for _, product := range []*models.Product{tenCents, twentyCents, tenCents, twentyCents, tenCents, twentyCents} {
+		_, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:   order.Id,
+			ProductID: product.Id,
+			Quantity:  1,
+		})
+		must(t, err)
+	}
+
+	line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   order.Id,
+		ProductID: tenCents.Id,
+		Quantity:  0.3,
+	})
+	must(t, err)
+	assertEqual(t, "sum of 0.3 at 0.10", "0.03", line.Sum.String())
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price", money.Amount(93)+order.DeliveryPrice, got.TotalPrice)
+}
@@ -0,0 +1,81 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/storage"
+)
+
+func testProduct(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	created := createProduct(t, strg, "product "+token(), money.Units(12000))
+
+	got, err := strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "product_id", created.ProductId, got.ProductId)
+	assertEqual(t, "title", created.Title, got.Title)
+	assertEqual(t, "description", "description", got.Description)
+	assertEqual(t, "price", money.Units(12000), got.Price)
+
+	category := createCategory(t, strg, "category "+token())
+	rowsAffected, err := strg.Product().Update(ctx, &models.UpdateProduct{
+		Id:          created.Id,
+		Title:       "updated " + token(),
+		Description: "updated description",
+		Photo:       "updated.png",
+		Price:       money.Units(15000),
+		CategoryId:  category.Id,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err = strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "price", money.Units(15000), got.Price)
+	assertEqual(t, "category_id", category.Id, got.CategoryId)
+	assertEqual(t, "product_id", created.ProductId, got.ProductId)
+
+	rowsAffected, err = strg.Product().Update(ctx, &models.UpdateProduct{Id: missingID(), CategoryId: category.Id})
+	assertAffected(t, 0, rowsAffected, err)
+
+	if _, err := strg.Product().Create(ctx, &models.CreateProduct{
+		Title:      "duplicate",
+		ProductId:  created.ProductId,
+		CategoryId: category.Id,
+	}); err == nil {
+		t.Fatal("expected an error for a duplicate product_id")
+	}
+
+	must(t, strg.Product().Delete(ctx, &models.ProductPrimaryKey{Id: created.Id}))
+
+	_, err = strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: created.Id})
+	assertNoRows(t, err)
+}
+
+func testProductList(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	search := token()
+	for i := 0; i < 3; i++ {
+		createProduct(t, strg, "list "+search, money.Units(1000))
+	}
+
+	resp, err := strg.Product().GetList(ctx, &models.GetListProductRequest{Limit: 2, Search: search})
+	must(t, err)
+
+	var createdAt []string
+	for _, product := range resp.Products {
+		createdAt = append(createdAt, product.CreatedAt)
+		if product.ProductId == "" || product.Description != "description" || product.Photo != "product.png" {
+			t.Fatalf("list returned incomplete product: %+v", product)
+		}
+	}
+	assertPage(t, 2, len(resp.Products), 3, resp.Count, createdAt)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{Offset: 2, Limit: 2, Search: search})
+	must(t, err)
+	assertPage(t, 1, len(resp.Products), 3, resp.Count, nil)
+}
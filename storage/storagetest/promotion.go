@@ -0,0 +1,268 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/promo"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+)
+
+func testPromotion(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		branch = createBranch(t, strg, "branch "+token())
+		code   = "spring" + token()
+	)
+
+	if _, err := strg.Promotion().Create(ctx, &models.CreatePromotion{Code: code, Type: promo.TypePercent, Value: money.Units(150)}); !errors.Is(err, promo.ErrInvalid) {
+		t.Fatalf("expected promo.ErrInvalid for a percent above 100, got %v", err)
+	}
+
+	if _, err := strg.Promotion().Create(ctx, &models.CreatePromotion{Code: code, Type: promo.TypeFix, Value: money.Units(5), BranchIds: []string{missingID()}}); !errors.Is(err, promo.ErrInvalid) {
+		t.Fatalf("expected promo.ErrInvalid for a missing branch, got %v", err)
+	}
+
+	startsAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	created, err := strg.Promotion().Create(ctx, &models.CreatePromotion{
+		Code:      " " + code + " ",
+		Type:      promo.TypePercent,
+		Value:     money.Units(10),
+		StartsAt:  startsAt,
+		BranchIds: []string{branch.ID},
+	})
+	must(t, err)
+	assertEqual(t, "code is stored upper-case", strings.ToUpper(code), created.Code)
+	assertEqual(t, "version", int64(1), created.Version)
+	assertEqual(t, "branches", 1, len(created.BranchIds))
+	assertEqual(t, "ends_at", "", created.EndsAt)
+
+	got, err := time.Parse(time.RFC3339Nano, created.StartsAt)
+	must(t, err)
+	assertEqual(t, "starts_at", startsAt, got.UTC())
+
+	if _, err := strg.Promotion().Create(ctx, &models.CreatePromotion{Code: code, Type: promo.TypeFix, Value: money.Units(5)}); !errors.Is(err, promo.ErrInvalid) {
+		t.Fatalf("expected promo.ErrInvalid for a taken code, got %v", err)
+	}
+
+	rowsAffected, err := strg.Promotion().Update(ctx, &models.UpdatePromotion{
+		Id:         created.Id,
+		Code:       code,
+		Type:       promo.TypeFix,
+		Value:      750,
+		UsageLimit: 3,
+		Version:    created.Version,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	updated, err := strg.Promotion().GetByID(ctx, &models.PromotionPrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "type", promo.TypeFix, updated.Type)
+	assertEqual(t, "usage_limit", 3, updated.UsageLimit)
+	assertEqual(t, "starts_at is cleared", "", updated.StartsAt)
+	assertEqual(t, "branches are cleared", 0, len(updated.BranchIds))
+
+	if _, err := strg.Promotion().Update(ctx, &models.UpdatePromotion{Id: created.Id, Code: code, Type: promo.TypeFix, Value: money.Units(1), Version: created.Version}); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a stale version, got %v", err)
+	}
+
+	list, err := strg.Promotion().GetList(ctx, &models.GetListPromotionRequest{Search: code[:10]})
+	must(t, err)
+	assertEqual(t, "listed", 1, list.Count)
+	assertEqual(t, "listed id", created.Id, list.Promotions[0].Id)
+
+	must(t, strg.Promotion().Delete(ctx, &models.PromotionPrimaryKey{Id: created.Id}))
+
+	_, err = strg.Promotion().GetByID(ctx, &models.PromotionPrimaryKey{Id: created.Id})
+	assertNoRows(t, err)
+
+	// The code of a deleted promotion can be given out again.
+	_, err = strg.Promotion().Create(ctx, &models.CreatePromotion{Code: code, Type: promo.TypeFix, Value: money.Units(5)})
+	must(t, err)
+
+	list, err = strg.Promotion().GetList(ctx, &models.GetListPromotionRequest{Search: code, IncludeDeleted: true})
+	must(t, err)
+	assertEqual(t, "listed with the deleted one", 2, list.Count)
+}
+
+func testOrderPromotion(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client = createClient(t, strg, "client "+token())
+		branch = createBranch(t, strg, "branch "+token())
+		food   = createProduct(t, strg, "food "+token(), money.Units(100))
+		drink  = createProduct(t, strg, "drink "+token(), money.Units(50))
+		code   = "SAVE" + strings.ToUpper(token())
+	)
+
+	addStock(t, strg, branch.ID, food.Id, 10)
+	addStock(t, strg, branch.ID, drink.Id, 10)
+
+	_, err := strg.Promotion().Create(ctx, &models.CreatePromotion{
+		Code:           code,
+		Type:           promo.TypePercent,
+		Value:          money.Units(10),
+		PerClientLimit: 1,
+		MinOrderTotal:  money.Units(150),
+		BranchIds:      []string{branch.ID},
+		CategoryIds:    []string{food.CategoryId},
+	})
+	must(t, err)
+
+	applyCode := func(order *models.Order, code string) (*models.Order, error) {
+		t.Helper()
+
+		_, err := strg.Order().Update(ctx, &models.UpdateOrder{
+			Id:            order.Id,
+			ClientId:      order.ClientId,
+			OrderId:       order.OrderId,
+			BranchId:      order.BranchId,
+			Address:       order.Address,
+			DeliveryPrice: order.DeliveryPrice,
+			PromoCode:     &code,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	}
+
+	addLine := func(order *models.Order, product *models.Product, quantity float64) *models.OrderProduct {
+		t.Helper()
+
+		line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:   order.Id,
+			ProductID: product.Id,
+			Quantity:  quantity,
+		})
+		must(t, err)
+
+		return line
+	}
+
+	first := createOrder(t, strg, client, branch)
+	addLine(first, food, 1)
+
+	if _, err := applyCode(first, code); !errors.Is(err, promo.ErrNotApplicable) {
+		t.Fatalf("expected promo.ErrNotApplicable below the minimum total, got %v", err)
+	}
+
+	drinkLine := addLine(first, drink, 1)
+
+	if _, err := applyCode(first, "NO"+code); !errors.Is(err, promo.ErrNotApplicable) {
+		t.Fatalf("expected promo.ErrNotApplicable for an unknown code, got %v", err)
+	}
+
+	// Only the food line is in the promotion categories.
+	first, err = applyCode(first, strings.ToLower(code))
+	must(t, err)
+	assertEqual(t, "promo_code", code, first.PromoCode)
+	assertEqual(t, "discount", money.Units(10), first.Discount)
+	assertEqual(t, "total_price", money.Units(140)+first.DeliveryPrice, first.TotalPrice)
+
+	// Below the minimum total the order keeps its code without a discount.
+	must(t, strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: drinkLine.OrderProductID}))
+
+	first, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: first.Id})
+	must(t, err)
+	assertEqual(t, "code kept", code, first.PromoCode)
+	assertEqual(t, "no discount below the minimum", money.Amount(0), first.Discount)
+	assertEqual(t, "undiscounted total", money.Units(100)+first.DeliveryPrice, first.TotalPrice)
+
+	rowsAffected, err := strg.OrderProduct().Restore(ctx, &models.OrderProductPrimaryKey{OrderProductID: drinkLine.OrderProductID})
+	assertAffected(t, 1, rowsAffected, err)
+
+	first, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: first.Id})
+	must(t, err)
+	assertEqual(t, "discount is back", money.Units(10), first.Discount)
+
+	// The client used the code once already.
+	second := createOrder(t, strg, client, branch)
+	addLine(second, food, 2)
+
+	if _, err := applyCode(second, code); !errors.Is(err, promo.ErrNotApplicable) {
+		t.Fatalf("expected promo.ErrNotApplicable past the per client limit, got %v", err)
+	}
+
+	// A canceled order gives its use back.
+	setStatus(t, strg, first, workflow.StatusCanceled)
+
+	second, err = applyCode(second, code)
+	must(t, err)
+	assertEqual(t, "second discount", money.Units(20), second.Discount)
+	assertEqual(t, "second total", money.Units(180)+second.DeliveryPrice, second.TotalPrice)
+
+	// An update without a code keeps the promotion the order has.
+	rowsAffected, err = strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:            second.Id,
+		ClientId:      second.ClientId,
+		OrderId:       second.OrderId,
+		BranchId:      second.BranchId,
+		Address:       "Yunusobod 3",
+		DeliveryPrice: second.DeliveryPrice,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	second, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: second.Id})
+	must(t, err)
+	assertEqual(t, "code kept without one", code, second.PromoCode)
+	assertEqual(t, "discount kept without a code", money.Units(20), second.Discount)
+
+	second, err = applyCode(second, "")
+	must(t, err)
+	assertEqual(t, "promotion taken off", "", second.PromotionId)
+	assertEqual(t, "discount taken off", money.Amount(0), second.Discount)
+	assertEqual(t, "full total", money.Units(200)+second.DeliveryPrice, second.TotalPrice)
+
+	expired := "OLD" + strings.ToUpper(token())
+	_, err = strg.Promotion().Create(ctx, &models.CreatePromotion{
+		Code:   expired,
+		Type:   promo.TypeFix,
+		Value:  money.Units(5),
+		EndsAt: time.Now().Add(-time.Hour),
+	})
+	must(t, err)
+
+	if _, err := applyCode(second, expired); !errors.Is(err, promo.ErrNotApplicable) {
+		t.Fatalf("expected promo.ErrNotApplicable for an expired code, got %v", err)
+	}
+
+	// The money and the stock of a finished order are settled, so neither
+	// its promotion nor its branch change any more.
+	finished := createOrder(t, strg, client, branch)
+	addLine(finished, drink, 2)
+	finished = setStatus(t, strg, finished, workflow.StatusFinished)
+
+	fix := "FIX" + strings.ToUpper(token())
+	_, err = strg.Promotion().Create(ctx, &models.CreatePromotion{Code: fix, Type: promo.TypeFix, Value: money.Units(5)})
+	must(t, err)
+
+	if _, err := applyCode(finished, fix); !errors.Is(err, storage.ErrOrderClosed) {
+		t.Fatalf("expected ErrOrderClosed for a code on a finished order, got %v", err)
+	}
+
+	_, err = strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:            finished.Id,
+		ClientId:      finished.ClientId,
+		OrderId:       finished.OrderId,
+		BranchId:      createBranch(t, strg, "branch "+token()).ID,
+		Address:       finished.Address,
+		DeliveryPrice: finished.DeliveryPrice,
+	})
+	if !errors.Is(err, storage.ErrOrderClosed) {
+		t.Fatalf("expected ErrOrderClosed for moving a finished order, got %v", err)
+	}
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: finished.Id})
+	must(t, err)
+	assertEqual(t, "finished order", *finished, *got)
+}
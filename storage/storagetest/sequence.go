@@ -0,0 +1,44 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/storage"
+)
+
+func testSequence(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		name  = "test-" + token() + "-"
+		other = "test-" + token() + "-"
+	)
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := strg.Sequence().Next(ctx, name)
+		must(t, err)
+		assertEqual(t, "next", want, got)
+	}
+
+	got, err := strg.Sequence().Next(ctx, other)
+	must(t, err)
+	assertEqual(t, "next of another sequence", int64(1), got)
+
+	errRollback := errors.New("rollback")
+	err = strg.Tx(ctx, func(tx storage.StorageI) error {
+		got, err := tx.Sequence().Next(ctx, name)
+		must(t, err)
+		assertEqual(t, "next in a transaction", int64(4), got)
+
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected the rollback error, got %v", err)
+	}
+
+	got, err = strg.Sequence().Next(ctx, name)
+	must(t, err)
+	assertEqual(t, "next after a rollback", int64(4), got)
+}
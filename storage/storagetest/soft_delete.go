@@ -0,0 +1,81 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/storage"
+)
+
+func testSoftDelete(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		search  = token()
+		product = createProduct(t, strg, "deleted "+search, money.Units(5000))
+		order   = newOrder(t, strg)
+		pk      = &models.ProductPrimaryKey{Id: product.Id}
+	)
+
+	addStock(t, strg, order.BranchId, product.Id, 1)
+
+	_, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   order.Id,
+		ProductID: product.Id,
+		Quantity:  1,
+	})
+	must(t, err)
+
+	must(t, strg.Product().Delete(ctx, pk))
+
+	_, err = strg.Product().GetByID(ctx, pk)
+	assertNoRows(t, err)
+
+	rowsAffected, err := strg.Product().Update(ctx, &models.UpdateProduct{Id: product.Id, CategoryId: product.CategoryId})
+	assertAffected(t, 0, rowsAffected, err)
+
+	resp, err := strg.Product().GetList(ctx, &models.GetListProductRequest{Search: search})
+	must(t, err)
+	assertEqual(t, "count without deleted", 0, resp.Count)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{Search: search, IncludeDeleted: true})
+	must(t, err)
+	assertEqual(t, "count with deleted", 1, resp.Count)
+	if resp.Products[0].DeletedAt == "" {
+		t.Fatal("a deleted product is listed without deleted_at")
+	}
+
+	rowsAffected, err = strg.Product().Restore(ctx, pk)
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err := strg.Product().GetByID(ctx, pk)
+	must(t, err)
+	assertEqual(t, "deleted_at after restore", "", got.DeletedAt)
+
+	rowsAffected, err = strg.Product().Restore(ctx, pk)
+	assertAffected(t, 0, rowsAffected, err)
+
+	// Purge keeps a deleted product that an order line still refers to and
+	// removes a deleted category nothing refers to.
+	must(t, strg.Product().Delete(ctx, pk))
+
+	category := createCategory(t, strg, "deleted "+search)
+	must(t, strg.Category().Delete(ctx, &models.CategoryPrimaryKey{Id: category.Id}))
+
+	before := time.Now().Add(time.Hour)
+	_, err = strg.Product().Purge(ctx, before)
+	must(t, err)
+	_, err = strg.Category().Purge(ctx, before)
+	must(t, err)
+
+	resp, err = strg.Product().GetList(ctx, &models.GetListProductRequest{Search: search, IncludeDeleted: true})
+	must(t, err)
+	assertEqual(t, "referenced product after purge", 1, resp.Count)
+
+	categories, err := strg.Category().GetList(ctx, &models.GetListCategoryRequest{Search: search, IncludeDeleted: true})
+	must(t, err)
+	assertEqual(t, "unreferenced category after purge", 0, categories.Count)
+}
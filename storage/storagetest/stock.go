@@ -0,0 +1,338 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/ledger"
+	"market_system/pkg/money"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+)
+
+func testStock(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client  = createClient(t, strg, "client "+token())
+		branch  = createBranch(t, strg, "branch "+token())
+		product = createProduct(t, strg, "product "+token(), money.Units(1000))
+		pk      = &models.StockPrimaryKey{BranchId: branch.ID, ProductId: product.Id}
+	)
+
+	_, err := strg.Stock().GetByID(ctx, pk)
+	assertNoRows(t, err)
+
+	addStock(t, strg, branch.ID, product.Id, 10)
+
+	assertStock := func(field string, quantity, reserved float64) {
+		t.Helper()
+
+		stock, err := strg.Stock().GetByID(ctx, pk)
+		must(t, err)
+		assertEqual(t, field+" quantity", quantity, stock.Quantity)
+		assertEqual(t, field+" reserved", reserved, stock.Reserved)
+		assertEqual(t, field+" available", quantity-reserved, stock.Available)
+	}
+
+	canceled := createOrder(t, strg, client, branch)
+	line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   canceled.Id,
+		ProductID: product.Id,
+		Quantity:  4,
+	})
+	must(t, err)
+	assertEqual(t, "line reserved", 4.0, line.Reserved)
+	assertStock("after a line", 10, 4)
+
+	if _, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   canceled.Id,
+		ProductID: product.Id,
+		Quantity:  7,
+	}); !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock for more than is available, got %v", err)
+	}
+
+	rowsAffected, err := strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{
+		OrderProductID: line.OrderProductID,
+		ProductID:      product.Id,
+		Quantity:       6,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+	assertStock("after a bigger line", 10, 6)
+
+	if _, err := strg.Stock().Adjust(ctx, &models.AdjustStock{
+		BranchId:  branch.ID,
+		ProductId: product.Id,
+		Type:      ledger.WriteOff,
+		Delta:     -5,
+	}); !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock below the reserved quantity, got %v", err)
+	}
+
+	setStatus(t, strg, canceled, workflow.StatusCanceled)
+	assertStock("after cancel", 10, 0)
+
+	if _, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   canceled.Id,
+		ProductID: product.Id,
+		Quantity:  1,
+	}); !errors.Is(err, workflow.ErrTransition) {
+		t.Fatalf("expected ErrTransition for a line of a canceled order, got %v", err)
+	}
+
+	finished := createOrder(t, strg, client, branch)
+	deleted, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   finished.Id,
+		ProductID: product.Id,
+		Quantity:  2,
+	})
+	must(t, err)
+
+	_, err = strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   finished.Id,
+		ProductID: product.Id,
+		Quantity:  3,
+	})
+	must(t, err)
+	assertStock("after two lines", 10, 5)
+
+	must(t, strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: deleted.OrderProductID}))
+	assertStock("after a deleted line", 10, 3)
+
+	setStatus(t, strg, finished, workflow.StatusFinished)
+	assertStock("after finish", 7, 0)
+
+	resp, err := strg.Stock().GetList(ctx, &models.GetListStockRequest{BranchId: branch.ID})
+	must(t, err)
+	assertEqual(t, "count", 1, resp.Count)
+	assertEqual(t, "listed product", product.Id, resp.Stocks[0].ProductId)
+}
+
+func testDeletedOrderStock(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client  = createClient(t, strg, "client "+token())
+		branch  = createBranch(t, strg, "branch "+token())
+		product = createProduct(t, strg, "product "+token(), money.Units(1000))
+		order   = createOrder(t, strg, client, branch)
+		pk      = &models.StockPrimaryKey{BranchId: branch.ID, ProductId: product.Id}
+	)
+
+	addStock(t, strg, branch.ID, product.Id, 10)
+
+	assertReserved := func(field string, reserved float64) {
+		t.Helper()
+
+		stock, err := strg.Stock().GetByID(ctx, pk)
+		must(t, err)
+		assertEqual(t, field+" reserved", reserved, stock.Reserved)
+	}
+
+	line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{OrderID: order.Id, ProductID: product.Id, Quantity: 4})
+	must(t, err)
+	assertReserved("after a line", 4)
+
+	// A deleted order holds no stock and takes no lines.
+	must(t, strg.Order().Delete(ctx, &models.OrderPrimaryKey{Id: order.Id}))
+	assertReserved("after the delete", 0)
+
+	if _, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{OrderID: order.Id, ProductID: product.Id, Quantity: 3}); err == nil {
+		t.Fatal("expected an error for a line of a deleted order")
+	}
+	assertReserved("after a line of the deleted order", 0)
+
+	// Restoring the order reserves its lines again, once there is enough.
+	_, err = strg.Stock().Adjust(ctx, &models.AdjustStock{BranchId: branch.ID, ProductId: product.Id, Type: ledger.WriteOff, Delta: -7})
+	must(t, err)
+
+	if _, err := strg.Order().Restore(ctx, &models.OrderPrimaryKey{Id: order.Id}); !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock for a restore beyond the stock, got %v", err)
+	}
+
+	_, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	assertNoRows(t, err)
+
+	addStock(t, strg, branch.ID, product.Id, 7)
+
+	rowsAffected, err := strg.Order().Restore(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	assertAffected(t, 1, rowsAffected, err)
+	assertReserved("after the restore", 4)
+
+	restored, err := strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: line.OrderProductID})
+	must(t, err)
+	assertEqual(t, "restored line reserved", 4.0, restored.Reserved)
+
+	setStatus(t, strg, order, workflow.StatusFinished)
+
+	stock, err := strg.Stock().GetByID(ctx, pk)
+	must(t, err)
+	assertEqual(t, "quantity after the finish", 6.0, stock.Quantity)
+	assertEqual(t, "reserved after the finish", 0.0, stock.Reserved)
+}
+
+func testClosedOrderLines(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	for _, status := range []string{workflow.StatusFinished, workflow.StatusCanceled} {
+		t.Run(status, func(t *testing.T) {
+			var (
+				client  = createClient(t, strg, "client "+token())
+				branch  = createBranch(t, strg, "branch "+token())
+				product = createProduct(t, strg, "product "+token(), money.Units(1000))
+				order   = createOrder(t, strg, client, branch)
+				pk      = &models.StockPrimaryKey{BranchId: branch.ID, ProductId: product.Id}
+			)
+
+			addStock(t, strg, branch.ID, product.Id, 10)
+
+			line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{OrderID: order.Id, ProductID: product.Id, Quantity: 2})
+			must(t, err)
+
+			closed := setStatus(t, strg, order, status)
+
+			before, err := strg.Stock().GetByID(ctx, pk)
+			must(t, err)
+
+			// The lines of a closed order settled its stock and totals.
+			if err := strg.OrderProduct().Delete(ctx, &models.OrderProductPrimaryKey{OrderProductID: line.OrderProductID}); !errors.Is(err, workflow.ErrTransition) {
+				t.Fatalf("expected workflow.ErrTransition for a line of a %s order, got %v", status, err)
+			}
+
+			_, err = strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: line.OrderProductID})
+			must(t, err)
+
+			after, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+			must(t, err)
+			assertEqual(t, "total", closed.TotalPrice, after.TotalPrice)
+			assertEqual(t, "total count", closed.TotalCount, after.TotalCount)
+			assertEqual(t, "version", closed.Version, after.Version)
+
+			stock, err := strg.Stock().GetByID(ctx, pk)
+			must(t, err)
+			assertEqual(t, "quantity", before.Quantity, stock.Quantity)
+			assertEqual(t, "reserved", before.Reserved, stock.Reserved)
+		})
+	}
+}
+
+func testStockLedger(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client  = createClient(t, strg, "client "+token())
+		from    = createBranch(t, strg, "branch "+token())
+		to      = createBranch(t, strg, "branch "+token())
+		product = createProduct(t, strg, "product "+token(), money.Units(1000))
+	)
+
+	addStock(t, strg, from.ID, product.Id, 10)
+
+	if _, err := strg.Stock().Adjust(ctx, &models.AdjustStock{
+		BranchId:  from.ID,
+		ProductId: product.Id,
+		Type:      ledger.Sale,
+		Delta:     -1,
+	}); !errors.Is(err, ledger.ErrInvalid) {
+		t.Fatalf("expected ledger.ErrInvalid for a sale entered by hand, got %v", err)
+	}
+
+	_, err := strg.Stock().Adjust(ctx, &models.AdjustStock{
+		BranchId:  from.ID,
+		ProductId: product.Id,
+		Type:      ledger.WriteOff,
+		Delta:     -2,
+		Reference: "damaged in storage",
+	})
+	must(t, err)
+
+	if _, err := strg.Stock().Transfer(ctx, &models.CreateStockTransfer{
+		FromBranchId: from.ID,
+		ToBranchId:   to.ID,
+		Items:        []models.StockTransferItem{{ProductId: product.Id, Quantity: 5}, {ProductId: product.Id, Quantity: 4}},
+	}); !errors.Is(err, storage.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock for a transfer of more than is available, got %v", err)
+	}
+
+	transfer, err := strg.Stock().Transfer(ctx, &models.CreateStockTransfer{
+		FromBranchId: from.ID,
+		ToBranchId:   to.ID,
+		Reference:    "waybill 7",
+		Items:        []models.StockTransferItem{{ProductId: product.Id, Quantity: 3}},
+	})
+	must(t, err)
+
+	got, err := strg.Stock().GetTransfer(ctx, &models.StockTransferPrimaryKey{Id: transfer.Id})
+	must(t, err)
+	assertEqual(t, "transfer reference", "waybill 7", got.Reference)
+	assertEqual(t, "transfer created_at", transfer.CreatedAt, got.CreatedAt)
+	assertEqual(t, "transfer items", 1, len(got.Items))
+	assertEqual(t, "transfer item", models.StockTransferItem{ProductId: product.Id, Quantity: 3}, got.Items[0])
+
+	sides, err := strg.Stock().Movements(ctx, &models.GetListStockMovementRequest{Reference: transfer.Id})
+	must(t, err)
+	assertEqual(t, "transfer sides", 2, sides.Count)
+	assertEqual(t, "transfer out", ledger.TransferOut, sides.Movements[0].Type)
+	assertEqual(t, "transfer out quantity", -3.0, sides.Movements[0].Quantity)
+	assertEqual(t, "transfer in", ledger.TransferIn, sides.Movements[1].Type)
+	assertEqual(t, "transfer in branch", to.ID, sides.Movements[1].BranchId)
+
+	// The stock of the source branch is the sum of its movements, as a
+	// whole and up to any point in time.
+	movements, err := strg.Stock().Movements(ctx, &models.GetListStockMovementRequest{BranchId: from.ID})
+	must(t, err)
+	assertEqual(t, "source movements", 3, movements.Count)
+
+	writeOff, err := time.Parse(time.RFC3339Nano, movements.Movements[1].CreatedAt)
+	must(t, err)
+
+	stock, err := strg.Stock().GetByID(ctx, &models.StockPrimaryKey{BranchId: from.ID, ProductId: product.Id})
+	must(t, err)
+	assertEqual(t, "source quantity", 5.0, stock.Quantity)
+
+	reconciliation, err := strg.Stock().Reconcile(ctx, &models.StockReconciliationRequest{BranchId: from.ID, From: writeOff})
+	must(t, err)
+	assertEqual(t, "reconciled products", 1, len(reconciliation.Products))
+
+	line := reconciliation.Products[0]
+	assertEqual(t, "opening", 10.0, line.Opening)
+	assertEqual(t, "movement types", 2, len(line.Movements))
+	assertEqual(t, "write-offs", -2.0, line.Movements[ledger.WriteOff])
+	assertEqual(t, "transfers out", -3.0, line.Movements[ledger.TransferOut])
+	assertEqual(t, "closing is the stock", stock.Quantity, line.Closing)
+
+	reconciliation, err = strg.Stock().Reconcile(ctx, &models.StockReconciliationRequest{BranchId: from.ID, To: writeOff})
+	must(t, err)
+	assertEqual(t, "balance before the write-off", 10.0, reconciliation.Products[0].Closing)
+
+	// A finished order sells its lines and a returned one brings them back.
+	order := createOrder(t, strg, client, to)
+	_, err = strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+		OrderID:   order.Id,
+		ProductID: product.Id,
+		Quantity:  2,
+	})
+	must(t, err)
+
+	setStatus(t, strg, order, workflow.StatusFinished)
+
+	_, err = strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: workflow.StatusReturned, Reason: "wrong size", Actor: "cashier"})
+	must(t, err)
+
+	movements, err = strg.Stock().Movements(ctx, &models.GetListStockMovementRequest{Reference: order.Id})
+	must(t, err)
+	assertEqual(t, "order movements", 2, movements.Count)
+	assertEqual(t, "sale", ledger.Sale, movements.Movements[0].Type)
+	assertEqual(t, "sale quantity", -2.0, movements.Movements[0].Quantity)
+	assertEqual(t, "return", ledger.Return, movements.Movements[1].Type)
+	assertEqual(t, "return quantity", 2.0, movements.Movements[1].Quantity)
+	assertEqual(t, "return actor", "cashier", movements.Movements[1].Actor)
+
+	stock, err = strg.Stock().GetByID(ctx, &models.StockPrimaryKey{BranchId: to.ID, ProductId: product.Id})
+	must(t, err)
+	assertEqual(t, "destination quantity after the return", 3.0, stock.Quantity)
+}
@@ -0,0 +1,236 @@
+// Package storagetest holds the behavioral suite every storage.StorageI
+// backend must pass. Backends call Run from their own tests.
+//
+// The suite only creates rows and scopes list assertions with a random
+// search token, so it can run against a shared, non-empty database. The one
+// exception is Purge, which removes every soft deleted row it may remove.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/ledger"
+	"market_system/pkg/money"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+// Run runs the whole suite. newStorage is called once per subtest.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.StorageI) {
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, strg storage.StorageI)
+	}{
+		{"Category", testCategory},
+		{"CategoryList", testCategoryList},
+		{"Product", testProduct},
+		{"ProductList", testProductList},
+		{"Filter", testFilter},
+		{"Cursor", testCursor},
+		{"SoftDelete", testSoftDelete},
+		{"Version", testVersion},
+		{"Client", testClient},
+		{"ClientList", testClientList},
+		{"Branch", testBranch},
+		{"BranchSchedule", testBranchSchedule},
+		{"Order", testOrder},
+		{"OrderList", testOrderList},
+		{"OrderStatusUpdate", testOrderStatusUpdate},
+		{"OrderHistory", testOrderHistory},
+		{"OrderProduct", testOrderProduct},
+		{"OrderProductList", testOrderProductList},
+		{"OrderTotals", testOrderTotals},
+		{"OrderPricing", testOrderPricing},
+		{"OrderCents", testOrderCents},
+		{"Stock", testStock},
+		{"StockLedger", testStockLedger},
+		{"DeletedOrderStock", testDeletedOrderStock},
+		{"ClosedOrderLines", testClosedOrderLines},
+		{"Promotion", testPromotion},
+		{"OrderPromotion", testOrderPromotion},
+		{"TaxRate", testTaxRate},
+		{"OrderTax", testOrderTax},
+		{"DeliveryTariff", testDeliveryTariff},
+		{"OrderDelivery", testOrderDelivery},
+		{"NearestBranch", testNearestBranch},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"Audit", testAudit},
+		{"Sequence", testSequence},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func token() string {
+	return uuid.New().String()[:8]
+}
+
+// missingID returns a well-formed id that no backend has stored.
+func missingID() string {
+	return uuid.New().String()
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertNoRows(t *testing.T, err error) {
+	t.Helper()
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func assertAffected(t *testing.T, want int64, got int64, err error) {
+	t.Helper()
+
+	must(t, err)
+	if got != want {
+		t.Fatalf("rows affected: want %d, got %d", want, got)
+	}
+}
+
+func assertEqual(t *testing.T, field string, want, got interface{}) {
+	t.Helper()
+
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("%s: want %v, got %v", field, want, got)
+	}
+}
+
+// assertPage checks one page of a list: its size, the total count and that
+// it is ordered newest first.
+func assertPage(t *testing.T, wantLen, gotLen, wantCount, gotCount int, createdAt []string) {
+	t.Helper()
+
+	if gotLen != wantLen {
+		t.Fatalf("page size: want %d, got %d", wantLen, gotLen)
+	}
+
+	if gotLen > 0 && gotCount != wantCount {
+		t.Fatalf("count: want %d, got %d", wantCount, gotCount)
+	}
+
+	for i := 1; i < len(createdAt); i++ {
+		if createdAt[i-1] < createdAt[i] {
+			t.Fatalf("list is not ordered by created_at desc: %v", createdAt)
+		}
+	}
+}
+
+func createCategory(t *testing.T, strg storage.StorageI, title string) *models.Category {
+	t.Helper()
+
+	ctx := context.Background()
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{
+		Title: title,
+		Image: "category.png",
+	})
+	must(t, err)
+
+	return category
+}
+
+func createProduct(t *testing.T, strg storage.StorageI, title string, price money.Amount) *models.Product {
+	t.Helper()
+
+	ctx := context.Background()
+	category := createCategory(t, strg, "category "+token())
+	product, err := strg.Product().Create(ctx, &models.CreateProduct{
+		Title:       title,
+		Description: "description",
+		ProductId:   "P-" + uuid.New().String(),
+		CategoryId:  category.Id,
+		Photo:       "product.png",
+		Price:       price,
+	})
+	must(t, err)
+
+	return product
+}
+
+func createClient(t *testing.T, strg storage.StorageI, firstName string) *models.Client {
+	t.Helper()
+
+	ctx := context.Background()
+	client, err := strg.Client().Create(ctx, &models.CreateClient{
+		FirstName:   firstName,
+		LastName:    "Client",
+		Phone:       "+998901234567",
+		Photo:       "client.png",
+		DateOfBirth: "2000-01-02",
+	})
+	must(t, err)
+
+	return client
+}
+
+func createBranch(t *testing.T, strg storage.StorageI, name string) *models.Branch {
+	t.Helper()
+
+	ctx := context.Background()
+	branch, err := strg.Branch().Create(ctx, &models.CreateBranch{
+		Name:          name,
+		Phone:         "+998711234567",
+		Photo:         "branch.png",
+		WorkStartHour: "00:00",
+		WorkEndHour:   "00:00",
+		Address:       "Tashkent",
+	})
+	must(t, err)
+
+	return branch
+}
+
+func createOrder(t *testing.T, strg storage.StorageI, client *models.Client, branch *models.Branch) *models.Order {
+	t.Helper()
+
+	ctx := context.Background()
+	order, err := strg.Order().Create(ctx, &models.CreateOrder{
+		OrderId:  "O-" + uuid.New().String(),
+		ClientId: client.ID,
+		BranchId: branch.ID,
+		Address:  "Chilonzor 1",
+	})
+	must(t, err)
+
+	return order
+}
+
+// addStock puts quantity of a product on the shelves of a branch, so order
+// lines can reserve it.
+func addStock(t *testing.T, strg storage.StorageI, branchID, productID string, quantity float64) {
+	t.Helper()
+
+	_, err := strg.Stock().Adjust(context.Background(), &models.AdjustStock{
+		BranchId:  branchID,
+		ProductId: productID,
+		Type:      ledger.Receipt,
+		Delta:     quantity,
+	})
+	must(t, err)
+}
+
+func newOrder(t *testing.T, strg storage.StorageI) *models.Order {
+	t.Helper()
+
+	return createOrder(t, strg, createClient(t, strg, "client "+token()), createBranch(t, strg, "branch "+token()))
+}
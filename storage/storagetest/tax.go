@@ -0,0 +1,267 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/pkg/promo"
+	"market_system/pkg/tax"
+	"market_system/pkg/workflow"
+	"market_system/storage"
+
+	"github.com/google/uuid"
+)
+
+func testTaxRate(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	name := "vat " + token()
+
+	if _, err := strg.TaxRate().Create(ctx, &models.CreateTaxRate{Name: name, Percent: money.Units(101)}); !errors.Is(err, tax.ErrInvalid) {
+		t.Fatalf("expected tax.ErrInvalid for a percent above 100, got %v", err)
+	}
+
+	created, err := strg.TaxRate().Create(ctx, &models.CreateTaxRate{Name: name, Percent: money.Units(12), Inclusive: true})
+	must(t, err)
+	assertEqual(t, "percent", money.Units(12), created.Percent)
+	assertEqual(t, "inclusive", true, created.Inclusive)
+	assertEqual(t, "version", int64(1), created.Version)
+
+	rowsAffected, err := strg.TaxRate().Update(ctx, &models.UpdateTaxRate{
+		Id:      created.Id,
+		Name:    name,
+		Percent: 1250,
+		Version: created.Version,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	updated, err := strg.TaxRate().GetByID(ctx, &models.TaxRatePrimaryKey{Id: created.Id})
+	must(t, err)
+	assertEqual(t, "updated percent", money.Amount(1250), updated.Percent)
+	assertEqual(t, "updated inclusive", false, updated.Inclusive)
+
+	if _, err := strg.TaxRate().Update(ctx, &models.UpdateTaxRate{Id: created.Id, Name: name, Percent: -1}); !errors.Is(err, tax.ErrInvalid) {
+		t.Fatalf("expected tax.ErrInvalid for a negative percent, got %v", err)
+	}
+
+	if _, err := strg.TaxRate().Update(ctx, &models.UpdateTaxRate{Id: created.Id, Name: name, Version: created.Version}); !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a stale version, got %v", err)
+	}
+
+	list, err := strg.TaxRate().GetList(ctx, &models.GetListTaxRateRequest{Search: name[4:]})
+	must(t, err)
+	assertEqual(t, "listed", 1, list.Count)
+	assertEqual(t, "listed id", created.Id, list.TaxRates[0].Id)
+
+	must(t, strg.TaxRate().Delete(ctx, &models.TaxRatePrimaryKey{Id: created.Id}))
+
+	_, err = strg.TaxRate().GetByID(ctx, &models.TaxRatePrimaryKey{Id: created.Id})
+	assertNoRows(t, err)
+
+	list, err = strg.TaxRate().GetList(ctx, &models.GetListTaxRateRequest{Search: name[4:], IncludeDeleted: true})
+	must(t, err)
+	assertEqual(t, "listed with deleted", 1, list.Count)
+
+	// A deleted or missing rate cannot be assigned.
+	for _, taxRateID := range []string{created.Id, missingID()} {
+		if _, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "category " + token(), TaxRateId: taxRateID}); err == nil {
+			t.Fatalf("expected an error for category tax_rate_id %s", taxRateID)
+		}
+	}
+}
+
+func testOrderTax(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	standard, err := strg.TaxRate().Create(ctx, &models.CreateTaxRate{Name: "standard " + token(), Percent: money.Units(12), Inclusive: true})
+	must(t, err)
+
+	reduced, err := strg.TaxRate().Create(ctx, &models.CreateTaxRate{Name: "reduced " + token(), Percent: money.Units(5)})
+	must(t, err)
+
+	category, err := strg.Category().Create(ctx, &models.CreateCategory{Title: "category " + token(), TaxRateId: standard.Id})
+	must(t, err)
+	assertEqual(t, "category tax_rate_id", standard.Id, category.TaxRateId)
+
+	newProduct := func(title string, price money.Amount, taxRateID string) *models.Product {
+		t.Helper()
+
+		product, err := strg.Product().Create(ctx, &models.CreateProduct{
+			Title:      title + " " + token(),
+			ProductId:  "P-" + uuid.New().String(),
+			CategoryId: category.Id,
+			Price:      price,
+			TaxRateId:  taxRateID,
+		})
+		must(t, err)
+
+		return product
+	}
+
+	var (
+		branch = createBranch(t, strg, "branch "+token())
+		client = createClient(t, strg, "client "+token())
+		food   = newProduct("food", money.Units(112), "")
+		drink  = newProduct("drink", money.Units(20), reduced.Id)
+		plain  = createProduct(t, strg, "plain "+token(), money.Units(10))
+	)
+
+	assertEqual(t, "product tax_rate_id", reduced.Id, drink.TaxRateId)
+
+	for _, product := range []*models.Product{food, drink, plain} {
+		addStock(t, strg, branch.ID, product.Id, 10)
+	}
+
+	addLine := func(order *models.Order, product *models.Product, quantity float64) *models.OrderProduct {
+		t.Helper()
+
+		line, err := strg.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:   order.Id,
+			ProductID: product.Id,
+			Quantity:  quantity,
+		})
+		must(t, err)
+
+		return line
+	}
+
+	first := createOrder(t, strg, client, branch)
+
+	// The food line is taxed at the rate of its category, the drink line at
+	// the rate of the product and the plain line not at all.
+	foodLine := addLine(first, food, 1)
+	assertEqual(t, "inherited tax_rate_id", standard.Id, foodLine.TaxRateId)
+	assertEqual(t, "inclusive tax", money.Units(12), foodLine.Tax)
+	assertEqual(t, "inclusive sum", money.Units(112), foodLine.Sum)
+
+	drinkLine := addLine(first, drink, 2)
+	assertEqual(t, "own tax_rate_id", reduced.Id, drinkLine.TaxRateId)
+	assertEqual(t, "exclusive tax", money.Units(2), drinkLine.Tax)
+	assertEqual(t, "exclusive sum", money.Units(40), drinkLine.Sum)
+
+	plainLine := addLine(first, plain, 1)
+	assertEqual(t, "no tax_rate_id", "", plainLine.TaxRateId)
+	assertEqual(t, "no tax", money.Amount(0), plainLine.Tax)
+
+	first, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: first.Id})
+	must(t, err)
+	assertEqual(t, "order tax", money.Units(14), first.Tax)
+	assertEqual(t, "exclusive tax is added", money.Units(164)+first.DeliveryPrice, first.TotalPrice)
+	assertEqual(t, "rates", 2, len(first.Taxes))
+	assertEqual(t, "first rate", models.OrderTax{TaxRateId: reduced.Id, Percent: money.Units(5), Base: money.Units(40), Tax: money.Units(2)}, first.Taxes[0])
+	assertEqual(t, "second rate", models.OrderTax{TaxRateId: standard.Id, Percent: money.Units(12), Inclusive: true, Base: money.Units(100), Tax: money.Units(12)}, first.Taxes[1])
+
+	// A line keeps the rate it was added at.
+	_, err = strg.TaxRate().Update(ctx, &models.UpdateTaxRate{Id: standard.Id, Name: standard.Name, Percent: money.Units(20), Inclusive: true})
+	must(t, err)
+
+	rowsAffected, err := strg.OrderProduct().Update(ctx, &models.UpdateOrderProduct{
+		OrderProductID: foodLine.OrderProductID,
+		OrderID:        first.Id,
+		ProductID:      food.Id,
+		Quantity:       2,
+	})
+	assertAffected(t, 1, rowsAffected, err)
+
+	foodLine, err = strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: foodLine.OrderProductID})
+	must(t, err)
+	assertEqual(t, "kept percent", money.Units(12), foodLine.TaxPercent)
+	assertEqual(t, "tax of the new quantity", money.Units(24), foodLine.Tax)
+
+	first, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: first.Id})
+	must(t, err)
+	assertEqual(t, "order tax after update", money.Units(26), first.Tax)
+
+	// New lines are taxed at the new rate: 112 * 20 / 120.
+	second := createOrder(t, strg, client, branch)
+	secondLine := addLine(second, food, 1)
+	assertEqual(t, "new percent", money.Units(20), secondLine.TaxPercent)
+	assertEqual(t, "new tax", money.Amount(1867), secondLine.Tax)
+
+	// Open orders are not sales yet.
+	report, err := strg.TaxRate().Report(ctx, &models.TaxReportRequest{BranchId: branch.ID})
+	must(t, err)
+	assertEqual(t, "report lines of open orders", 0, len(report.Lines))
+
+	first = setStatus(t, strg, first, workflow.StatusFinished)
+	second = setStatus(t, strg, second, workflow.StatusDelivered)
+
+	report, err = strg.TaxRate().Report(ctx, &models.TaxReportRequest{BranchId: branch.ID})
+	must(t, err)
+	assertEqual(t, "report lines", 3, len(report.Lines))
+	assertEqual(t, "reduced line", models.TaxReportLine{BranchId: branch.ID, TaxRateId: reduced.Id, Percent: money.Units(5), Orders: 1, Base: money.Units(40), Tax: money.Units(2)}, *report.Lines[0])
+	assertEqual(t, "standard line", models.TaxReportLine{BranchId: branch.ID, TaxRateId: standard.Id, Percent: money.Units(12), Inclusive: true, Orders: 1, Base: money.Units(200), Tax: money.Units(24)}, *report.Lines[1])
+	assertEqual(t, "new standard line", money.Amount(1867), report.Lines[2].Tax)
+
+	// A returned order nets to nothing, so it is taken off the report again.
+	_, err = strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: second.Id, Status: workflow.StatusReturned, Reason: "damaged"})
+	must(t, err)
+
+	report, err = strg.TaxRate().Report(ctx, &models.TaxReportRequest{BranchId: branch.ID})
+	must(t, err)
+	assertEqual(t, "report lines without the returned order", 2, len(report.Lines))
+	assertEqual(t, "standard line after the return", money.Units(24), report.Lines[1].Tax)
+
+	// Orders outside the period are left out.
+	report, err = strg.TaxRate().Report(ctx, &models.TaxReportRequest{BranchId: branch.ID, To: time.Now().Add(-time.Hour)})
+	must(t, err)
+	assertEqual(t, "report lines before the orders", 0, len(report.Lines))
+
+	// A promotion is taxed off every line it applies to, so the lines still
+	// add up to the order: 10% of 112 and 20 leaves 100.80 taxed at 20%
+	// inclusive and 18 at 5%.
+	code := "TAX" + strings.ToUpper(token())
+	_, err = strg.Promotion().Create(ctx, &models.CreatePromotion{
+		Code:        code,
+		Type:        promo.TypePercent,
+		Value:       money.Units(10),
+		BranchIds:   []string{branch.ID},
+		CategoryIds: []string{category.Id},
+	})
+	must(t, err)
+
+	promoted := createOrder(t, strg, client, branch)
+	promotedLines := []*models.OrderProduct{addLine(promoted, food, 1), addLine(promoted, drink, 1)}
+
+	_, err = strg.Order().Update(ctx, &models.UpdateOrder{
+		Id:            promoted.Id,
+		ClientId:      promoted.ClientId,
+		OrderId:       promoted.OrderId,
+		BranchId:      promoted.BranchId,
+		Address:       promoted.Address,
+		DeliveryPrice: promoted.DeliveryPrice,
+		PromoCode:     &code,
+	})
+	must(t, err)
+
+	promoted, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: promoted.Id})
+	must(t, err)
+	assertEqual(t, "promoted discount", money.Amount(1320), promoted.Discount)
+	assertEqual(t, "promoted tax", money.Amount(1770), promoted.Tax)
+
+	var lineTaxes money.Amount
+	for i, line := range promotedLines {
+		line, err = strg.OrderProduct().GetByID(ctx, &models.OrderProductPrimaryKey{OrderProductID: line.OrderProductID})
+		must(t, err)
+
+		promotedLines[i] = line
+		lineTaxes += line.Tax
+	}
+
+	assertEqual(t, "discounted food tax", money.Amount(1680), promotedLines[0].Tax)
+	assertEqual(t, "discounted drink tax", money.Amount(90), promotedLines[1].Tax)
+	assertEqual(t, "line taxes", promoted.Tax, lineTaxes)
+
+	// A deleted rate no longer applies to new lines.
+	must(t, strg.TaxRate().Delete(ctx, &models.TaxRatePrimaryKey{Id: reduced.Id}))
+
+	third := createOrder(t, strg, client, branch)
+	thirdLine := addLine(third, drink, 1)
+	assertEqual(t, "deleted rate", "", thirdLine.TaxRateId)
+	assertEqual(t, "untaxed", money.Amount(0), thirdLine.Tax)
+}
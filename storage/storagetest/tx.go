@@ -0,0 +1,69 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/storage"
+)
+
+func testTxCommit(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client  = createClient(t, strg, "client "+token())
+		branch  = createBranch(t, strg, "branch "+token())
+		product = createProduct(t, strg, "product "+token(), money.Units(3000))
+		order   *models.Order
+	)
+
+	addStock(t, strg, branch.ID, product.Id, 2)
+
+	err := strg.Tx(ctx, func(tx storage.StorageI) error {
+		order = createOrder(t, tx, client, branch)
+
+		_, err := tx.OrderProduct().Create(ctx, &models.CreateOrderProduct{
+			OrderID:   order.Id,
+			ProductID: product.Id,
+			Quantity:  2,
+		})
+		return err
+	})
+	must(t, err)
+
+	got, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	must(t, err)
+	assertEqual(t, "total_price", money.Units(6000)+order.DeliveryPrice, got.TotalPrice)
+}
+
+func testTxRollback(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	var (
+		client   = createClient(t, strg, "client "+token())
+		branch   = createBranch(t, strg, "branch "+token())
+		order    *models.Order
+		errAbort = errors.New("abort")
+	)
+
+	err := strg.Tx(ctx, func(tx storage.StorageI) error {
+		order = createOrder(t, tx, client, branch)
+
+		// Nested calls join the outer transaction.
+		return tx.Tx(ctx, func(tx storage.StorageI) error {
+			_, err := tx.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: "in-process"})
+			must(t, err)
+
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected the error returned by fn, got %v", err)
+	}
+
+	_, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: order.Id})
+	assertNoRows(t, err)
+}
@@ -0,0 +1,62 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"market_system/models"
+	"market_system/pkg/money"
+	"market_system/storage"
+)
+
+func testVersion(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	product := createProduct(t, strg, "versioned "+token(), money.Units(5000))
+	assertEqual(t, "version after create", int64(1), product.Version)
+
+	update := models.UpdateProduct{
+		Id:         product.Id,
+		Title:      product.Title,
+		CategoryId: product.CategoryId,
+		Price:      money.Units(6000),
+		Version:    product.Version,
+	}
+
+	rowsAffected, err := strg.Product().Update(ctx, &update)
+	assertAffected(t, 1, rowsAffected, err)
+
+	got, err := strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: product.Id})
+	must(t, err)
+	assertEqual(t, "version after update", int64(2), got.Version)
+
+	// The second writer still holds version 1.
+	update.Price = money.Units(7000)
+	_, err = strg.Product().Update(ctx, &update)
+	if !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected storage.ErrVersionConflict, got %v", err)
+	}
+
+	got, err = strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: product.Id})
+	must(t, err)
+	assertEqual(t, "price after conflict", money.Units(6000), got.Price)
+
+	// Version 0 skips the check.
+	update.Version = 0
+	rowsAffected, err = strg.Product().Update(ctx, &update)
+	assertAffected(t, 1, rowsAffected, err)
+
+	rowsAffected, err = strg.Product().Update(ctx, &models.UpdateProduct{Id: missingID(), CategoryId: product.CategoryId, Version: 1})
+	assertAffected(t, 0, rowsAffected, err)
+
+	order := newOrder(t, strg)
+	_, err = strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: "in-process", Version: order.Version + 1})
+	if !errors.Is(err, storage.ErrVersionConflict) {
+		t.Fatalf("expected storage.ErrVersionConflict, got %v", err)
+	}
+
+	updated, err := strg.Order().StatusUpdate(ctx, models.CheckStatus{Id: order.Id, Status: "in-process", Version: order.Version})
+	must(t, err)
+	assertEqual(t, "order version after status update", order.Version+1, updated.Version)
+}